@@ -31,6 +31,6 @@ If you don't like (or have outgrown) its opinions, it should be straightforward
 to use the APIs of goji's subpackages to reimplement things to your liking. Both
 methods of using this library are equally well supported.
 
-Goji requires Go 1.2 or newer.
+Goji requires Go 1.21 or newer.
 */
 package goji
@@ -0,0 +1,63 @@
+package web
+
+import (
+	"net/http"
+)
+
+// The key used to record that the request has been aborted. See C.Abort.
+const AbortedKey = "goji.web.aborted"
+
+/*
+Abort marks the request as finished, so that no more of the middleware stack
+is run for it: once a request is aborted, the Mux does not call into any
+further layer of its middleware stack, does not route the request, and does not
+call the route's handler (or any of the route's own middleware, see Route.Use).
+Middleware which has to stop the request after having called into the rest of
+the stack, or which calls into it conditionally, can use Abort to make sure
+nothing further runs:
+
+	func auth(c *web.C, h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !authorized(r) {
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				c.Abort()
+			}
+			h.ServeHTTP(w, r)
+		})
+	}
+
+Abort does not write a response of its own, so the caller should have written
+(or at least begun) one; whatever has been written is left as it is. Nor does it
+unwind the stack the way a panic does: layers which have already called into
+the stack carry on once the call returns, and can check Aborted to find out what
+happened. In particular, panic recoverers are not involved, although
+middleware.Recoverer and middleware.RecoverWith abort the requests whose panics
+they recover from, since the rest of the stack can no longer be run for them.
+
+The mark is kept in Env, so an abort is seen by every layer sharing the
+request's Env, including handlers and nested Muxes.
+*/
+func (c *C) Abort() {
+	c.initEnv()
+	c.Env[AbortedKey] = true
+}
+
+// Aborted reports whether the request has been aborted. See Abort.
+func (c C) Aborted() bool {
+	aborted, _ := c.env()[AbortedKey].(bool)
+	return aborted
+}
+
+// abortGuard sits between two layers of a middleware stack, and only calls the
+// inner one if the request has not been aborted.
+type abortGuard struct {
+	c *C
+	h http.Handler
+}
+
+func (g abortGuard) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if g.c.Aborted() {
+		return
+	}
+	g.h.ServeHTTP(w, r)
+}
@@ -0,0 +1,76 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAbort(t *testing.T) {
+	t.Parallel()
+	var trace []string
+	layer := func(name string, abort bool) func(*C, http.Handler) http.Handler {
+		return func(c *C, h http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				trace = append(trace, name)
+				if abort && r.Header.Get("Abort") == name {
+					w.WriteHeader(http.StatusUnauthorized)
+					c.Abort()
+				}
+				// Carry on regardless, as a careless middleware might
+				h.ServeHTTP(w, r)
+				if c.Aborted() {
+					trace = append(trace, name+" saw abort")
+				}
+			})
+		}
+	}
+	m := New()
+	m.Use(layer("outer", false))
+	m.Use(layer("auth", true))
+	m.Use(layer("inner", false))
+	m.Use(func(c *C, h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Abort") == "routed" {
+				OnRouted(c, func(c C) { c.Abort() })
+			}
+			h.ServeHTTP(w, r)
+		})
+	})
+	m.Get("/", func(c C, w http.ResponseWriter, r *http.Request) {
+		trace = append(trace, "handler")
+		if r.Header.Get("Abort") == "handler" {
+			c.Abort()
+		}
+	}).Use(layer("route", true))
+
+	tests := []struct {
+		abort, trace string
+		code         int
+	}{
+		{"", "outer auth inner route handler", 200},
+		{"auth", "outer auth auth saw abort outer saw abort", 401},
+		{"route", "outer auth inner route route saw abort inner saw abort " +
+			"auth saw abort outer saw abort", 401},
+		{"routed", "outer auth inner inner saw abort auth saw abort " +
+			"outer saw abort", 200},
+		{"handler", "outer auth inner route handler route saw abort " +
+			"inner saw abort auth saw abort outer saw abort", 200},
+	}
+	for _, test := range tests {
+		trace = nil
+		r, _ := http.NewRequest("GET", "/", nil)
+		r.Header.Set("Abort", test.abort)
+		w := httptest.NewRecorder()
+		m.ServeHTTP(w, r)
+		if got := strings.Join(trace, " "); got != test.trace {
+			t.Errorf("For abort at %q, expected %q, got %q", test.abort,
+				test.trace, got)
+		}
+		if w.Code != test.code {
+			t.Errorf("For abort at %q, expected %d, got %d", test.abort,
+				test.code, w.Code)
+		}
+	}
+}
@@ -142,6 +142,22 @@ func BenchmarkStatic(b *testing.B) {
 	})
 }
 
+func BenchmarkParam(b *testing.B) {
+	m := New()
+	m.ReuseURLParams(true)
+	m.Get("/users/:id", nilRouter{})
+	r, _ := http.NewRequest("GET", "/users/carl", nil)
+	m.Compile()
+
+	b.ResetTimer()
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			m.ServeHTTP(w, r)
+		}
+	})
+}
+
 func BenchmarkRoute5(b *testing.B) {
 	benchN(b, 1)
 }
@@ -164,3 +180,48 @@ func BenchmarkMiddleware10(b *testing.B) {
 func BenchmarkMiddleware100(b *testing.B) {
 	benchM(b, 100)
 }
+
+// benchMatch measures only the cost of finding the route for each request, by
+// way of either the compiled state machine or, if naive is set, by trying every
+// route in turn as a router without a state machine would.
+func benchMatch(b *testing.B, n int, naive bool) {
+	m := New()
+	prefixes := genPrefixes(n)
+	for _, prefix := range prefixes {
+		addRoutes(m, prefix)
+	}
+	rm := m.rt.compile()
+	reqs := permuteRequests(genRequests(prefixes))
+
+	b.ResetTimer()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		r := reqs[i%len(reqs)]
+		var c C
+		if !naive {
+			if _, route := rm.route(&c, w, r); route == nil {
+				b.Fatalf("no route for %s %s", r.Method, r.URL.Path)
+			}
+			continue
+		}
+		meth := httpMethod(r.Method)
+		found := false
+		for _, route := range m.rt.routes {
+			if route.method&meth != 0 && route.pattern.Match(r, &c) {
+				route.pattern.Run(r, &c)
+				found = true
+				break
+			}
+		}
+		if !found {
+			b.Fatalf("no route for %s %s", r.Method, r.URL.Path)
+		}
+	}
+}
+
+func BenchmarkMatch500(b *testing.B) {
+	benchMatch(b, 100, false)
+}
+func BenchmarkMatch500Naive(b *testing.B) {
+	benchMatch(b, 100, true)
+}
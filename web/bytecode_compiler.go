@@ -13,8 +13,8 @@ support. For any given request ("GET /hello/carl"), there is a list of
 prefix that is a prefix of the requested path ("/" and "/hello/", for instance,
 but not "/foobar"). Patterns also have some amount of arbitrary code associated
 with them, which tells us whether or not the route matched. Just like the naive
-router, our goal is to call each plausible pattern, in the order they were
-added, until we find one that matches. The "fast" part here is being smart about
+router, our goal is to call each plausible pattern, in order of precedence (see
+byMatchOrder), until we find one that matches. The "fast" part here is being smart about
 which non-plausible routes we can skip.
 
 First, we sort routes using a pairwise comparison function: sorting occurs as
@@ -5,28 +5,64 @@ import "net/http"
 type routeMachine struct {
 	sm     stateMachine
 	routes []route
+	// Whether the state machine was compiled from lowercased prefixes, and
+	// so must be run on a lowercased path. See Mux.CaseInsensitive.
+	fold bool
 }
 
-func matchRoute(route route, m method, ms *method, r *http.Request, c *C) bool {
+// matchState accumulates information about the routes which were considered
+// while routing a request, for use in the event that none of them match.
+type matchState struct {
+	// The methods that would have been routed had they been provided.
+	methods method
+	// The ways in which content negotiation failed for routes which
+	// otherwise would have matched.
+	negotiation negotiation
+	// The extension methods (see Mux.Method) that would have been routed.
+	verbs []string
+}
+
+func matchRoute(route route, m method, ms *matchState, r *http.Request, c *C) bool {
 	if !route.pattern.Match(r, c) {
 		return false
 	}
-	*ms |= route.method
+	ms.methods |= route.method
+	if route.verb != "" {
+		ms.verbs = append(ms.verbs, route.verb)
+	}
+
+	// Routes with their own CORS policy answer their own preflights
+	preflight := m == mOPTIONS && route.cors != nil && isPreflight(r)
+	if !route.allows(m, r.Method) && !preflight {
+		return false
+	}
+	if neg := route.negotiate(r); neg != 0 && !preflight {
+		ms.negotiation |= neg
+		return false
+	}
+	route.pattern.Run(r, c)
+	return true
+}
 
-	if route.method&m != 0 {
-		route.pattern.Run(r, c)
-		return true
+// allows reports whether the route responds to requests with the given method.
+func (route route) allows(m method, name string) bool {
+	if route.verb != "" {
+		return name == route.verb
 	}
-	return false
+	return route.method&m != 0
 }
 
-func (rm routeMachine) route(c *C, w http.ResponseWriter, r *http.Request) (method, *route) {
+func (rm routeMachine) route(c *C, w http.ResponseWriter, r *http.Request) (matchState, *route) {
 	m := httpMethod(r.Method)
-	var methods method
-	p := r.URL.Path
+	var ms matchState
+	path := r.URL.Path
+	if rm.fold {
+		path = asciiLower(path)
+	}
+	p := path
 
 	if len(rm.sm) == 0 {
-		return methods, nil
+		return ms, nil
 	}
 
 	var i int
@@ -34,7 +70,7 @@ func (rm routeMachine) route(c *C, w http.ResponseWriter, r *http.Request) (meth
 		sm := rm.sm[i].mode
 		if sm&smSetCursor != 0 {
 			si := rm.sm[i].i
-			p = r.URL.Path[si:]
+			p = path[si:]
 			i++
 			continue
 		}
@@ -67,13 +103,13 @@ func (rm routeMachine) route(c *C, w http.ResponseWriter, r *http.Request) (meth
 
 		if match && sm&smRoute != 0 {
 			si := rm.sm[i].i
-			if matchRoute(rm.routes[si], m, &methods, r, c) {
-				return 0, &rm.routes[si]
+			if matchRoute(rm.routes[si], m, &ms, r, c) {
+				return matchState{}, &rm.routes[si]
 			}
 			i++
 		} else if match != (sm&smJumpOnMatch == 0) {
 			if sm&smFail != 0 {
-				return methods, nil
+				return ms, nil
 			}
 			i = int(rm.sm[i].i)
 		} else {
@@ -81,5 +117,5 @@ func (rm routeMachine) route(c *C, w http.ResponseWriter, r *http.Request) (meth
 		}
 	}
 
-	return methods, nil
+	return ms, nil
 }
@@ -0,0 +1,21 @@
+package web
+
+import (
+	"sync/atomic"
+)
+
+// The key used to store the request body byte counter. The value stored under
+// this key is an *int64, which must be updated atomically. See C.BytesRead.
+const BytesReadKey = "goji.web.bytesRead"
+
+// BytesRead returns the number of bytes of the request body the handler has
+// read so far (or in total, once it has returned), as counted by a middleware
+// like middleware.CountBytesRead. It returns -1 if no such middleware is
+// counting bytes for this request.
+func (c C) BytesRead() int64 {
+	n, ok := c.env()[BytesReadKey].(*int64)
+	if !ok {
+		return -1
+	}
+	return atomic.LoadInt64(n)
+}
@@ -0,0 +1,79 @@
+package web
+
+// CaseInsensitive controls whether the literal parts of string patterns match
+// regardless of case, so that "/pricing" also matches requests for "/Pricing" and
+// "/PRICING". Only ASCII letters are folded. Named parameters and wildcards
+// still capture the path as it was requested, and regular expression patterns
+// (which can ask for case-insensitivity themselves, with the "(?i)" flag) and
+// custom Patterns are unaffected. This composes with RedirectTrailingSlash: a
+// request for "/Pricing/" is redirected to "/Pricing" if "/pricing" is routed.
+// Matching is case-sensitive by default.
+//
+// It is illegal to call this function concurrently with active requests.
+func (m *Mux) CaseInsensitive(enabled bool) {
+	m.rt.lock.Lock()
+	defer m.rt.lock.Unlock()
+	m.rt.caseInsensitive = enabled
+	m.rt.setMachine(nil)
+}
+
+// foldRoute returns a copy of r whose prefix, and whose pattern if it is (or
+// wraps) one of the built-in string patterns, ignore case.
+func foldRoute(r *route) *route {
+	folded := *r
+	folded.prefix = asciiLower(r.prefix)
+	folded.pattern = foldPattern(r.pattern)
+	return &folded
+}
+
+func foldPattern(p Pattern) Pattern {
+	switch v := p.(type) {
+	case stringPattern:
+		return v.withFold()
+	case mountPattern:
+		v.exact = v.exact.withFold()
+		v.tree = v.tree.withFold()
+		return v
+	case schedulePattern:
+		v.Pattern = foldPattern(v.Pattern)
+		return v
+	case acceptsPattern:
+		v.Pattern = foldPattern(v.Pattern)
+		return v
+	}
+	return p
+}
+
+func asciiLower(s string) string {
+	for i := 0; i < len(s); i++ {
+		if 'A' <= s[i] && s[i] <= 'Z' {
+			b := []byte(s)
+			for j := i; j < len(b); j++ {
+				if 'A' <= b[j] && b[j] <= 'Z' {
+					b[j] += 'a' - 'A'
+				}
+			}
+			return string(b)
+		}
+	}
+	return s
+}
+
+func equalFoldASCII(a, b string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := 0; i < len(a); i++ {
+		ca, cb := a[i], b[i]
+		if 'A' <= ca && ca <= 'Z' {
+			ca += 'a' - 'A'
+		}
+		if 'A' <= cb && cb <= 'Z' {
+			cb += 'a' - 'A'
+		}
+		if ca != cb {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,104 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestCaseInsensitive(t *testing.T) {
+	t.Parallel()
+	show := func(c C, w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(c.URLParams["name"] + c.URLParams["*"]))
+	}
+	m := New()
+	m.Get("/pricing", show)
+	m.Get("/Users/:name", show)
+	m.Get("/files/*", show)
+	m.Get(regexp.MustCompile(`^/strict$`), show)
+	m.Get(regexp.MustCompile(`(?i)^/loose$`), show)
+
+	tests := []struct {
+		path string
+		code int
+		body string
+	}{
+		{"/PRICING", 404, ""},
+		{"/pricing", 200, ""},
+		{"/users/Carl", 404, ""},
+	}
+	for _, test := range tests {
+		r, _ := http.NewRequest("GET", test.path, nil)
+		w := httptest.NewRecorder()
+		m.ServeHTTP(w, r)
+		if w.Code != test.code || (w.Code == 200 && w.Body.String() != test.body) {
+			t.Errorf("By default, for %q, expected %d %q, got %d %q",
+				test.path, test.code, test.body, w.Code, w.Body.String())
+		}
+	}
+
+	m.CaseInsensitive(true)
+	m.RedirectTrailingSlash(true)
+	tests = []struct {
+		path string
+		code int
+		body string
+	}{
+		{"/Pricing", 200, ""},
+		{"/PRICING", 200, ""},
+		{"/users/Carl", 200, "Carl"},
+		{"/USERS/CARL", 200, "CARL"},
+		{"/Files/A/b", 200, "/A/b"},
+		{"/Strict", 404, ""},
+		{"/strict", 200, ""},
+		{"/LOOSE", 200, ""},
+		{"/Pricing/", 301, ""},
+		{"/elsewhere", 404, ""},
+	}
+	for _, test := range tests {
+		r, _ := http.NewRequest("GET", test.path, nil)
+		w := httptest.NewRecorder()
+		m.ServeHTTP(w, r)
+		if w.Code != test.code || (w.Code == 200 && w.Body.String() != test.body) {
+			t.Errorf("For %q, expected %d %q, got %d %q", test.path,
+				test.code, test.body, w.Code, w.Body.String())
+		}
+		if w.Code == 301 && w.HeaderMap.Get("Location") != "/Pricing" {
+			t.Errorf("For %q, expected a redirect to %q, got %q",
+				test.path, "/Pricing", w.HeaderMap.Get("Location"))
+		}
+	}
+}
+
+func TestCaseInsensitiveWrapped(t *testing.T) {
+	t.Parallel()
+	m := New()
+	m.CaseInsensitive(true)
+	m.Get("/Promo", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("promo"))
+	}).Active(ScheduleFunc(func(time.Time) bool { return true }))
+	m.Get(Accepts("application/json", "/Data"), func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("data"))
+	})
+
+	tests := []struct {
+		path, accept, body string
+	}{
+		{"/promo", "", "promo"},
+		{"/PROMO", "", "promo"},
+		{"/data", "application/json", "data"},
+		{"/DATA", "application/json", "data"},
+	}
+	for _, test := range tests {
+		r, _ := http.NewRequest("GET", test.path, nil)
+		r.Header.Set("Accept", test.accept)
+		w := httptest.NewRecorder()
+		m.ServeHTTP(w, r)
+		if w.Code != 200 || w.Body.String() != test.body {
+			t.Errorf("For %q, expected 200 %q, got %d %q", test.path,
+				test.body, w.Code, w.Body.String())
+		}
+	}
+}
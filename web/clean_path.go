@@ -0,0 +1,57 @@
+package web
+
+import (
+	"net/http"
+	"path"
+	"strings"
+)
+
+/*
+CleanPath controls whether requests for paths which are not in canonical form,
+because they contain "." or ".." segments or repeated slashes, are redirected
+to the canonical path (as computed by path.Clean) before they are routed. A
+request for "/docs/../about" is redirected to "/about", and one for
+"//users///5" to "/users/5", with their query strings intact: with a 301 Moved
+Permanently for GET and HEAD requests, and a 308 Permanent Redirect (which
+preserves the method and body) for every other method. Requests are redirected
+whether or not the canonical path would be routed. It is disabled by default.
+
+A trailing slash is not removed, so that the choice between "/dir/" and "/dir"
+is left to the routes (and to RedirectTrailingSlash, which is applied after the
+path has been cleaned). Paths are cleaned in their escaped form, so escaped
+slashes ("%2F"), which may be part of a named parameter's value, are neither
+treated as separators nor collapsed. Since requests are redirected to the path
+the mux sees, this should be enabled on the outermost mux, rather than on one
+added with Mount.
+
+It is illegal to call this function concurrently with active requests.
+*/
+func (m *Mux) CleanPath(enabled bool) {
+	m.rt.cleanPath = enabled
+}
+
+// cleanPath returns the canonical form of the given path, as computed by
+// path.Clean, except that a trailing slash is kept. Paths which are not
+// absolute (like the "*" of "OPTIONS *") are left alone.
+func cleanPath(p string) string {
+	if p == "" || p[0] != '/' {
+		return p
+	}
+	clean := path.Clean(p)
+	if strings.HasSuffix(p, "/") && clean != "/" {
+		clean += "/"
+	}
+	return clean
+}
+
+// redirectCleaned redirects the request to the canonical form of its path, if
+// its path is not already canonical, and reports whether it did so.
+func (rt *router) redirectCleaned(w http.ResponseWriter, r *http.Request) bool {
+	escaped := r.URL.EscapedPath()
+	clean := cleanPath(escaped)
+	if clean == escaped {
+		return false
+	}
+	permanentRedirect(w, r, clean)
+	return true
+}
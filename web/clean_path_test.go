@@ -0,0 +1,67 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCleanPath(t *testing.T) {
+	t.Parallel()
+	m := New()
+	m.CleanPath(true)
+	m.RedirectTrailingSlash(true)
+	m.Handle("/users/:id", func(c C, w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(c.URLParams["id"]))
+	})
+	m.Get("/docs/", http.NotFound)
+	m.Get("/files/*", func(c C, w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(c.URLParams["*"]))
+	})
+
+	tests := []struct {
+		method, url string
+		code        int
+		location    string
+	}{
+		{"GET", "/users/5", 200, ""},
+		{"GET", "/users//5", 301, "/users/5"},
+		{"GET", "/users/./5?x=1", 301, "/users/5?x=1"},
+		{"HEAD", "/docs/../users/5", 301, "/users/5"},
+		{"POST", "/users/../users/5", 308, "/users/5"},
+		{"GET", "/x/../../users/5", 301, "/users/5"},
+		{"GET", "/docs//", 301, "/docs/"},
+		{"GET", "/docs/./", 301, "/docs/"},
+		{"GET", "/docs/", 404, ""},
+		{"GET", "/docs", 301, "/docs/"},
+		{"GET", "/files/a%2F%2Fb", 200, ""},
+		{"GET", "/files//a%2F..%2Fb", 301, "/files/a%2F..%2Fb"},
+		{"GET", "/nowhere/../else", 301, "/else"},
+	}
+	for _, test := range tests {
+		r, _ := http.NewRequest(test.method, test.url, nil)
+		w := httptest.NewRecorder()
+		m.ServeHTTP(w, r)
+		if w.Code != test.code || w.Header().Get("Location") != test.location {
+			t.Errorf("For %s %q, expected %d %q, got %d %q", test.method,
+				test.url, test.code, test.location, w.Code,
+				w.Header().Get("Location"))
+		}
+	}
+
+	r, _ := http.NewRequest("GET", "/files/a%2F%2Fb", nil)
+	w := httptest.NewRecorder()
+	m.ServeHTTP(w, r)
+	if w.Body.String() != "/a//b" {
+		t.Errorf("Expected escaped slashes to be left alone, got %q",
+			w.Body.String())
+	}
+
+	m.CleanPath(false)
+	r, _ = http.NewRequest("GET", "/users//5", nil)
+	w = httptest.NewRecorder()
+	m.ServeHTTP(w, r)
+	if w.Code == http.StatusMovedPermanently {
+		t.Errorf("Expected no redirect once disabled")
+	}
+}
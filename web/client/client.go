@@ -0,0 +1,175 @@
+// Package client contains helpers for handlers which make requests to other
+// services.
+package client
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// RetryOptions configures Retry.
+type RetryOptions struct {
+	// MaxRetries is the maximum number of times a request is retried.
+	// Defaults to 3.
+	MaxRetries int
+	// BaseDelay is the delay before the first retry. Each subsequent
+	// retry waits twice as long as the one before it. Defaults to 100ms.
+	BaseDelay time.Duration
+	// MaxDelay caps the delay between two attempts, including delays
+	// requested by the server with Retry-After. Defaults to 10s.
+	MaxDelay time.Duration
+}
+
+/*
+Retry returns an http.RoundTripper which retries requests made through the given
+one (or http.DefaultTransport, if nil) when they fail with a transient error: a
+refused connection, or a 503 Service Unavailable or 429 Too Many Requests
+response. Retries back off exponentially, unless the server asks for a specific
+delay with a Retry-After header, in which case it is honored.
+
+Only requests which are safe to repeat are retried: requests with idempotent
+methods (GET, HEAD, OPTIONS, TRACE, PUT, and DELETE), as well as requests
+carrying an Idempotency-Key header, which promises that the server will
+recognize repeated attempts. Requests with bodies are only retried if their
+bodies can be replayed (see http.Request.GetBody).
+
+The request's context bounds the whole operation: if it is canceled, or if its
+deadline would pass before the next attempt, the most recent response (or error)
+is returned without further retries.
+
+Typical use:
+
+	hc := &http.Client{
+		Transport: client.Retry(nil, client.RetryOptions{}),
+	}
+	req, _ := http.NewRequest("GET", "http://inventory/widgets", nil)
+	resp, err := hc.Do(req.WithContext(r.Context()))
+*/
+func Retry(base http.RoundTripper, opts RetryOptions) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if opts.MaxRetries <= 0 {
+		opts.MaxRetries = 3
+	}
+	if opts.BaseDelay <= 0 {
+		opts.BaseDelay = 100 * time.Millisecond
+	}
+	if opts.MaxDelay <= 0 {
+		opts.MaxDelay = 10 * time.Second
+	}
+	return &retryTransport{base: base, opts: opts}
+}
+
+type retryTransport struct {
+	base http.RoundTripper
+	opts RetryOptions
+}
+
+var idempotentMethods = map[string]bool{
+	"GET":     true,
+	"HEAD":    true,
+	"OPTIONS": true,
+	"TRACE":   true,
+	"PUT":     true,
+	"DELETE":  true,
+}
+
+func retryable(r *http.Request) bool {
+	if !idempotentMethods[r.Method] && r.Header.Get("Idempotency-Key") == "" {
+		return false
+	}
+	return r.Body == nil || r.Body == http.NoBody || r.GetBody != nil
+}
+
+func (t *retryTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	if !retryable(r) {
+		return t.base.RoundTrip(r)
+	}
+
+	ctx := r.Context()
+	delay := t.opts.BaseDelay
+	for attempt := 0; ; attempt++ {
+		resp, err := t.base.RoundTrip(r)
+		if attempt == t.opts.MaxRetries || !transient(resp, err) {
+			return resp, err
+		}
+
+		wait := delay
+		if ra, ok := retryAfter(resp); ok {
+			wait = ra
+		}
+		if wait > t.opts.MaxDelay {
+			wait = t.opts.MaxDelay
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Now().Add(wait).After(deadline) {
+			return resp, err
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return resp, err
+		case <-timer.C:
+		}
+
+		if resp != nil {
+			io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		if r.GetBody != nil {
+			body, err := r.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			r = cloneRequest(r)
+			r.Body = body
+		}
+		delay *= 2
+	}
+}
+
+// cloneRequest returns a shallow copy of r, since RoundTrippers may not modify
+// the requests they are given.
+func cloneRequest(r *http.Request) *http.Request {
+	r2 := new(http.Request)
+	*r2 = *r
+	return r2
+}
+
+func transient(resp *http.Response, err error) bool {
+	if err != nil {
+		return errors.Is(err, syscall.ECONNREFUSED)
+	}
+	return resp.StatusCode == http.StatusServiceUnavailable ||
+		resp.StatusCode == http.StatusTooManyRequests
+}
+
+// retryAfter parses the response's Retry-After header, which may either be a
+// number of seconds or a date.
+func retryAfter(resp *http.Response) (time.Duration, bool) {
+	if resp == nil {
+		return 0, false
+	}
+	v := resp.Header.Get("Retry-After")
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		d := time.Until(t)
+		if d < 0 {
+			d = 0
+		}
+		return d, true
+	}
+	return 0, false
+}
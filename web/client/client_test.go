@@ -0,0 +1,142 @@
+package client
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// flakyServer fails the first n requests it receives with the given status.
+func flakyServer(n int32, status int, retryAfter string) (*httptest.Server, *int32) {
+	var attempts int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if atomic.AddInt32(&attempts, 1) <= n {
+			if retryAfter != "" {
+				w.Header().Set("Retry-After", retryAfter)
+			}
+			w.WriteHeader(status)
+			return
+		}
+		w.Write(body)
+	}))
+	return s, &attempts
+}
+
+var fastRetries = RetryOptions{BaseDelay: time.Millisecond}
+
+func TestRetry(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		method, key string
+		status      int
+		attempts    int32
+		code        int
+	}{
+		{"GET", "", http.StatusServiceUnavailable, 3, http.StatusOK},
+		{"PUT", "", http.StatusTooManyRequests, 3, http.StatusOK},
+		{"DELETE", "", http.StatusServiceUnavailable, 3, http.StatusOK},
+		{"POST", "", http.StatusServiceUnavailable, 1,
+			http.StatusServiceUnavailable},
+		{"POST", "abc", http.StatusServiceUnavailable, 3, http.StatusOK},
+		{"GET", "", http.StatusInternalServerError, 1,
+			http.StatusInternalServerError},
+	}
+	for _, test := range tests {
+		s, attempts := flakyServer(2, test.status, "0")
+		c := &http.Client{Transport: Retry(nil, fastRetries)}
+		r, _ := http.NewRequest(test.method, s.URL, strings.NewReader("body"))
+		if test.key != "" {
+			r.Header.Set("Idempotency-Key", test.key)
+		}
+		resp, err := c.Do(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		s.Close()
+
+		if *attempts != test.attempts || resp.StatusCode != test.code {
+			t.Errorf("For %s (key %q) failing with %d, expected %d "+
+				"attempts and %d, got %d and %d", test.method,
+				test.key, test.status, test.attempts, test.code,
+				*attempts, resp.StatusCode)
+		}
+		if resp.StatusCode == http.StatusOK && string(body) != "body" {
+			t.Errorf("For %s, body wasn't replayed: got %q",
+				test.method, body)
+		}
+	}
+}
+
+func TestRetryGivesUp(t *testing.T) {
+	t.Parallel()
+	s, attempts := flakyServer(100, http.StatusServiceUnavailable, "")
+	defer s.Close()
+	c := &http.Client{Transport: Retry(nil, RetryOptions{
+		MaxRetries: 2,
+		BaseDelay:  time.Millisecond,
+	})}
+	resp, err := c.Get(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if *attempts != 3 || resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("Expected 3 attempts ending in 503, got %d and %d",
+			*attempts, resp.StatusCode)
+	}
+}
+
+func TestRetryDeadline(t *testing.T) {
+	t.Parallel()
+	s, attempts := flakyServer(100, http.StatusServiceUnavailable, "60")
+	defer s.Close()
+	c := &http.Client{Transport: Retry(nil, RetryOptions{MaxDelay: time.Minute})}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	r, _ := http.NewRequest("GET", s.URL, nil)
+
+	start := time.Now()
+	resp, err := c.Do(r.WithContext(ctx))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if *attempts != 1 || time.Since(start) > 500*time.Millisecond {
+		t.Errorf("Expected to give up rather than wait past the deadline, "+
+			"got %d attempts in %v", *attempts, time.Since(start))
+	}
+}
+
+func TestRetryConnectionRefused(t *testing.T) {
+	t.Parallel()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	var dials int32
+	base := &http.Transport{
+		DialContext: func(ctx context.Context, network, a string) (net.Conn, error) {
+			atomic.AddInt32(&dials, 1)
+			return (&net.Dialer{}).DialContext(ctx, network, a)
+		},
+	}
+	c := &http.Client{Transport: Retry(base, fastRetries)}
+	if _, err := c.Get("http://" + addr); err == nil {
+		t.Fatal("Expected an error")
+	}
+	if dials != 4 {
+		t.Errorf("Expected 4 attempts, got %d", dials)
+	}
+}
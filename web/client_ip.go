@@ -0,0 +1,116 @@
+package web
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+var xForwardedFor = http.CanonicalHeaderKey("X-Forwarded-For")
+var xRealIP = http.CanonicalHeaderKey("X-Real-IP")
+
+/*
+ClientIP returns the IP address of the client which made r, as seen through
+the reverse proxies (load balancers, and so on) whose addresses are among
+trustedProxies.
+
+The X-Forwarded-For and X-Real-IP headers are only believed when the request's
+immediate peer (its RemoteAddr) is a trusted proxy, since anybody else can set
+them to anything they like. In that case, the chain of addresses formed by
+X-Forwarded-For and the peer is walked back from the peer, and the first address
+which is not that of a trusted proxy is returned, since it was recorded by a
+proxy which can be believed. Addresses further to its left were reported by the
+client (or by untrusted proxies), and may be forged. If every address in the
+chain is trusted, the left-most one is returned. If the peer is trusted but
+there is no X-Forwarded-For header, X-Real-IP is used instead.
+
+Ports are stripped, IPv6 addresses are returned without brackets or zones, and
+IPv4 addresses are returned in dotted-quad form even when they were written as
+IPv4-mapped IPv6 addresses. Addresses which cannot be parsed are never trusted,
+and are returned as they were written.
+*/
+func (c C) ClientIP(r *http.Request, trustedProxies []net.IPNet) string {
+	peer := parseAddr(r.RemoteAddr)
+	if !isTrustedProxy(peer, trustedProxies) {
+		return formatAddr(peer, r.RemoteAddr)
+	}
+
+	hops := strings.Split(strings.Join(r.Header[xForwardedFor], ","), ",")
+	if len(r.Header[xForwardedFor]) == 0 {
+		hops = r.Header[xRealIP]
+	}
+	ip, raw := peer, r.RemoteAddr
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if hop == "" {
+			continue
+		}
+		ip, raw = parseAddr(hop), hop
+		if !isTrustedProxy(ip, trustedProxies) {
+			break
+		}
+	}
+	return formatAddr(ip, raw)
+}
+
+/*
+ParseTrustedProxies parses a list of IP addresses and CIDR ranges (such as
+"10.0.0.0/8" or "fd00::/8"), as understood by C.ClientIP. A bare address is
+taken to be a range containing only that address.
+*/
+func ParseTrustedProxies(proxies ...string) ([]net.IPNet, error) {
+	nets := make([]net.IPNet, 0, len(proxies))
+	for _, p := range proxies {
+		if !strings.Contains(p, "/") {
+			if ip := net.ParseIP(p); ip != nil {
+				if ip4 := ip.To4(); ip4 != nil {
+					ip = ip4
+				}
+				bits := 8 * len(ip)
+				nets = append(nets, net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+				continue
+			}
+		}
+		_, n, err := net.ParseCIDR(p)
+		if err != nil {
+			return nil, err
+		}
+		nets = append(nets, *n)
+	}
+	return nets, nil
+}
+
+// parseAddr parses an address as it might appear in RemoteAddr or in a
+// forwarding header: with or without a port, and with or without brackets
+// around an IPv6 address, which may have a zone. It returns nil if the address
+// cannot be parsed.
+func parseAddr(addr string) net.IP {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		addr = host
+	} else if strings.HasPrefix(addr, "[") && strings.HasSuffix(addr, "]") {
+		addr = addr[1 : len(addr)-1]
+	}
+	if i := strings.IndexByte(addr, '%'); i != -1 {
+		addr = addr[:i]
+	}
+	return net.ParseIP(addr)
+}
+
+func formatAddr(ip net.IP, raw string) string {
+	if ip == nil {
+		return raw
+	}
+	return ip.String()
+}
+
+func isTrustedProxy(ip net.IP, trustedProxies []net.IPNet) bool {
+	if ip == nil {
+		return false
+	}
+	for _, n := range trustedProxies {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
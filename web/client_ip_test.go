@@ -0,0 +1,60 @@
+package web
+
+import (
+	"net/http"
+	"testing"
+)
+
+var clientIPTests = []struct {
+	remote, xff, xrip, ip string
+}{
+	{"1.2.3.4:80", "", "", "1.2.3.4"},
+	{"1.2.3.4:80", "5.6.7.8", "", "1.2.3.4"},
+	{"1.2.3.4:80", "", "5.6.7.8", "1.2.3.4"},
+	{"10.0.0.1:80", "5.6.7.8", "", "5.6.7.8"},
+	{"10.0.0.1:80", "", "5.6.7.8", "5.6.7.8"},
+	{"10.0.0.1:80", "5.6.7.8", "9.9.9.9", "5.6.7.8"},
+	{"10.0.0.1:80", "9.9.9.9, 5.6.7.8, 192.168.1.1", "", "5.6.7.8"},
+	{"10.0.0.1:80", "10.1.1.1, 10.2.2.2", "", "10.1.1.1"},
+	{"10.0.0.1:80", "", "", "10.0.0.1"},
+	{"192.168.1.2:80", "5.6.7.8", "", "192.168.1.2"},
+	{"10.0.0.1:80", "5.6.7.8:1234", "", "5.6.7.8"},
+	{"10.0.0.1:80", "unknown", "", "unknown"},
+	{"[::ffff:10.0.0.1]:80", "5.6.7.8", "", "5.6.7.8"},
+	{"[fd00::1%eth0]:80", "[2001:db8::1]:443", "", "2001:db8::1"},
+	{"[fd00::1]:80", "2001:db8::1, fd00::2", "", "2001:db8::1"},
+	{"[2001:db8::1]:80", "5.6.7.8", "", "2001:db8::1"},
+	{"fd00::1", "::ffff:5.6.7.8", "", "5.6.7.8"},
+}
+
+func TestClientIP(t *testing.T) {
+	t.Parallel()
+	trusted, err := ParseTrustedProxies("10.0.0.0/8", "192.168.1.1", "fd00::/8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range clientIPTests {
+		r, _ := http.NewRequest("GET", "/", nil)
+		r.RemoteAddr = test.remote
+		if test.xff != "" {
+			r.Header.Set("X-Forwarded-For", test.xff)
+		}
+		if test.xrip != "" {
+			r.Header.Set("X-Real-IP", test.xrip)
+		}
+		if ip := (C{}).ClientIP(r, trusted); ip != test.ip {
+			t.Errorf("For %q/%q via %q, expected %q, got %q", test.xff,
+				test.xrip, test.remote, test.ip, ip)
+		}
+	}
+}
+
+func TestParseTrustedProxies(t *testing.T) {
+	t.Parallel()
+	if _, err := ParseTrustedProxies("10.0.0.0/33"); err == nil {
+		t.Error("Expected an invalid range to be rejected")
+	}
+	if _, err := ParseTrustedProxies("proxy.local"); err == nil {
+		t.Error("Expected a host name to be rejected")
+	}
+}
@@ -0,0 +1,20 @@
+package web
+
+import (
+	"time"
+)
+
+// A Clock tells the time. Goji's time-dependent features read the time through
+// a Clock so that they can be tested deterministically.
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// SystemClock is a Clock that reports the system time (i.e., time.Now).
+var SystemClock Clock = systemClock{}
@@ -0,0 +1,44 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestClose(t *testing.T) {
+	t.Parallel()
+	m := New()
+	started := make(chan struct{})
+	done := make(chan TerminationReason, 1)
+	m.Use(func(c *C, h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			h.ServeHTTP(w, r)
+			done <- c.TerminationReason()
+		})
+	})
+	m.Get("/", func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-r.Context().Done()
+	})
+
+	r, _ := http.NewRequest("GET", "/", nil)
+	go m.ServeHTTP(httptest.NewRecorder(), r)
+	<-started
+	if err := m.Close(); err != nil {
+		t.Errorf("Close returned %v", err)
+	}
+
+	select {
+	case reason := <-done:
+		if reason != Closed {
+			t.Errorf("Expected reason %v, got %v", Closed, reason)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("handler's context was not canceled by Close")
+	}
+	if r.Context().Err() != nil {
+		t.Error("Close should not cancel the caller's context")
+	}
+}
@@ -0,0 +1,204 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+/*
+Context returns the request's context.Context. A Mux seeds it from the context
+of the incoming request, so it is canceled when the client disconnects (and when
+the Mux is closed), and it carries the request's deadline, if any. A C given to
+ServeHTTPC which already has a context (as from an enclosing Mux) keeps it, but
+the request's deadline and cancellation are applied to it just the same.
+Middleware may replace it with WithContext, for instance to store typed values
+with context.WithValue, and the replacement is seen by every layer inside it,
+including the handler.
+
+For a C that was not given to a handler by a Mux, Context returns
+context.Background().
+*/
+func (c C) Context() context.Context {
+	if c.ctx == nil {
+		return context.Background()
+	}
+	return c.ctx
+}
+
+/*
+WithContext returns a copy of c whose context is changed to ctx, which must be
+non-nil. Since middleware are given a pointer to the C which is passed on to the
+rest of the stack, they will typically replace it in place:
+
+	func tenant(c *web.C, h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			*c = c.WithContext(context.WithValue(c.Context(), tenantKey{}, lookup(r)))
+			h.ServeHTTP(w, r)
+		})
+	}
+
+Note that this changes the context returned by Context, not the context of the
+*http.Request. Env is unaffected, and continues to work alongside the context.
+*/
+func (c C) WithContext(ctx context.Context) C {
+	if ctx == nil {
+		panic("web: nil context")
+	}
+	c.ctx = ctx
+	return c
+}
+
+/*
+Copy returns a copy of c which may be handed to goroutines that outlive the
+request, or that run alongside the rest of it. The copy has its own URLParams
+and Env maps, holding the same entries as c's (the values themselves are not
+copied), so neither the request nor the goroutine sees the other's changes to
+them, and the copy's maps may be read concurrently by any number of goroutines.
+Its context carries the same values as c's, but is not canceled when the
+request ends, and has no deadline; callers which want to bound background work
+should derive a context of their own from it:
+
+	bg := c.Copy()
+	go func() {
+		ctx, cancel := context.WithTimeout(bg.Context(), time.Minute)
+		defer cancel()
+		reindex(ctx, bg.URLParams["id"])
+	}()
+*/
+func (c C) Copy() C {
+	if c.URLParams != nil {
+		params := make(map[string]string, len(c.URLParams))
+		for k, v := range c.URLParams {
+			params[k] = v
+		}
+		c.URLParams = params
+	}
+	if c.env() != nil || c.rs != nil {
+		env := make(map[interface{}]interface{}, len(c.env()))
+		for k, v := range c.env() {
+			env[k] = v
+		}
+		// Errors recorded on either side must not be appended into the
+		// other's slice.
+		if errs, ok := env[ErrorsKey].([]error); ok {
+			env[ErrorsKey] = errs[:len(errs):len(errs)]
+		}
+		c.Env = env
+	}
+	c.ctx = context.WithoutCancel(c.Context())
+	return c
+}
+
+/*
+linkedCtx is a context which carries the values of its parent, but which is also
+done when another context is: the Mux's base context (so that Close cancels it),
+or, for a Mux mounted in another, the context of the request itself. Its
+deadline is the sooner of the two.
+
+Unlike a context.WithCancel and context.AfterFunc pair, linkedCtx only subscribes
+to the other context once somebody asks for its Done channel, so requests which
+never wait on their context don't pay for it. Err is computed directly from the
+two contexts.
+*/
+type linkedCtx struct {
+	context.Context
+	other context.Context
+
+	mu       sync.Mutex
+	done     <-chan struct{}
+	stop     func()
+	released bool
+}
+
+func (l *linkedCtx) Deadline() (time.Time, bool) {
+	d, ok := l.Context.Deadline()
+	if od, ook := l.other.Deadline(); ook && (!ok || od.Before(d)) {
+		return od, true
+	}
+	return d, ok
+}
+
+func (l *linkedCtx) Done() <-chan struct{} {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.done == nil {
+		ctx, cancel := context.WithCancel(l.Context)
+		stop := context.AfterFunc(l.other, cancel)
+		l.done = ctx.Done()
+		l.stop = func() {
+			stop()
+			cancel()
+		}
+		if l.released {
+			l.stop()
+		}
+	}
+	return l.done
+}
+
+func (l *linkedCtx) Err() error {
+	if err := l.Context.Err(); err != nil {
+		return err
+	}
+	if err := l.other.Err(); err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.released {
+		return context.Canceled
+	}
+	return nil
+}
+
+// release cancels l once the request it belongs to is over, and unsubscribes
+// it from the other context.
+func (l *linkedCtx) release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.released = true
+	if l.stop != nil {
+		l.stop()
+	}
+}
+
+// env returns c's Env or, if it has none, the Env allocated for its request by
+// initEnv (which may be nil too). Readers of the Env values the package itself
+// stores use it, so as to see values stored after the reader's C was made.
+func (c C) env() map[interface{}]interface{} {
+	if c.Env == nil && c.rs != nil {
+		return c.rs.env
+	}
+	return c.Env
+}
+
+// initEnv makes sure c has an Env. A Mux does not allocate one for every request,
+// so the first C of a request to need one allocates it for all of them: every C
+// of the request which has none shares it, and sees its contents through env.
+func (c *C) initEnv() {
+	if c.Env != nil {
+		return
+	}
+	if c.rs == nil {
+		c.Env = make(map[interface{}]interface{})
+		return
+	}
+	if c.rs.env == nil {
+		c.rs.env = make(map[interface{}]interface{})
+	}
+	c.Env = c.rs.env
+}
+
+// requestState is what a Mux keeps for each request it serves: the request's
+// contexts, the route it was matched to (see C.Route) and its shared Env (see
+// initEnv), gathered up so that they cost a single allocation. Muxes mounted in
+// another share the outermost Mux's route and Env.
+type requestState struct {
+	req   http.Request
+	ctx   linkedCtx // r.Context(), also done when the Mux is closed
+	cctx  linkedCtx // an enclosing Mux's c.ctx, also done with the request
+	route *RouteInfo
+	env   map[interface{}]interface{}
+}
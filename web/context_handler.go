@@ -0,0 +1,45 @@
+package web
+
+import (
+	"context"
+	"net/http"
+)
+
+/*
+ContextHandlerFunc is a handler which takes the standard context.Context in place
+of a C, for those who would rather not use C directly. It implements both
+http.Handler and Handler, and functions with its signature are accepted wherever
+a HandlerType is:
+
+	m.Get("/users/:id", func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		user, err := users.Find(ctx, web.ParamsFromContext(ctx)["id"])
+		...
+	})
+
+When served by a Mux, the function is passed the context returned by C.Context,
+from which the request's URL parameters can be had with ParamsFromContext.
+*/
+type ContextHandlerFunc func(context.Context, http.ResponseWriter, *http.Request)
+
+// ServeHTTP implements http.Handler, allowing ContextHandlerFunc's to be used
+// with net/http and other compliant routers. When used in this way, the
+// underlying function is passed the request's context.
+func (h ContextHandlerFunc) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h(r.Context(), w, r)
+}
+
+// ServeHTTPC implements Handler.
+func (h ContextHandlerFunc) ServeHTTPC(c C, w http.ResponseWriter, r *http.Request) {
+	h(context.WithValue(c.Context(), cContextKey{}, c), w, r)
+}
+
+// ParamsFromContext returns the URL parameters (see C.URLParams) of the request
+// whose context is given, if it is the context a ContextHandlerFunc is passed,
+// or the context of a request passed on by FromHTTPHandler, or one derived from
+// either. Otherwise, it returns nil. As with C.URLParams, if the Mux reuses
+// URLParams maps (see Mux.ReuseURLParams), the map must be copied by goroutines
+// which outlive the request.
+func ParamsFromContext(ctx context.Context) map[string]string {
+	c, _ := ctx.Value(cContextKey{}).(C)
+	return c.URLParams
+}
@@ -0,0 +1,54 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestContextHandlerFunc(t *testing.T) {
+	t.Parallel()
+	type key struct{}
+	m := New()
+	m.Use(func(c *C, h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			*c = c.WithContext(context.WithValue(c.Context(), key{}, "value"))
+			h.ServeHTTP(w, r)
+		})
+	})
+	var id string
+	var value interface{}
+	m.Get("/users/:id", func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		id = ParamsFromContext(ctx)["id"]
+		value = ctx.Value(key{})
+	})
+
+	r, _ := http.NewRequest("GET", "/users/5", nil)
+	m.ServeHTTP(httptest.NewRecorder(), r)
+	if id != "5" {
+		t.Errorf("Expected id 5, got %q", id)
+	}
+	if value != "value" {
+		t.Errorf("Expected the context set by middleware, got %v", value)
+	}
+
+	if p := ParamsFromContext(context.Background()); p != nil {
+		t.Errorf("Expected no params, got %v", p)
+	}
+}
+
+func TestParamsFromContextHTTPHandler(t *testing.T) {
+	t.Parallel()
+	var id string
+	m := New()
+	m.Get("/users/:id", FromHTTPHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id = ParamsFromContext(r.Context())["id"]
+	})))
+
+	r, _ := http.NewRequest("GET", "/users/7", nil)
+	m.ServeHTTP(httptest.NewRecorder(), r)
+	if id != "7" {
+		t.Errorf("Expected id 7, got %q", id)
+	}
+}
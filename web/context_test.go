@@ -0,0 +1,184 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type contextTestKey struct{}
+
+func TestCContext(t *testing.T) {
+	t.Parallel()
+	if ctx := (C{}).Context(); ctx != context.Background() {
+		t.Errorf("Expected a zero C to have a background context, got %v", ctx)
+	}
+
+	m := New()
+	m.Use(func(c *C, h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := context.WithValue(c.Context(), contextTestKey{}, "hello")
+			*c = c.WithContext(ctx)
+			h.ServeHTTP(w, r)
+		})
+	})
+	m.Get("/", func(c C, w http.ResponseWriter, r *http.Request) {
+		v, _ := c.Context().Value(contextTestKey{}).(string)
+		w.Write([]byte(v))
+	})
+
+	r, _ := http.NewRequest("GET", "/", nil)
+	w := httptest.NewRecorder()
+	m.ServeHTTP(w, r)
+	if w.Body.String() != "hello" {
+		t.Errorf("Expected the handler to see %q, got %q", "hello",
+			w.Body.String())
+	}
+}
+
+func TestCContextCanceled(t *testing.T) {
+	t.Parallel()
+	m := New()
+	started := make(chan struct{})
+	done := make(chan error, 1)
+	m.Get("/", func(c C, w http.ResponseWriter, r *http.Request) {
+		if v := c.Context().Value(contextTestKey{}); v != "request" {
+			t.Errorf("Expected context seeded from the request, got %v", v)
+		}
+		close(started)
+		<-c.Context().Done()
+		done <- c.Context().Err()
+	})
+
+	ctx, cancel := context.WithCancel(context.WithValue(
+		context.Background(), contextTestKey{}, "request"))
+	r, _ := http.NewRequest("GET", "/", nil)
+	go m.ServeHTTP(httptest.NewRecorder(), r.WithContext(ctx))
+	<-started
+	cancel()
+
+	select {
+	case err := <-done:
+		if err != context.Canceled {
+			t.Errorf("Expected %v, got %v", context.Canceled, err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("handler's context was not canceled with the request's")
+	}
+}
+
+func TestCContextDeadline(t *testing.T) {
+	t.Parallel()
+	deadline := time.Now().Add(time.Hour)
+	check := func(where string, ctx context.Context) {
+		if d, ok := ctx.Deadline(); !ok || !d.Equal(deadline) {
+			t.Errorf("Expected %s to see deadline %v, got %v (%v)",
+				where, deadline, d, ok)
+		}
+	}
+
+	m := New()
+	m.Use(func(c *C, h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			check("middleware", c.Context())
+			*c = c.WithContext(context.WithValue(c.Context(),
+				contextTestKey{}, "mw"))
+			h.ServeHTTP(w, r)
+		})
+	})
+	m.Get("/", func(c C, w http.ResponseWriter, r *http.Request) {
+		check("handler", c.Context())
+		check("request", r.Context())
+	})
+
+	ctx, cancel := context.WithDeadline(context.Background(), deadline)
+	defer cancel()
+	r, _ := http.NewRequest("GET", "/", nil)
+	m.ServeHTTP(httptest.NewRecorder(), r.WithContext(ctx))
+
+	// A context given to ServeHTTPC keeps its values, but still inherits
+	// the request's deadline and cancellation.
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	done := make(chan error, 1)
+	m2 := New()
+	m2.Get("/", func(c C, w http.ResponseWriter, r *http.Request) {
+		if v := c.Context().Value(contextTestKey{}); v != "given" {
+			t.Errorf("Expected the given context's values, got %v", v)
+		}
+		<-c.Context().Done()
+		done <- c.Context().Err()
+	})
+	c := C{}.WithContext(context.WithValue(context.Background(),
+		contextTestKey{}, "given"))
+	go m2.ServeHTTPC(c, httptest.NewRecorder(), r.WithContext(ctx))
+	select {
+	case err := <-done:
+		if err != context.DeadlineExceeded {
+			t.Errorf("Expected %v, got %v", context.DeadlineExceeded, err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("handler's context did not inherit the request's deadline")
+	}
+}
+
+func TestCContextNested(t *testing.T) {
+	t.Parallel()
+	inner := New()
+	inner.Get("/sub/", func(c C, w http.ResponseWriter, r *http.Request) {
+		v, _ := c.Context().Value(contextTestKey{}).(string)
+		w.Write([]byte(v))
+	})
+	outer := New()
+	outer.Use(func(c *C, h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			*c = c.WithContext(context.WithValue(c.Context(),
+				contextTestKey{}, "outer"))
+			h.ServeHTTP(w, r)
+		})
+	})
+	outer.Handle("/sub/*", inner)
+
+	r, _ := http.NewRequest("GET", "/sub/", nil)
+	w := httptest.NewRecorder()
+	outer.ServeHTTP(w, r)
+	if w.Body.String() != "outer" {
+		t.Errorf("Expected a nested mux to keep the context, got %q",
+			w.Body.String())
+	}
+}
+
+func TestCCopy(t *testing.T) {
+	t.Parallel()
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(),
+		contextTestKey{}, "value"))
+	c := C{
+		URLParams: map[string]string{"id": "123"},
+		Env:       map[interface{}]interface{}{"user": "carl"},
+	}.WithContext(ctx)
+	c.AddError(errors.New("one"))
+
+	cp := c.Copy()
+	cancel()
+	c.URLParams["id"] = "456"
+	c.Env["user"] = "bob"
+	c.AddError(errors.New("two"))
+	cp.AddError(errors.New("three"))
+
+	if cp.URLParams["id"] != "123" || cp.Env["user"] != "carl" {
+		t.Errorf("Expected the copy to be detached, got %v and %v",
+			cp.URLParams, cp.Env)
+	}
+	if errs := c.Errors(); len(errs) != 2 || errs[1].Error() != "two" {
+		t.Errorf("Expected the original's errors to be unaffected, got %v", errs)
+	}
+	if err := cp.Context().Err(); err != nil {
+		t.Errorf("Expected the copy's context to outlive the request, got %v", err)
+	}
+	if v := cp.Context().Value(contextTestKey{}); v != "value" {
+		t.Errorf("Expected the copy's context to keep its values, got %v", v)
+	}
+}
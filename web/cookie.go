@@ -0,0 +1,179 @@
+package web
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The key used to store the CookieSigner used by C.SetSignedCookie and
+// C.SignedCookie. See CookieSigner.Middleware.
+const CookieSignerKey = "goji.web.cookieSigner"
+
+/*
+CookieSigner signs values with HMAC-SHA256, so that they can be handed to a
+client (typically in a cookie) and later trusted to be unmodified when they are
+returned. Values are not encrypted: the client can read them, but not change
+them.
+
+A signer has one or more keys. Values are always signed with the first key, but
+are accepted if they were signed with any of them, so a key can be rotated
+without invalidating the values signed with its predecessor by putting the new
+key first, and dropping the old one once the values it signed have expired:
+
+	signer := web.NewCookieSigner(newKey, oldKey)
+	m.Use(signer.Middleware)
+
+The zero value is not usable; create signers with NewCookieSigner.
+*/
+type CookieSigner struct {
+	// MaxAge, if positive, is how long signed values remain valid: Verify
+	// rejects values which were signed longer ago than that.
+	MaxAge time.Duration
+	// Clock is used to timestamp signed values and check their age. If it
+	// is nil, SystemClock is used.
+	Clock Clock
+
+	keys [][]byte
+}
+
+// NewCookieSigner returns a signer which signs values with the first of the
+// given keys, and accepts values signed with any of them. It panics if no keys,
+// or an empty key, are given.
+func NewCookieSigner(keys ...[]byte) *CookieSigner {
+	if len(keys) == 0 {
+		panic("web: CookieSigner needs at least one key")
+	}
+	for _, key := range keys {
+		if len(key) == 0 {
+			panic("web: empty CookieSigner key")
+		}
+	}
+	return &CookieSigner{keys: keys}
+}
+
+// Sign returns a signed, timestamped form of value, which is safe to use as a
+// cookie value. The signature also covers name, which is the name of the cookie
+// (or some other description of the value's purpose), so that the value cannot
+// be passed off as one signed for another purpose: it only verifies under the
+// same name.
+func (s *CookieSigner) Sign(name, value string) string {
+	payload := base64.RawURLEncoding.EncodeToString([]byte(value)) + "." +
+		strconv.FormatInt(s.now().Unix(), 10)
+	return payload + "." + s.mac(s.keys[0], name, payload)
+}
+
+// Verify checks the signature of a value produced by Sign with the given name,
+// returning the original value if the signature was made with one of the
+// signer's keys and the value has not expired (see MaxAge).
+func (s *CookieSigner) Verify(name, signed string) (string, bool) {
+	i := strings.LastIndexByte(signed, '.')
+	if i < 0 {
+		return "", false
+	}
+	payload, sig := signed[:i], signed[i+1:]
+	valid := false
+	for _, key := range s.keys {
+		// Check every key, so that the time taken does not reveal
+		// which one matched.
+		if hmac.Equal([]byte(sig), []byte(s.mac(key, name, payload))) {
+			valid = true
+		}
+	}
+	if !valid {
+		return "", false
+	}
+
+	j := strings.IndexByte(payload, '.')
+	if j < 0 {
+		return "", false
+	}
+	ts, err := strconv.ParseInt(payload[j+1:], 10, 64)
+	if err != nil {
+		return "", false
+	}
+	if s.MaxAge > 0 && s.now().Sub(time.Unix(ts, 0)) > s.MaxAge {
+		return "", false
+	}
+	value, err := base64.RawURLEncoding.DecodeString(payload[:j])
+	if err != nil {
+		return "", false
+	}
+	return string(value), true
+}
+
+/*
+Middleware makes this signer the one used by C.SetSignedCookie and
+C.SignedCookie for the requests it handles, by storing it in the context's Env
+under CookieSignerKey. It can be used as either Mux or route middleware.
+*/
+func (s *CookieSigner) Middleware(c *C, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.initEnv()
+		c.Env[CookieSignerKey] = s
+		h.ServeHTTP(w, r)
+	})
+}
+
+func (s *CookieSigner) mac(key []byte, name, payload string) string {
+	h := hmac.New(sha256.New, key)
+	// The payload never contains a NUL, so this is unambiguous whatever
+	// the name.
+	h.Write([]byte(name))
+	h.Write([]byte{0})
+	h.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(h.Sum(nil))
+}
+
+func (s *CookieSigner) now() time.Time {
+	if s.Clock == nil {
+		return SystemClock.Now()
+	}
+	return s.Clock.Now()
+}
+
+var errNoCookieSigner = errors.New("web: no CookieSigner (see CookieSigner.Middleware)")
+
+/*
+SetSignedCookie sets a cookie whose value is signed by the request's
+CookieSigner (see CookieSigner.Middleware), so that it can be read back with
+SignedCookie. The cookie's other attributes (Path, MaxAge, HttpOnly, and so on)
+are copied from opts, which may be nil. An error is returned if there is no
+CookieSigner.
+*/
+func (c C) SetSignedCookie(w http.ResponseWriter, name, value string, opts *http.Cookie) error {
+	s, ok := c.env()[CookieSignerKey].(*CookieSigner)
+	if !ok {
+		return errNoCookieSigner
+	}
+	var cookie http.Cookie
+	if opts != nil {
+		cookie = *opts
+	}
+	cookie.Name = name
+	cookie.Value = s.Sign(name, value)
+	http.SetCookie(w, &cookie)
+	return nil
+}
+
+// SignedCookie returns the value of the named cookie, provided it was set with
+// SetSignedCookie and its signature is valid according to the request's
+// CookieSigner. It returns false if the cookie is missing, if it has been
+// tampered with or has expired, if its value was signed for a cookie of another
+// name, or if there is no CookieSigner.
+func (c C) SignedCookie(r *http.Request, name string) (string, bool) {
+	s, ok := c.env()[CookieSignerKey].(*CookieSigner)
+	if !ok {
+		return "", false
+	}
+	cookie, err := r.Cookie(name)
+	if err != nil {
+		return "", false
+	}
+	return s.Verify(name, cookie.Value)
+}
@@ -0,0 +1,113 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCookieSigner(t *testing.T) {
+	t.Parallel()
+	clock := &fakeClock{time.Unix(1400000000, 0)}
+	old := NewCookieSigner([]byte("old secret"))
+	old.Clock = clock
+	s := NewCookieSigner([]byte("new secret"), []byte("old secret"))
+	s.Clock = clock
+	s.MaxAge = time.Hour
+
+	signed := s.Sign("session", "user=carl.1")
+	if v, ok := s.Verify("session", signed); !ok || v != "user=carl.1" {
+		t.Errorf("Expected %q to verify, got %q %v", signed, v, ok)
+	}
+	if v, ok := s.Verify("session", old.Sign("session", "rotated")); !ok || v != "rotated" {
+		t.Errorf("Expected a value signed with an old key to verify, "+
+			"got %q %v", v, ok)
+	}
+	if _, ok := old.Verify("session", signed); ok {
+		t.Errorf("Expected a value signed with a new key to be rejected " +
+			"by a signer without it")
+	}
+
+	tampered := []string{
+		"",
+		"garbage",
+		strings.Replace(signed, "dXNlcj1jYXJsLjE", "dXNlcj1hZG1pbi4x", 1),
+		signed[:len(signed)-1],
+		signed + "x",
+	}
+	for _, v := range tampered {
+		if _, ok := s.Verify("session", v); ok {
+			t.Errorf("Expected %q to be rejected", v)
+		}
+	}
+
+	if _, ok := s.Verify("csrf", signed); ok {
+		t.Errorf("Expected a value signed for another name to be rejected")
+	}
+
+	clock.t = clock.t.Add(2 * time.Hour)
+	if _, ok := s.Verify("session", signed); ok {
+		t.Errorf("Expected an expired value to be rejected")
+	}
+}
+
+func TestSignedCookie(t *testing.T) {
+	t.Parallel()
+	s := NewCookieSigner([]byte("secret"))
+	m := New()
+	m.Use(s.Middleware)
+	m.Get("/login", func(c C, w http.ResponseWriter, r *http.Request) {
+		err := c.SetSignedCookie(w, "session", "carl", &http.Cookie{
+			Path:     "/",
+			HttpOnly: true,
+		})
+		if err != nil {
+			t.Error(err)
+		}
+	})
+	m.Get("/flash", func(c C, w http.ResponseWriter, r *http.Request) {
+		c.SetSignedCookie(w, "flash", "carl", nil)
+	})
+	m.Get("/whoami", func(c C, w http.ResponseWriter, r *http.Request) {
+		if user, ok := c.SignedCookie(r, "session"); ok {
+			w.Write([]byte(user))
+		}
+	})
+
+	r, _ := http.NewRequest("GET", "/login", nil)
+	w := httptest.NewRecorder()
+	m.ServeHTTP(w, r)
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != "session" ||
+		cookies[0].Path != "/" || !cookies[0].HttpOnly {
+		t.Fatalf("Expected a session cookie, got %v", cookies)
+	}
+
+	r, _ = http.NewRequest("GET", "/flash", nil)
+	w = httptest.NewRecorder()
+	m.ServeHTTP(w, r)
+	flash := w.Result().Cookies()[0]
+
+	for value, expected := range map[string]string{
+		cookies[0].Value:       "carl",
+		cookies[0].Value + "x": "",
+		"carl":                 "",
+		// A value signed for another cookie is not a valid session
+		flash.Value: "",
+	} {
+		r, _ = http.NewRequest("GET", "/whoami", nil)
+		r.AddCookie(&http.Cookie{Name: "session", Value: value})
+		w = httptest.NewRecorder()
+		m.ServeHTTP(w, r)
+		if w.Body.String() != expected {
+			t.Errorf("For cookie %q, expected %q, got %q", value,
+				expected, w.Body.String())
+		}
+	}
+
+	if err := (C{}).SetSignedCookie(httptest.NewRecorder(), "a", "b", nil); err == nil {
+		t.Error("Expected an error without a CookieSigner")
+	}
+}
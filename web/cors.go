@@ -0,0 +1,56 @@
+package web
+
+import (
+	"net/http"
+)
+
+// CORSPolicy is a Cross-Origin Resource Sharing policy which can be applied to
+// a single route. See Route.CORS. The CORS middleware in package middleware
+// satisfies this interface.
+type CORSPolicy interface {
+	// Handler returns a handler which applies the policy to requests
+	// before passing them to h, answering preflight requests itself.
+	Handler(h http.Handler) http.Handler
+}
+
+/*
+CORS applies the given Cross-Origin Resource Sharing policy to this route, so
+that different endpoints can have different origin policies. Preflight requests
+(OPTIONS requests bearing an Access-Control-Request-Method header) for the
+route's path are routed to it, regardless of the methods it otherwise responds
+to, so that its policy can answer them.
+
+A route-level policy supplements whichever CORS middleware is installed on the
+Mux, rather than replacing it: since the middleware runs first, requests from
+origins that it allows are handled by it as usual, and only requests it does not
+allow get as far as the route, whose policy then gets a chance to allow them
+itself. The typical arrangement is therefore a restrictive global policy, and
+more permissive policies on individual routes (say, a public widget endpoint
+which allows any origin). Preflight requests which the route's policy does not
+answer are rejected with a 403 Forbidden.
+*/
+func (r *Route) CORS(policy CORSPolicy) *Route {
+	r.rt.lock.Lock()
+	defer r.rt.lock.Unlock()
+	r.route.cors = policy
+	r.rt.setMachine(nil)
+	return r
+}
+
+func isPreflight(r *http.Request) bool {
+	return r.Method == "OPTIONS" &&
+		r.Header.Get("Access-Control-Request-Method") != ""
+}
+
+// serveCORS serves the request through the route's CORS policy.
+func (rt route) serveCORS(c C, w http.ResponseWriter, r *http.Request) {
+	inner := func(w http.ResponseWriter, r *http.Request) {
+		if isPreflight(r) && rt.method&mOPTIONS == 0 {
+			http.Error(w, http.StatusText(http.StatusForbidden),
+				http.StatusForbidden)
+			return
+		}
+		rt.serveHandler(c, w, r)
+	}
+	rt.cors.Handler(http.HandlerFunc(inner)).ServeHTTP(w, r)
+}
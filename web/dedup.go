@@ -0,0 +1,157 @@
+package web
+
+import (
+	"container/list"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/zenazn/goji/web/mutil"
+)
+
+// The maximum number of keys a route's deduplication window remembers. Should
+// more distinct keys than this arrive within a single window, the oldest are
+// forgotten early.
+const maxDedupKeys = 10000
+
+/*
+Dedup suppresses duplicate requests to this route. Each request is assigned a
+key by the given function (for a webhook receiver, typically the event ID the
+sender put in the body or headers), and if a request with the same key has
+already been handled within the given window, it is answered with an empty 200
+OK without invoking the route's handler. A duplicate which arrives while the
+first request is still being handled is answered with an empty 409 Conflict
+instead, since the first request may yet fail. Requests for which the key
+function returns "" are never deduplicated.
+
+This is meant for receivers of webhooks and similar at-least-once deliveries,
+which frequently retry requests that were in fact processed successfully. To
+allow for the retry of requests which were not, a key is only remembered if its
+request was answered with a 2xx or 3xx status, and was not aborted (see
+C.Abort). Should the handler panic, or the request be rejected (for instance
+with a 429 Too Many Requests by rate limiting middleware, or a 401 Unauthorized
+while a signing key is being rotated), its key is forgotten, and a retry is
+handled as if it were the first delivery.
+
+Dedup is installed as route middleware (see Route.Use), so it runs inside the
+route's middleware added before it, and outside the middleware added after it.
+Time is measured using the Mux's clock (see Mux.SetClock). Keys are
+remembered in memory, and are therefore not shared between processes.
+*/
+func (r *Route) Dedup(key func(C, *http.Request) string, window time.Duration) *Route {
+	d := &dedup{
+		rt:     r.rt,
+		key:    key,
+		window: window,
+		seen:   make(map[string]*list.Element),
+	}
+	return r.Use(d.middleware)
+}
+
+type dedup struct {
+	rt     *router
+	key    func(C, *http.Request) string
+	window time.Duration
+
+	mu   sync.Mutex
+	seen map[string]*list.Element
+	// The elements of seen, oldest first. Since keys are only ever added
+	// with the current time, they expire from the front.
+	order list.List
+}
+
+type dedupEntry struct {
+	key  string
+	t    time.Time
+	done bool
+}
+
+func (d *dedup) middleware(c *C, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := d.key(*c, r)
+		if key == "" {
+			h.ServeHTTP(w, r)
+			return
+		}
+		e, code := d.mark(key)
+		if e == nil {
+			w.WriteHeader(code)
+			return
+		}
+
+		lw := mutil.WrapWriter(w)
+		ok := false
+		defer func() {
+			d.finish(e, ok && dedupHandled(lw) && !c.Aborted())
+		}()
+		h.ServeHTTP(lw, r)
+		ok = true
+	})
+}
+
+// dedupHandled reports whether the response written to w shows that its request
+// was handled successfully, and therefore should not be handled again.
+func dedupHandled(w mutil.WriterProxy) bool {
+	code := w.Status()
+	if !w.Written() {
+		// Nothing was written, which net/http sends as a 200 OK
+		code = http.StatusOK
+	}
+	return code >= 200 && code < 400
+}
+
+// mark records the given key as seen, and returns its element of d.order. If the
+// key had already been seen within the window, it instead returns a nil element
+// and the status to answer the duplicate with.
+func (d *dedup) mark(key string) (*list.Element, int) {
+	now := d.rt.clock.Now()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.expire(now)
+	if e, ok := d.seen[key]; ok {
+		if e.Value.(*dedupEntry).done {
+			return nil, http.StatusOK
+		}
+		return nil, http.StatusConflict
+	}
+	if len(d.seen) >= maxDedupKeys {
+		d.remove(d.order.Front())
+	}
+	e := d.order.PushBack(&dedupEntry{key: key, t: now})
+	d.seen[key] = e
+	return e, 0
+}
+
+// finish records that the request for the given element of d.order has been
+// handled, forgetting its key unless it was handled successfully.
+func (d *dedup) finish(e *list.Element, ok bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	entry := e.Value.(*dedupEntry)
+	if d.seen[entry.key] != e {
+		// Expired or evicted while the request was being handled
+		return
+	}
+	if ok {
+		entry.done = true
+	} else {
+		d.remove(e)
+	}
+}
+
+// expire forgets every key which has outlived the window. d.mu must be held.
+func (d *dedup) expire(now time.Time) {
+	for e := d.order.Front(); e != nil; e = d.order.Front() {
+		if now.Sub(e.Value.(*dedupEntry).t) < d.window {
+			return
+		}
+		d.remove(e)
+	}
+}
+
+// remove forgets the key of the given element of d.order. d.mu must be held.
+func (d *dedup) remove(e *list.Element) {
+	delete(d.seen, e.Value.(*dedupEntry).key)
+	d.order.Remove(e)
+}
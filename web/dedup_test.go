@@ -0,0 +1,135 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDedup(t *testing.T) {
+	t.Parallel()
+	clock := &fakeClock{t: time.Date(2014, 11, 28, 0, 0, 0, 0, time.UTC)}
+	m := New()
+	m.SetClock(clock)
+	calls := 0
+	status := http.StatusAccepted
+	m.Post("/webhook", func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(status)
+	}).Dedup(func(c C, r *http.Request) string {
+		return r.Header.Get("X-Event-ID")
+	}, time.Minute)
+
+	table := []struct {
+		id      string
+		advance time.Duration
+		calls   int
+		code    int
+	}{
+		{"a", 0, 1, http.StatusAccepted},
+		{"a", 30 * time.Second, 1, http.StatusOK},
+		{"b", 0, 2, http.StatusAccepted},
+		{"", 0, 3, http.StatusAccepted},
+		{"", 0, 4, http.StatusAccepted},
+		{"a", 31 * time.Second, 5, http.StatusAccepted},
+		{"a", 59 * time.Second, 5, http.StatusOK},
+	}
+	for _, test := range table {
+		clock.t = clock.t.Add(test.advance)
+		r, _ := http.NewRequest("POST", "/webhook", nil)
+		r.Header.Set("X-Event-ID", test.id)
+		w := httptest.NewRecorder()
+		m.ServeHTTP(w, r)
+		if calls != test.calls || w.Code != test.code {
+			t.Errorf("For %q at %v, expected %d calls and status %d, "+
+				"got %d and %d", test.id, clock.t, test.calls,
+				test.code, calls, w.Code)
+		}
+	}
+
+	// Failed deliveries may be retried.
+	status = http.StatusServiceUnavailable
+	for i := 0; i < 2; i++ {
+		r, _ := http.NewRequest("POST", "/webhook", nil)
+		r.Header.Set("X-Event-ID", "c")
+		m.ServeHTTP(httptest.NewRecorder(), r)
+	}
+	if calls != 7 {
+		t.Errorf("Expected failed requests to be retried, got %d calls", calls)
+	}
+
+	// As may rejected ones.
+	for _, status = range []int{http.StatusTooManyRequests, http.StatusAccepted} {
+		r, _ := http.NewRequest("POST", "/webhook", nil)
+		r.Header.Set("X-Event-ID", "d")
+		w := httptest.NewRecorder()
+		m.ServeHTTP(w, r)
+		if w.Code != status {
+			t.Errorf("Expected the retry of a rejected request to reach "+
+				"the handler, got %d", w.Code)
+		}
+	}
+	if calls != 9 {
+		t.Errorf("Expected rejected requests to be retried, got %d calls", calls)
+	}
+}
+
+func TestDedupInFlight(t *testing.T) {
+	t.Parallel()
+	m := New()
+	started := make(chan struct{})
+	release := make(chan int)
+	m.Post("/webhook", func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		w.WriteHeader(<-release)
+	}).Dedup(func(c C, r *http.Request) string {
+		return "a"
+	}, time.Minute)
+
+	serve := func() <-chan int {
+		codes := make(chan int, 1)
+		go func() {
+			r, _ := http.NewRequest("POST", "/webhook", nil)
+			w := httptest.NewRecorder()
+			m.ServeHTTP(w, r)
+			codes <- w.Code
+		}()
+		return codes
+	}
+
+	// A duplicate of a delivery that is still being handled is refused,
+	// since the delivery may yet fail...
+	first := serve()
+	<-started
+	if code := <-serve(); code != http.StatusConflict {
+		t.Errorf("Expected an in-flight duplicate to get a 409, got %d", code)
+	}
+	release <- http.StatusInternalServerError
+	<-first
+
+	// ...in which case the next one is handled.
+	second := serve()
+	<-started
+	release <- http.StatusAccepted
+	if code := <-second; code != http.StatusAccepted {
+		t.Errorf("Expected a retry of a failed delivery to be handled, got %d", code)
+	}
+	if code := <-serve(); code != http.StatusOK {
+		t.Errorf("Expected a duplicate to get a 200, got %d", code)
+	}
+}
+
+func dedupWebhook(w http.ResponseWriter, r *http.Request) {}
+
+func TestDedupRouteInfo(t *testing.T) {
+	t.Parallel()
+	m := New()
+	m.Post("/webhook", dedupWebhook).Dedup(func(c C, r *http.Request) string {
+		return r.Header.Get("X-Event-ID")
+	}, time.Minute)
+	if h := m.Routes()[0].Handler; !strings.HasSuffix(h, ".dedupWebhook") {
+		t.Errorf("Expected the route's own handler to be reported, got %q", h)
+	}
+}
@@ -0,0 +1,57 @@
+package web
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+)
+
+// MaxDispatchDepth is the maximum number of nested requests C.Dispatch will
+// make before concluding that it is recursing infinitely.
+const MaxDispatchDepth = 8
+
+// ErrDispatchDepth is returned by C.Dispatch when the request it would make is
+// nested more than MaxDispatchDepth requests deep.
+var ErrDispatchDepth = errors.New("web: maximum dispatch depth exceeded")
+
+/*
+Dispatch internally makes a request to the Mux that routed the current request,
+and returns the captured response. The synthetic request runs through the Mux's
+full middleware stack, just as a real one would, but without a network round
+trip. This allows a handler to compose the responses of several other endpoints
+into one:
+
+	func dashboard(c web.C, w http.ResponseWriter, r *http.Request) {
+		user, err := c.Dispatch("GET", "/users/"+c.URLParams["id"], nil)
+		...
+	}
+
+If the request was routed by more than one Mux (for instance, if it was passed
+to a sub-router), the outermost Mux is used, so path should be given in full.
+The synthetic request starts with an otherwise empty context (in particular,
+none of the current request's headers or Env are copied to it), and its
+context is independent of the current request's.
+
+Dispatch returns an error if path cannot be parsed, if called from outside a
+Mux, or (with ErrDispatchDepth) if the chain of dispatched requests leading to
+this one is already MaxDispatchDepth requests long.
+*/
+func (c C) Dispatch(method, path string, body io.Reader) (*httptest.ResponseRecorder, error) {
+	m := c.mux
+	if m == nil {
+		return nil, errors.New("web: Dispatch called outside of a Mux")
+	}
+	if c.dispatchDepth >= MaxDispatchDepth {
+		return nil, ErrDispatchDepth
+	}
+
+	r, err := http.NewRequest(method, path, body)
+	if err != nil {
+		return nil, err
+	}
+	sub := C{dispatchDepth: c.dispatchDepth + 1}
+	w := httptest.NewRecorder()
+	m.ServeHTTPC(sub, w, r)
+	return w, nil
+}
@@ -0,0 +1,65 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDispatch(t *testing.T) {
+	t.Parallel()
+	m := New()
+	m.Use(func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Middleware", "yes")
+			h.ServeHTTP(w, r)
+		})
+	})
+	m.Get("/users/:id", func(c C, w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte("user " + c.URLParams["id"]))
+	})
+
+	var err error
+	m.Get("/dashboard/:id", func(c C, w http.ResponseWriter, r *http.Request) {
+		resp, e := c.Dispatch("GET", "/users/"+c.URLParams["id"], nil)
+		if err = e; err != nil {
+			return
+		}
+		if resp.Code != http.StatusOK {
+			t.Errorf("Dispatched request returned %d", resp.Code)
+		}
+		if mw := resp.HeaderMap.Get("X-Middleware"); mw != "yes" {
+			t.Error("Dispatched request did not run through middleware")
+		}
+		w.Write(resp.Body.Bytes())
+	})
+	m.Get("/forever", func(c C, w http.ResponseWriter, r *http.Request) {
+		resp, e := c.Dispatch("GET", "/forever", nil)
+		if e != nil {
+			err = e
+			return
+		}
+		w.Write(resp.Body.Bytes())
+	})
+
+	r, _ := http.NewRequest("GET", "/dashboard/42", nil)
+	w := httptest.NewRecorder()
+	m.ServeHTTP(w, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w.Body.String() != "user 42" {
+		t.Errorf("Expected body %q, got %q", "user 42", w.Body.String())
+	}
+
+	r, _ = http.NewRequest("GET", "/forever", nil)
+	m.ServeHTTP(httptest.NewRecorder(), r)
+	if err != ErrDispatchDepth {
+		t.Errorf("Expected ErrDispatchDepth, got %v", err)
+	}
+
+	if _, err := (C{}).Dispatch("GET", "/users/1", nil); err == nil {
+		t.Error("Expected an error dispatching outside of a Mux")
+	}
+}
@@ -0,0 +1,68 @@
+package web
+
+/*
+EnvKey is a key for storing a value of type T in a context's Env which cannot
+collide with any other key, not even another EnvKey of the same name: each key
+created by NewEnvKey is distinct from every other. This saves packages from
+having to declare an unexported key type of their own in order to share data
+between middleware and handlers safely, and from asserting the type of the
+values they read back:
+
+	var userKey = web.NewEnvKey[*User]("user")
+
+	userKey.Set(c, u)
+	u, ok := userKey.Get(*c)
+
+The zero EnvKey is not a valid key.
+*/
+type EnvKey[T any] struct {
+	k *envKey
+}
+
+type envKey struct {
+	name string
+}
+
+// NewEnvKey returns a new, unique EnvKey. The name is only used to describe the
+// key (for instance, when printing the Env map), and need not be unique.
+func NewEnvKey[T any](name string) EnvKey[T] {
+	return EnvKey[T]{&envKey{name}}
+}
+
+// Get returns the value stored under the key in the context's Env, and whether
+// there was one. If there was not, the returned value is T's zero value.
+func (k EnvKey[T]) Get(c C) (T, bool) {
+	v, ok := c.env()[k.k]
+	// Only Set stores values under k, so v is a T, unless it is a nil
+	// interface.
+	t, _ := v.(T)
+	return t, ok
+}
+
+// Set stores the value under the key in the context's Env, allocating the Env
+// map if necessary.
+func (k EnvKey[T]) Set(c *C, v T) {
+	if k.k == nil {
+		panic("web: use of the zero EnvKey")
+	}
+	c.initEnv()
+	c.Env[k.k] = v
+}
+
+// Delete removes the value stored under the key from the context's Env, if
+// there is one.
+func (k EnvKey[T]) Delete(c *C) {
+	delete(c.env(), k.k)
+}
+
+// String describes the key by the name it was created with.
+func (k EnvKey[T]) String() string {
+	if k.k == nil {
+		return "web.EnvKey(nil)"
+	}
+	return k.k.String()
+}
+
+func (k *envKey) String() string {
+	return "web.EnvKey(" + k.name + ")"
+}
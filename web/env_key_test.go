@@ -0,0 +1,72 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestEnvKey(t *testing.T) {
+	t.Parallel()
+	a, b := NewEnvKey[string]("user"), NewEnvKey[int]("user")
+	var c C
+	if _, ok := a.Get(c); ok {
+		t.Error("Expected an empty context to have no value")
+	}
+
+	a.Set(&c, "carl")
+	b.Set(&c, 42)
+	c.Env["user"] = "string key"
+	if v, ok := a.Get(c); !ok || v != "carl" {
+		t.Errorf("Expected %q, got %v, %v", "carl", v, ok)
+	}
+	if v, ok := b.Get(c); !ok || v != 42 {
+		t.Errorf("Expected keys of the same name not to collide, got %v, %v",
+			v, ok)
+	}
+
+	a.Delete(&c)
+	if _, ok := a.Get(c); ok {
+		t.Error("Expected the value to be deleted")
+	}
+	if v, _ := b.Get(c); v != 42 {
+		t.Errorf("Expected other keys to be unaffected, got %v", v)
+	}
+	if s := fmt.Sprint(a); s != "web.EnvKey(user)" {
+		t.Errorf("Expected %q, got %q", "web.EnvKey(user)", s)
+	}
+
+	e := NewEnvKey[error]("err")
+	e.Set(&c, nil)
+	if v, ok := e.Get(c); !ok || v != nil {
+		t.Errorf("Expected a stored nil, got %v, %v", v, ok)
+	}
+}
+
+func TestEnvKeyShared(t *testing.T) {
+	t.Parallel()
+	user := NewEnvKey[string]("user")
+	m := New()
+	m.Use(func(c *C, h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user.Set(c, "carl")
+			h.ServeHTTP(w, r)
+		})
+	})
+	m.Get("/", func(c C, w http.ResponseWriter, r *http.Request) {
+		// A copy of the handler's context with no Env of its own
+		// still sees the request's Env.
+		d := C{URLParams: c.URLParams}
+		d.rs = c.rs
+		if v, ok := user.Get(d); !ok || v != "carl" {
+			t.Errorf("Expected %q, got %q, %v", "carl", v, ok)
+		}
+		user.Delete(&d)
+		if _, ok := user.Get(c); ok {
+			t.Error("Expected the value to be deleted from the request's Env")
+		}
+	})
+	r, _ := http.NewRequest("GET", "/", nil)
+	m.ServeHTTP(httptest.NewRecorder(), r)
+}
@@ -0,0 +1,135 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// The key used to communicate the Mux's ErrorHandler to the handlers of the
+// routes it matches. See Mux.ErrorHandler.
+const errorHandlerKey = "goji.web.errorHandler"
+
+/*
+HandlerFuncWithError is a handler which returns an error rather than responding
+to requests which fail, leaving the translation of the error into a response to
+a single function shared by the whole Mux (see Mux.ErrorHandler). It implements
+both http.Handler and Handler, and functions with its signature are accepted
+wherever a HandlerType is:
+
+	m.Get("/users/:id", func(c web.C, w http.ResponseWriter, r *http.Request) error {
+		id, err := c.ParamInt64("id")
+		if err != nil {
+			return err
+		}
+		user, err := users.Find(c.Context(), id)
+		if err == users.ErrNotFound {
+			return web.StatusError{Code: http.StatusNotFound}
+		} else if err != nil {
+			return err
+		}
+		c.JSON(w, http.StatusOK, user)
+		return nil
+	})
+
+The function should not write a response if it returns an error.
+*/
+type HandlerFuncWithError func(C, http.ResponseWriter, *http.Request) error
+
+// ServeHTTP implements http.Handler, allowing HandlerFuncWithError's to be used
+// with net/http and other compliant routers. When used in this way, the
+// underlying function will be passed an empty context, and errors are handled
+// by DefaultErrorHandler.
+func (h HandlerFuncWithError) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.ServeHTTPC(C{}, w, r)
+}
+
+// ServeHTTPC implements Handler. Errors are handled by the ErrorHandler of the
+// Mux which routed the request, or by DefaultErrorHandler if it has none.
+func (h HandlerFuncWithError) ServeHTTPC(c C, w http.ResponseWriter, r *http.Request) {
+	err := h(c, w, r)
+	if err == nil {
+		return
+	}
+	if eh, ok := c.env()[errorHandlerKey].(ErrorHandler); ok {
+		eh(c, w, r, err)
+		return
+	}
+	DefaultErrorHandler(c, w, r, err)
+}
+
+// ErrorHandler responds to a request whose handler returned an error. It is
+// passed the same arguments as the handler, as well as the error. See
+// Mux.ErrorHandler.
+type ErrorHandler func(c C, w http.ResponseWriter, r *http.Request, err error)
+
+/*
+ErrorHandler sets the function which responds to requests whose handlers (of
+type HandlerFuncWithError) return an error, for the routes of this mux. This
+allows errors to be reported in an application's own format, for instance as a
+JSON body:
+
+	m.ErrorHandler(func(c web.C, w http.ResponseWriter, r *http.Request, err error) {
+		var se web.StatusError
+		if !errors.As(err, &se) {
+			c.AddError(err)
+			se = web.StatusError{Code: http.StatusInternalServerError}
+		}
+		c.JSON(w, se.Code, map[string]string{"error": se.Error()})
+	})
+
+Passing nil restores the default, DefaultErrorHandler. The handler of a route
+of a mux mounted inside another (see Mux.Mount) uses the mounted mux's error
+handler if it has one, and the enclosing mux's otherwise.
+
+It is illegal to call ErrorHandler concurrently with active requests.
+*/
+func (m *Mux) ErrorHandler(fn ErrorHandler) {
+	m.rt.errorHandler = fn
+}
+
+/*
+StatusError is an error which carries the HTTP status code it should be
+reported with, for handlers which return errors (see HandlerFuncWithError). Msg
+is the message reported to the client, which defaults to the text of the status
+code (as given by http.StatusText).
+*/
+type StatusError struct {
+	Code int
+	Msg  string
+}
+
+func (e StatusError) Error() string {
+	if e.Msg != "" {
+		return e.Msg
+	}
+	if text := http.StatusText(e.Code); text != "" {
+		return text
+	}
+	return fmt.Sprintf("status %d", e.Code)
+}
+
+/*
+DefaultErrorHandler is the ErrorHandler used by Muxes which have not been given
+one of their own. It responds with a plain text error whose status depends on
+the error: a StatusError (or an error wrapping one) is reported with its own
+status and message, a *ParamError with a 400 Bad Request and its message, and
+ErrBodyTooLarge with a 413 Request Entity Too Large. Any other error is recorded
+with C.AddError, so that it can be logged, and reported with a 500 Internal
+Server Error, without revealing the error's message to the client.
+*/
+func DefaultErrorHandler(c C, w http.ResponseWriter, r *http.Request, err error) {
+	var se StatusError
+	var pe *ParamError
+	switch {
+	case errors.As(err, &se):
+	case errors.As(err, &pe):
+		se = StatusError{Code: http.StatusBadRequest, Msg: pe.Error()}
+	case errors.Is(err, ErrBodyTooLarge):
+		se = StatusError{Code: http.StatusRequestEntityTooLarge}
+	default:
+		c.AddError(err)
+		se = StatusError{Code: http.StatusInternalServerError}
+	}
+	http.Error(w, se.Error(), se.Code)
+}
@@ -0,0 +1,107 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func errorMux() *Mux {
+	m := New()
+	m.Get("/ok", func(c C, w http.ResponseWriter, r *http.Request) error {
+		w.Write([]byte("ok"))
+		return nil
+	})
+	m.Get("/missing", func(c C, w http.ResponseWriter, r *http.Request) error {
+		return fmt.Errorf("finding widget: %w",
+			StatusError{Code: http.StatusNotFound, Msg: "no such widget"})
+	})
+	m.Get("/forbidden", func(c C, w http.ResponseWriter, r *http.Request) error {
+		return StatusError{Code: http.StatusForbidden}
+	})
+	m.Get("/users/:id", func(c C, w http.ResponseWriter, r *http.Request) error {
+		_, err := c.ParamInt64("id")
+		return err
+	})
+	m.Get("/broken", func(c C, w http.ResponseWriter, r *http.Request) error {
+		return errors.New("database password is hunter2")
+	})
+	return m
+}
+
+func TestDefaultErrorHandler(t *testing.T) {
+	t.Parallel()
+	m := errorMux()
+	var recorded []error
+	m.OnResponse(func(c C, status, bytes int) {
+		recorded = append(recorded, c.Errors()...)
+	})
+
+	tests := []struct {
+		path   string
+		status int
+		body   string
+	}{
+		{"/ok", http.StatusOK, "ok"},
+		{"/missing", http.StatusNotFound, "no such widget\n"},
+		{"/forbidden", http.StatusForbidden, "Forbidden\n"},
+		{"/users/carl", http.StatusBadRequest,
+			`web: URL parameter "id": "carl" is not a valid integer (invalid syntax)` + "\n"},
+		{"/broken", http.StatusInternalServerError, "Internal Server Error\n"},
+	}
+	for _, test := range tests {
+		r, _ := http.NewRequest("GET", test.path, nil)
+		w := httptest.NewRecorder()
+		m.ServeHTTP(w, r)
+		if w.Code != test.status || w.Body.String() != test.body {
+			t.Errorf("For %q, expected %d %q, got %d %q", test.path,
+				test.status, test.body, w.Code, w.Body.String())
+		}
+	}
+	if len(recorded) != 1 || !strings.Contains(recorded[0].Error(), "hunter2") {
+		t.Errorf("Expected the internal error to be recorded, got %v", recorded)
+	}
+}
+
+func TestErrorHandler(t *testing.T) {
+	t.Parallel()
+	m := errorMux()
+	m.ErrorHandler(func(c C, w http.ResponseWriter, r *http.Request, err error) {
+		var se StatusError
+		if !errors.As(err, &se) {
+			se = StatusError{Code: http.StatusInternalServerError}
+		}
+		c.JSON(w, se.Code, map[string]string{"error": se.Error()})
+	})
+
+	sub := New()
+	sub.Get("/sub/broken", func(c C, w http.ResponseWriter, r *http.Request) error {
+		return StatusError{Code: http.StatusTeapot}
+	})
+	m.Handle("/sub/*", sub)
+
+	tests := []struct {
+		path   string
+		status int
+		body   string
+	}{
+		{"/ok", http.StatusOK, "ok"},
+		{"/missing", http.StatusNotFound, `{"error":"no such widget"}` + "\n"},
+		{"/broken", http.StatusInternalServerError,
+			`{"error":"Internal Server Error"}` + "\n"},
+		// The sub-mux has no error handler of its own.
+		{"/sub/broken", http.StatusTeapot, `{"error":"I'm a teapot"}` + "\n"},
+	}
+	for _, test := range tests {
+		r, _ := http.NewRequest("GET", test.path, nil)
+		w := httptest.NewRecorder()
+		m.ServeHTTP(w, r)
+		if w.Code != test.status || w.Body.String() != test.body {
+			t.Errorf("For %q, expected %d %q, got %d %q", test.path,
+				test.status, test.body, w.Code, w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package web
+
+// The key used to accumulate non-fatal errors. See C.AddError.
+const ErrorsKey = "goji.web.errors"
+
+/*
+AddError records a non-fatal error encountered while processing the request,
+such as the failure of an optional dependency, so that the handler can carry on
+with a partial response while still reporting the failure. Errors accumulate in
+the context's Env, from which middleware (a request logger, say, or one which
+adds a "warnings" field to responses) can retrieve them with Errors once the
+handler has returned.
+
+Errors are shared with middleware through the Env map. A Mux allocates one Env
+for the request the first time one is needed, and every layer which had none
+then sees the errors through Errors, so every middleware can observe errors
+added by handlers unless some layer in between gave the request an Env of its
+own.
+*/
+func (c *C) AddError(err error) {
+	c.initEnv()
+	errs, _ := c.Env[ErrorsKey].([]error)
+	c.Env[ErrorsKey] = append(errs, err)
+}
+
+// Errors returns the errors recorded with AddError, in the order they were
+// added.
+func (c C) Errors() []error {
+	errs, _ := c.env()[ErrorsKey].([]error)
+	return errs
+}
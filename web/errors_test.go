@@ -0,0 +1,49 @@
+package web
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestErrors(t *testing.T) {
+	t.Parallel()
+	m := New()
+	m.Use(func(c *C, h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			h.ServeHTTP(w, r)
+			var warnings []string
+			for _, err := range c.Errors() {
+				warnings = append(warnings, err.Error())
+			}
+			w.Write([]byte(" warnings: " + strings.Join(warnings, ", ")))
+		})
+	})
+	m.Get("/dashboard", func(c C, w http.ResponseWriter, r *http.Request) {
+		c.AddError(errors.New("weather unavailable"))
+		c.AddError(errors.New("stocks unavailable"))
+		w.Write([]byte("news"))
+	})
+
+	r, _ := http.NewRequest("GET", "/dashboard", nil)
+	w := httptest.NewRecorder()
+	m.ServeHTTP(w, r)
+	expected := "news warnings: weather unavailable, stocks unavailable"
+	if w.Body.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, w.Body.String())
+	}
+
+	var c C
+	if c.Errors() != nil {
+		t.Error("Expected no errors on an empty context")
+	}
+	e1, e2 := errors.New("one"), errors.New("two")
+	c.AddError(e1)
+	c.AddError(e2)
+	if errs := c.Errors(); !reflect.DeepEqual(errs, []error{e1, e2}) {
+		t.Errorf("Expected [one two], got %v", errs)
+	}
+}
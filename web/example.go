@@ -0,0 +1,102 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Example is a sample request body for a route, along with the response body
+// it is expected to produce. See Route.Example.
+type Example struct {
+	Request  string
+	Response string
+}
+
+// Example attaches a sample request body and the response body it produces to
+// this route, for the benefit of documentation tools (see Mux.Routes) and
+// developers (see Mux.TryIt). Like Doc, it has no effect on routing. For
+// routes which take no request body, req may be empty.
+func (r *Route) Example(req, resp string) *Route {
+	r.rt.lock.Lock()
+	defer r.rt.lock.Unlock()
+	r.route.example = &Example{Request: req, Response: resp}
+	r.rt.setMachine(nil)
+	return r
+}
+
+/*
+TryIt is a debugging handler which issues the example request of one of the
+mux's routes (see Route.Example), and responds with whatever the route's handler
+responded with. It is meant to be mounted on the same mux, typically behind some
+kind of authentication:
+
+	m.Get("/debug/try", m.TryIt)
+
+The route is chosen with the "route" query parameter, which must be its pattern
+as reported by Routes, and optionally the "method" query parameter, which
+defaults to the first method the route responds to (other than HEAD). If the
+route's pattern captures URL parameters, the "path" query parameter must give
+the concrete path to request; otherwise it defaults to the pattern itself. For
+instance, "/debug/try?route=/widgets/:id&method=PUT&path=/widgets/1".
+
+The example request is made using C.Dispatch, and so it runs through the full
+middleware stack of the outermost mux which routed the TryIt request.
+*/
+func (m *Mux) TryIt(c C, w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	pattern, method, path := q.Get("route"), q.Get("method"), q.Get("path")
+
+	var info *RouteInfo
+	for _, ri := range m.Routes() {
+		if ri.Pattern == pattern && ri.Example != nil &&
+			(method == "" || ri.Methods == nil || hasMethod(ri.Methods, method)) {
+			info = &ri
+			break
+		}
+	}
+	if info == nil {
+		http.Error(w, fmt.Sprintf("No route %q with an example", pattern),
+			http.StatusNotFound)
+		return
+	}
+
+	if method == "" {
+		method = "GET"
+		for _, meth := range info.Methods {
+			if meth != "HEAD" {
+				method = meth
+				break
+			}
+		}
+	}
+	if path == "" {
+		if !strings.HasPrefix(pattern, "/") ||
+			strings.ContainsAny(pattern, ":*") {
+			http.Error(w, "A path is required for this route",
+				http.StatusBadRequest)
+			return
+		}
+		path = pattern
+	}
+
+	resp, err := c.Dispatch(method, path, strings.NewReader(info.Example.Request))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	for k, v := range resp.HeaderMap {
+		w.Header()[k] = v
+	}
+	w.WriteHeader(resp.Code)
+	w.Write(resp.Body.Bytes())
+}
+
+func hasMethod(methods []string, method string) bool {
+	for _, m := range methods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
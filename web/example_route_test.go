@@ -0,0 +1,61 @@
+package web
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRouteExample(t *testing.T) {
+	t.Parallel()
+	m := New()
+	m.Post("/echo", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		w.Write(body)
+	}).Example(`{"a":1}`, `{"a":1}`)
+	m.Get("/widgets/:id", func(c C, w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("widget " + c.URLParams["id"]))
+	}).Example("", "widget 1")
+	m.Get("/plain", http.NotFound)
+	m.Get("/debug/try", m.TryIt)
+
+	routes := make(map[string]RouteInfo)
+	for _, info := range m.Routes() {
+		routes[info.Pattern] = info
+	}
+	if ex := routes["/echo"].Example; ex == nil || ex.Request != `{"a":1}` ||
+		ex.Response != `{"a":1}` {
+		t.Errorf("Expected example in Routes, got %+v", ex)
+	}
+	if routes["/plain"].Example != nil {
+		t.Errorf("Expected no example, got %+v", routes["/plain"].Example)
+	}
+
+	tests := []struct {
+		query string
+		code  int
+		body  string
+	}{
+		{"route=/echo", http.StatusCreated, `{"a":1}`},
+		{"route=/widgets/:id&path=/widgets/1", http.StatusOK, "widget 1"},
+		{"route=/widgets/:id", http.StatusBadRequest, ""},
+		{"route=/echo&method=GET", http.StatusNotFound, ""},
+		{"route=/plain", http.StatusNotFound, ""},
+	}
+	for _, test := range tests {
+		r, _ := http.NewRequest("GET", "/debug/try?"+test.query, nil)
+		w := httptest.NewRecorder()
+		m.ServeHTTP(w, r)
+		if w.Code != test.code {
+			t.Errorf("For %q, expected %d, got %d", test.query,
+				test.code, w.Code)
+		}
+		if test.body != "" && w.Body.String() != test.body {
+			t.Errorf("For %q, expected %q, got %q", test.query,
+				test.body, w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package web
+
+import (
+	"errors"
+	"net/http"
+)
+
+// Explanation describes how a Mux would serve a request. See Mux.Explain.
+type Explanation struct {
+	// Middleware is the names of the middleware the request would pass
+	// through, outermost first: the mux's own, followed by those of the
+	// matched route (see Route.Use), and, for requests routed to a mounted
+	// sub-mux, those of the sub-mux and its matched route. Middleware are
+	// named as handlers are in RouteInfo.
+	Middleware []string
+	// Route describes the route the request would be matched to, or is nil
+	// if it would not be matched to any. The patterns of the routes of
+	// mounted sub-muxes are prefixed with their mount point's, as they are
+	// by Mux.Routes.
+	Route *RouteInfo
+	// Params are the URL parameters the route would bind.
+	Params map[string]string
+	// Handler is the name of the handler which would respond to the
+	// request: the matched route's, or, if there is none, the handler the
+	// mux would fall back to (such as its NotFound handler). It is empty
+	// for OPTIONS requests which the mux would answer itself (see
+	// Mux.AutoOptions).
+	Handler string
+}
+
+/*
+Explain routes the given request as the mux would serve it, without serving it,
+and describes the middleware and handler it would be passed to. It is meant as a
+debugging aid, for instance behind an endpoint available only in development:
+
+	m.Get("/debug/routes", func(w http.ResponseWriter, r *http.Request) {
+		probe, _ := http.NewRequest(r.FormValue("method"), r.FormValue("path"), nil)
+		e, err := m.Explain(probe)
+		...
+	})
+
+Neither middleware nor handlers are run, and the request is left as it is.
+Patterns are matched against the request as usual, so custom Patterns (see
+Pattern) and Route.Active schedules are consulted. Since middleware do not run,
+any changes they would make to the request (such as setting its headers, or
+aborting it) are not taken into account, nor are the redirects made by
+Mux.CleanPath and Mux.RedirectTrailingSlash for requests which would otherwise
+not be matched. An error is returned if the request has no URL.
+*/
+func (m *Mux) Explain(r *http.Request) (Explanation, error) {
+	if r == nil || r.URL == nil {
+		return Explanation{}, errors.New("web: Explain requires a request with a URL")
+	}
+	var e Explanation
+	m.explain(&e, C{}, r)
+	return e, nil
+}
+
+func (m *Mux) explain(e *Explanation, c C, r *http.Request) {
+	e.Middleware = appendLayerNames(e.Middleware, m.ms.layers())
+
+	rt := &m.rt
+	rm := rt.getMachine()
+	if rm == nil {
+		rm = rt.compile()
+	}
+	ms, route := rm.route(&c, nil, r)
+	if route == nil {
+		e.Route, e.Params = nil, nil
+		e.Handler = rt.fallbackName(ms, r)
+		return
+	}
+
+	if route.middleware != nil {
+		e.Middleware = appendLayerNames(e.Middleware, route.middleware.layers())
+	}
+	info := *route.info
+	e.Route = &info
+	e.Params = c.URLParams
+	e.Handler = info.Handler
+
+	if mh, ok := route.handler.(mountHandler); ok {
+		// As mountHandler.ServeHTTPC would pass the request on.
+		params := make(map[string]string, len(c.URLParams))
+		for k, v := range c.URLParams {
+			params[k] = v
+		}
+		path := params["*"]
+		delete(params, "*")
+		u := *r.URL
+		u.Path = path
+		u.RawPath = ""
+		r2 := r.WithContext(r.Context())
+		r2.URL = &u
+		mh.sub.explain(e, C{URLParams: params}, r2)
+		if e.Route != nil {
+			prefix := route.pattern.(mountPattern).exact.raw
+			e.Route.Pattern = mountedPattern(prefix, e.Route.Pattern)
+		}
+	}
+}
+
+// fallbackName returns the name of the handler the router falls back to for a
+// request which matched no route, given the state of its matching.
+func (rt *router) fallbackName(ms matchState, r *http.Request) string {
+	switch {
+	case ms.negotiation&negNotAcceptable != 0:
+		return handlerName(rt.notAcceptable)
+	case ms.negotiation&negUnsupportedMediaType != 0:
+		return handlerName(rt.unsupportedMediaType)
+	case ms.methods == 0 || !rt.autoOptions:
+		return handlerName(rt.notFound)
+	case r.Method == "OPTIONS":
+		return ""
+	}
+	return handlerName(rt.methodNotAllowed)
+}
+
+func appendLayerNames(names []string, layers []mLayer) []string {
+	for _, l := range layers {
+		names = append(names, valueName(l.orig))
+	}
+	return names
+}
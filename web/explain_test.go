@@ -0,0 +1,89 @@
+package web
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func explainMiddleware(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("middleware must not run")
+	})
+}
+
+func explainRouteMiddleware(c *C, h http.Handler) http.Handler {
+	return explainMiddleware(h)
+}
+
+func explainHandler(w http.ResponseWriter, r *http.Request) {
+	panic("handler must not run")
+}
+
+func TestExplain(t *testing.T) {
+	t.Parallel()
+	m := New()
+	m.Use(explainMiddleware)
+	m.Get("/users/:id", explainHandler).Use(explainRouteMiddleware)
+
+	api := New()
+	api.Use(explainRouteMiddleware)
+	api.Post("/widgets/:wid", explainHandler)
+	m.Mount("/:tenant/api", api)
+
+	const prefix = "github.com/zenazn/goji/web."
+	tests := []struct {
+		method, path string
+		middleware   []string
+		pattern      string
+		params       map[string]string
+		handler      string
+	}{
+		{"GET", "/users/5",
+			[]string{"explainMiddleware", "explainRouteMiddleware"},
+			"/users/:id", map[string]string{"id": "5"}, "explainHandler"},
+		{"POST", "/acme/api/widgets/7",
+			[]string{"explainMiddleware", "explainRouteMiddleware"},
+			"/:tenant/api/widgets/:wid",
+			map[string]string{"tenant": "acme", "wid": "7"}, "explainHandler"},
+		{"GET", "/nowhere", []string{"explainMiddleware"}, "", nil,
+			"net/http.NotFound"},
+		{"DELETE", "/users/5", []string{"explainMiddleware"}, "", nil,
+			"statusHandler.func1"},
+		{"OPTIONS", "/users/5", []string{"explainMiddleware"}, "", nil, ""},
+	}
+	for _, test := range tests {
+		r, _ := http.NewRequest(test.method, test.path, nil)
+		e, err := m.Explain(r)
+		if err != nil {
+			t.Errorf("For %s %s, unexpected error: %v", test.method,
+				test.path, err)
+			continue
+		}
+		var middleware []string
+		for _, name := range e.Middleware {
+			middleware = append(middleware, strings.TrimPrefix(name, prefix))
+		}
+		if !reflect.DeepEqual(middleware, test.middleware) {
+			t.Errorf("For %s %s, expected middleware %v, got %v",
+				test.method, test.path, test.middleware, middleware)
+		}
+		pattern := ""
+		if e.Route != nil {
+			pattern = e.Route.Pattern
+		}
+		if pattern != test.pattern || !reflect.DeepEqual(e.Params, test.params) {
+			t.Errorf("For %s %s, expected %q %v, got %q %v", test.method,
+				test.path, test.pattern, test.params, pattern, e.Params)
+		}
+		if strings.TrimPrefix(e.Handler, prefix) != test.handler {
+			t.Errorf("For %s %s, expected handler %q, got %q",
+				test.method, test.path, test.handler, e.Handler)
+		}
+	}
+
+	if _, err := m.Explain(&http.Request{}); err == nil {
+		t.Error("Expected an error for a request without a URL")
+	}
+}
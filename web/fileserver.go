@@ -0,0 +1,271 @@
+package web
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"html"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FileServerOptions controls how a FileServer serves files. The zero value
+// serves directory listings, and responds with a plain 404 Not Found to
+// requests for files which do not exist.
+type FileServerOptions struct {
+	// DisableListing makes requests for directories without an index.html
+	// be treated as requests for files which do not exist, rather than
+	// being answered with a listing of the directory's contents.
+	DisableListing bool
+	// NotFoundHandler, if non-nil, handles requests for files which do not
+	// exist. Single-page applications, which route on the client, can use
+	// it to serve their index.html for every unknown path.
+	NotFoundHandler Handler
+	// MaxAge, if positive, is how long clients and caches may reuse the
+	// files they are served without checking whether they have changed,
+	// as announced in each file's Cache-Control and Expires headers. It is
+	// best suited to assets whose names change along with their contents.
+	MaxAge time.Duration
+	// Precompressed makes requests from clients which accept gzip be
+	// served the compressed copy of a file, named as the file with a ".gz"
+	// suffix, if there is one. Compressed copies are served in full, even
+	// for Range requests.
+	Precompressed bool
+}
+
+/*
+FileServer returns a Handler which serves the files in the directory tree rooted
+at root. It is meant to be routed with a wildcard pattern, and serves the file
+named by the unmatched tail of the path (the "*" URL parameter):
+
+	m.Get("/static/*", web.FileServer("./public", web.FileServerOptions{}))
+	// GET /static/css/site.css serves ./public/css/site.css
+
+If the route's pattern does not bind "*", the request's whole path is used
+instead. Paths are cleaned before they are resolved, so requests can never
+reach files outside of root (though symbolic links within it are followed).
+
+Files are served with http.ServeContent, which sets their Content-Type (based on
+their extension, or failing that their contents) and Last-Modified headers, and
+which handles conditional and Range requests. Files are also given an ETag, a
+hash of their contents (computed once for each version of a file, as told by
+its modification time and size), so that requests with If-None-Match, as well
+as those with If-Modified-Since, are answered with a 304 Not Modified if the
+file has not changed. Requests for a directory whose
+path lacks a trailing slash are redirected to the path with one, and those which
+have one are served the directory's index.html, if it has one, or else a listing
+of its contents (see FileServerOptions).
+*/
+func FileServer(root string, opts FileServerOptions) Handler {
+	if opts.NotFoundHandler == nil {
+		opts.NotFoundHandler = statusHandler(http.StatusNotFound)
+	}
+	return fileServer{
+		root:  http.Dir(root),
+		opts:  opts,
+		etags: &etagCache{tags: make(map[string]etagEntry)},
+	}
+}
+
+type fileServer struct {
+	root  http.FileSystem
+	opts  FileServerOptions
+	etags *etagCache
+}
+
+func (fs fileServer) ServeHTTPC(c C, w http.ResponseWriter, r *http.Request) {
+	name, ok := c.URLParams["*"]
+	if !ok {
+		name = r.URL.Path
+	}
+	name = path.Clean("/" + name)
+
+	f, err := fs.root.Open(name)
+	if err != nil {
+		fs.openError(c, w, r, err)
+		return
+	}
+	defer f.Close()
+	d, err := f.Stat()
+	if err != nil {
+		fs.openError(c, w, r, err)
+		return
+	}
+
+	if d.IsDir() {
+		if !strings.HasSuffix(r.URL.Path, "/") {
+			location := r.URL.EscapedPath() + "/"
+			if r.URL.RawQuery != "" {
+				location += "?" + r.URL.RawQuery
+			}
+			http.Redirect(w, r, location, http.StatusMovedPermanently)
+			return
+		}
+		index, err := fs.root.Open(path.Join(name, "index.html"))
+		if err == nil {
+			defer index.Close()
+			if id, err := index.Stat(); err == nil && !id.IsDir() {
+				fs.serveFile(w, r, path.Join(name, "index.html"), index, id)
+				return
+			}
+		}
+		if fs.opts.DisableListing {
+			fs.opts.NotFoundHandler.ServeHTTPC(c, w, r)
+			return
+		}
+		fs.list(w, f)
+		return
+	}
+
+	fs.serveFile(w, r, name, f, d)
+}
+
+// serveFile serves the file with the given (cleaned) name, or its compressed
+// copy, if the options allow it, and there is one.
+func (fs fileServer) serveFile(w http.ResponseWriter, r *http.Request, name string, f http.File, d os.FileInfo) {
+	h := w.Header()
+	if fs.opts.MaxAge > 0 {
+		h.Set("Cache-Control", "public, max-age="+
+			strconv.FormatInt(int64(fs.opts.MaxAge/time.Second), 10))
+		h.Set("Expires", time.Now().Add(fs.opts.MaxAge).UTC().Format(http.TimeFormat))
+	}
+
+	base := d.Name()
+	if fs.opts.Precompressed {
+		h.Add("Vary", "Accept-Encoding")
+		if acceptsGzip(r) {
+			if gz, err := fs.root.Open(name + ".gz"); err == nil {
+				defer gz.Close()
+				if gd, err := gz.Stat(); err == nil && !gd.IsDir() {
+					ctype := mime.TypeByExtension(path.Ext(base))
+					if ctype == "" {
+						ctype = "application/octet-stream"
+					}
+					h.Set("Content-Type", ctype)
+					h.Set("Content-Encoding", "gzip")
+					name, f, d = name+".gz", gz, gd
+					// Ranges of the compressed copy would
+					// be ranges of a different
+					// representation.
+					r = r.Clone(r.Context())
+					r.Header.Del("Range")
+				}
+			}
+		}
+	}
+
+	if tag, ok := fs.etags.get(name, f, d); ok {
+		h.Set("ETag", tag)
+	}
+	http.ServeContent(w, r, base, d.ModTime(), f)
+}
+
+// acceptsGzip reports whether the request's Accept-Encoding header allows a
+// gzip-encoded response.
+func acceptsGzip(r *http.Request) bool {
+	for _, v := range r.Header["Accept-Encoding"] {
+		for _, coding := range strings.Split(v, ",") {
+			coding, params, _ := strings.Cut(coding, ";")
+			coding = strings.ToLower(strings.TrimSpace(coding))
+			if coding != "gzip" && coding != "*" {
+				continue
+			}
+			q, ok := strings.CutPrefix(strings.TrimSpace(params), "q=")
+			if !ok {
+				return true
+			}
+			if f, err := strconv.ParseFloat(q, 64); err == nil && f > 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// etagCache holds the ETags of the files served by a FileServer, each as of
+// the version of the file it was computed for.
+type etagCache struct {
+	mu   sync.Mutex
+	tags map[string]etagEntry
+}
+
+type etagEntry struct {
+	mod  time.Time
+	size int64
+	tag  string
+}
+
+// get returns the ETag of the file with the given name, hashing it if the
+// version of it given by d has not been hashed before. The file is left at its
+// beginning.
+func (c *etagCache) get(name string, f http.File, d os.FileInfo) (string, bool) {
+	c.mu.Lock()
+	e, ok := c.tags[name]
+	c.mu.Unlock()
+	if ok && e.mod.Equal(d.ModTime()) && e.size == d.Size() {
+		return e.tag, true
+	}
+
+	h := sha256.New()
+	_, err := io.Copy(h, f)
+	if _, serr := f.Seek(0, io.SeekStart); err != nil || serr != nil {
+		return "", false
+	}
+	e = etagEntry{
+		mod:  d.ModTime(),
+		size: d.Size(),
+		tag:  `"` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`,
+	}
+	c.mu.Lock()
+	c.tags[name] = e
+	c.mu.Unlock()
+	return e.tag, true
+}
+
+func (fs fileServer) openError(c C, w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case os.IsNotExist(err):
+		fs.opts.NotFoundHandler.ServeHTTPC(c, w, r)
+	case os.IsPermission(err):
+		http.Error(w, http.StatusText(http.StatusForbidden),
+			http.StatusForbidden)
+	default:
+		http.Error(w, http.StatusText(http.StatusInternalServerError),
+			http.StatusInternalServerError)
+	}
+}
+
+func (fs fileServer) list(w http.ResponseWriter, dir http.File) {
+	entries, err := dir.Readdir(-1)
+	if err != nil {
+		http.Error(w, "Error reading directory",
+			http.StatusInternalServerError)
+		return
+	}
+	names := make([]string, len(entries))
+	for i, entry := range entries {
+		names[i] = entry.Name()
+		if entry.IsDir() {
+			names[i] += "/"
+		}
+	}
+	sort.Strings(names)
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprintf(w, "<pre>\n")
+	for _, name := range names {
+		u := url.URL{Path: name}
+		fmt.Fprintf(w, "<a href=\"%s\">%s</a>\n", u.String(),
+			html.EscapeString(name))
+	}
+	fmt.Fprintf(w, "</pre>\n")
+}
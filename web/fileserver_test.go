@@ -0,0 +1,237 @@
+package web
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func fileServerRoot(t *testing.T) string {
+	dir := t.TempDir()
+	files := map[string]string{
+		"site.css":            "body { color: red }",
+		"docs/index.html":     "<h1>docs</h1>",
+		"assets/logo.txt":     "logo",
+		"assets/more/two.txt": "two",
+	}
+	for name, body := range files {
+		name = filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(name, []byte(body), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestFileServer(t *testing.T) {
+	t.Parallel()
+	dir := fileServerRoot(t)
+	if err := os.WriteFile(filepath.Join(filepath.Dir(dir), "secret"),
+		[]byte("secret"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	m := New()
+	m.Get("/static/*", FileServer(dir, FileServerOptions{}))
+
+	tests := []struct {
+		path     string
+		code     int
+		body     string
+		ctype    string
+		location string
+	}{
+		{"/static/site.css", 200, "body { color: red }", "text/css; charset=utf-8", ""},
+		{"/static/docs/", 200, "<h1>docs</h1>", "text/html; charset=utf-8", ""},
+		{"/static/docs?x=1", 301, "", "", "/static/docs/?x=1"},
+		{"/static/assets/", 200, `<a href="logo.txt">logo.txt</a>`, "text/html; charset=utf-8", ""},
+		{"/static/nowhere.txt", 404, "", "", ""},
+		{"/static/../secret", 404, "", "", ""},
+		{"/static/%2e%2e/secret", 404, "", "", ""},
+	}
+	for _, test := range tests {
+		r, _ := http.NewRequest("GET", test.path, nil)
+		w := httptest.NewRecorder()
+		m.ServeHTTP(w, r)
+		if w.Code != test.code || !strings.Contains(w.Body.String(), test.body) {
+			t.Errorf("For %q, expected %d with %q, got %d %q", test.path,
+				test.code, test.body, w.Code, w.Body.String())
+		}
+		if ct := w.HeaderMap.Get("Content-Type"); test.ctype != "" && ct != test.ctype {
+			t.Errorf("For %q, expected Content-Type %q, got %q", test.path,
+				test.ctype, ct)
+		}
+		if loc := w.HeaderMap.Get("Location"); loc != test.location {
+			t.Errorf("For %q, expected Location %q, got %q", test.path,
+				test.location, loc)
+		}
+		if test.code == 200 && w.HeaderMap.Get("Last-Modified") == "" &&
+			!strings.HasSuffix(test.path, "assets/") {
+			t.Errorf("For %q, expected a Last-Modified header", test.path)
+		}
+	}
+
+	r, _ := http.NewRequest("GET", "/static/site.css", nil)
+	r.Header.Set("Range", "bytes=0-3")
+	w := httptest.NewRecorder()
+	m.ServeHTTP(w, r)
+	if w.Code != http.StatusPartialContent || w.Body.String() != "body" {
+		t.Errorf("Expected a partial response, got %d %q", w.Code,
+			w.Body.String())
+	}
+}
+
+func TestFileServerOptions(t *testing.T) {
+	t.Parallel()
+	dir := fileServerRoot(t)
+	m := New()
+	m.Get("/*", FileServer(dir, FileServerOptions{
+		DisableListing: true,
+		NotFoundHandler: HandlerFunc(func(c C, w http.ResponseWriter, r *http.Request) {
+			http.ServeFile(w, r, filepath.Join(dir, "docs", "index.html"))
+		}),
+	}))
+
+	for _, path := range []string{"/assets/", "/app/settings"} {
+		r, _ := http.NewRequest("GET", path, nil)
+		w := httptest.NewRecorder()
+		m.ServeHTTP(w, r)
+		if w.Code != 200 || w.Body.String() != "<h1>docs</h1>" {
+			t.Errorf("For %q, expected the fallback, got %d %q", path,
+				w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestFileServerConditional(t *testing.T) {
+	t.Parallel()
+	dir := fileServerRoot(t)
+	m := New()
+	m.Get("/*", FileServer(dir, FileServerOptions{MaxAge: time.Hour}))
+
+	r, _ := http.NewRequest("GET", "/site.css", nil)
+	w := httptest.NewRecorder()
+	m.ServeHTTP(w, r)
+	etag := w.HeaderMap.Get("ETag")
+	if w.Code != 200 || etag == "" {
+		t.Fatalf("Expected a 200 with an ETag, got %d %q", w.Code, etag)
+	}
+	if cc := w.HeaderMap.Get("Cache-Control"); cc != "public, max-age=3600" {
+		t.Errorf("Expected Cache-Control for an hour, got %q", cc)
+	}
+	if _, err := http.ParseTime(w.HeaderMap.Get("Expires")); err != nil {
+		t.Errorf("Expected an Expires header, got %v", err)
+	}
+
+	conditional := []struct {
+		header, value string
+		code          int
+	}{
+		{"If-None-Match", etag, http.StatusNotModified},
+		{"If-None-Match", `"stale"`, http.StatusOK},
+		{"If-Modified-Since", w.HeaderMap.Get("Last-Modified"), http.StatusNotModified},
+		{"If-Modified-Since", "Mon, 02 Jan 2006 15:04:05 GMT", http.StatusOK},
+	}
+	for _, test := range conditional {
+		r, _ := http.NewRequest("GET", "/site.css", nil)
+		r.Header.Set(test.header, test.value)
+		w := httptest.NewRecorder()
+		m.ServeHTTP(w, r)
+		if w.Code != test.code {
+			t.Errorf("For %s: %s, expected %d, got %d", test.header,
+				test.value, test.code, w.Code)
+		}
+	}
+
+	// Changing the file changes its ETag.
+	name := filepath.Join(dir, "site.css")
+	if err := os.WriteFile(name, []byte("body { color: blue; }"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	r, _ = http.NewRequest("GET", "/site.css", nil)
+	r.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	m.ServeHTTP(w, r)
+	if w.Code != http.StatusOK || w.HeaderMap.Get("ETag") == etag {
+		t.Errorf("Expected a new ETag, got %d %q", w.Code,
+			w.HeaderMap.Get("ETag"))
+	}
+}
+
+func TestFileServerPrecompressed(t *testing.T) {
+	t.Parallel()
+	dir := fileServerRoot(t)
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write([]byte("body { color: red }"))
+	zw.Close()
+	if err := os.WriteFile(filepath.Join(dir, "site.css.gz"), buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	m := New()
+	m.Get("/*", FileServer(dir, FileServerOptions{Precompressed: true}))
+
+	tests := []struct {
+		path, encoding, rng string
+		code                int
+		gzipped             bool
+		body                string
+	}{
+		{"/site.css", "gzip, deflate", "", 200, true, ""},
+		{"/site.css", "gzip, deflate", "bytes=0-3", 200, true, ""},
+		{"/site.css", "gzip;q=0", "", 200, false, "body { color: red }"},
+		{"/site.css", "", "bytes=0-3", 206, false, "body"},
+		{"/assets/logo.txt", "gzip", "", 200, false, "logo"},
+	}
+	for _, test := range tests {
+		r, _ := http.NewRequest("GET", test.path, nil)
+		if test.encoding != "" {
+			r.Header.Set("Accept-Encoding", test.encoding)
+		}
+		if test.rng != "" {
+			r.Header.Set("Range", test.rng)
+		}
+		w := httptest.NewRecorder()
+		m.ServeHTTP(w, r)
+		if w.Code != test.code {
+			t.Errorf("For %q (%q), expected %d, got %d", test.path,
+				test.encoding, test.code, w.Code)
+		}
+		if v := w.HeaderMap.Get("Vary"); v != "Accept-Encoding" {
+			t.Errorf("For %q, expected Vary: Accept-Encoding, got %q",
+				test.path, v)
+		}
+		gzipped := w.HeaderMap.Get("Content-Encoding") == "gzip"
+		if gzipped != test.gzipped {
+			t.Errorf("For %q (%q), expected gzipped %v, got %v",
+				test.path, test.encoding, test.gzipped, gzipped)
+			continue
+		}
+		if !gzipped {
+			if w.Body.String() != test.body {
+				t.Errorf("For %q (%q), expected %q, got %q", test.path,
+					test.encoding, test.body, w.Body.String())
+			}
+			continue
+		}
+		if ct := w.HeaderMap.Get("Content-Type"); ct != "text/css; charset=utf-8" {
+			t.Errorf("Expected the type of the uncompressed file, got %q", ct)
+		}
+		zr, err := gzip.NewReader(w.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if body, _ := io.ReadAll(zr); string(body) != "body { color: red }" {
+			t.Errorf("Expected the compressed file, got %q", body)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package web
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+/*
+Fingerprint computes a stable hash over the given attributes of the request, for
+use as a key by anything which needs to recognize equivalent requests (caches,
+deduplication windows, and the like). Sharing a single definition of
+"equivalent" between them keeps their keys consistent. Each field is one of:
+
+	"method"        the request method
+	"path"          the request path
+	"query"         the query string, with parameters sorted by name
+	"header:Name"   the values of the named request header
+	"param:name"    the value of the named URL parameter
+	"body"          the request body
+
+Requests whose selected attributes are equal have equal fingerprints, and the
+order in which fields are given matters. When "body" is requested, the body is
+read in full and then replaced, so that handlers can read it as usual; if
+reading it fails, the handler will see the same error once it reaches that
+point. Fingerprint panics if it is passed an unknown field.
+*/
+func (c C) Fingerprint(r *http.Request, fields ...string) string {
+	h := sha256.New()
+	for _, field := range fields {
+		switch {
+		case field == "method":
+			writeField(h, field, r.Method)
+		case field == "path":
+			writeField(h, field, r.URL.Path)
+		case field == "query":
+			writeField(h, field, r.URL.Query().Encode())
+		case strings.HasPrefix(field, "header:"):
+			vs := r.Header[http.CanonicalHeaderKey(field[len("header:"):])]
+			writeField(h, field, strings.Join(vs, "\x00"))
+		case strings.HasPrefix(field, "param:"):
+			writeField(h, field, c.URLParams[field[len("param:"):]])
+		case field == "body":
+			writeField(h, field, string(readBody(r)))
+		default:
+			panic(fmt.Sprintf("web: unknown fingerprint field %q", field))
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// writeField writes a length-prefixed field, so that the boundaries between
+// fields are unambiguous.
+func writeField(h hash.Hash, name, value string) {
+	var n [8]byte
+	for _, s := range []string{name, value} {
+		binary.BigEndian.PutUint64(n[:], uint64(len(s)))
+		h.Write(n[:])
+		io.WriteString(h, s)
+	}
+}
+
+// readBody reads the request's body, replacing it with one which replays the
+// same bytes (and then the same error, if reading failed).
+func readBody(r *http.Request) []byte {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil
+	}
+	buf, err := ioutil.ReadAll(r.Body)
+	var rest io.Reader = bytes.NewReader(nil)
+	if err != nil {
+		rest = &errReader{err}
+	}
+	r.Body = readCloser{io.MultiReader(bytes.NewReader(buf), rest), r.Body}
+	return buf
+}
+
+type errReader struct {
+	err error
+}
+
+func (e *errReader) Read([]byte) (int, error) {
+	return 0, e.err
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
@@ -0,0 +1,61 @@
+package web
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func fingerprint(method, url, body string, header, param string, fields ...string) string {
+	r, _ := http.NewRequest(method, url, strings.NewReader(body))
+	r.Header.Set("X-Tenant", header)
+	c := C{URLParams: map[string]string{"id": param}}
+	return c.Fingerprint(r, fields...)
+}
+
+func TestFingerprint(t *testing.T) {
+	t.Parallel()
+	all := []string{"method", "path", "query", "header:x-tenant", "param:id",
+		"body"}
+	base := fingerprint("POST", "/a?x=1&y=2", "body", "acme", "1", all...)
+
+	if fp := fingerprint("POST", "/a?y=2&x=1", "body", "acme", "1", all...); fp != base {
+		t.Error("Expected reordered query to have the same fingerprint")
+	}
+	different := []string{
+		fingerprint("PUT", "/a?x=1&y=2", "body", "acme", "1", all...),
+		fingerprint("POST", "/b?x=1&y=2", "body", "acme", "1", all...),
+		fingerprint("POST", "/a?x=1&y=3", "body", "acme", "1", all...),
+		fingerprint("POST", "/a?x=1&y=2", "body", "other", "1", all...),
+		fingerprint("POST", "/a?x=1&y=2", "body", "acme", "2", all...),
+		fingerprint("POST", "/a?x=1&y=2", "other", "acme", "1", all...),
+	}
+	for i, fp := range different {
+		if fp == base {
+			t.Errorf("Expected variation %d to change the fingerprint", i)
+		}
+	}
+
+	// Only the selected fields matter
+	a := fingerprint("GET", "/a", "one", "acme", "1", "method", "path")
+	b := fingerprint("GET", "/a", "two", "other", "2", "method", "path")
+	if a != b {
+		t.Error("Expected unselected fields not to affect the fingerprint")
+	}
+	// Field boundaries are unambiguous
+	if fingerprint("GET", "/ab", "", "", "", "path", "body") ==
+		fingerprint("GET", "/a", "b", "", "", "path", "body") {
+		t.Error("Expected different field splits to differ")
+	}
+}
+
+func TestFingerprintRestoresBody(t *testing.T) {
+	t.Parallel()
+	r, _ := http.NewRequest("POST", "/", strings.NewReader("hello"))
+	C{}.Fingerprint(r, "body")
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil || string(body) != "hello" {
+		t.Errorf("Expected body to be restored, got %q, %v", body, err)
+	}
+}
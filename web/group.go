@@ -0,0 +1,153 @@
+package web
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+/*
+Group is a set of routes which share a path prefix and a middleware stack. It is
+a thin view over a Mux: routes added to a group are added to the Mux itself, with
+the group's prefix prepended to their patterns and the group's middleware
+installed as route middleware (see Route.Use), so they are routed exactly like
+the Mux's other routes.
+
+	admin := m.Group("/admin")
+	admin.Use(RequireAdmin)
+	admin.Get("/users", listUsers)     // GET /admin/users
+	admin.Get("/users/:id", showUser)  // GET /admin/users/123
+
+Groups may be nested, in which case their prefixes are joined and their
+middleware stacked, outermost group first. Since group middleware is route
+middleware, it runs after the Mux's middleware stack and after routing.
+
+Since a group's middleware is installed on its routes as they are added, it
+must be added before any of the group's routes (including those of the groups
+nested within it) are. Use panics otherwise, rather than leave the routes
+already added without the middleware.
+*/
+type Group struct {
+	mux        *Mux
+	parent     *Group
+	prefix     string
+	middleware []MiddlewareType
+	// Whether any routes have been added to this group, or to the groups
+	// nested within it.
+	routed bool
+}
+
+// Group returns a new group of routes whose patterns begin with the given
+// prefix, which is an ordinary string pattern and may contain named parameters.
+// See Group.
+func (m *Mux) Group(prefix string) *Group {
+	return &Group{mux: m, prefix: strings.TrimSuffix(prefix, "/")}
+}
+
+// Group returns a new group nested within this one, whose patterns begin with
+// this group's prefix followed by the given one.
+func (g *Group) Group(prefix string) *Group {
+	return &Group{
+		mux:    g.mux,
+		parent: g,
+		prefix: g.prefix + strings.TrimSuffix(prefix, "/"),
+	}
+}
+
+// Use appends the given middleware to the group's middleware stack. It applies
+// to the routes subsequently added to the group and to the groups nested within
+// it. It panics if any routes have already been added to either.
+func (g *Group) Use(middleware MiddlewareType) {
+	if g.routed {
+		panic("web: group middleware must be added before the group's routes")
+	}
+	g.middleware = append(g.middleware, middleware)
+}
+
+// Handle adds a route for every HTTP method to the group. See Mux.Handle.
+func (g *Group) Handle(pattern PatternType, handler HandlerType) *Route {
+	return g.add(g.mux.Handle(g.pattern(pattern), handler))
+}
+
+// Connect adds a CONNECT route to the group. See Mux.Connect.
+func (g *Group) Connect(pattern PatternType, handler HandlerType) *Route {
+	return g.add(g.mux.Connect(g.pattern(pattern), handler))
+}
+
+// Delete adds a DELETE route to the group. See Mux.Delete.
+func (g *Group) Delete(pattern PatternType, handler HandlerType) *Route {
+	return g.add(g.mux.Delete(g.pattern(pattern), handler))
+}
+
+// Get adds a GET route to the group, which also serves HEAD requests. See
+// Mux.Get.
+func (g *Group) Get(pattern PatternType, handler HandlerType) *Route {
+	return g.add(g.mux.Get(g.pattern(pattern), handler))
+}
+
+// Head adds a HEAD route to the group. See Mux.Head.
+func (g *Group) Head(pattern PatternType, handler HandlerType) *Route {
+	return g.add(g.mux.Head(g.pattern(pattern), handler))
+}
+
+// Method adds a route for the given HTTP method to the group. See Mux.Method.
+func (g *Group) Method(method string, pattern PatternType, handler HandlerType) *Route {
+	return g.add(g.mux.Method(method, g.pattern(pattern), handler))
+}
+
+// Options adds an OPTIONS route to the group. See Mux.Options.
+func (g *Group) Options(pattern PatternType, handler HandlerType) *Route {
+	return g.add(g.mux.Options(g.pattern(pattern), handler))
+}
+
+// Patch adds a PATCH route to the group. See Mux.Patch.
+func (g *Group) Patch(pattern PatternType, handler HandlerType) *Route {
+	return g.add(g.mux.Patch(g.pattern(pattern), handler))
+}
+
+// Post adds a POST route to the group. See Mux.Post.
+func (g *Group) Post(pattern PatternType, handler HandlerType) *Route {
+	return g.add(g.mux.Post(g.pattern(pattern), handler))
+}
+
+// Put adds a PUT route to the group. See Mux.Put.
+func (g *Group) Put(pattern PatternType, handler HandlerType) *Route {
+	return g.add(g.mux.Put(g.pattern(pattern), handler))
+}
+
+// Trace adds a TRACE route to the group. See Mux.Trace.
+func (g *Group) Trace(pattern PatternType, handler HandlerType) *Route {
+	return g.add(g.mux.Trace(g.pattern(pattern), handler))
+}
+
+// add installs the middleware of the group, and of the groups it is nested
+// within, on a newly added route.
+func (g *Group) add(r *Route) *Route {
+	var groups []*Group
+	for ; g != nil; g = g.parent {
+		g.routed = true
+		groups = append(groups, g)
+	}
+	for i := len(groups) - 1; i >= 0; i-- {
+		for _, middleware := range groups[i].middleware {
+			r.Use(middleware)
+		}
+	}
+	return r
+}
+
+// pattern prepends the group's prefix to the given pattern. Regular expressions
+// are given the prefix literally, so its parameters are only bound for string
+// patterns.
+func (g *Group) pattern(pattern PatternType) PatternType {
+	switch p := pattern.(type) {
+	case string:
+		return g.prefix + p
+	case *regexp.Regexp:
+		re := strings.TrimPrefix(p.String(), "^")
+		return regexp.MustCompile("^" + regexp.QuoteMeta(g.prefix) + re)
+	default:
+		panic(fmt.Sprintf("web: group routes must use string or regular "+
+			"expression patterns, not %T", pattern))
+	}
+}
@@ -0,0 +1,104 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestGroup(t *testing.T) {
+	t.Parallel()
+	var trace []string
+	tracer := func(name string) func(*C, http.Handler) http.Handler {
+		return func(c *C, h http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				trace = append(trace, name)
+				h.ServeHTTP(w, r)
+			})
+		}
+	}
+	handler := func(name string) func(C, http.ResponseWriter, *http.Request) {
+		return func(c C, w http.ResponseWriter, r *http.Request) {
+			trace = append(trace, name+":"+c.URLParams["org"]+c.URLParams["id"])
+		}
+	}
+
+	m := New()
+	m.Use(tracer("global"))
+	admin := m.Group("/admin/")
+	admin.Use(tracer("admin"))
+	admin.Get("/users/:id", handler("user"))
+	admin.Get(regexp.MustCompile(`^/stats/(?P<id>\d+)$`), handler("stats"))
+	orgs := admin.Group("/orgs/:org")
+	orgs.Use(tracer("orgs"))
+	orgs.Post("/members", handler("members"))
+	m.Get("/users/:id", handler("public"))
+
+	tests := []struct {
+		method string
+		path   string
+		code   int
+		trace  string
+	}{
+		{"GET", "/admin/users/1", 200, "global admin user:1"},
+		{"GET", "/admin/stats/2", 200, "global admin stats:2"},
+		{"POST", "/admin/orgs/acme/members", 200, "global admin orgs members:acme"},
+		{"GET", "/admin/orgs/acme/members", 405, "global"},
+		{"GET", "/users/3", 200, "global public:3"},
+		{"GET", "/admin/nowhere", 404, "global"},
+	}
+	for _, test := range tests {
+		trace = nil
+		r, _ := http.NewRequest(test.method, test.path, nil)
+		w := httptest.NewRecorder()
+		m.ServeHTTP(w, r)
+		if w.Code != test.code {
+			t.Errorf("For %s %q, expected status %d, got %d",
+				test.method, test.path, test.code, w.Code)
+		}
+		if s := strings.Join(trace, " "); s != test.trace {
+			t.Errorf("For %s %q, expected trace %q, got %q",
+				test.method, test.path, test.trace, s)
+		}
+	}
+}
+
+func TestGroupLateUse(t *testing.T) {
+	t.Parallel()
+	deny := func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusForbidden)
+		})
+	}
+	panics := func(g *Group) (panicked bool) {
+		defer func() {
+			panicked = recover() != nil
+		}()
+		g.Use(deny)
+		return false
+	}
+
+	m := New()
+	admin := m.Group("/admin")
+	orgs := admin.Group("/orgs")
+	admin.Use(deny)
+	orgs.Get("/:org", http.NotFound)
+	if !panics(admin) {
+		t.Error("Expected middleware added after a nested group's routes to panic")
+	}
+	if !panics(orgs) {
+		t.Error("Expected middleware added after the group's routes to panic")
+	}
+	if panics(m.Group("/public")) {
+		t.Error("Expected middleware added to an empty group not to panic")
+	}
+
+	r, _ := http.NewRequest("GET", "/admin/orgs/acme", nil)
+	w := httptest.NewRecorder()
+	m.ServeHTTP(w, r)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Expected the group's middleware to apply, got %d", w.Code)
+	}
+}
@@ -0,0 +1,158 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// A Probe checks whether one of the things a server depends on (a database,
+// say, or another service) is ready for it to use, returning an error
+// describing the problem if it is not. It should return promptly once its
+// context is done.
+type Probe func(ctx context.Context) error
+
+// DefaultProbeTimeout is how long probes are given to complete if
+// HealthOptions.Timeout is zero.
+const DefaultProbeTimeout = 5 * time.Second
+
+// HealthOptions configures HealthCheck.
+type HealthOptions struct {
+	// Probes are the readiness probes to run, by name. If there are none,
+	// the health check is a liveness check, which always succeeds.
+	Probes map[string]Probe
+	// Timeout is how long the probes are given to complete, after which
+	// those which have not are counted as failed. If it is zero,
+	// DefaultProbeTimeout is used. The probes are also stopped early if the
+	// request's context is done.
+	Timeout time.Duration
+}
+
+// healthReport is the JSON response of a health check.
+type healthReport struct {
+	Status string            `json:"status"`
+	Probes map[string]string `json:"probes,omitempty"`
+}
+
+/*
+HealthCheck returns a Handler which reports the health of the server. Without
+probes, it serves as a liveness check, and always responds 200 OK. Otherwise, it
+serves as a readiness check: it runs every probe concurrently, each with a
+context derived from the request's which is done once opts.Timeout has passed,
+and responds 200 OK if all of them succeeded, or 503 Service Unavailable if any
+of them failed. Either way, the response is a JSON summary of each probe's
+result:
+
+	{"status":"unavailable","probes":{"cache":"ok","db":"dial tcp: connection refused"}}
+
+Probes which do not return by the time their context is done are counted as
+failed, and are not waited for.
+*/
+func HealthCheck(opts HealthOptions) Handler {
+	return HandlerFunc(func(c C, w http.ResponseWriter, r *http.Request) {
+		serveHealth(c, w, opts.Probes, opts.Timeout)
+	})
+}
+
+func serveHealth(c C, w http.ResponseWriter, probes map[string]Probe, timeout time.Duration) {
+	if timeout == 0 {
+		timeout = DefaultProbeTimeout
+	}
+	if len(probes) == 0 {
+		c.JSON(w, http.StatusOK, healthReport{Status: "ok"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Context(), timeout)
+	defer cancel()
+
+	type result struct {
+		name string
+		err  error
+	}
+	// The channel is buffered so that probes which outlive the request do
+	// not block forever.
+	results := make(chan result, len(probes))
+	for name, probe := range probes {
+		go func(name string, probe Probe) {
+			results <- result{name, probe(ctx)}
+		}(name, probe)
+	}
+
+	report := healthReport{Status: "ok", Probes: make(map[string]string, len(probes))}
+	for range probes {
+		var res result
+		select {
+		case res = <-results:
+		case <-ctx.Done():
+		}
+		if res.name == "" {
+			break
+		}
+		if res.err != nil {
+			report.Probes[res.name] = res.err.Error()
+			report.Status = "unavailable"
+		} else {
+			report.Probes[res.name] = "ok"
+		}
+	}
+	for name := range probes {
+		if _, ok := report.Probes[name]; !ok {
+			report.Probes[name] = ctx.Err().Error()
+			report.Status = "unavailable"
+		}
+	}
+
+	status := http.StatusOK
+	if report.Status != "ok" {
+		status = http.StatusServiceUnavailable
+	}
+	c.JSON(w, status, report)
+}
+
+// healthProbes holds the readiness probes registered on a Mux.
+type healthProbes struct {
+	lock   sync.Mutex
+	probes map[string]Probe
+}
+
+// ReadinessProbe registers a readiness probe under the given name, replacing any
+// probe already registered under it, to be run by the readiness route added by
+// HealthRoutes. Unlike routes, probes may be registered concurrently with active
+// requests.
+func (m *Mux) ReadinessProbe(name string, probe Probe) {
+	m.health.lock.Lock()
+	defer m.health.lock.Unlock()
+	if m.health.probes == nil {
+		m.health.probes = make(map[string]Probe)
+	}
+	m.health.probes[name] = probe
+}
+
+/*
+HealthRoutes adds GET routes for a liveness check at livenessPath and a
+readiness check at readinessPath (see HealthCheck), typically "/healthz" and
+"/readyz". The readiness check runs the probes registered with ReadinessProbe,
+including those registered after the routes are added, with a timeout of
+DefaultProbeTimeout. Either path may be empty, in which case its route is not
+added.
+*/
+func (m *Mux) HealthRoutes(livenessPath, readinessPath string) {
+	if livenessPath != "" {
+		m.Get(livenessPath, HealthCheck(HealthOptions{}))
+	}
+	if readinessPath != "" {
+		m.Get(readinessPath, HandlerFunc(m.serveReadiness))
+	}
+}
+
+func (m *Mux) serveReadiness(c C, w http.ResponseWriter, r *http.Request) {
+	m.health.lock.Lock()
+	probes := make(map[string]Probe, len(m.health.probes))
+	for name, probe := range m.health.probes {
+		probes[name] = probe
+	}
+	m.health.lock.Unlock()
+	serveHealth(c, w, probes, 0)
+}
@@ -0,0 +1,102 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHealthCheck(t *testing.T) {
+	t.Parallel()
+	ok := func(ctx context.Context) error { return nil }
+	failing := func(ctx context.Context) error { return errors.New("connection refused") }
+	hung := func(ctx context.Context) error {
+		<-ctx.Done()
+		time.Sleep(time.Second)
+		return nil
+	}
+
+	tests := []struct {
+		opts   HealthOptions
+		status int
+		body   string
+	}{
+		{HealthOptions{}, http.StatusOK, `{"status":"ok"}`},
+		{HealthOptions{Probes: map[string]Probe{"db": ok, "cache": ok}},
+			http.StatusOK, `{"status":"ok","probes":{"cache":"ok","db":"ok"}}`},
+		{HealthOptions{Probes: map[string]Probe{"db": failing, "cache": ok}},
+			http.StatusServiceUnavailable,
+			`{"status":"unavailable","probes":{"cache":"ok","db":"connection refused"}}`},
+		{HealthOptions{Probes: map[string]Probe{"db": hung, "cache": ok}, Timeout: 10 * time.Millisecond},
+			http.StatusServiceUnavailable,
+			`{"status":"unavailable","probes":{"cache":"ok","db":"context deadline exceeded"}}`},
+	}
+
+	for _, test := range tests {
+		h := HealthCheck(test.opts)
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("GET", "/readyz", nil)
+		start := time.Now()
+		h.ServeHTTPC(C{}, w, r)
+		if d := time.Since(start); d > 500*time.Millisecond {
+			t.Errorf("Health check took %v", d)
+		}
+		if w.Code != test.status {
+			t.Errorf("Expected status %d, got %d", test.status, w.Code)
+		}
+		if body := strings.TrimSpace(w.Body.String()); body != test.body {
+			t.Errorf("Expected body %s, got %s", test.body, body)
+		}
+	}
+}
+
+func TestHealthCheckContext(t *testing.T) {
+	t.Parallel()
+	type key struct{}
+	h := HealthCheck(HealthOptions{Probes: map[string]Probe{
+		"db": func(ctx context.Context) error {
+			if ctx.Value(key{}) != "yes" {
+				return errors.New("not derived from the request's context")
+			}
+			return nil
+		},
+	}})
+	ctx := context.WithValue(context.Background(), key{}, "yes")
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "/readyz", nil)
+	h.ServeHTTPC(C{}.WithContext(ctx), w, r)
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestHealthRoutes(t *testing.T) {
+	t.Parallel()
+	m := New()
+	m.HealthRoutes("/healthz", "/readyz")
+
+	check := func(path string, status int) {
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("GET", path, nil)
+		m.ServeHTTP(w, r)
+		if w.Code != status {
+			t.Errorf("For %s, expected status %d, got %d", path, status, w.Code)
+		}
+	}
+	check("/healthz", http.StatusOK)
+	check("/readyz", http.StatusOK)
+
+	// Probes registered after the routes are added are run too.
+	m.ReadinessProbe("db", func(ctx context.Context) error {
+		return errors.New("down")
+	})
+	check("/healthz", http.StatusOK)
+	check("/readyz", http.StatusServiceUnavailable)
+
+	m.ReadinessProbe("db", func(ctx context.Context) error { return nil })
+	check("/readyz", http.StatusOK)
+}
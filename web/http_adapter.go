@@ -0,0 +1,54 @@
+package web
+
+import (
+	"context"
+	"net/http"
+)
+
+// cContextKey is the key under which FromHTTPHandler stashes a C in the
+// request's context.
+type cContextKey struct{}
+
+/*
+FromHTTPHandler returns a Handler which serves requests with h, an ordinary
+net/http handler, having first stashed the request's C in the context of the
+request h is given (r.Context()), so that a handler converted with
+ToHTTPHandler can recover it further down. Together, the two allow net/http
+middleware which knows nothing of Goji to be placed between Goji handlers
+without losing the C threaded through them:
+
+	m.Get("/widgets/:id", web.FromHTTPHandler(
+		tracing.Middleware(web.ToHTTPHandler(web.HandlerFunc(showWidget)))))
+
+The C survives the round trip as follows. FromHTTPHandler stashes a copy of it
+in a context derived from the request's, and passes h a shallow copy of the
+request with that context (as r.WithContext does). The net/http middleware may
+replace the request, or its context, as it likes: so long as the context it
+passes on is derived from the one it was given, as it almost always is, the C
+comes along. ToHTTPHandler then calls its handler with the stashed C and the
+request it was given. Since a C is passed by value, but refers to its URLParams
+and Env maps, changes made to the entries of those maps on either side of the
+net/http middleware are seen by the other, while changes to the C itself (for
+instance with C.WithContext) are not. The context returned by C.Context is the
+one stashed, so values the net/http middleware adds to the request's context
+must be read from r.Context().
+*/
+func FromHTTPHandler(h http.Handler) Handler {
+	return HandlerFunc(func(c C, w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), cContextKey{}, c)
+		h.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+/*
+ToHTTPHandler returns an http.Handler which serves requests with h, giving it
+the C stashed in the request's context by FromHTTPHandler (see FromHTTPHandler
+for how the C is carried across net/http middleware). Requests without a
+stashed C are served with an empty one, as a Mux's ServeHTTP does.
+*/
+func ToHTTPHandler(h Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, _ := r.Context().Value(cContextKey{}).(C)
+		h.ServeHTTPC(c, w, r)
+	})
+}
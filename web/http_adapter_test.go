@@ -0,0 +1,59 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type spanKey struct{}
+
+// tracing stands in for net/http middleware which knows nothing of Goji.
+func tracing(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), spanKey{}, "span")
+		h.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func TestHTTPHandlerRoundTrip(t *testing.T) {
+	t.Parallel()
+	inner := HandlerFunc(func(c C, w http.ResponseWriter, r *http.Request) {
+		c.Env["inner"] = true
+		w.Write([]byte(c.URLParams["id"] + " " + c.Env["user"].(string) +
+			" " + r.Context().Value(spanKey{}).(string)))
+	})
+
+	var env map[interface{}]interface{}
+	m := New()
+	m.Use(func(c *C, h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			c.Env = map[interface{}]interface{}{"user": "carl"}
+			env = c.Env
+			h.ServeHTTP(w, r)
+		})
+	})
+	m.Get("/widgets/:id", FromHTTPHandler(tracing(ToHTTPHandler(inner))))
+
+	r, _ := http.NewRequest("GET", "/widgets/7", nil)
+	w := httptest.NewRecorder()
+	m.ServeHTTP(w, r)
+	if w.Body.String() != "7 carl span" {
+		t.Errorf("Expected the context to survive, got %q", w.Body.String())
+	}
+	if env["inner"] != true {
+		t.Error("Expected changes to Env to be seen outside")
+	}
+}
+
+func TestToHTTPHandlerWithoutC(t *testing.T) {
+	t.Parallel()
+	h := ToHTTPHandler(HandlerFunc(func(c C, w http.ResponseWriter, r *http.Request) {
+		if c.URLParams != nil || c.Env != nil {
+			t.Errorf("Expected an empty context, got %+v", c)
+		}
+	}))
+	r, _ := http.NewRequest("GET", "/", nil)
+	h.ServeHTTP(httptest.NewRecorder(), r)
+}
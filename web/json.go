@@ -0,0 +1,75 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// MaxBodyBytes is the largest request body, in bytes, that C.DecodeJSON will
+// read. It may be changed, but not concurrently with active requests.
+var MaxBodyBytes int64 = 1 << 20
+
+// ErrBodyTooLarge is returned by C.DecodeJSON when the request body is larger
+// than MaxBodyBytes, or than the limit of an http.MaxBytesReader wrapping it
+// (see middleware.LimitBody). Handlers will typically respond to it with a 413
+// Request Entity Too Large.
+var ErrBodyTooLarge = errors.New("web: request body too large")
+
+/*
+DecodeJSON reads the request body, which must be no larger than MaxBodyBytes,
+and unmarshals it into v. An oversized body results in ErrBodyTooLarge (and is
+not read past the limit), while a body which is not a single valid JSON value
+(including an empty one) results in an error describing what was wrong with it,
+which is suitable for returning to the client alongside a 400 Bad Request:
+
+	var req createWidget
+	if err := c.DecodeJSON(r, &req); err == web.ErrBodyTooLarge {
+		c.JSON(w, http.StatusRequestEntityTooLarge, nil)
+		return
+	} else if err != nil {
+		c.JSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+*/
+func (c C) DecodeJSON(r *http.Request, v interface{}) error {
+	if r.Body == nil {
+		return errors.New("web: malformed JSON body: empty body")
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, MaxBodyBytes+1))
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return ErrBodyTooLarge
+	} else if err != nil {
+		return err
+	}
+	if int64(len(body)) > MaxBodyBytes {
+		return ErrBodyTooLarge
+	}
+	if len(body) == 0 {
+		return errors.New("web: malformed JSON body: empty body")
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("web: malformed JSON body: %w", err)
+	}
+	return nil
+}
+
+// JSON responds to the request with the given status code and v encoded as
+// JSON, with a Content-Type of application/json. The value is encoded before
+// anything is written, so if it cannot be encoded, a plain 500 Internal Server
+// Error is sent instead, and the encoding error is recorded with C.AddError.
+func (c C) JSON(w http.ResponseWriter, status int, v interface{}) {
+	buf, err := json.Marshal(v)
+	if err != nil {
+		c.AddError(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError),
+			http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(append(buf, '\n'))
+}
@@ -0,0 +1,66 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDecodeJSON(t *testing.T) {
+	t.Parallel()
+	type widget struct {
+		Name string `json:"name"`
+	}
+	var w widget
+	r, _ := http.NewRequest("POST", "/", strings.NewReader(`{"name":"gear"}`))
+	if err := (C{}).DecodeJSON(r, &w); err != nil || w.Name != "gear" {
+		t.Errorf("Expected %q, got %q, %v", "gear", w.Name, err)
+	}
+
+	malformed := []string{"", "{", `{"name":1}`, `{} {}`}
+	for _, body := range malformed {
+		r, _ := http.NewRequest("POST", "/", strings.NewReader(body))
+		err := (C{}).DecodeJSON(r, &w)
+		if err == nil || !strings.HasPrefix(err.Error(), "web: malformed JSON body") {
+			t.Errorf("For %q, expected a malformed body error, got %v",
+				body, err)
+		}
+	}
+
+	big := `"` + strings.Repeat("x", int(MaxBodyBytes)) + `"`
+	r, _ = http.NewRequest("POST", "/", strings.NewReader(big))
+	var s string
+	if err := (C{}).DecodeJSON(r, &s); err != ErrBodyTooLarge {
+		t.Errorf("Expected %v, got %v", ErrBodyTooLarge, err)
+	}
+}
+
+func TestJSON(t *testing.T) {
+	t.Parallel()
+	w := httptest.NewRecorder()
+	(C{}).JSON(w, http.StatusCreated, map[string]int{"id": 7})
+	if w.Code != http.StatusCreated {
+		t.Errorf("Expected status %d, got %d", http.StatusCreated, w.Code)
+	}
+	if ct := w.HeaderMap.Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Expected Content-Type application/json, got %q", ct)
+	}
+	if body := w.Body.String(); body != "{\"id\":7}\n" {
+		t.Errorf("Expected body %q, got %q", "{\"id\":7}\n", body)
+	}
+
+	c := C{Env: map[interface{}]interface{}{}}
+	w = httptest.NewRecorder()
+	c.JSON(w, http.StatusOK, func() {})
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("Expected status %d, got %d", http.StatusInternalServerError,
+			w.Code)
+	}
+	var uerr *json.UnsupportedTypeError
+	if errs := c.Errors(); len(errs) != 1 || !errors.As(errs[0], &uerr) {
+		t.Errorf("Expected the encoding error to be recorded, got %v", errs)
+	}
+}
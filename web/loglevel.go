@@ -0,0 +1,42 @@
+package web
+
+// LogLevel controls how verbosely request loggers (such as the one in package
+// middleware) log requests to a given route. See Route.LogLevel.
+type LogLevel int
+
+const (
+	// LogNormal routes are logged as usual. This is the default.
+	LogNormal LogLevel = iota
+	// LogSilent routes are not logged at all. This is useful for
+	// high-traffic routes like health checks, which would otherwise drown
+	// out everything else.
+	LogSilent
+	// LogVerbose routes are logged in more detail than usual.
+	LogVerbose
+)
+
+// The key used to communicate the log level of the matched route to request
+// loggers. See C.LogLevel.
+const LogLevelKey = "goji.web.logLevel"
+
+// LogLevel sets the log level for requests to this route. It is up to request
+// loggers to respect it: see C.LogLevel.
+func (r *Route) LogLevel(level LogLevel) *Route {
+	r.rt.lock.Lock()
+	defer r.rt.lock.Unlock()
+	r.route.logLevel = level
+	r.rt.setMachine(nil)
+	return r
+}
+
+// LogLevel returns the log level of the route the request was matched to. Since
+// routing happens after the middleware stack has run, request loggers will
+// typically want to use OnRouted to find out the log level before deciding
+// what to log. Requests which have not (or not yet) been matched to a route are
+// logged at LogNormal.
+func (c C) LogLevel() LogLevel {
+	if level, ok := c.env()[LogLevelKey].(LogLevel); ok {
+		return level
+	}
+	return LogNormal
+}
@@ -0,0 +1,72 @@
+package web
+
+// memoKey is the type of the Env keys under which memoized values are stored,
+// so that they do not collide with ordinary Env entries.
+type memoKey struct {
+	key interface{}
+}
+
+// memoValue wraps a memoized value, so that nil values are remembered too.
+type memoValue struct {
+	v interface{}
+}
+
+// typedMemoKey is the type of the Env keys under which the values memoized by
+// Memoize are stored. Its type parameter keeps values of different types apart.
+type typedMemoKey[T any] struct {
+	key interface{}
+}
+
+/*
+Memoize returns the value computed by fn, calling fn only the first time
+Memoize is called with the given key during each request. This is useful for
+expensive computations whose results are needed by several middleware or
+handlers, like parsing authentication claims which are used both for
+authorization and for logging:
+
+	claims := c.Memoize("claims", func() interface{} {
+		return parseClaims(r)
+	}).(*Claims)
+
+The package-level Memoize does the same without the type assertion.
+
+Memoized values are stored in the context's Env (under keys which will not
+collide with any other Env entry), and so, like Env, they only last for the
+duration of the request, and are only shared with middleware that can see the
+same Env map. As with the rest of Env, it is illegal to call Memoize
+concurrently from multiple goroutines serving the same request.
+*/
+func (c *C) Memoize(key interface{}, fn func() interface{}) interface{} {
+	c.initEnv()
+	k := memoKey{key}
+	if v, ok := c.Env[k].(memoValue); ok {
+		return v.v
+	}
+	v := fn()
+	c.Env[k] = memoValue{v}
+	return v
+}
+
+/*
+Memoize is the typed form of C.Memoize: it returns the value computed by fn,
+calling fn only the first time Memoize is called with the given key and type
+during each request.
+
+	claims := web.Memoize(c, "claims", func() *Claims {
+		return parseClaims(r)
+	})
+
+Values memoized for different types are kept apart, even under the same key, as
+are the values memoized by C.Memoize.
+*/
+func Memoize[T any](c *C, key interface{}, fn func() T) T {
+	c.initEnv()
+	k := typedMemoKey[T]{key}
+	if v, ok := c.Env[k]; ok {
+		t, _ := v.(T)
+		return t
+	}
+	v := fn()
+	c.Env[k] = v
+	return v
+}
@@ -0,0 +1,92 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMemoize(t *testing.T) {
+	t.Parallel()
+	calls := 0
+	claims := func(c *C) string {
+		return c.Memoize("claims", func() interface{} {
+			calls++
+			return "alice"
+		}).(string)
+	}
+
+	m := New()
+	m.Use(func(c *C, h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if claims(c) != "alice" {
+				t.Error("Middleware got the wrong claims")
+			}
+			h.ServeHTTP(w, r)
+		})
+	})
+	m.Get("/", func(c C, w http.ResponseWriter, r *http.Request) {
+		if claims(&c) != "alice" || claims(&c) != "alice" {
+			t.Error("Handler got the wrong claims")
+		}
+		if _, ok := c.Env["claims"]; ok {
+			t.Error("Memoized value collided with an ordinary Env key")
+		}
+	})
+
+	for i := 1; i <= 2; i++ {
+		r, _ := http.NewRequest("GET", "/", nil)
+		m.ServeHTTP(httptest.NewRecorder(), r)
+		if calls != i {
+			t.Errorf("Expected %d calls after %d requests, got %d",
+				i, i, calls)
+		}
+	}
+
+	var c C
+	nils := 0
+	for i := 0; i < 2; i++ {
+		c.Memoize(1, func() interface{} {
+			nils++
+			return nil
+		})
+	}
+	if nils != 1 {
+		t.Errorf("Expected nil value to be memoized, got %d calls", nils)
+	}
+}
+
+func TestMemoizeTyped(t *testing.T) {
+	t.Parallel()
+	var c C
+	calls := 0
+	claims := func() string {
+		return Memoize(&c, "claims", func() string {
+			calls++
+			return "alice"
+		})
+	}
+	if claims() != "alice" || claims() != "alice" || calls != 1 {
+		t.Errorf("Expected one call, got %d", calls)
+	}
+
+	if n := Memoize(&c, "claims", func() int { return 1 }); n != 1 {
+		t.Errorf("Expected values of different types to be kept apart, got %d", n)
+	}
+	if v := c.Memoize("claims", func() interface{} { return 2 }); v != 2 {
+		t.Errorf("Expected untyped values to be kept apart, got %v", v)
+	}
+
+	nils := 0
+	for i := 0; i < 2; i++ {
+		if err := Memoize(&c, 1, func() error {
+			nils++
+			return nil
+		}); err != nil {
+			t.Errorf("Expected a nil error, got %v", err)
+		}
+	}
+	if nils != 1 {
+		t.Errorf("Expected nil value to be memoized, got %d calls", nils)
+	}
+}
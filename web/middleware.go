@@ -5,6 +5,7 @@ import (
 	"log"
 	"net/http"
 	"sync"
+	"sync/atomic"
 )
 
 // mLayer is a single middleware stack layer. It contains a canonicalized
@@ -14,14 +15,26 @@ type mLayer struct {
 	orig interface{}
 }
 
-// mStack is an entire middleware stack. It contains a slice of middleware
-// layers (outermost first) protected by a mutex, a cache of pre-built stack
-// instances, and a final routing function.
+// mStack is an entire middleware stack. Its layers are kept in an immutable
+// snapshot, which modifications replace wholesale rather than change in place
+// (copy-on-write), so that the stack can be modified concurrently with the
+// requests it serves: each request runs the layers of whichever snapshot was
+// current when it began. Modifications themselves are serialized by a mutex.
 type mStack struct {
 	lock   sync.Mutex
-	stack  []mLayer
-	pool   *cPool
+	snap   atomic.Pointer[mSnapshot]
 	router internalRouter
+	// Whether the stacks built lend a URLParams map to the requests they
+	// serve. See Mux.ReuseURLParams.
+	reuseParams bool
+}
+
+// mSnapshot is a version of a middleware stack: a slice of middleware layers
+// (outermost first), which is never modified, and a cache of stack instances
+// built from them.
+type mSnapshot struct {
+	stack []mLayer
+	pool  *cPool
 }
 
 type internalRouter interface {
@@ -39,36 +52,99 @@ type cStack struct {
 	C
 	m    http.Handler
 	pool *cPool
+	// The stack's URLParams map, if it has one (see Mux.ReuseURLParams),
+	// which is lent to each request it serves and cleared once the request
+	// is done, so that routes with parameters do not need to allocate a
+	// map on every request.
+	params map[string]string
 }
 
 func (s *cStack) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	s.C = C{}
+	s.C = C{params: s.params}
 	s.m.ServeHTTP(w, r)
 }
 func (s *cStack) ServeHTTPC(c C, w http.ResponseWriter, r *http.Request) {
 	s.C = c
+	s.C.params = s.params
 	s.m.ServeHTTP(w, r)
 }
 
+// urlParams returns c.URLParams, first setting it to a map with room for n
+// parameters if it is nil. Patterns (and others who bind parameters during
+// routing) use it so that the map comes from the middleware stack where one is
+// lent (see Mux.ReuseURLParams), rather than being allocated anew for each
+// request.
+func (c *C) urlParams(n int) map[string]string {
+	if c.URLParams == nil {
+		if c.params != nil {
+			c.URLParams = c.params
+		} else {
+			c.URLParams = make(map[string]string, n)
+		}
+	}
+	return c.URLParams
+}
+
 const unknownMiddleware = `Unknown middleware type %T. See http://godoc.org/github.com/zenazn/goji/web#MiddlewareType for a list of acceptable types.`
 
-func (m *mStack) appendLayer(fn interface{}) {
-	ml := mLayer{orig: fn}
+// parseMiddleware canonicalizes the given MiddlewareType, returning false if it
+// is of an unknown type.
+func parseMiddleware(fn interface{}) (func(*C, http.Handler) http.Handler, bool) {
 	switch f := fn.(type) {
 	case func(http.Handler) http.Handler:
-		ml.fn = func(c *C, h http.Handler) http.Handler {
+		return func(c *C, h http.Handler) http.Handler {
 			return f(h)
-		}
+		}, true
 	case func(*C, http.Handler) http.Handler:
-		ml.fn = f
+		return f, true
 	default:
+		return nil, false
+	}
+}
+
+// layers returns the current layers of the stack. The returned slice must not
+// be modified.
+func (m *mStack) layers() []mLayer {
+	if snap := m.snap.Load(); snap != nil {
+		return snap.stack
+	}
+	return nil
+}
+
+// setLayers makes the given layers, which must not be modified afterwards, the
+// stack's current layers. Requests which are already being served carry on
+// with the layers they started with.
+func (m *mStack) setLayers(stack []mLayer) {
+	m.snap.Store(&mSnapshot{stack: stack, pool: makeCPool()})
+}
+
+// modify calls fn with a copy of the stack's current layers, which fn may
+// change as it likes, and then makes the layers it returns current, unless it
+// returns an error.
+func (m *mStack) modify(fn func([]mLayer) ([]mLayer, error)) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	current := m.layers()
+	stack := make([]mLayer, len(current), len(current)+1)
+	copy(stack, current)
+	stack, err := fn(stack)
+	if err != nil {
+		return err
+	}
+	m.setLayers(stack)
+	return nil
+}
+
+func newLayer(fn interface{}) mLayer {
+	f, ok := parseMiddleware(fn)
+	if !ok {
 		log.Fatalf(unknownMiddleware, fn)
 	}
-	m.stack = append(m.stack, ml)
+	return mLayer{fn: f, orig: fn}
 }
 
-func (m *mStack) findLayer(l interface{}) int {
-	for i, middleware := range m.stack {
+func findLayer(stack []mLayer, l interface{}) int {
+	for i, middleware := range stack {
 		if funcEqual(l, middleware.orig) {
 			return i
 		}
@@ -77,78 +153,115 @@ func (m *mStack) findLayer(l interface{}) int {
 }
 
 func (m *mStack) invalidate() {
-	m.pool = makeCPool()
+	m.setLayers(m.layers())
 }
 
-func (m *mStack) newStack() *cStack {
-	cs := cStack{}
+func (m *mStack) newStack(stack []mLayer) *cStack {
+	var cs cStack
+	if m.reuseParams {
+		cs.params = make(map[string]string)
+	}
+	// The layers of a Mux's own stack (but not those of routes, or of
+	// Stacks) are guarded so that panics can be attributed to them. See
+	// PanicLayerFromC.
+	_, guard := m.router.(*router)
 	router := m.router
 
 	cs.m = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		router.route(&cs.C, w, r)
+		if !cs.C.Aborted() {
+			router.route(&cs.C, w, r)
+		}
 	})
-	for i := len(m.stack) - 1; i >= 0; i-- {
-		cs.m = m.stack[i].fn(&cs.C, cs.m)
+	if guard {
+		cs.m = layerGuard{c: &cs.C, h: cs.m, index: len(stack)}
+	}
+	for i := len(stack) - 1; i >= 0; i-- {
+		cs.m = stack[i].fn(&cs.C, cs.m)
+		if guard {
+			cs.m = layerGuard{&cs.C, cs.m, i, stack[i].orig}
+		}
+		if i > 0 {
+			cs.m = abortGuard{&cs.C, cs.m}
+		}
 	}
 
 	return &cs
 }
 
 func (m *mStack) alloc() *cStack {
-	p := m.pool
-	cs := p.alloc()
+	snap := m.snap.Load()
+	if snap == nil {
+		m.lock.Lock()
+		if snap = m.snap.Load(); snap == nil {
+			m.setLayers(nil)
+			snap = m.snap.Load()
+		}
+		m.lock.Unlock()
+	}
+	cs := snap.pool.alloc()
 	if cs == nil {
-		cs = m.newStack()
+		cs = m.newStack(snap.stack)
 	}
 
-	cs.pool = p
+	cs.pool = snap.pool
 	return cs
 }
 
 func (m *mStack) release(cs *cStack) {
 	cs.C = C{}
-	if cs.pool != m.pool {
+	for k := range cs.params {
+		delete(cs.params, k)
+	}
+	// Stacks built from layers which have since been replaced are left
+	// for the garbage collector.
+	if snap := m.snap.Load(); snap == nil || cs.pool != snap.pool {
 		return
 	}
-	cs.pool.release(cs)
+	// Once the stack is back in the pool it might be handed to another
+	// request at any time, so we mustn't touch it after releasing it.
+	p := cs.pool
 	cs.pool = nil
+	p.release(cs)
 }
 
 func (m *mStack) Use(middleware interface{}) {
-	m.lock.Lock()
-	defer m.lock.Unlock()
-	m.appendLayer(middleware)
-	m.invalidate()
+	m.modify(func(stack []mLayer) ([]mLayer, error) {
+		return append(stack, newLayer(middleware)), nil
+	})
 }
 
 func (m *mStack) Insert(middleware, before interface{}) error {
-	m.lock.Lock()
-	defer m.lock.Unlock()
-	i := m.findLayer(before)
-	if i < 0 {
-		return fmt.Errorf("web: unknown middleware %v", before)
-	}
-
-	m.appendLayer(middleware)
-	inserted := m.stack[len(m.stack)-1]
-	copy(m.stack[i+1:], m.stack[i:])
-	m.stack[i] = inserted
+	return m.modify(func(stack []mLayer) ([]mLayer, error) {
+		i := findLayer(stack, before)
+		if i < 0 {
+			return nil, fmt.Errorf("web: unknown middleware %v", before)
+		}
+		stack = append(stack, mLayer{})
+		copy(stack[i+1:], stack[i:])
+		stack[i] = newLayer(middleware)
+		return stack, nil
+	})
+}
 
-	m.invalidate()
-	return nil
+func (m *mStack) InsertAfter(middleware, after interface{}) error {
+	return m.modify(func(stack []mLayer) ([]mLayer, error) {
+		i := findLayer(stack, after)
+		if i < 0 {
+			return nil, fmt.Errorf("web: unknown middleware %v", after)
+		}
+		stack = append(stack, mLayer{})
+		copy(stack[i+2:], stack[i+1:])
+		stack[i+1] = newLayer(middleware)
+		return stack, nil
+	})
 }
 
 func (m *mStack) Abandon(middleware interface{}) error {
-	m.lock.Lock()
-	defer m.lock.Unlock()
-	i := m.findLayer(middleware)
-	if i < 0 {
-		return fmt.Errorf("web: unknown middleware %v", middleware)
-	}
-
-	copy(m.stack[i:], m.stack[i+1:])
-	m.stack = m.stack[:len(m.stack)-1 : len(m.stack)]
-
-	m.invalidate()
-	return nil
+	return m.modify(func(stack []mLayer) ([]mLayer, error) {
+		i := findLayer(stack, middleware)
+		if i < 0 {
+			return nil, fmt.Errorf("web: unknown middleware %v", middleware)
+		}
+		return append(stack[:i], stack[i+1:]...), nil
+	})
 }
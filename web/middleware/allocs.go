@@ -0,0 +1,77 @@
+package middleware
+
+import (
+	"math/rand"
+	"net/http"
+	"runtime"
+
+	"github.com/zenazn/goji/web"
+)
+
+// AllocStatsKey is the context key used to store allocation statistics
+// gathered by TrackAllocs.
+const AllocStatsKey = "goji.middleware.allocStats"
+
+// AllocStats is a (very rough) estimate of the heap allocations made while
+// serving a request.
+type AllocStats struct {
+	// Bytes is the number of bytes allocated on the heap.
+	Bytes uint64
+	// Objects is the number of heap objects allocated.
+	Objects uint64
+}
+
+/*
+TrackAllocs returns a middleware that, for the given fraction (between 0 and 1)
+of requests, records the heap allocations made while the rest of the stack
+served the request, storing them in the context as an AllocStats (see
+GetAllocStats). Logger prints them for the requests that were sampled. Place
+TrackAllocs after Logger, so that it runs inside the logger: the logger's own
+allocations are then excluded, and the statistics are ready by the time the
+logger prints the request's end:
+
+	m.Use(middleware.Logger)
+	m.Use(middleware.TrackAllocs(0.01))
+
+The statistics are computed from process-wide runtime.MemStats counters, and so
+include allocations made by every goroutine in the process during the request,
+not just the goroutine serving it. On a busy server the numbers for any single
+request are therefore only meaningful in aggregate: averaged across many
+samples, routes that allocate heavily will stand out. Reading the counters also
+briefly stops the world, which is why tracking is sampled; rates much above a
+percent or so are best reserved for development.
+*/
+func TrackAllocs(rate float64) func(*web.C, http.Handler) http.Handler {
+	return func(c *web.C, h http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			if rate <= 0 || rate < 1 && rand.Float64() >= rate {
+				h.ServeHTTP(w, r)
+				return
+			}
+
+			var before, after runtime.MemStats
+			runtime.ReadMemStats(&before)
+			h.ServeHTTP(w, r)
+			runtime.ReadMemStats(&after)
+
+			if c.Env == nil {
+				c.Env = make(map[interface{}]interface{})
+			}
+			c.Env[AllocStatsKey] = AllocStats{
+				Bytes:   after.TotalAlloc - before.TotalAlloc,
+				Objects: after.Mallocs - before.Mallocs,
+			}
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+// GetAllocStats returns the allocation statistics recorded by TrackAllocs, if
+// the given request was sampled.
+func GetAllocStats(c web.C) (AllocStats, bool) {
+	if c.Env == nil {
+		return AllocStats{}, false
+	}
+	stats, ok := c.Env[AllocStatsKey].(AllocStats)
+	return stats, ok
+}
@@ -0,0 +1,67 @@
+package middleware
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/zenazn/goji/web"
+)
+
+var allocSink []byte
+
+func testAllocs(rate float64) (AllocStats, bool) {
+	var stats AllocStats
+	var ok bool
+	m := web.New()
+	m.Use(func(c *web.C, h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			h.ServeHTTP(w, r)
+			stats, ok = GetAllocStats(*c)
+		})
+	})
+	m.Use(TrackAllocs(rate))
+	m.Get("/", func(w http.ResponseWriter, r *http.Request) {
+		allocSink = make([]byte, 1<<20)
+	})
+
+	r, _ := http.NewRequest("GET", "/", nil)
+	m.ServeHTTP(httptest.NewRecorder(), r)
+	return stats, ok
+}
+
+func TestTrackAllocs(t *testing.T) {
+	stats, ok := testAllocs(1)
+	if !ok {
+		t.Fatal("expected allocation stats to be recorded")
+	}
+	if stats.Bytes < 1<<20 || stats.Objects < 1 {
+		t.Errorf("expected at least 1 MiB in 1 object, got %+v", stats)
+	}
+
+	if _, ok := testAllocs(0); ok {
+		t.Error("expected no allocation stats when tracking is disabled")
+	}
+}
+
+func TestTrackAllocsLogger(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	m := web.New()
+	m.Use(Logger)
+	m.Use(TrackAllocs(1))
+	m.Get("/", func(w http.ResponseWriter, r *http.Request) {
+		allocSink = make([]byte, 1<<20)
+	})
+	r, _ := http.NewRequest("GET", "/", nil)
+	m.ServeHTTP(httptest.NewRecorder(), r)
+	if !strings.Contains(buf.String(), " allocs=") {
+		t.Errorf("Expected the logger to print allocations, got %q", buf.String())
+	}
+}
@@ -0,0 +1,206 @@
+package middleware
+
+import (
+	"bytes"
+	"compress/gzip"
+	"hash/fnv"
+	"io"
+	"log"
+	"math"
+	"math/rand"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/zenazn/goji/web"
+	"github.com/zenazn/goji/web/mutil"
+)
+
+// AuditRecord is a sampled request and response, as captured by AuditBody.
+// Bodies are gzip-compressed, and are truncated to the configured maximum size
+// before compression. A body whose content type was not eligible for capture is
+// recorded as nil.
+type AuditRecord struct {
+	Method       string
+	URL          string
+	Status       int
+	RequestBody  []byte
+	ResponseBody []byte
+}
+
+// AuditStore is a destination for records captured by AuditBody. Store is
+// called once per sampled request, after the handler has returned, and may be
+// called concurrently.
+type AuditStore interface {
+	Store(reqID string, record AuditRecord) error
+}
+
+// DefaultAuditContentTypes is the list of media types whose bodies AuditBody
+// captures if AuditOptions.ContentTypes is empty. Entries ending in "/" match
+// any subtype.
+var DefaultAuditContentTypes = []string{
+	"text/",
+	"application/json",
+	"application/xml",
+	"application/x-www-form-urlencoded",
+}
+
+// AuditOptions configures AuditBody.
+type AuditOptions struct {
+	// SampleRate is the fraction of requests, between 0 and 1, whose
+	// bodies are captured.
+	SampleRate float64
+	// MaxBodyBytes is the maximum number of bytes of each of the request
+	// and response bodies to capture. Defaults to 64 KiB.
+	MaxBodyBytes int
+	// ContentTypes is the list of media types eligible for capture. Media
+	// types with a "+json" or "+xml" suffix are always eligible. Defaults
+	// to DefaultAuditContentTypes.
+	ContentTypes []string
+}
+
+const defaultAuditMaxBodyBytes = 64 << 10
+
+// AuditBody returns a middleware that captures the request and response bodies
+// of a sample of requests, writing them to the given store.
+//
+// Requests are sampled by hashing their request ID (see RequestID), so that the
+// decision to sample a given request is consistent across every process that
+// sees it. Requests without a request ID are sampled at random. Requests that
+// are not sampled are passed through untouched.
+func AuditBody(store AuditStore, opts AuditOptions) func(*web.C, http.Handler) http.Handler {
+	if opts.MaxBodyBytes <= 0 {
+		opts.MaxBodyBytes = defaultAuditMaxBodyBytes
+	}
+	if len(opts.ContentTypes) == 0 {
+		opts.ContentTypes = DefaultAuditContentTypes
+	}
+
+	return func(c *web.C, h http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			reqID := GetReqID(*c)
+			if !auditSampled(reqID, opts.SampleRate) {
+				h.ServeHTTP(w, r)
+				return
+			}
+
+			var reqBuf []byte
+			if r.Body != nil && opts.auditable(r.Header.Get("Content-Type")) {
+				reqBuf = peekBody(r, opts.MaxBodyBytes)
+			}
+
+			respBuf := cappedBuffer{max: opts.MaxBodyBytes}
+			lw := mutil.WrapWriter(w)
+			lw.Tee(&respBuf)
+
+			h.ServeHTTP(lw, r)
+
+			record := AuditRecord{
+				Method:      r.Method,
+				URL:         r.URL.String(),
+				Status:      lw.Status(),
+				RequestBody: gzipBytes(reqBuf),
+			}
+			if record.Status == 0 {
+				record.Status = http.StatusOK
+			}
+			if opts.auditable(lw.Header().Get("Content-Type")) {
+				record.ResponseBody = gzipBytes(respBuf.Bytes())
+			}
+			if err := store.Store(reqID, record); err != nil {
+				log.Printf("middleware: unable to store audit record for %q: %v",
+					reqID, err)
+			}
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+func auditSampled(reqID string, rate float64) bool {
+	if rate <= 0 {
+		return false
+	} else if rate >= 1 {
+		return true
+	}
+	if reqID == "" {
+		return rand.Float64() < rate
+	}
+	h := fnv.New32a()
+	io.WriteString(h, reqID)
+	return float64(h.Sum32()) < rate*math.MaxUint32
+}
+
+func (o AuditOptions) auditable(contentType string) bool {
+	mt, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	if strings.HasSuffix(mt, "+json") || strings.HasSuffix(mt, "+xml") {
+		return true
+	}
+	for _, t := range o.ContentTypes {
+		if mt == t || strings.HasSuffix(t, "/") && strings.HasPrefix(mt, t) {
+			return true
+		}
+	}
+	return false
+}
+
+// peekBody reads up to n bytes of the request body, and replaces the body with
+// one that replays those bytes before continuing with the rest of the original.
+func peekBody(r *http.Request, n int) []byte {
+	buf := make([]byte, n)
+	n, err := io.ReadFull(r.Body, buf)
+	buf = buf[:n]
+	var rest io.Reader = r.Body
+	if err != nil {
+		// Either we've hit EOF or the body is broken. In both cases the
+		// handler might as well see the same error we did.
+		rest = &errReader{err}
+		if err == io.ErrUnexpectedEOF {
+			rest = &errReader{io.EOF}
+		}
+	}
+	r.Body = readCloser{io.MultiReader(bytes.NewReader(buf), rest), r.Body}
+	return buf
+}
+
+type errReader struct {
+	err error
+}
+
+func (e *errReader) Read([]byte) (int, error) {
+	return 0, e.err
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// cappedBuffer is a bytes.Buffer that silently discards writes beyond a given
+// size.
+type cappedBuffer struct {
+	bytes.Buffer
+	max int
+}
+
+func (b *cappedBuffer) Write(buf []byte) (int, error) {
+	if room := b.max - b.Len(); room < len(buf) {
+		b.Buffer.Write(buf[:room])
+	} else {
+		b.Buffer.Write(buf)
+	}
+	return len(buf), nil
+}
+
+func gzipBytes(buf []byte) []byte {
+	if buf == nil {
+		return nil
+	}
+	var out bytes.Buffer
+	gz := gzip.NewWriter(&out)
+	gz.Write(buf)
+	gz.Close()
+	return out.Bytes()
+}
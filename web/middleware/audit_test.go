@@ -0,0 +1,132 @@
+package middleware
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/zenazn/goji/web"
+)
+
+type memAuditStore struct {
+	sync.Mutex
+	records map[string]AuditRecord
+}
+
+func (m *memAuditStore) Store(reqID string, rec AuditRecord) error {
+	m.Lock()
+	defer m.Unlock()
+	m.records[reqID] = rec
+	return nil
+}
+
+func gunzip(t *testing.T, buf []byte) string {
+	gz, err := gzip.NewReader(bytes.NewReader(buf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := ioutil.ReadAll(gz)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(out)
+}
+
+func auditMux(store AuditStore, opts AuditOptions, ch chan http.ResponseWriter) *web.Mux {
+	m := web.New()
+	m.Use(func(c *web.C, h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			c.Env = map[interface{}]interface{}{
+				RequestIDKey: r.Header.Get("X-Test-ID"),
+			}
+			h.ServeHTTP(w, r)
+		})
+	})
+	m.Use(AuditBody(store, opts))
+	m.Post("/echo", func(w http.ResponseWriter, r *http.Request) {
+		ch <- w
+		body, _ := ioutil.ReadAll(r.Body)
+		w.Header().Set("Content-Type", r.Header.Get("Content-Type"))
+		w.WriteHeader(http.StatusCreated)
+		w.Write(body)
+	})
+	return m
+}
+
+func auditRequest(m *web.Mux, id, ctype, body string) *httptest.ResponseRecorder {
+	r, _ := http.NewRequest("POST", "/echo", strings.NewReader(body))
+	r.Header.Set("X-Test-ID", id)
+	r.Header.Set("Content-Type", ctype)
+	w := httptest.NewRecorder()
+	m.ServeHTTP(w, r)
+	return w
+}
+
+func TestAuditBodySampled(t *testing.T) {
+	t.Parallel()
+	store := &memAuditStore{records: make(map[string]AuditRecord)}
+	ch := make(chan http.ResponseWriter, 1)
+	m := auditMux(store, AuditOptions{SampleRate: 1, MaxBodyBytes: 8}, ch)
+
+	w := auditRequest(m, "a", "application/json", `{"hello":"world"}`)
+	<-ch
+	if w.Body.String() != `{"hello":"world"}` {
+		t.Errorf("handler saw body %q, expected the full body", w.Body.String())
+	}
+	rec, ok := store.records["a"]
+	if !ok {
+		t.Fatal("expected request to be stored")
+	}
+	if rec.Status != http.StatusCreated || rec.Method != "POST" ||
+		rec.URL != "/echo" {
+		t.Errorf("unexpected record %+v", rec)
+	}
+	if body := gunzip(t, rec.RequestBody); body != `{"hello"` {
+		t.Errorf("request body was %q, expected a truncated body", body)
+	}
+	if body := gunzip(t, rec.ResponseBody); body != `{"hello"` {
+		t.Errorf("response body was %q, expected a truncated body", body)
+	}
+
+	auditRequest(m, "b", "application/octet-stream", "\x00\x01")
+	<-ch
+	rec = store.records["b"]
+	if rec.RequestBody != nil || rec.ResponseBody != nil {
+		t.Errorf("binary bodies should not be captured: %+v", rec)
+	}
+}
+
+func TestAuditBodyNotSampled(t *testing.T) {
+	t.Parallel()
+	store := &memAuditStore{records: make(map[string]AuditRecord)}
+	ch := make(chan http.ResponseWriter, 1)
+	m := auditMux(store, AuditOptions{SampleRate: 0}, ch)
+
+	w := auditRequest(m, "a", "text/plain", "hello")
+	if _, ok := (<-ch).(*httptest.ResponseRecorder); !ok {
+		t.Error("expected unsampled request's writer to be untouched")
+	}
+	if w.Body.String() != "hello" {
+		t.Errorf("body was %q, expected %q", w.Body.String(), "hello")
+	}
+	if len(store.records) != 0 {
+		t.Errorf("expected nothing to be stored, got %v", store.records)
+	}
+}
+
+func TestAuditSampledConsistently(t *testing.T) {
+	t.Parallel()
+	for _, id := range []string{"a", "b", "host/abc-000001"} {
+		first := auditSampled(id, 0.5)
+		for i := 0; i < 10; i++ {
+			if auditSampled(id, 0.5) != first {
+				t.Errorf("sampling decision for %q is not stable", id)
+			}
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/zenazn/goji/web"
+)
+
+// Key to use when setting the authenticated principal. See GetPrincipal.
+const PrincipalKey = "goji.middleware.principal"
+
+// BasicAuth returns a middleware which requires requests to carry HTTP Basic
+// credentials accepted by verify. The verified user name becomes the request's
+// principal (see GetPrincipal). Requests without acceptable credentials are
+// answered with 401 Unauthorized and a WWW-Authenticate challenge for the given
+// realm, and are aborted (see web.C.Abort).
+//
+// Since verify is given the password in full, it is responsible for comparing
+// it in constant time; SecureCompare does so.
+func BasicAuth(realm string, verify func(user, pass string) bool) func(*web.C, http.Handler) http.Handler {
+	challenge := "Basic realm=" + strconv.Quote(realm)
+	return func(c *web.C, h http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			user, pass, ok := r.BasicAuth()
+			if !ok || !verify(user, pass) {
+				unauthorized(c, w, challenge)
+				return
+			}
+			setPrincipal(c, user)
+			h.ServeHTTP(w, r)
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+// BearerAuth returns a middleware which requires requests to carry a bearer token
+// (in an "Authorization: Bearer" header) accepted by verify, which returns the
+// principal the token identifies. That principal becomes the request's principal
+// (see GetPrincipal). Requests without an acceptable token are answered with 401
+// Unauthorized and a WWW-Authenticate challenge, and are aborted (see
+// web.C.Abort).
+//
+// As with BasicAuth, verify should compare tokens in constant time, for instance
+// with SecureCompare.
+func BearerAuth(verify func(token string) (principal interface{}, ok bool)) func(*web.C, http.Handler) http.Handler {
+	return func(c *web.C, h http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			auth := r.Header.Get("Authorization")
+			const scheme = "bearer "
+			if len(auth) <= len(scheme) || !strings.EqualFold(auth[:len(scheme)], scheme) {
+				unauthorized(c, w, "Bearer")
+				return
+			}
+			principal, ok := verify(strings.TrimSpace(auth[len(scheme):]))
+			if !ok {
+				unauthorized(c, w, `Bearer error="invalid_token"`)
+				return
+			}
+			setPrincipal(c, principal)
+			h.ServeHTTP(w, r)
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+// GetPrincipal returns the principal authenticated by BasicAuth (the user name,
+// as a string) or BearerAuth (whatever its verify function returned), or nil if
+// the request has not been authenticated.
+func GetPrincipal(c web.C) interface{} {
+	if c.Env == nil {
+		return nil
+	}
+	return c.Env[PrincipalKey]
+}
+
+// SecureCompare reports whether a and b are equal, taking time which depends
+// only on their lengths, not on their contents, so as not to reveal how much of
+// a guessed secret is correct.
+func SecureCompare(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+func setPrincipal(c *web.C, principal interface{}) {
+	if c.Env == nil {
+		c.Env = make(map[interface{}]interface{})
+	}
+	c.Env[PrincipalKey] = principal
+}
+
+func unauthorized(c *web.C, w http.ResponseWriter, challenge string) {
+	w.Header().Set("WWW-Authenticate", challenge)
+	http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+	c.Abort()
+}
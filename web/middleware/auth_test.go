@@ -0,0 +1,96 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/zenazn/goji/web"
+)
+
+func whoami(c web.C, w http.ResponseWriter, r *http.Request) {
+	fmt.Fprint(w, GetPrincipal(c))
+}
+
+func TestBasicAuth(t *testing.T) {
+	t.Parallel()
+	m := web.New()
+	m.Use(BasicAuth("admin area", func(user, pass string) bool {
+		return user == "carl" && SecureCompare(pass, "hunter2")
+	}))
+	m.Get("/", whoami)
+
+	tests := []struct {
+		user, pass string
+		code       int
+		body       string
+	}{
+		{"carl", "hunter2", 200, "carl"},
+		{"carl", "hunter3", 401, "Unauthorized\n"},
+		{"", "", 401, "Unauthorized\n"},
+	}
+	for _, test := range tests {
+		r, _ := http.NewRequest("GET", "/", nil)
+		if test.user != "" {
+			r.SetBasicAuth(test.user, test.pass)
+		}
+		w := httptest.NewRecorder()
+		m.ServeHTTP(w, r)
+		if w.Code != test.code || w.Body.String() != test.body {
+			t.Errorf("For %q/%q, expected %d %q, got %d %q", test.user,
+				test.pass, test.code, test.body, w.Code, w.Body.String())
+		}
+		challenge := w.Header().Get("WWW-Authenticate")
+		if test.code == 401 && challenge != `Basic realm="admin area"` {
+			t.Errorf("Expected a Basic challenge, got %q", challenge)
+		}
+	}
+}
+
+func TestBearerAuth(t *testing.T) {
+	t.Parallel()
+	type user struct{ name string }
+	m := web.New()
+	m.Get("/", whoami)
+	m.Get("/me", func(c web.C, w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, GetPrincipal(c).(*user).name)
+	}).Use(BearerAuth(func(token string) (interface{}, bool) {
+		if SecureCompare(token, "s3cret") {
+			return &user{"carl"}, true
+		}
+		return nil, false
+	}))
+
+	tests := []struct {
+		path, auth string
+		code       int
+		body       string
+		challenge  string
+	}{
+		{"/me", "Bearer s3cret", 200, "carl", ""},
+		{"/me", "bearer s3cret", 200, "carl", ""},
+		{"/me", "Bearer wrong", 401, "Unauthorized\n",
+			`Bearer error="invalid_token"`},
+		{"/me", "Basic Y2FybDpodW50ZXIy", 401, "Unauthorized\n", "Bearer"},
+		{"/me", "", 401, "Unauthorized\n", "Bearer"},
+		{"/", "", 200, "<nil>", ""},
+	}
+	for _, test := range tests {
+		r, _ := http.NewRequest("GET", test.path, nil)
+		if test.auth != "" {
+			r.Header.Set("Authorization", test.auth)
+		}
+		w := httptest.NewRecorder()
+		m.ServeHTTP(w, r)
+		if w.Code != test.code || w.Body.String() != test.body {
+			t.Errorf("For %q with %q, expected %d %q, got %d %q",
+				test.path, test.auth, test.code, test.body, w.Code,
+				w.Body.String())
+		}
+		if c := w.Header().Get("WWW-Authenticate"); c != test.challenge {
+			t.Errorf("For %q with %q, expected challenge %q, got %q",
+				test.path, test.auth, test.challenge, c)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"regexp"
+	"strings"
+)
+
+// PathPlaceholder is a heuristic used by PathBucket to recognize path segments
+// which are likely to be identifiers.
+type PathPlaceholder struct {
+	// Name is the name of the placeholder. Segments which match Pattern
+	// are replaced with ":" + Name.
+	Name string
+	// Pattern is matched against each path segment in its entirety.
+	Pattern *regexp.Regexp
+}
+
+// PathPlaceholders is the list of heuristics PathBucket consults, in order. It
+// may be changed to suit the application (for instance, to recognize usernames
+// or slugs), but it is illegal to do so concurrently with active requests.
+var PathPlaceholders = []PathPlaceholder{
+	{"num", regexp.MustCompile(`^[0-9]+$`)},
+	{"uuid", regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-` +
+		`[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)},
+	{"hex", regexp.MustCompile(`^[0-9a-fA-F]{16,}$`)},
+}
+
+// PathBucket normalizes a request path into a bucket of bounded cardinality,
+// by replacing each segment of the path which matches one of PathPlaceholders
+// with that placeholder, so that "/users/12345/posts" becomes
+// "/users/:num/posts". Logger uses this to report what kind of path requests
+// which didn't match any route were for, allowing unmatched traffic to be
+// analyzed without an unbounded number of distinct paths.
+func PathBucket(path string) string {
+	segments := strings.Split(path, "/")
+	for i, s := range segments {
+		for _, p := range PathPlaceholders {
+			if p.Pattern.MatchString(s) {
+				segments[i] = ":" + p.Name
+				break
+			}
+		}
+	}
+	return strings.Join(segments, "/")
+}
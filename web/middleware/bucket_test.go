@@ -0,0 +1,27 @@
+package middleware
+
+import (
+	"testing"
+)
+
+var pathBucketTests = []struct {
+	path, bucket string
+}{
+	{"/", "/"},
+	{"/users/12345", "/users/:num"},
+	{"/users/12345/posts/6", "/users/:num/posts/:num"},
+	{"/orders/6ba7b810-9dad-11d1-80b4-00c04fd430c8", "/orders/:uuid"},
+	{"/commits/0123456789abcdef0123", "/commits/:hex"},
+	{"/users/bob", "/users/bob"},
+	{"/v2/feed", "/v2/feed"},
+}
+
+func TestPathBucket(t *testing.T) {
+	t.Parallel()
+	for _, test := range pathBucketTests {
+		if b := PathBucket(test.path); b != test.bucket {
+			t.Errorf("For %q, expected %q, got %q", test.path,
+				test.bucket, b)
+		}
+	}
+}
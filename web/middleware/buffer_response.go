@@ -0,0 +1,207 @@
+package middleware
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"strconv"
+
+	"github.com/zenazn/goji/web"
+)
+
+/*
+BufferResponse returns a middleware which holds back the response (its status,
+headers and body) until the handler returns, so that a handler which fails
+midway can discard what it has written so far and send a clean error response
+in its place. To do so, the handler aborts the request (see web.C.Abort) and
+then writes its error response, which replaces everything written before the
+request was aborted, headers included:
+
+	if err := renderRows(w, rows); err != nil {
+		c.Abort()
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+If nothing is written once the request has been aborted, the response written
+before is sent as it is if it is an error (its status is 400 or above), as it is
+when middleware answers a request and then aborts it. Otherwise, the partial
+response is discarded, and a plain 500 Internal Server Error is sent instead.
+
+Responses whose body grows beyond maxBytes, or which are flushed (as streaming
+handlers do), are sent as they are written from then on, and can no longer be
+discarded. Buffered responses with a body are sent with a Content-Length.
+
+If the handler panics, the response it has written so far is discarded unsent
+(unless it could no longer be held back), so that middleware which recovers
+from the panic (such as Recoverer) can send its own response in its place.
+*/
+func BufferResponse(maxBytes int) func(*web.C, http.Handler) http.Handler {
+	return func(c *web.C, h http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			bw := &bufferWriter{
+				ResponseWriter: w,
+				c:              c,
+				max:            maxBytes,
+				orig:           w.Header().Clone(),
+				aborted:        c.Aborted(),
+			}
+			bw.header = bw.orig.Clone()
+			// If the handler panics, whatever it wrote is thrown away
+			// unsent, leaving the response to the middleware which
+			// recovers from the panic.
+			h.ServeHTTP(bw, r)
+			bw.finish()
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+// bufferWriter is an http.ResponseWriter that holds back the response until it
+// is finished, or until it can be held back no longer.
+type bufferWriter struct {
+	http.ResponseWriter
+	c   *web.C
+	max int
+	// The headers of the underlying writer when the request began, which
+	// a replaced response starts from.
+	orig http.Header
+
+	header    http.Header
+	status    int
+	buf       []byte
+	committed bool
+	hijacked  bool
+	// Whether the request was seen to be aborted, and whether anything
+	// was written after it was.
+	aborted  bool
+	replaced bool
+}
+
+// check discards the response written so far the first time the writer is used
+// after the request was aborted, unless it has already been sent.
+func (b *bufferWriter) check() {
+	if b.aborted || !b.c.Aborted() {
+		if b.aborted && !b.committed {
+			b.replaced = true
+		}
+		return
+	}
+	b.aborted = true
+	if b.committed {
+		return
+	}
+	b.header = b.orig.Clone()
+	b.status = 0
+	b.buf = nil
+	b.replaced = true
+}
+
+func (b *bufferWriter) Header() http.Header {
+	if b.committed {
+		return b.ResponseWriter.Header()
+	}
+	b.check()
+	return b.header
+}
+
+func (b *bufferWriter) WriteHeader(code int) {
+	if b.committed {
+		b.ResponseWriter.WriteHeader(code)
+		return
+	}
+	b.check()
+	if code < 200 {
+		b.ResponseWriter.WriteHeader(code)
+		return
+	}
+	if b.status == 0 {
+		b.status = code
+	}
+}
+
+func (b *bufferWriter) Write(buf []byte) (int, error) {
+	if b.committed {
+		return b.ResponseWriter.Write(buf)
+	}
+	b.check()
+	if b.status == 0 {
+		b.status = http.StatusOK
+	}
+	if len(b.buf)+len(buf) > b.max {
+		if err := b.commit(); err != nil {
+			return 0, err
+		}
+		return b.ResponseWriter.Write(buf)
+	}
+	b.buf = append(b.buf, buf...)
+	return len(buf), nil
+}
+
+// commit sends the held back header and body, and stops holding back the rest.
+func (b *bufferWriter) commit() error {
+	b.committed = true
+	h := b.ResponseWriter.Header()
+	for k := range h {
+		delete(h, k)
+	}
+	for k, v := range b.header {
+		h[k] = v
+	}
+	if b.status == 0 {
+		b.status = http.StatusOK
+	}
+	b.ResponseWriter.WriteHeader(b.status)
+	buf := b.buf
+	b.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	_, err := b.ResponseWriter.Write(buf)
+	return err
+}
+
+func (b *bufferWriter) Flush() {
+	if !b.committed {
+		b.check()
+		b.commit()
+	}
+	if f, ok := b.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (b *bufferWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hj, ok := b.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+	conn, rw, err := hj.Hijack()
+	if err == nil {
+		b.hijacked = true
+	}
+	return conn, rw, err
+}
+
+func (b *bufferWriter) Push(target string, opts *http.PushOptions) error {
+	return web.C{}.Push(b.ResponseWriter, target, opts)
+}
+
+// finish sends the held back response, or its replacement if the request was
+// aborted.
+func (b *bufferWriter) finish() {
+	if b.hijacked || b.committed {
+		return
+	}
+	if b.c.Aborted() && !b.replaced && b.status < 400 {
+		b.header = b.orig.Clone()
+		b.header.Set("Content-Type", "text/plain; charset=utf-8")
+		b.header.Set("X-Content-Type-Options", "nosniff")
+		b.status = http.StatusInternalServerError
+		b.buf = []byte(http.StatusText(http.StatusInternalServerError) + "\n")
+	}
+	if len(b.buf) != 0 && b.header.Get("Content-Length") == "" {
+		b.header.Set("Content-Length", strconv.Itoa(len(b.buf)))
+	}
+	b.commit()
+}
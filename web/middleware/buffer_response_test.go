@@ -0,0 +1,112 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/zenazn/goji/web"
+)
+
+func TestBufferResponse(t *testing.T) {
+	t.Parallel()
+	m := web.New()
+	m.Use(func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Request-Id", "1")
+			h.ServeHTTP(w, r)
+		})
+	})
+	m.Use(BufferResponse(16))
+	m.Get("/ok", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/csv")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("a,b\n"))
+		w.Write([]byte("c,d\n"))
+	})
+	m.Get("/fail", func(c web.C, w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", "attachment")
+		w.Write([]byte("a,b\n"))
+		c.Abort()
+		http.Error(w, "broken", http.StatusBadGateway)
+	})
+	m.Get("/silent", func(c web.C, w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("a,b\n"))
+		c.Abort()
+	})
+	m.Get("/large", func(c web.C, w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("0123456789"))
+		w.Write([]byte("0123456789"))
+		c.Abort()
+		http.Error(w, "too late", http.StatusInternalServerError)
+	})
+	m.Get("/flush", func(c web.C, w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("partial"))
+		w.(http.Flusher).Flush()
+		c.Abort()
+	})
+	m.Get("/denied", http.NotFound).Use(BasicAuth("x", func(u, p string) bool {
+		return false
+	}))
+
+	tests := []struct {
+		path        string
+		code        int
+		contentType string
+		body        string
+		length      string
+	}{
+		{"/ok", 201, "text/csv", "a,b\nc,d\n", "8"},
+		{"/fail", 502, "text/plain; charset=utf-8", "broken\n", "7"},
+		{"/silent", 500, "text/plain; charset=utf-8", "Internal Server Error\n", "22"},
+		{"/large", 200, "text/plain; charset=utf-8", "01234567890123456789too late\n", ""},
+		{"/flush", 200, "", "partial", ""},
+		{"/denied", 401, "text/plain; charset=utf-8", "Unauthorized\n", "13"},
+	}
+	for _, test := range tests {
+		r, _ := http.NewRequest("GET", test.path, nil)
+		w := httptest.NewRecorder()
+		m.ServeHTTP(w, r)
+		h := w.Header()
+		if w.Code != test.code || w.Body.String() != test.body ||
+			h.Get("Content-Type") != test.contentType ||
+			h.Get("Content-Length") != test.length {
+			t.Errorf("For %q, expected %d %q %q (%s), got %d %q %q (%s)",
+				test.path, test.code, test.contentType, test.body,
+				test.length, w.Code, h.Get("Content-Type"),
+				w.Body.String(), h.Get("Content-Length"))
+		}
+		if h.Get("X-Request-Id") != "1" {
+			t.Errorf("For %q, expected earlier headers to be kept", test.path)
+		}
+		if test.path == "/fail" && h.Get("Content-Disposition") != "" {
+			t.Errorf("Expected the failed response's headers to be discarded")
+		}
+	}
+}
+
+func TestBufferResponsePanic(t *testing.T) {
+	t.Parallel()
+	m := web.New()
+	m.Use(Recoverer)
+	m.Use(BufferResponse(64))
+	m.Get("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/csv")
+		w.Write([]byte("partial row data"))
+		panic("broken")
+	})
+
+	r, _ := http.NewRequest("GET", "/", nil)
+	w := httptest.NewRecorder()
+	m.ServeHTTP(w, r)
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("Expected 500, got %d", w.Code)
+	}
+	if body := w.Body.String(); body != "Internal Server Error\n" {
+		t.Errorf("Expected the partial body to be discarded, got %q", body)
+	}
+	if ct := w.Header().Get("Content-Type"); ct == "text/csv" {
+		t.Errorf("Expected the partial response's headers to be discarded")
+	}
+}
@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/zenazn/goji/web"
+)
+
+/*
+CountBytesRead is a middleware which counts the number of bytes of the request
+body read by the rest of the stack, making it available through
+web.C.BytesRead. This helps identify bandwidth-heavy endpoints, as well as
+clients which send more than they are expected to. Logger prints the count if it
+is available, so CountBytesRead should be placed inside of it.
+
+Only the bytes which are actually read are counted. If the handler stops
+reading partway through the body (or never reads it at all), the remainder is
+not counted, even though net/http may read and discard some of it once the
+handler has returned.
+*/
+func CountBytesRead(c *web.C, h http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		var n int64
+		if c.Env == nil {
+			c.Env = make(map[interface{}]interface{})
+		}
+		c.Env[web.BytesReadKey] = &n
+		if r.Body != nil {
+			r.Body = &countingReader{r.Body, &n}
+		}
+		h.ServeHTTP(w, r)
+	}
+	return http.HandlerFunc(fn)
+}
+
+type countingReader struct {
+	io.ReadCloser
+	n *int64
+}
+
+func (c *countingReader) Read(buf []byte) (int, error) {
+	n, err := c.ReadCloser.Read(buf)
+	atomic.AddInt64(c.n, int64(n))
+	return n, err
+}
@@ -0,0 +1,75 @@
+package middleware
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/zenazn/goji/web"
+)
+
+func TestCountBytesRead(t *testing.T) {
+	t.Parallel()
+	var read int64
+	m := web.New()
+	m.Use(func(c *web.C, h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			h.ServeHTTP(w, r)
+			read = c.BytesRead()
+		})
+	})
+	m.Use(CountBytesRead)
+	m.Post("/all", func(w http.ResponseWriter, r *http.Request) {
+		ioutil.ReadAll(r.Body)
+	})
+	m.Post("/some", func(w http.ResponseWriter, r *http.Request) {
+		io.ReadFull(r.Body, make([]byte, 4))
+	})
+	m.Post("/none", func(w http.ResponseWriter, r *http.Request) {})
+
+	tests := []struct {
+		path string
+		read int64
+	}{
+		{"/all", 11},
+		{"/some", 4},
+		{"/none", 0},
+	}
+	for _, test := range tests {
+		r, _ := http.NewRequest("POST", test.path, strings.NewReader("hello world"))
+		m.ServeHTTP(httptest.NewRecorder(), r)
+		if read != test.read {
+			t.Errorf("For %q, expected %d bytes read, got %d", test.path,
+				test.read, read)
+		}
+	}
+
+	if n := (web.C{}).BytesRead(); n != -1 {
+		t.Errorf("Expected -1 without a counter, got %d", n)
+	}
+}
+
+func TestLoggerBytesRead(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	m := web.New()
+	m.Use(Logger)
+	m.Use(CountBytesRead)
+	m.Post("/", func(w http.ResponseWriter, r *http.Request) {
+		ioutil.ReadAll(r.Body)
+	})
+
+	r, _ := http.NewRequest("POST", "/", strings.NewReader("hello"))
+	m.ServeHTTP(httptest.NewRecorder(), r)
+	if !strings.Contains(buf.String(), "read=5") {
+		t.Errorf("Expected bytes read in %q", buf.String())
+	}
+}
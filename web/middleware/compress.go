@@ -0,0 +1,243 @@
+package middleware
+
+import (
+	"bufio"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/zenazn/goji/web"
+)
+
+// Responses with bodies smaller than this many bytes are not worth compressing:
+// the saving, if there is any, does not make up for the cost.
+const compressMinSize = 1024
+
+// Prefixes of the media types of content which is already compressed, and
+// which would only grow if it were compressed again.
+var compressedTypes = []string{
+	"application/gzip",
+	"application/x-gzip",
+	"application/zip",
+	"application/zstd",
+	"audio/",
+	"font/woff",
+	"image/",
+	"video/",
+}
+
+func compressible(contentType string) bool {
+	contentType = strings.ToLower(contentType)
+	if strings.HasPrefix(contentType, "image/svg+xml") {
+		return true
+	}
+	for _, prefix := range compressedTypes {
+		if strings.HasPrefix(contentType, prefix) {
+			return false
+		}
+	}
+	return true
+}
+
+/*
+Compress returns a middleware that compresses response bodies for clients that
+accept it, using gzip if the request's Accept-Encoding allows it and deflate
+(that is, a zlib stream) otherwise. Every response is sent with a "Vary:
+Accept-Encoding" header.
+
+The start of each body is buffered until it is clear whether compression is
+worthwhile, so that bodies smaller than about a kilobyte, bodies whose
+Content-Type is that of already-compressed content (most images, audio and
+video, and archives), bodies which already have a Content-Encoding, and
+responses which must not have a body are all sent as they are. When a body is
+compressed, its Content-Length is removed, as it no longer describes the body
+actually sent.
+
+Flushing the response (as streaming handlers do) ends the buffering early and
+flushes the compressor as well as the underlying connection, so everything
+written so far reaches the client. Connections which the handler hijacks are
+left entirely alone. level is one of the compression levels from package
+compress/flate; Compress panics if it is invalid.
+*/
+func Compress(level int) func(http.Handler) http.Handler {
+	if _, err := gzip.NewWriterLevel(ioutil.Discard, level); err != nil {
+		panic("middleware: " + err.Error())
+	}
+
+	return func(h http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("Vary", "Accept-Encoding")
+			ae := r.Header.Get("Accept-Encoding")
+
+			cw := &lazyCompressWriter{ResponseWriter: w}
+			switch {
+			case acceptsEncoding(ae, "gzip"):
+				cw.encoding = "gzip"
+				cw.newWriter = func(w io.Writer) io.WriteCloser {
+					gw, _ := gzip.NewWriterLevel(w, level)
+					return gw
+				}
+			case acceptsEncoding(ae, "deflate"):
+				cw.encoding = "deflate"
+				cw.newWriter = func(w io.Writer) io.WriteCloser {
+					zw, _ := zlib.NewWriterLevel(w, level)
+					return zw
+				}
+			default:
+				h.ServeHTTP(w, r)
+				return
+			}
+			defer cw.Close()
+			h.ServeHTTP(cw, r)
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+// lazyCompressWriter is an http.ResponseWriter that holds back the response's
+// header and the start of its body until it has seen enough of the body to
+// decide whether to compress it. It must be closed once the handler has
+// returned.
+type lazyCompressWriter struct {
+	http.ResponseWriter
+	encoding  string
+	newWriter func(io.Writer) io.WriteCloser
+
+	wroteHeader bool
+	// While buffering, code is the status the handler sent, and buf is the
+	// body it has written since.
+	buffering bool
+	code      int
+	buf       []byte
+	cw        io.WriteCloser
+	hijacked  bool
+}
+
+func (c *lazyCompressWriter) WriteHeader(code int) {
+	if c.wroteHeader {
+		return
+	}
+	// Informational responses are passed along, and do not count as the
+	// response's header.
+	if code < 200 {
+		c.ResponseWriter.WriteHeader(code)
+		return
+	}
+	c.wroteHeader = true
+
+	h := c.Header()
+	if code == http.StatusNoContent || code == http.StatusNotModified ||
+		h.Get("Content-Encoding") != "" {
+		c.ResponseWriter.WriteHeader(code)
+		return
+	}
+	if ct := h.Get("Content-Type"); ct != "" && !compressible(ct) {
+		c.ResponseWriter.WriteHeader(code)
+		return
+	}
+	if n, err := strconv.Atoi(h.Get("Content-Length")); err == nil && n < compressMinSize {
+		c.ResponseWriter.WriteHeader(code)
+		return
+	}
+	c.buffering = true
+	c.code = code
+}
+
+func (c *lazyCompressWriter) Write(buf []byte) (int, error) {
+	if !c.wroteHeader {
+		c.WriteHeader(http.StatusOK)
+	}
+	if c.buffering {
+		c.buf = append(c.buf, buf...)
+		if len(c.buf) >= compressMinSize {
+			if err := c.commit(true); err != nil {
+				return 0, err
+			}
+		}
+		return len(buf), nil
+	}
+	if c.cw != nil {
+		return c.cw.Write(buf)
+	}
+	return c.ResponseWriter.Write(buf)
+}
+
+// commit ends buffering, sending the held back header followed by the buffered
+// body, which is compressed (as is everything written after it) if compress is
+// true and the body's type turns out to be compressible.
+func (c *lazyCompressWriter) commit(compress bool) error {
+	c.buffering = false
+	buf := c.buf
+	c.buf = nil
+
+	h := c.Header()
+	if h.Get("Content-Type") == "" && len(buf) != 0 {
+		// Sniff before compression scrambles the body.
+		h.Set("Content-Type", http.DetectContentType(buf))
+	}
+	if !compress || !compressible(h.Get("Content-Type")) {
+		c.ResponseWriter.WriteHeader(c.code)
+		_, err := c.ResponseWriter.Write(buf)
+		return err
+	}
+
+	h.Set("Content-Encoding", c.encoding)
+	h.Del("Content-Length")
+	c.ResponseWriter.WriteHeader(c.code)
+	c.cw = c.newWriter(c.ResponseWriter)
+	_, err := c.cw.Write(buf)
+	return err
+}
+
+func (c *lazyCompressWriter) Flush() {
+	if !c.wroteHeader {
+		c.WriteHeader(http.StatusOK)
+	}
+	if c.buffering {
+		c.commit(true)
+	}
+	if f, ok := c.cw.(interface {
+		Flush() error
+	}); ok {
+		f.Flush()
+	}
+	if f, ok := c.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (c *lazyCompressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hj, ok := c.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+	conn, rw, err := hj.Hijack()
+	if err == nil {
+		c.hijacked = true
+	}
+	return conn, rw, err
+}
+
+func (c *lazyCompressWriter) Push(target string, opts *http.PushOptions) error {
+	return web.C{}.Push(c.ResponseWriter, target, opts)
+}
+
+func (c *lazyCompressWriter) Close() error {
+	if c.hijacked {
+		return nil
+	}
+	if c.buffering {
+		// The whole body fit in the buffer, so it was too small to be
+		// worth compressing.
+		return c.commit(false)
+	}
+	if c.cw == nil {
+		return nil
+	}
+	return c.cw.Close()
+}
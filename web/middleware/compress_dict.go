@@ -0,0 +1,169 @@
+package middleware
+
+import (
+	"bufio"
+	"compress/zlib"
+	"crypto/sha256"
+	"encoding/base64"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/zenazn/goji/web"
+)
+
+/*
+CompressWithDictionary returns a middleware that deflate-compresses responses
+using a shared, preset dictionary. For APIs that return many small, similar
+responses (say, JSON documents that share most of their keys), a dictionary
+trained on representative payloads lets even tiny responses compress well,
+while ordinary compression gains little or nothing on them.
+
+A client can only decompress such a response if it already holds the same
+dictionary, so responses are only compressed for requests that advertise it.
+Following the Compression Dictionary Transport draft, clients do so by sending
+an Available-Dictionary header containing the SHA-256 hash of the dictionary
+as a structured field byte sequence (i.e., the base64-encoded hash surrounded by
+colons), alongside an Accept-Encoding header that accepts "deflate". Such
+responses are sent with a "deflate" Content-Encoding, and are zlib streams whose
+header names the dictionary by its Adler-32 checksum; they can be read with
+compress/zlib's NewReaderDict. Requests that do not advertise the dictionary
+(or that advertise a different one) receive uncompressed responses.
+
+The dictionary-based content codings from that draft are built on brotli and
+zstd, neither of which is available in the standard library, which is why this
+middleware uses zlib's preset dictionaries instead.
+
+Responses that already have a Content-Encoding, as well as responses which must
+not have a body, are never compressed. level is one of the compression levels
+from package compress/flate; CompressWithDictionary panics if it is invalid.
+*/
+func CompressWithDictionary(level int, dict []byte) func(http.Handler) http.Handler {
+	if _, err := zlib.NewWriterLevelDict(ioutil.Discard, level, dict); err != nil {
+		panic("middleware: " + err.Error())
+	}
+	sum := sha256.Sum256(dict)
+	hash := ":" + base64.StdEncoding.EncodeToString(sum[:]) + ":"
+
+	return func(h http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("Vary", "Accept-Encoding, Available-Dictionary")
+			if r.Header.Get("Available-Dictionary") != hash ||
+				!acceptsEncoding(r.Header.Get("Accept-Encoding"), "deflate") {
+				h.ServeHTTP(w, r)
+				return
+			}
+
+			cw := &compressWriter{
+				ResponseWriter: w,
+				encoding:       "deflate",
+				newWriter: func(w io.Writer) io.WriteCloser {
+					zw, _ := zlib.NewWriterLevelDict(w, level, dict)
+					return zw
+				},
+			}
+			defer cw.Close()
+			h.ServeHTTP(cw, r)
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+// acceptsEncoding reports whether the given Accept-Encoding header accepts the
+// given content coding.
+func acceptsEncoding(header, coding string) bool {
+	accepted := false
+	for _, part := range strings.Split(header, ",") {
+		params := strings.Split(part, ";")
+		name := strings.ToLower(strings.TrimSpace(params[0]))
+		if name != coding && name != "*" {
+			continue
+		}
+		q := 1.0
+		for _, p := range params[1:] {
+			p = strings.TrimSpace(p)
+			if strings.HasPrefix(p, "q=") {
+				q, _ = strconv.ParseFloat(p[2:], 64)
+			}
+		}
+		// An explicit mention of the coding trumps the wildcard.
+		if name == coding {
+			return q > 0
+		}
+		accepted = q > 0
+	}
+	return accepted
+}
+
+// compressWriter is an http.ResponseWriter that compresses the response body
+// (if it has one) with a writer obtained from newWriter. It must be closed once
+// the handler has returned.
+type compressWriter struct {
+	http.ResponseWriter
+	encoding    string
+	newWriter   func(io.Writer) io.WriteCloser
+	cw          io.WriteCloser
+	wroteHeader bool
+}
+
+func (c *compressWriter) WriteHeader(code int) {
+	if c.wroteHeader {
+		return
+	}
+	c.wroteHeader = true
+	h := c.Header()
+	if code >= 200 && code != http.StatusNoContent &&
+		code != http.StatusNotModified && h.Get("Content-Encoding") == "" {
+		h.Set("Content-Encoding", c.encoding)
+		h.Del("Content-Length")
+		c.cw = c.newWriter(c.ResponseWriter)
+	}
+	c.ResponseWriter.WriteHeader(code)
+}
+
+func (c *compressWriter) Write(buf []byte) (int, error) {
+	if !c.wroteHeader {
+		if c.Header().Get("Content-Type") == "" {
+			// Sniff before compression scrambles the body.
+			c.Header().Set("Content-Type", http.DetectContentType(buf))
+		}
+		c.WriteHeader(http.StatusOK)
+	}
+	if c.cw == nil {
+		return c.ResponseWriter.Write(buf)
+	}
+	return c.cw.Write(buf)
+}
+
+func (c *compressWriter) Flush() {
+	if f, ok := c.cw.(interface {
+		Flush() error
+	}); ok {
+		f.Flush()
+	}
+	if f, ok := c.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (c *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hj, ok := c.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+	return hj.Hijack()
+}
+
+func (c *compressWriter) Push(target string, opts *http.PushOptions) error {
+	return web.C{}.Push(c.ResponseWriter, target, opts)
+}
+
+func (c *compressWriter) Close() error {
+	if c.cw == nil {
+		return nil
+	}
+	return c.cw.Close()
+}
@@ -0,0 +1,99 @@
+package middleware
+
+import (
+	"bytes"
+	"compress/zlib"
+	"crypto/sha256"
+	"encoding/base64"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+var widgetDict = []byte(`{"id":"","name":"","color":"","price":,` +
+	`"tags":[],"created_at":"2014-01-01T00:00:00Z","in_stock":true}`)
+
+const widgetJSON = `{"id":"w-123","name":"sprocket","color":"red",` +
+	`"price":12,"tags":["small"],"created_at":"2014-06-05T12:34:56Z",` +
+	`"in_stock":true}`
+
+func dictHash(dict []byte) string {
+	sum := sha256.Sum256(dict)
+	return ":" + base64.StdEncoding.EncodeToString(sum[:]) + ":"
+}
+
+func dictRequest(h http.Handler, ae, dict string) *httptest.ResponseRecorder {
+	r, _ := http.NewRequest("GET", "/widget", nil)
+	if ae != "" {
+		r.Header.Set("Accept-Encoding", ae)
+	}
+	if dict != "" {
+		r.Header.Set("Available-Dictionary", dict)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestCompressWithDictionary(t *testing.T) {
+	t.Parallel()
+	h := CompressWithDictionary(9, widgetDict)(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(widgetJSON))
+		}))
+
+	w := dictRequest(h, "gzip, deflate", dictHash(widgetDict))
+	if ce := w.HeaderMap.Get("Content-Encoding"); ce != "deflate" {
+		t.Fatalf("Expected deflate encoding, got %q", ce)
+	}
+	compressed := w.Body.Bytes()
+	zr, err := zlib.NewReaderDict(bytes.NewReader(compressed), widgetDict)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := ioutil.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != widgetJSON {
+		t.Errorf("Decompressed body was %q", body)
+	}
+
+	var plain bytes.Buffer
+	zw, _ := zlib.NewWriterLevel(&plain, 9)
+	zw.Write([]byte(widgetJSON))
+	zw.Close()
+	if len(compressed) >= plain.Len() {
+		t.Errorf("Dictionary compression produced %d bytes, ordinary "+
+			"compression %d", len(compressed), plain.Len())
+	}
+}
+
+func TestCompressWithDictionaryFallback(t *testing.T) {
+	t.Parallel()
+	h := CompressWithDictionary(9, widgetDict)(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(widgetJSON))
+		}))
+
+	tests := []struct{ ae, dict string }{
+		{"deflate", ""},
+		{"deflate", dictHash([]byte("some other dictionary"))},
+		{"gzip", dictHash(widgetDict)},
+		{"*;q=1, deflate;q=0", dictHash(widgetDict)},
+	}
+	for _, test := range tests {
+		w := dictRequest(h, test.ae, test.dict)
+		if ce := w.HeaderMap.Get("Content-Encoding"); ce != "" {
+			t.Errorf("For %+v, expected no encoding, got %q", test, ce)
+		}
+		if w.Body.String() != widgetJSON {
+			t.Errorf("For %+v, body was %q", test, w.Body.String())
+		}
+		if vary := w.HeaderMap.Get("Vary"); vary == "" {
+			t.Errorf("For %+v, expected a Vary header", test)
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package middleware
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+var bigJSON = `[` + strings.Repeat(widgetJSON+",", 20) + widgetJSON + `]`
+
+func compressRequest(h http.Handler, ae string) *httptest.ResponseRecorder {
+	r, _ := http.NewRequest("GET", "/", nil)
+	if ae != "" {
+		r.Header.Set("Accept-Encoding", ae)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestCompress(t *testing.T) {
+	t.Parallel()
+	h := Compress(gzip.DefaultCompression)(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Content-Length", strconv.Itoa(len(bigJSON)))
+			w.Write([]byte(bigJSON))
+		}))
+
+	w := compressRequest(h, "gzip, deflate")
+	if ce := w.HeaderMap.Get("Content-Encoding"); ce != "gzip" {
+		t.Fatalf("Expected gzip encoding, got %q", ce)
+	}
+	if cl := w.HeaderMap.Get("Content-Length"); cl != "" {
+		t.Errorf("Expected Content-Length to be removed, got %q", cl)
+	}
+	if vary := w.HeaderMap.Get("Vary"); vary != "Accept-Encoding" {
+		t.Errorf("Expected Vary: Accept-Encoding, got %q", vary)
+	}
+	gr, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body, _ := ioutil.ReadAll(gr); string(body) != bigJSON {
+		t.Errorf("Expected the body to round-trip, got %q", body)
+	}
+
+	w = compressRequest(h, "deflate")
+	if ce := w.HeaderMap.Get("Content-Encoding"); ce != "deflate" {
+		t.Fatalf("Expected deflate encoding, got %q", ce)
+	}
+	zr, err := zlib.NewReader(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body, _ := ioutil.ReadAll(zr); string(body) != bigJSON {
+		t.Errorf("Expected the body to round-trip, got %q", body)
+	}
+
+	for _, ae := range []string{"", "identity", "gzip;q=0"} {
+		w = compressRequest(h, ae)
+		if ce := w.HeaderMap.Get("Content-Encoding"); ce != "" {
+			t.Errorf("For %q, expected no encoding, got %q", ae, ce)
+		}
+		if w.Body.String() != bigJSON {
+			t.Errorf("For %q, expected an uncompressed body", ae)
+		}
+	}
+}
+
+func TestCompressSkipped(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name string
+		fn   func(w http.ResponseWriter)
+		code int
+		body string
+	}{
+		{"small", func(w http.ResponseWriter) {
+			w.Write([]byte(widgetJSON))
+		}, 200, widgetJSON},
+		{"small, in pieces", func(w http.ResponseWriter) {
+			w.Write([]byte("hello, "))
+			w.Write([]byte("world"))
+		}, 200, "hello, world"},
+		{"short Content-Length", func(w http.ResponseWriter) {
+			w.Header().Set("Content-Length", "5")
+			w.WriteHeader(201)
+			w.Write([]byte("hello"))
+		}, 201, "hello"},
+		{"image", func(w http.ResponseWriter) {
+			w.Header().Set("Content-Type", "image/png")
+			w.Write([]byte(bigJSON))
+		}, 200, bigJSON},
+		{"sniffed image", func(w http.ResponseWriter) {
+			w.Write([]byte("GIF89a" + bigJSON))
+		}, 200, "GIF89a" + bigJSON},
+		{"already encoded", func(w http.ResponseWriter) {
+			w.Header().Set("Content-Encoding", "br")
+			w.Write([]byte(bigJSON))
+		}, 200, bigJSON},
+		{"no content", func(w http.ResponseWriter) {
+			w.WriteHeader(204)
+		}, 204, ""},
+	}
+	for _, test := range tests {
+		fn := test.fn
+		h := Compress(gzip.BestSpeed)(http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) { fn(w) }))
+		w := compressRequest(h, "gzip")
+		if ce := w.HeaderMap.Get("Content-Encoding"); ce == "gzip" {
+			t.Errorf("For %s, expected the response not to be compressed",
+				test.name)
+		}
+		if w.Code != test.code || w.Body.String() != test.body {
+			t.Errorf("For %s, expected %d %q, got %d %q", test.name,
+				test.code, test.body, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestCompressFlush(t *testing.T) {
+	t.Parallel()
+	w := httptest.NewRecorder()
+	var flushed int
+	h := Compress(gzip.DefaultCompression)(http.HandlerFunc(
+		func(cw http.ResponseWriter, r *http.Request) {
+			cw.Header().Set("Content-Type", "text/plain")
+			cw.Write([]byte("first chunk\n"))
+			cw.(http.Flusher).Flush()
+			flushed = w.Body.Len()
+			cw.Write([]byte("second chunk\n"))
+		}))
+	r, _ := http.NewRequest("GET", "/", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	h.ServeHTTP(w, r)
+
+	if ce := w.HeaderMap.Get("Content-Encoding"); ce != "gzip" {
+		t.Fatalf("Expected a flushed response to be compressed, got %q", ce)
+	}
+	if !w.Flushed {
+		t.Error("Expected the flush to reach the underlying writer")
+	}
+	gr, err := gzip.NewReader(bytes.NewReader(w.Body.Bytes()[:flushed]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, _ := bufio.NewReader(gr).ReadString('\n')
+	if first != "first chunk\n" {
+		t.Errorf("Expected the first chunk by the flush, got %q", first)
+	}
+	gr, _ = gzip.NewReader(w.Body)
+	if body, _ := ioutil.ReadAll(gr); string(body) != "first chunk\nsecond chunk\n" {
+		t.Errorf("Expected the whole body, got %q", body)
+	}
+}
+
+func TestCompressHijack(t *testing.T) {
+	t.Parallel()
+	h := Compress(gzip.DefaultCompression)(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			conn, rw, err := w.(http.Hijacker).Hijack()
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			defer conn.Close()
+			io.WriteString(rw, "HTTP/1.1 200 OK\r\nContent-Length: 6\r\n"+
+				"Connection: close\r\n\r\nraw ok")
+			rw.Flush()
+		}))
+	ts := httptest.NewServer(h)
+	defer ts.Close()
+
+	req, _ := http.NewRequest("GET", ts.URL, nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	res, err := http.DefaultTransport.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	body, _ := ioutil.ReadAll(res.Body)
+	if ce := res.Header.Get("Content-Encoding"); ce != "" || string(body) != "raw ok" {
+		t.Errorf("Expected the hijacked response untouched, got %q %q", ce, body)
+	}
+}
@@ -0,0 +1,190 @@
+package middleware
+
+import (
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CORSOptions configures a CORS middleware.
+type CORSOptions struct {
+	// AllowedOrigins is the list of origins which may make cross-origin
+	// requests. Each entry is either an exact origin (like
+	// "https://example.com"), or a pattern in which "*" matches any
+	// sequence of characters (like "https://*.example.com"). A lone "*"
+	// allows every origin, and may not be combined with
+	// AllowCredentials.
+	AllowedOrigins []string
+	// AllowedMethods is the list of methods allowed in cross-origin
+	// requests. Defaults to GET, HEAD, and POST.
+	AllowedMethods []string
+	// AllowedHeaders is the list of request headers allowed in
+	// cross-origin requests.
+	AllowedHeaders []string
+	// AllowCredentials allows cross-origin requests to include
+	// credentials (cookies and the like). Since this lets allowed origins
+	// read responses on behalf of the user, it may not be combined with
+	// an AllowedOrigins of "*", which would allow it for every website.
+	AllowCredentials bool
+	// MaxAge is how long the results of a preflight request may be cached
+	// by clients. If zero, no Access-Control-Max-Age header is sent.
+	MaxAge time.Duration
+	// CacheSize is the maximum number of origins whose allow or deny
+	// decision is remembered, so that the patterns in AllowedOrigins need
+	// not be rerun on every request. Defaults to 1024; if negative,
+	// decisions are not cached at all.
+	CacheSize int
+}
+
+const defaultCORSCacheSize = 1024
+
+/*
+CORS is a middleware which implements Cross-Origin Resource Sharing. Requests
+from allowed origins are answered with that origin reflected in the
+Access-Control-Allow-Origin header, and preflight requests from allowed origins
+are answered directly. Requests from other origins are passed through untouched,
+without any CORS headers.
+
+Since matching an origin against a long list of patterns can be relatively
+expensive, the decision for each origin is cached. The cache is bounded, and is
+cleared whenever the list of allowed origins is changed with SetAllowedOrigins,
+so caching never changes the outcome of a request.
+*/
+type CORS struct {
+	methods     string
+	headers     string
+	credentials bool
+	maxAge      string
+	cacheSize   int
+
+	mu        sync.RWMutex
+	anyOrigin bool
+	origins   []*regexp.Regexp
+	cache     map[string]bool
+	// Incremented each time the list of allowed origins is changed.
+	gen int
+}
+
+// NewCORS returns a new CORS middleware with the given options. It panics if the
+// options allow credentialed requests from every origin.
+func NewCORS(opts CORSOptions) *CORS {
+	methods := opts.AllowedMethods
+	if len(methods) == 0 {
+		methods = []string{"GET", "HEAD", "POST"}
+	}
+	c := &CORS{
+		methods:     strings.Join(methods, ", "),
+		headers:     strings.Join(opts.AllowedHeaders, ", "),
+		credentials: opts.AllowCredentials,
+		cacheSize:   opts.CacheSize,
+	}
+	if opts.MaxAge > 0 {
+		c.maxAge = strconv.Itoa(int(opts.MaxAge / time.Second))
+	}
+	if c.cacheSize == 0 {
+		c.cacheSize = defaultCORSCacheSize
+	}
+	c.SetAllowedOrigins(opts.AllowedOrigins...)
+	return c
+}
+
+// SetAllowedOrigins replaces the list of allowed origins (see
+// CORSOptions.AllowedOrigins), discarding any cached decisions. It is safe to
+// call concurrently with active requests. Like NewCORS, it panics if it would
+// allow credentialed requests from every origin.
+func (c *CORS) SetAllowedOrigins(origins ...string) {
+	anyOrigin := false
+	res := make([]*regexp.Regexp, 0, len(origins))
+	for _, o := range origins {
+		if o == "*" {
+			if c.credentials {
+				panic(`middleware: CORS may not allow credentials ` +
+					`from every origin ("*")`)
+			}
+			anyOrigin = true
+			continue
+		}
+		parts := strings.Split(o, "*")
+		for i, p := range parts {
+			parts[i] = regexp.QuoteMeta(p)
+		}
+		res = append(res, regexp.MustCompile(
+			"^"+strings.Join(parts, ".*")+"$"))
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.anyOrigin = anyOrigin
+	c.origins = res
+	c.cache = make(map[string]bool)
+	c.gen++
+}
+
+// allowed reports whether the given origin is allowed.
+func (c *CORS) allowed(origin string) bool {
+	c.mu.RLock()
+	allowed, ok := c.cache[origin]
+	anyOrigin, origins, gen := c.anyOrigin, c.origins, c.gen
+	c.mu.RUnlock()
+	if ok {
+		return allowed
+	}
+
+	allowed = anyOrigin
+	for i := 0; !allowed && i < len(origins); i++ {
+		allowed = origins[i].MatchString(origin)
+	}
+	if c.cacheSize < 0 {
+		return allowed
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// Don't cache a decision made against a list of origins that has since
+	// been replaced.
+	if c.gen == gen {
+		if len(c.cache) >= c.cacheSize {
+			c.cache = make(map[string]bool)
+		}
+		c.cache[origin] = allowed
+	}
+	return allowed
+}
+
+// Handler is the middleware function. Pass it to Use.
+func (c *CORS) Handler(h http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		w.Header().Add("Vary", "Origin")
+		if origin == "" || !c.allowed(origin) {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		hd := w.Header()
+		hd.Set("Access-Control-Allow-Origin", origin)
+		if c.credentials {
+			hd.Set("Access-Control-Allow-Credentials", "true")
+		}
+
+		if r.Method != "OPTIONS" ||
+			r.Header.Get("Access-Control-Request-Method") == "" {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		// Preflight request
+		hd.Set("Access-Control-Allow-Methods", c.methods)
+		if c.headers != "" {
+			hd.Set("Access-Control-Allow-Headers", c.headers)
+		}
+		if c.maxAge != "" {
+			hd.Set("Access-Control-Max-Age", c.maxAge)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+	return http.HandlerFunc(fn)
+}
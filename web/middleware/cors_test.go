@@ -0,0 +1,226 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/zenazn/goji/web"
+)
+
+func corsRequest(c *CORS, method, origin string, preflight bool) *httptest.ResponseRecorder {
+	h := c.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("handler"))
+	}))
+	r, _ := http.NewRequest(method, "/", nil)
+	r.Header.Set("Origin", origin)
+	if preflight {
+		r.Header.Set("Access-Control-Request-Method", "PUT")
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+var corsTests = []struct {
+	origin  string
+	allowed bool
+}{
+	{"https://example.com", true},
+	{"https://api.example.com", true},
+	{"https://a.b.example.com", true},
+	{"http://example.com", false},
+	{"https://example.com.evil.com", false},
+	{"https://evilexample.com", false},
+}
+
+func TestCORS(t *testing.T) {
+	t.Parallel()
+	c := NewCORS(CORSOptions{
+		AllowedOrigins:   []string{"https://example.com", "https://*.example.com"},
+		AllowedMethods:   []string{"GET", "PUT"},
+		AllowCredentials: true,
+	})
+	// Run each test twice, to exercise both cold and warm caches.
+	for i := 0; i < 2; i++ {
+		for _, test := range corsTests {
+			w := corsRequest(c, "GET", test.origin, false)
+			acao := w.HeaderMap.Get("Access-Control-Allow-Origin")
+			if test.allowed && acao != test.origin {
+				t.Errorf("Expected %q to be reflected, got %q",
+					test.origin, acao)
+			} else if !test.allowed && acao != "" {
+				t.Errorf("Expected %q to be denied, got %q",
+					test.origin, acao)
+			}
+			if w.Body.String() != "handler" {
+				t.Errorf("For %q, handler was not called", test.origin)
+			}
+		}
+	}
+
+	w := corsRequest(c, "OPTIONS", "https://example.com", true)
+	if w.Code != http.StatusNoContent || w.Body.Len() != 0 {
+		t.Errorf("Preflight returned %d %q", w.Code, w.Body.String())
+	}
+	if m := w.HeaderMap.Get("Access-Control-Allow-Methods"); m != "GET, PUT" {
+		t.Errorf("Preflight allowed methods %q", m)
+	}
+	if cr := w.HeaderMap.Get("Access-Control-Allow-Credentials"); cr != "true" {
+		t.Errorf("Preflight allowed credentials %q", cr)
+	}
+}
+
+func TestCORSInvalidation(t *testing.T) {
+	t.Parallel()
+	c := NewCORS(CORSOptions{AllowedOrigins: []string{"https://a.com"}})
+	allowed := func(origin string) bool {
+		w := corsRequest(c, "GET", origin, false)
+		return w.HeaderMap.Get("Access-Control-Allow-Origin") != ""
+	}
+	if !allowed("https://a.com") || allowed("https://b.com") {
+		t.Fatal("Unexpected initial decisions")
+	}
+
+	c.SetAllowedOrigins("https://b.com")
+	if allowed("https://a.com") || !allowed("https://b.com") {
+		t.Error("Cached decisions survived a change of allowed origins")
+	}
+
+	c.SetAllowedOrigins("*")
+	if !allowed("https://a.com") || !allowed("https://c.com") {
+		t.Error("Expected every origin to be allowed")
+	}
+}
+
+func TestCORSAnyOriginCredentials(t *testing.T) {
+	t.Parallel()
+	panics := func(f func()) (panicked bool) {
+		defer func() {
+			panicked = recover() != nil
+		}()
+		f()
+		return false
+	}
+
+	if !panics(func() {
+		NewCORS(CORSOptions{
+			AllowedOrigins:   []string{"https://a.com", "*"},
+			AllowCredentials: true,
+		})
+	}) {
+		t.Error("Expected credentials from every origin to be refused")
+	}
+
+	c := NewCORS(CORSOptions{
+		AllowedOrigins:   []string{"https://a.com"},
+		AllowCredentials: true,
+	})
+	if !panics(func() { c.SetAllowedOrigins("*") }) {
+		t.Error("Expected credentials from every origin to be refused")
+	}
+	w := corsRequest(c, "GET", "https://evil.com", false)
+	if acao := w.HeaderMap.Get("Access-Control-Allow-Origin"); acao != "" {
+		t.Errorf("Expected a refused change to have no effect, got %q", acao)
+	}
+}
+
+func TestCORSCacheBounded(t *testing.T) {
+	t.Parallel()
+	c := NewCORS(CORSOptions{
+		AllowedOrigins: []string{"https://*.example.com"},
+		CacheSize:      4,
+	})
+	for i := 0; i < 10; i++ {
+		origin := fmt.Sprintf("https://%d.example.com", i)
+		if !c.allowed(origin) || c.allowed(origin+".evil.com") {
+			t.Errorf("Wrong decision for %q", origin)
+		}
+		if n := len(c.cache); n > 4 {
+			t.Fatalf("Cache grew to %d entries", n)
+		}
+	}
+}
+
+func benchmarkCORS(b *testing.B, cacheSize int) {
+	origins := make([]string, 50)
+	for i := range origins {
+		origins[i] = fmt.Sprintf("https://*.customer%d.example.com", i)
+	}
+	c := NewCORS(CORSOptions{AllowedOrigins: origins, CacheSize: cacheSize})
+	h := c.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	r, _ := http.NewRequest("GET", "/", nil)
+	r.Header.Set("Origin", "https://app.customer49.example.com")
+	w := httptest.NewRecorder()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		w.HeaderMap = make(http.Header)
+		h.ServeHTTP(w, r)
+	}
+}
+
+func BenchmarkCORSCached(b *testing.B) {
+	benchmarkCORS(b, 0)
+}
+
+func BenchmarkCORSUncached(b *testing.B) {
+	benchmarkCORS(b, -1)
+}
+
+func TestRouteCORS(t *testing.T) {
+	t.Parallel()
+	m := web.New()
+	m.Use(NewCORS(CORSOptions{
+		AllowedOrigins: []string{"https://app.example.com"},
+	}).Handler)
+	ok := func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}
+	m.Get("/api/private", ok)
+	m.Get("/api/widget", ok).CORS(NewCORS(CORSOptions{
+		AllowedOrigins: []string{"*"},
+		AllowedMethods: []string{"GET"},
+	}))
+	m.Get("/api/partner", ok).CORS(NewCORS(CORSOptions{
+		AllowedOrigins: []string{"https://partner.com"},
+		AllowedMethods: []string{"GET"},
+	}))
+
+	tests := []struct {
+		path, origin string
+		preflight    bool
+		code         int
+		acao         string
+	}{
+		{"/api/private", "https://app.example.com", false, 200, "https://app.example.com"},
+		{"/api/private", "https://evil.com", false, 200, ""},
+		{"/api/private", "https://evil.com", true, 200, ""},
+		{"/api/widget", "https://evil.com", false, 200, "https://evil.com"},
+		{"/api/widget", "https://evil.com", true, 204, "https://evil.com"},
+		{"/api/widget", "https://app.example.com", true, 204, "https://app.example.com"},
+		{"/api/partner", "https://partner.com", true, 204, "https://partner.com"},
+		{"/api/partner", "https://evil.com", true, 403, ""},
+		{"/api/partner", "https://evil.com", false, 200, ""},
+	}
+	for _, test := range tests {
+		method := "GET"
+		if test.preflight {
+			method = "OPTIONS"
+		}
+		r, _ := http.NewRequest(method, test.path, nil)
+		r.Header.Set("Origin", test.origin)
+		if test.preflight {
+			r.Header.Set("Access-Control-Request-Method", "GET")
+		}
+		w := httptest.NewRecorder()
+		m.ServeHTTP(w, r)
+		acao := w.HeaderMap.Get("Access-Control-Allow-Origin")
+		if w.Code != test.code || acao != test.acao {
+			t.Errorf("For %s %s from %s, expected %d %q, got %d %q",
+				method, test.path, test.origin, test.code, test.acao,
+				w.Code, acao)
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package middleware
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zenazn/goji/web"
+)
+
+/*
+ETag returns a middleware which gives the successful responses to GET requests
+an entity tag: a hash of the response body, sent as the response's ETag header,
+which the client may present in an If-None-Match header to revalidate its cached
+copy of the response. When it does, and the tag still matches, a 304 Not Modified
+is sent in place of the body. The tag is weak (W/"...") if weak is true, and
+strong otherwise; weak tags are appropriate when the body is not byte-for-byte
+stable, for instance because a later middleware compresses it.
+
+To compute the tag, the response body is held back until the handler returns.
+Bodies larger than maxSize bytes, responses which are flushed (as streaming
+handlers do) before they are complete, responses whose status is not 200 OK and
+responses whose handler set an ETag of its own are all sent as they are written,
+without a tag. If the handler set a Last-Modified header, requests whose
+If-Modified-Since is no earlier than it (and which have no If-None-Match) are
+also answered with a 304 Not Modified.
+
+If the handler panics, a response still being held back is discarded unsent and
+untagged, so that middleware which recovers from the panic (such as Recoverer)
+can send its own response in its place.
+*/
+func ETag(weak bool, maxSize int) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != "GET" {
+				h.ServeHTTP(w, r)
+				return
+			}
+			ew := &etagWriter{
+				ResponseWriter: w,
+				r:              r,
+				weak:           weak,
+				max:            maxSize,
+			}
+			// If the handler panics, the held back response is
+			// neither tagged nor sent, leaving the response to the
+			// middleware which recovers from the panic.
+			h.ServeHTTP(ew, r)
+			ew.finish()
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+// etagWriter is an http.ResponseWriter that holds back the header and body of a
+// 200 OK response until the handler is done with it, so that they can be sent
+// with an ETag (or replaced with a 304 Not Modified). It must be finished once
+// the handler has returned.
+type etagWriter struct {
+	http.ResponseWriter
+	r    *http.Request
+	weak bool
+	max  int
+
+	wroteHeader bool
+	buffering   bool
+	buf         []byte
+	hijacked    bool
+}
+
+func (e *etagWriter) WriteHeader(code int) {
+	if e.wroteHeader {
+		return
+	}
+	if code < 200 {
+		e.ResponseWriter.WriteHeader(code)
+		return
+	}
+	e.wroteHeader = true
+	h := e.Header()
+	if code != http.StatusOK || h.Get("ETag") != "" {
+		e.ResponseWriter.WriteHeader(code)
+		return
+	}
+	if n, err := strconv.Atoi(h.Get("Content-Length")); err == nil && n > e.max {
+		e.ResponseWriter.WriteHeader(code)
+		return
+	}
+	e.buffering = true
+}
+
+func (e *etagWriter) Write(buf []byte) (int, error) {
+	if !e.wroteHeader {
+		e.WriteHeader(http.StatusOK)
+	}
+	if !e.buffering {
+		return e.ResponseWriter.Write(buf)
+	}
+	if len(e.buf)+len(buf) > e.max {
+		if err := e.commit(); err != nil {
+			return 0, err
+		}
+		return e.ResponseWriter.Write(buf)
+	}
+	e.buf = append(e.buf, buf...)
+	return len(buf), nil
+}
+
+// commit ends buffering, sending the held back header and body untagged.
+func (e *etagWriter) commit() error {
+	e.buffering = false
+	buf := e.buf
+	e.buf = nil
+	e.ResponseWriter.WriteHeader(http.StatusOK)
+	_, err := e.ResponseWriter.Write(buf)
+	return err
+}
+
+func (e *etagWriter) Flush() {
+	if !e.wroteHeader {
+		e.WriteHeader(http.StatusOK)
+	}
+	if e.buffering {
+		e.commit()
+	}
+	if f, ok := e.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (e *etagWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hj, ok := e.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+	conn, rw, err := hj.Hijack()
+	if err == nil {
+		e.hijacked = true
+	}
+	return conn, rw, err
+}
+
+func (e *etagWriter) Push(target string, opts *http.PushOptions) error {
+	return web.C{}.Push(e.ResponseWriter, target, opts)
+}
+
+// finish sends the held back response, if there is one, along with its ETag.
+func (e *etagWriter) finish() {
+	if e.hijacked || (e.wroteHeader && !e.buffering) {
+		return
+	}
+	if !e.wroteHeader {
+		// The handler wrote nothing at all, which net/http would have
+		// sent as an empty 200 OK.
+		e.WriteHeader(http.StatusOK)
+		if !e.buffering {
+			return
+		}
+	}
+
+	sum := sha256.Sum256(e.buf)
+	tag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	if e.weak {
+		tag = "W/" + tag
+	}
+	h := e.Header()
+	h.Set("ETag", tag)
+
+	if notModified(e.r, tag, h.Get("Last-Modified")) {
+		h.Del("Content-Type")
+		h.Del("Content-Length")
+		e.ResponseWriter.WriteHeader(http.StatusNotModified)
+		return
+	}
+	if h.Get("Content-Type") == "" && len(e.buf) != 0 {
+		h.Set("Content-Type", http.DetectContentType(e.buf))
+	}
+	h.Set("Content-Length", strconv.Itoa(len(e.buf)))
+	e.ResponseWriter.WriteHeader(http.StatusOK)
+	e.ResponseWriter.Write(e.buf)
+}
+
+// notModified reports whether the request's preconditions show that the client
+// already has the response with the given ETag and Last-Modified.
+func notModified(r *http.Request, tag, lastModified string) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		for _, t := range strings.Split(inm, ",") {
+			t = strings.TrimSpace(t)
+			// If-None-Match uses the weak comparison function
+			if t == "*" || strings.TrimPrefix(t, "W/") == strings.TrimPrefix(tag, "W/") {
+				return true
+			}
+		}
+		return false
+	}
+
+	ims, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil {
+		return false
+	}
+	lm, err := http.ParseTime(lastModified)
+	if err != nil {
+		return false
+	}
+	return !lm.Truncate(time.Second).After(ims)
+}
@@ -0,0 +1,177 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zenazn/goji/web"
+)
+
+func etagRequest(h http.Handler, method string, headers map[string]string) *httptest.ResponseRecorder {
+	r, _ := http.NewRequest(method, "/", nil)
+	for k, v := range headers {
+		r.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestETag(t *testing.T) {
+	t.Parallel()
+	widget := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(widgetJSON))
+	})
+	h := ETag(false, 1024)(widget)
+
+	w := etagRequest(h, "GET", nil)
+	tag := w.HeaderMap.Get("ETag")
+	if w.Code != http.StatusOK || w.Body.String() != widgetJSON {
+		t.Fatalf("Expected the response to be sent, got %d %q", w.Code,
+			w.Body.String())
+	}
+	if !strings.HasPrefix(tag, `"`) || !strings.HasSuffix(tag, `"`) {
+		t.Fatalf("Expected a strong ETag, got %q", tag)
+	}
+	if cl := w.HeaderMap.Get("Content-Length"); cl != strconv.Itoa(len(widgetJSON)) {
+		t.Errorf("Expected a Content-Length of %d, got %q", len(widgetJSON), cl)
+	}
+
+	tests := []struct {
+		inm  string
+		code int
+	}{
+		{tag, http.StatusNotModified},
+		{"W/" + tag, http.StatusNotModified},
+		{`"other", ` + tag, http.StatusNotModified},
+		{"*", http.StatusNotModified},
+		{`"other"`, http.StatusOK},
+	}
+	for _, test := range tests {
+		w := etagRequest(h, "GET", map[string]string{"If-None-Match": test.inm})
+		if w.Code != test.code {
+			t.Errorf("For %q, expected %d, got %d", test.inm, test.code, w.Code)
+		}
+		if test.code == http.StatusNotModified && w.Body.Len() != 0 {
+			t.Errorf("For %q, expected no body, got %q", test.inm, w.Body.String())
+		}
+		if etag := w.HeaderMap.Get("ETag"); etag != tag {
+			t.Errorf("For %q, expected ETag %q, got %q", test.inm, tag, etag)
+		}
+	}
+
+	w = etagRequest(ETag(true, 1024)(widget), "GET", nil)
+	if weak := w.HeaderMap.Get("ETag"); weak != "W/"+tag {
+		t.Errorf("Expected weak ETag %q, got %q", "W/"+tag, weak)
+	}
+}
+
+func TestETagLastModified(t *testing.T) {
+	t.Parallel()
+	modified := time.Date(2014, 6, 1, 12, 0, 0, 0, time.UTC)
+	h := ETag(false, 1024)(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Last-Modified", modified.Format(http.TimeFormat))
+			w.Write([]byte("hello"))
+		}))
+
+	tests := []struct {
+		ims  time.Time
+		code int
+	}{
+		{modified, http.StatusNotModified},
+		{modified.Add(time.Hour), http.StatusNotModified},
+		{modified.Add(-time.Hour), http.StatusOK},
+	}
+	for _, test := range tests {
+		ims := test.ims.Format(http.TimeFormat)
+		w := etagRequest(h, "GET", map[string]string{"If-Modified-Since": ims})
+		if w.Code != test.code {
+			t.Errorf("For %q, expected %d, got %d", ims, test.code, w.Code)
+		}
+	}
+
+	// If-None-Match takes precedence
+	w := etagRequest(h, "GET", map[string]string{
+		"If-Modified-Since": modified.Format(http.TimeFormat),
+		"If-None-Match":     `"other"`,
+	})
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected If-None-Match to take precedence, got %d", w.Code)
+	}
+}
+
+func TestETagSkipped(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name   string
+		method string
+		fn     func(w http.ResponseWriter)
+		code   int
+		body   string
+	}{
+		{"not found", "GET", func(w http.ResponseWriter) {
+			http.Error(w, "nope", http.StatusNotFound)
+		}, 404, "nope\n"},
+		{"own ETag", "GET", func(w http.ResponseWriter) {
+			w.Header().Set("ETag", `"mine"`)
+			w.Write([]byte("hello"))
+		}, 200, "hello"},
+		{"too large", "GET", func(w http.ResponseWriter) {
+			w.Write([]byte(strings.Repeat("x", 600)))
+			w.Write([]byte(strings.Repeat("y", 600)))
+		}, 200, strings.Repeat("x", 600) + strings.Repeat("y", 600)},
+		{"long Content-Length", "GET", func(w http.ResponseWriter) {
+			w.Header().Set("Content-Length", "2048")
+			w.Write([]byte("hello"))
+		}, 200, "hello"},
+		{"flushed", "GET", func(w http.ResponseWriter) {
+			w.Write([]byte("hello, "))
+			w.(http.Flusher).Flush()
+			w.Write([]byte("world"))
+		}, 200, "hello, world"},
+		{"post", "POST", func(w http.ResponseWriter) {
+			w.Write([]byte("hello"))
+		}, 200, "hello"},
+	}
+	for _, test := range tests {
+		fn := test.fn
+		h := ETag(false, 1024)(http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) { fn(w) }))
+		w := etagRequest(h, test.method, nil)
+		if etag := w.HeaderMap.Get("ETag"); etag != "" && etag != `"mine"` {
+			t.Errorf("For %s, expected no generated ETag, got %q", test.name, etag)
+		}
+		if w.Code != test.code || w.Body.String() != test.body {
+			t.Errorf("For %s, expected %d %q, got %d %q", test.name,
+				test.code, test.body, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestETagPanic(t *testing.T) {
+	t.Parallel()
+	m := web.New()
+	m.Use(Recoverer)
+	m.Use(ETag(false, 1024))
+	m.Get("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("partial row data"))
+		panic("broken")
+	})
+
+	w := etagRequest(m, "GET", nil)
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("Expected 500, got %d", w.Code)
+	}
+	if body := w.Body.String(); body != "Internal Server Error\n" {
+		t.Errorf("Expected the partial body to be discarded, got %q", body)
+	}
+	if tag := w.HeaderMap.Get("ETag"); tag != "" {
+		t.Errorf("Expected no ETag, got %q", tag)
+	}
+}
@@ -0,0 +1,89 @@
+package middleware
+
+import (
+	"bufio"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/zenazn/goji/web"
+)
+
+// hijackOnlyWriter stands in for a third-party wrapper which forwards Hijack
+// and Flush, but none of the other optional interfaces.
+type hijackOnlyWriter struct {
+	http.ResponseWriter
+}
+
+func (w hijackOnlyWriter) Flush() {
+	w.ResponseWriter.(http.Flusher).Flush()
+}
+
+func (w hijackOnlyWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return w.ResponseWriter.(http.Hijacker).Hijack()
+}
+
+func TestHijackThroughMiddleware(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	m := web.New()
+	m.TrackResponses(true)
+	m.Use(func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			h.ServeHTTP(hijackOnlyWriter{w}, r)
+		})
+	})
+	m.Use(Logger)
+	m.Use(Recoverer)
+	m.Use(Compress(gzip.DefaultCompression))
+	m.Get("/ws", func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := w.(http.Flusher); !ok {
+			t.Error("Expected the handler's writer to be a Flusher")
+		}
+		hj, ok := w.(http.Hijacker)
+		if !ok {
+			t.Errorf("Expected the handler's writer to be a Hijacker, got %T", w)
+			return
+		}
+		conn, rw, err := hj.Hijack()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+		io.WriteString(rw, "HTTP/1.1 101 Switching Protocols\r\n"+
+			"Upgrade: websocket\r\nConnection: Upgrade\r\n\r\nhello")
+		rw.Flush()
+	})
+	ts := httptest.NewServer(m)
+	defer ts.Close()
+
+	conn, err := net.Dial("tcp", ts.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	io.WriteString(conn, "GET /ws HTTP/1.1\r\nHost: example.com\r\n"+
+		"Accept-Encoding: gzip\r\nUpgrade: websocket\r\n"+
+		"Connection: Upgrade\r\n\r\n")
+	br := bufio.NewReader(conn)
+	res, err := http.ReadResponse(br, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("Expected 101, got %d", res.StatusCode)
+	}
+	buf := make([]byte, 5)
+	if _, err := io.ReadFull(br, buf); err != nil || string(buf) != "hello" {
+		t.Errorf("Expected to read %q from the hijacked connection, got %q, %v",
+			"hello", buf, err)
+	}
+}
@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+)
+
+/*
+LimitBody returns a middleware which limits request bodies to maxBytes bytes, by
+wrapping them with http.MaxBytesReader: reads past the limit fail with an
+*http.MaxBytesError (which web.C.DecodeJSON reports as web.ErrBodyTooLarge), and
+the connection is closed once the response has been written. Requests which
+declare a larger Content-Length fail on their first read, without any of the
+body being read at all.
+
+Requests are not rejected outright, since a later LimitBody replaces the limit
+imposed by an earlier one, rather than being bounded by it. This allows a global
+limit to be relaxed (or tightened) for individual routes with route middleware,
+which runs after routing, and so after the Mux's own middleware:
+
+	m.Use(middleware.LimitBody(1 << 20))
+	m.Post("/uploads", upload).Use(middleware.LimitBody(100 << 20))
+
+Handlers therefore remain responsible for answering requests whose bodies are
+too large, typically with 413 Request Entity Too Large.
+*/
+func LimitBody(maxBytes int64) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			if r.Body != nil && r.Body != http.NoBody {
+				orig := r.Body
+				if lb, ok := orig.(limitedBody); ok {
+					orig = lb.orig
+				}
+				var body io.ReadCloser = tooLargeBody{orig, w, maxBytes}
+				if r.ContentLength <= maxBytes {
+					body = http.MaxBytesReader(w, orig, maxBytes)
+				}
+				r.Body = limitedBody{ReadCloser: body, orig: orig}
+			}
+			h.ServeHTTP(w, r)
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+// limitedBody is a request body limited by LimitBody, which remembers the body
+// it limits so that a subsequent LimitBody can replace the limit.
+type limitedBody struct {
+	io.ReadCloser
+	orig io.ReadCloser
+}
+
+// tooLargeBody stands in for a body whose declared length exceeds the limit,
+// failing as MaxBytesReader would once the limit was reached.
+type tooLargeBody struct {
+	io.Closer
+	w     http.ResponseWriter
+	limit int64
+}
+
+func (b tooLargeBody) Read(p []byte) (int, error) {
+	b.w.Header().Set("Connection", "close")
+	return 0, &http.MaxBytesError{Limit: b.limit}
+}
@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/zenazn/goji/web"
+)
+
+func TestLimitBody(t *testing.T) {
+	t.Parallel()
+	decode := func(c web.C, w http.ResponseWriter, r *http.Request) {
+		var v interface{}
+		if err := c.DecodeJSON(r, &v); err == web.ErrBodyTooLarge {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			w.Write([]byte("too large"))
+		} else if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+		} else {
+			w.Write([]byte("ok"))
+		}
+	}
+	m := web.New()
+	m.Use(LimitBody(16))
+	m.Post("/small", decode)
+	m.Post("/upload", decode).Use(LimitBody(64))
+
+	big := `{"name": "` + strings.Repeat("x", 32) + `"}`
+	tests := []struct {
+		path    string
+		body    string
+		chunked bool
+		code    int
+		resp    string
+	}{
+		{"/small", `{"a": 1}`, false, 200, "ok"},
+		{"/small", big, false, 413, "too large"},
+		{"/small", big, true, 413, "too large"},
+		{"/upload", big, false, 200, "ok"},
+		{"/upload", big, true, 200, "ok"},
+		{"/upload", big + big, true, 413, "too large"},
+	}
+	for _, test := range tests {
+		r, _ := http.NewRequest("POST", test.path, strings.NewReader(test.body))
+		if test.chunked {
+			// The length is unknown, so the limit is enforced as the
+			// body is read
+			r.ContentLength = -1
+		}
+		w := httptest.NewRecorder()
+		m.ServeHTTP(w, r)
+		if w.Code != test.code || w.Body.String() != test.resp {
+			t.Errorf("For %q (%d bytes, chunked %v), expected %d %q, "+
+				"got %d %q", test.path, len(test.body), test.chunked,
+				test.code, test.resp, w.Code, w.Body.String())
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"log"
 	"net/http"
+	"sort"
 	"time"
 
 	"github.com/zenazn/goji/web"
@@ -15,7 +16,20 @@ import (
 // and how long it took to return. When standard output is a TTY, Logger will
 // print in color, otherwise it will print in black and white.
 //
-// Logger prints a request ID if one is provided.
+// Logger respects the log level of the matched route (see web.Route.LogLevel),
+// printing nothing at all for LogSilent routes and additionally printing the
+// request headers for LogVerbose routes. The values of the headers listed in
+// RedactedHeaders are not printed.
+//
+// Logger prints a request ID if one is provided, and notes when a request did
+// not run to completion because the client went away or it timed out (see
+// web.C.TerminationReason). Any non-fatal errors recorded by the handler (see
+// web.C.AddError) are printed as warnings. Requests which were routed but did
+// not match any route (see web.C.Unmatched) are logged with the bucket their
+// path falls into (see PathBucket), and
+// if CountBytesRead is in use, the number of request body bytes read is logged
+// too, as are the allocations made by requests sampled by TrackAllocs (which
+// should be placed after Logger).
 //
 // Logger has been designed explicitly to be Good Enough for use in small
 // applications and for people just getting started with Goji. It is expected
@@ -27,7 +41,18 @@ func Logger(c *web.C, h http.Handler) http.Handler {
 	fn := func(w http.ResponseWriter, r *http.Request) {
 		reqID := GetReqID(*c)
 
-		printStart(reqID, r)
+		// We don't know the route's log level until the request has
+		// been routed, so we put off announcing the request until then.
+		var level web.LogLevel
+		started := false
+		start := func(c web.C) {
+			started = true
+			level = c.LogLevel()
+			if level != web.LogSilent {
+				printStart(reqID, r, level)
+			}
+		}
+		web.OnRouted(c, start)
 
 		lw := mutil.WrapWriter(w)
 
@@ -39,13 +64,43 @@ func Logger(c *web.C, h http.Handler) http.Handler {
 		}
 		t2 := time.Now()
 
-		printEnd(reqID, lw, t2.Sub(t1))
+		bucket := ""
+		if !started {
+			start(*c)
+		}
+		if c.Unmatched() {
+			bucket = PathBucket(r.URL.Path)
+		}
+		if level != web.LogSilent {
+			printEnd(reqID, lw, t2.Sub(t1), *c, bucket)
+		}
 	}
 
 	return http.HandlerFunc(fn)
 }
 
-func printStart(reqID string, r *http.Request) {
+// RedactedHeaders is the list of request headers whose values Logger replaces
+// with "[redacted]" when it prints a request's headers, since they typically
+// carry credentials. It may be changed to suit the application, but it is
+// illegal to do so concurrently with active requests.
+var RedactedHeaders = []string{
+	"Authorization",
+	"Cookie",
+	"Proxy-Authorization",
+	"Set-Cookie",
+	"X-Api-Key",
+}
+
+func redactedHeader(name string) bool {
+	for _, h := range RedactedHeaders {
+		if http.CanonicalHeaderKey(h) == name {
+			return true
+		}
+	}
+	return false
+}
+
+func printStart(reqID string, r *http.Request, level web.LogLevel) {
 	var buf bytes.Buffer
 
 	if reqID != "" {
@@ -57,10 +112,29 @@ func printStart(reqID string, r *http.Request) {
 	buf.WriteString("from ")
 	buf.WriteString(r.RemoteAddr)
 
+	if level == web.LogVerbose {
+		keys := make([]string, 0, len(r.Header))
+		for k := range r.Header {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			redact := redactedHeader(http.CanonicalHeaderKey(k))
+			for _, v := range r.Header[k] {
+				buf.WriteString("\n\t")
+				cW(&buf, nCyan, "%s: ", k)
+				if redact {
+					v = "[redacted]"
+				}
+				buf.WriteString(v)
+			}
+		}
+	}
+
 	log.Print(buf.String())
 }
 
-func printEnd(reqID string, w mutil.WriterProxy, dt time.Duration) {
+func printEnd(reqID string, w mutil.WriterProxy, dt time.Duration, c web.C, bucket string) {
 	var buf bytes.Buffer
 
 	if reqID != "" {
@@ -87,6 +161,21 @@ func printEnd(reqID string, w mutil.WriterProxy, dt time.Duration) {
 	} else {
 		cW(&buf, nRed, "%s", dt)
 	}
+	if reason := c.TerminationReason(); reason != web.Completed {
+		cW(&buf, bRed, " (%s)", reason)
+	}
+	if n := c.BytesRead(); n >= 0 {
+		cW(&buf, nBlue, " read=%d", n)
+	}
+	if stats, ok := GetAllocStats(c); ok {
+		cW(&buf, nBlue, " allocs=%d (%d B)", stats.Objects, stats.Bytes)
+	}
+	if bucket != "" {
+		cW(&buf, nBlue, " bucket=%s", bucket)
+	}
+	for _, err := range c.Errors() {
+		cW(&buf, nYellow, "\n\twarning: %v", err)
+	}
 
 	log.Print(buf.String())
 }
@@ -0,0 +1,145 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/zenazn/goji/web"
+)
+
+func TestLoggerTerminationReason(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	m := web.New()
+	m.Use(Logger)
+	m.Get("/", func(w http.ResponseWriter, r *http.Request) {})
+
+	r, _ := http.NewRequest("GET", "/", nil)
+	m.ServeHTTP(httptest.NewRecorder(), r)
+	if strings.Contains(buf.String(), "(") {
+		t.Errorf("Unexpected termination reason in %q", buf.String())
+	}
+
+	buf.Reset()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	m.ServeHTTP(httptest.NewRecorder(), r.WithContext(ctx))
+	if !strings.Contains(buf.String(), "(client gone)") {
+		t.Errorf("Expected termination reason in %q", buf.String())
+	}
+}
+
+func TestLoggerLogLevel(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	m := web.New()
+	m.Use(Logger)
+	m.Get("/ping", func(w http.ResponseWriter, r *http.Request) {}).
+		LogLevel(web.LogSilent)
+	m.Get("/secret", func(w http.ResponseWriter, r *http.Request) {}).
+		LogLevel(web.LogVerbose)
+	m.Get("/normal", func(w http.ResponseWriter, r *http.Request) {})
+
+	r, _ := http.NewRequest("GET", "/ping", nil)
+	m.ServeHTTP(httptest.NewRecorder(), r)
+	if buf.Len() != 0 {
+		t.Errorf("Expected silent route not to be logged, got %q", buf.String())
+	}
+
+	r, _ = http.NewRequest("GET", "/secret", nil)
+	r.Header.Set("X-Widget", "sprocket")
+	r.Header.Set("Authorization", "Bearer hunter2")
+	r.Header.Set("X-API-Key", "hunter3")
+	m.ServeHTTP(httptest.NewRecorder(), r)
+	if !strings.Contains(buf.String(), "X-Widget: sprocket") {
+		t.Errorf("Expected verbose route to log headers, got %q", buf.String())
+	}
+	if strings.Contains(buf.String(), "hunter") ||
+		!strings.Contains(buf.String(), "Authorization: [redacted]") {
+		t.Errorf("Expected credentials to be redacted, got %q", buf.String())
+	}
+
+	buf.Reset()
+	r, _ = http.NewRequest("GET", "/normal", nil)
+	r.Header.Set("X-Widget", "sprocket")
+	m.ServeHTTP(httptest.NewRecorder(), r)
+	out := buf.String()
+	if !strings.Contains(out, "Started") || !strings.Contains(out, "Returning") {
+		t.Errorf("Expected normal route to be logged, got %q", out)
+	}
+	if strings.Contains(out, "X-Widget") {
+		t.Errorf("Expected normal route not to log headers, got %q", out)
+	}
+
+	// Unrouted requests are logged normally too.
+	buf.Reset()
+	r, _ = http.NewRequest("GET", "/nope", nil)
+	m.ServeHTTP(httptest.NewRecorder(), r)
+	if !strings.Contains(buf.String(), "Returning") {
+		t.Errorf("Expected 404 to be logged, got %q", buf.String())
+	}
+}
+
+func TestLoggerWarnings(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	m := web.New()
+	m.Use(Logger)
+	m.Get("/", func(c web.C, w http.ResponseWriter, r *http.Request) {
+		c.AddError(errors.New("cache unavailable"))
+	})
+
+	r, _ := http.NewRequest("GET", "/", nil)
+	m.ServeHTTP(httptest.NewRecorder(), r)
+	if !strings.Contains(buf.String(), "warning: cache unavailable") {
+		t.Errorf("Expected warning in %q", buf.String())
+	}
+}
+
+func TestLoggerBucket(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	m := web.New()
+	m.Use(Logger)
+	m.Get("/users/:id", func(w http.ResponseWriter, r *http.Request) {})
+
+	r, _ := http.NewRequest("GET", "/users/12345/posts", nil)
+	m.ServeHTTP(httptest.NewRecorder(), r)
+	if !strings.Contains(buf.String(), "bucket=/users/:num/posts") {
+		t.Errorf("Expected bucket in %q", buf.String())
+	}
+
+	buf.Reset()
+	r, _ = http.NewRequest("GET", "/users/12345", nil)
+	m.ServeHTTP(httptest.NewRecorder(), r)
+	if strings.Contains(buf.String(), "bucket=") {
+		t.Errorf("Unexpected bucket for matched route in %q", buf.String())
+	}
+
+	// Requests answered by middleware were never routed at all.
+	m.Use(BasicAuth("x", func(user, pass string) bool { return false }))
+	buf.Reset()
+	r, _ = http.NewRequest("GET", "/users/12345", nil)
+	m.ServeHTTP(httptest.NewRecorder(), r)
+	if !strings.Contains(buf.String(), "Returning 401") {
+		t.Fatalf("Expected the request to be rejected in %q", buf.String())
+	}
+	if strings.Contains(buf.String(), "bucket=") {
+		t.Errorf("Unexpected bucket for unrouted request in %q", buf.String())
+	}
+}
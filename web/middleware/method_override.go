@@ -0,0 +1,71 @@
+package middleware
+
+import (
+	"mime"
+	"net/http"
+	"strings"
+)
+
+var xHTTPMethodOverride = http.CanonicalHeaderKey("X-HTTP-Method-Override")
+
+// The methods a POST may be overridden to. Overriding to GET, HEAD or OPTIONS
+// would let a form submission reach routes which assume they are free of side
+// effects (and which are not protected against cross-site request forgery), so
+// only the unsafe methods browsers cannot send themselves are allowed.
+var overridableMethods = map[string]bool{
+	"DELETE": true,
+	"PATCH":  true,
+	"PUT":    true,
+}
+
+// MethodOverride is a middleware that lets POST requests stand in for the PUT,
+// PATCH and DELETE requests that HTML forms cannot send. The method to use is
+// taken from the X-HTTP-Method-Override header, or failing that from a "_method"
+// field in the request's form body:
+//
+//	<form method="POST" action="/posts/123">
+//		<input type="hidden" name="_method" value="DELETE">
+//	</form>
+//
+// The override is case-insensitive, and overrides to any other method, or on
+// requests that are not POSTs, are ignored. Only urlencoded form bodies are
+// parsed by this middleware (the form remains available to the handler in
+// r.PostForm); a multipart form's "_method" field is only seen if the form has
+// already been parsed, for instance by MultipartLimits.
+//
+// Since the router is at the bottom of the middleware stack, requests are routed
+// using the overridden method no matter where in the stack this middleware is
+// inserted. It should nonetheless be inserted fairly early, so that other
+// layers (e.g., request loggers) see the method the request is routed with.
+func MethodOverride(h http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" {
+			if method := overrideMethod(r); overridableMethods[method] {
+				r.Method = method
+			}
+		}
+		h.ServeHTTP(w, r)
+	}
+
+	return http.HandlerFunc(fn)
+}
+
+func overrideMethod(r *http.Request) string {
+	if method := r.Header.Get(xHTTPMethodOverride); method != "" {
+		return strings.ToUpper(method)
+	}
+
+	mt, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mt {
+	case "application/x-www-form-urlencoded":
+		r.ParseForm()
+		return strings.ToUpper(r.PostForm.Get("_method"))
+	case "multipart/form-data":
+		if r.MultipartForm != nil {
+			if vs := r.MultipartForm.Value["_method"]; len(vs) > 0 {
+				return strings.ToUpper(vs[0])
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/zenazn/goji/web"
+)
+
+func TestMethodOverride(t *testing.T) {
+	t.Parallel()
+	m := web.New()
+	m.Use(MethodOverride)
+	echo := func(method string) web.HandlerFunc {
+		return func(c web.C, w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(method + " " + r.PostFormValue("title")))
+		}
+	}
+	m.Get("/posts/123", echo("GET"))
+	m.Post("/posts/123", echo("POST"))
+	m.Put("/posts/123", echo("PUT"))
+	m.Delete("/posts/123", echo("DELETE"))
+	m.Options("/posts/123", echo("OPTIONS"))
+
+	form := "application/x-www-form-urlencoded"
+	tests := []struct {
+		method, ct, header, body string
+		expected                 string
+	}{
+		{"POST", form, "", "_method=DELETE", "DELETE "},
+		{"POST", form, "", "_method=put&title=Hello", "PUT Hello"},
+		{"POST", form, "put", "_method=DELETE", "PUT "},
+		{"POST", "application/json", "DELETE", "{}", "DELETE "},
+		{"POST", "application/json", "", `{"_method":"DELETE"}`, "POST "},
+		{"POST", form, "", "title=Hello", "POST Hello"},
+		{"POST", form, "", "_method=GET", "POST "},
+		{"POST", form, "OPTIONS", "", "POST "},
+		{"POST", form, "BREW", "", "POST "},
+		{"GET", "", "DELETE", "", "GET "},
+	}
+	for _, test := range tests {
+		r, _ := http.NewRequest(test.method, "/posts/123", strings.NewReader(test.body))
+		if test.ct != "" {
+			r.Header.Set("Content-Type", test.ct)
+		}
+		if test.header != "" {
+			r.Header.Set("X-HTTP-Method-Override", test.header)
+		}
+		w := httptest.NewRecorder()
+		m.ServeHTTP(w, r)
+		if body := w.Body.String(); body != test.expected {
+			t.Errorf("For %s %q with override %q, expected %q, got %q",
+				test.method, test.body, test.header, test.expected, body)
+		}
+	}
+}
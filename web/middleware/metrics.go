@@ -0,0 +1,128 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/zenazn/goji/web"
+	"github.com/zenazn/goji/web/mutil"
+)
+
+// MetricLabels identifies the kind of request a metric was observed for.
+type MetricLabels struct {
+	// Method is the request's method.
+	Method string
+	// Route is the pattern of the route the request was matched to (see
+	// web.RouteInfo), or "" if it didn't match any route.
+	Route string
+	// Status is the response's status code, formatted in decimal.
+	Status string
+}
+
+// MetricsSink receives the metrics observed by Metrics. Observe is called once
+// per request, after the handler has returned, and may be called concurrently.
+// The labels it is passed may be shared with other calls, and must not be
+// modified, although they may be retained (for instance, as a map key).
+type MetricsSink interface {
+	Observe(labels *MetricLabels, duration time.Duration, bytes int)
+}
+
+/*
+Metrics returns a middleware which reports the duration and response size of
+each request to the given sink, labeled with the request's method and status and
+the route it was matched to.
+
+Label sets are interned: each combination of route pattern, method, and status
+is constructed only once, the first time it is seen, and the same *MetricLabels is
+passed to the sink for every subsequent request with that combination. This
+saves several allocations per request, which adds up at high request rates, and
+allows the sink to use the labels themselves as a map key. Requests with
+nonstandard methods (which would otherwise allow clients to create arbitrarily
+many label sets) are not interned.
+*/
+func Metrics(sink MetricsSink) func(*web.C, http.Handler) http.Handler {
+	return metrics(sink, true)
+}
+
+func metrics(sink MetricsSink, intern bool) func(*web.C, http.Handler) http.Handler {
+	var labels labelCache
+	return func(c *web.C, h http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			lw := mutil.WrapWriter(w)
+			t1 := time.Now()
+			h.ServeHTTP(lw, r)
+			dt := time.Since(t1)
+
+			status := lw.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+			route := c.Route()
+			var l *MetricLabels
+			if intern {
+				l = labels.get(route, r.Method, status)
+			} else {
+				l = newMetricLabels(route, r.Method, status)
+			}
+			sink.Observe(l, dt, lw.BytesWritten())
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+// labelKey is keyed by the route's pattern rather than by its *web.RouteInfo,
+// which the Mux makes afresh each time its routes change.
+type labelKey struct {
+	route  string
+	method string
+	status int
+}
+
+type labelCache struct {
+	mu     sync.RWMutex
+	labels map[labelKey]*MetricLabels
+}
+
+var standardMethods = map[string]bool{
+	"CONNECT": true, "DELETE": true, "GET": true, "HEAD": true,
+	"OPTIONS": true, "PATCH": true, "POST": true, "PUT": true,
+	"TRACE": true,
+}
+
+func (lc *labelCache) get(route *web.RouteInfo, method string, status int) *MetricLabels {
+	if !standardMethods[method] {
+		return newMetricLabels(route, method, status)
+	}
+	key := labelKey{method: method, status: status}
+	if route != nil {
+		key.route = route.Pattern
+	}
+	lc.mu.RLock()
+	l, ok := lc.labels[key]
+	lc.mu.RUnlock()
+	if ok {
+		return l
+	}
+
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+	if l, ok := lc.labels[key]; ok {
+		return l
+	}
+	if lc.labels == nil {
+		lc.labels = make(map[labelKey]*MetricLabels)
+	}
+	l = newMetricLabels(route, method, status)
+	lc.labels[key] = l
+	return l
+}
+
+func newMetricLabels(route *web.RouteInfo, method string, status int) *MetricLabels {
+	l := &MetricLabels{Method: method, Status: strconv.Itoa(status)}
+	if route != nil {
+		l.Route = route.Pattern
+	}
+	return l
+}
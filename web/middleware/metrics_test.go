@@ -0,0 +1,140 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/zenazn/goji/web"
+)
+
+type countingSink struct {
+	sync.Mutex
+	counts map[MetricLabels]int
+	seen   map[*MetricLabels]bool
+}
+
+func (s *countingSink) Observe(l *MetricLabels, d time.Duration, bytes int) {
+	s.Lock()
+	defer s.Unlock()
+	s.counts[*l]++
+	s.seen[l] = true
+}
+
+func metricsMux(sink MetricsSink, intern bool) *web.Mux {
+	m := web.New()
+	m.Use(metrics(sink, intern))
+	m.Get("/users/:id", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("user"))
+	})
+	m.Post("/users", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	})
+	return m
+}
+
+func TestMetrics(t *testing.T) {
+	t.Parallel()
+	sink := &countingSink{
+		counts: make(map[MetricLabels]int),
+		seen:   make(map[*MetricLabels]bool),
+	}
+	m := metricsMux(sink, true)
+	requests := []struct{ method, path string }{
+		{"GET", "/users/1"},
+		{"GET", "/users/2"},
+		{"POST", "/users"},
+		{"GET", "/nope"},
+		{"GET", "/users/3"},
+		{"POST", "/users"},
+	}
+	for _, req := range requests {
+		r, _ := http.NewRequest(req.method, req.path, nil)
+		m.ServeHTTP(httptest.NewRecorder(), r)
+	}
+
+	expected := map[MetricLabels]int{
+		{"GET", "/users/:id", "200"}: 3,
+		{"POST", "/users", "201"}:    2,
+		{"GET", "", "404"}:           1,
+	}
+	if len(sink.counts) != len(expected) {
+		t.Errorf("Expected labels %v, got %v", expected, sink.counts)
+	}
+	for l, n := range expected {
+		if sink.counts[l] != n {
+			t.Errorf("Expected %d observations of %+v, got %d", n, l,
+				sink.counts[l])
+		}
+	}
+	if len(sink.seen) != len(expected) {
+		t.Errorf("Expected %d distinct label sets, got %d", len(expected),
+			len(sink.seen))
+	}
+}
+
+// Label sets are shared across changes to the Mux's routes, and are kept apart
+// for the same pattern in different sub-muxes.
+func TestMetricsLabelKeys(t *testing.T) {
+	t.Parallel()
+	sink := &countingSink{
+		counts: make(map[MetricLabels]int),
+		seen:   make(map[*MetricLabels]bool),
+	}
+	m := web.New()
+	m.Use(metrics(sink, true))
+	for _, prefix := range []string{"/a", "/b"} {
+		sub := web.New()
+		sub.Get("/users/:id", http.NotFound)
+		m.Mount(prefix, sub)
+	}
+	serve := func(path string) {
+		r, _ := http.NewRequest("GET", path, nil)
+		m.ServeHTTP(httptest.NewRecorder(), r)
+	}
+
+	serve("/a/users/1")
+	serve("/b/users/1")
+	m.Get("/other", http.NotFound)
+	serve("/a/users/2")
+
+	expected := map[MetricLabels]int{
+		{"GET", "/a/users/:id", "404"}: 2,
+		{"GET", "/b/users/:id", "404"}: 1,
+	}
+	for l, n := range expected {
+		if sink.counts[l] != n {
+			t.Errorf("Expected %d observations of %+v, got %v", n, l,
+				sink.counts)
+		}
+	}
+	if len(sink.seen) != len(expected) {
+		t.Errorf("Expected %d distinct label sets, got %d", len(expected),
+			len(sink.seen))
+	}
+}
+
+type nopSink struct{}
+
+func (nopSink) Observe(*MetricLabels, time.Duration, int) {}
+
+func benchmarkMetrics(b *testing.B, intern bool) {
+	m := metricsMux(nopSink{}, intern)
+	r, _ := http.NewRequest("GET", "/users/123", nil)
+	w := httptest.NewRecorder()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		m.ServeHTTP(w, r)
+	}
+}
+
+func BenchmarkMetricsInterned(b *testing.B) {
+	benchmarkMetrics(b, true)
+}
+
+func BenchmarkMetricsNaive(b *testing.B) {
+	benchmarkMetrics(b, false)
+}
@@ -0,0 +1,73 @@
+package middleware
+
+import (
+	"errors"
+	"mime"
+	"net/http"
+)
+
+/*
+MultipartLimits returns a middleware which parses multipart/form-data request
+bodies on behalf of the handler, enforcing limits on their size. Requests whose
+body exceeds maxTotal bytes, or which contain a file larger than maxFile bytes,
+are answered with 413 Request Entity Too Large (and the connection closed, since
+the remainder of the body is not read), without invoking the handler. Up to
+maxMemory bytes of each form are held in memory; the remainder is written to
+temporary files, as with http.Request.ParseMultipartForm.
+
+The total limit is enforced as the body is read, so oversized requests are
+rejected as soon as the limit is reached. Since net/http does not report the
+size of a file until it has been read in full, the per-file limit is checked
+once the form has been parsed (and is itself bounded by the total limit).
+
+Every temporary file created while parsing a form is removed once the handler
+returns, or immediately if the request is rejected, so that no partial uploads
+are left lying around on disk. Requests which are not multipart forms are passed
+through untouched.
+*/
+func MultipartLimits(maxTotal, maxFile, maxMemory int64) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			mt, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+			if mt != "multipart/form-data" || r.Body == nil {
+				h.ServeHTTP(w, r)
+				return
+			}
+
+			r.Body = http.MaxBytesReader(w, r.Body, maxTotal)
+			err := r.ParseMultipartForm(maxMemory)
+			if r.MultipartForm != nil {
+				defer r.MultipartForm.RemoveAll()
+			}
+
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) || oversizedFile(r, maxFile) {
+				w.Header().Set("Connection", "close")
+				http.Error(w, http.StatusText(http.StatusRequestEntityTooLarge),
+					http.StatusRequestEntityTooLarge)
+				return
+			} else if err != nil {
+				http.Error(w, http.StatusText(http.StatusBadRequest),
+					http.StatusBadRequest)
+				return
+			}
+
+			h.ServeHTTP(w, r)
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+func oversizedFile(r *http.Request, max int64) bool {
+	if r.MultipartForm == nil {
+		return false
+	}
+	for _, fhs := range r.MultipartForm.File {
+		for _, fh := range fhs {
+			if fh.Size > max {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,88 @@
+package middleware
+
+import (
+	"bytes"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func multipartRequest(files map[string]int) *http.Request {
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	mw.WriteField("title", "vacation")
+	for name, size := range files {
+		fw, _ := mw.CreateFormFile(name, name+".bin")
+		fw.Write(bytes.Repeat([]byte("x"), size))
+	}
+	mw.Close()
+	r, _ := http.NewRequest("POST", "/upload", &buf)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	return r
+}
+
+func TestMultipartLimits(t *testing.T) {
+	dir, err := ioutil.TempDir("", "multipart")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	// ParseMultipartForm puts its temporary files in os.TempDir
+	t.Setenv("TMPDIR", dir)
+
+	called := false
+	h := MultipartLimits(64<<10, 16<<10, 1<<10)(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			called = true
+			if r.FormValue("title") != "vacation" {
+				t.Error("Handler didn't see form values")
+			}
+			if _, _, err := r.FormFile("a"); err != nil {
+				t.Errorf("Handler couldn't open file: %v", err)
+			}
+		}))
+
+	tests := []struct {
+		files  map[string]int
+		code   int
+		called bool
+	}{
+		{map[string]int{"a": 8 << 10, "b": 8 << 10}, http.StatusOK, true},
+		{map[string]int{"a": 20 << 10}, http.StatusRequestEntityTooLarge, false},
+		{map[string]int{"a": 15 << 10, "b": 15 << 10, "c": 15 << 10,
+			"d": 15 << 10, "e": 15 << 10}, http.StatusRequestEntityTooLarge,
+			false},
+	}
+	for _, test := range tests {
+		called = false
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, multipartRequest(test.files))
+		if w.Code != test.code || called != test.called {
+			t.Errorf("For %v, expected %d (called: %v), got %d (called: %v)",
+				test.files, test.code, test.called, w.Code, called)
+		}
+		if test.code == http.StatusRequestEntityTooLarge &&
+			w.HeaderMap.Get("Connection") != "close" {
+			t.Errorf("For %v, expected connection to be closed", test.files)
+		}
+		left, _ := ioutil.ReadDir(dir)
+		if len(left) != 0 {
+			t.Errorf("For %v, %d temporary files were left behind",
+				test.files, len(left))
+		}
+	}
+
+	r, _ := http.NewRequest("POST", "/upload", strings.NewReader("a=b"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	called = false
+	passthrough := MultipartLimits(1, 1, 1)(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) { called = true }))
+	passthrough.ServeHTTP(httptest.NewRecorder(), r)
+	if !called {
+		t.Error("Expected non-multipart request to be passed through")
+	}
+}
@@ -0,0 +1,79 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/zenazn/goji/web"
+)
+
+// pushRecorder is a ResponseWriter for an HTTP/2 connection which records the
+// pushes made through it.
+type pushRecorder struct {
+	*httptest.ResponseRecorder
+	pushed []string
+}
+
+func (p *pushRecorder) Push(target string, opts *http.PushOptions) error {
+	p.pushed = append(p.pushed, target)
+	return nil
+}
+
+// unwrapOnlyWriter stands in for a third-party wrapper which forwards none of
+// the optional interfaces, but which can be unwrapped.
+type unwrapOnlyWriter struct {
+	http.ResponseWriter
+}
+
+func (w unwrapOnlyWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func TestPushThroughMiddleware(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	m := web.New()
+	m.Use(Logger)
+	m.Use(Compress(gzip.DefaultCompression))
+	m.Use(func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			h.ServeHTTP(unwrapOnlyWriter{w}, r)
+		})
+	})
+	var errs []error
+	m.Get("/", func(c web.C, w http.ResponseWriter, r *http.Request) {
+		errs = append(errs, c.Push(w, "/app.css", nil))
+		errs = append(errs, c.Push(w, "/app.js", nil))
+		w.Write([]byte("<html>"))
+	}).Use(web.Timeout(time.Minute))
+
+	w := &pushRecorder{ResponseRecorder: httptest.NewRecorder()}
+	r, _ := http.NewRequest("GET", "/", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	m.ServeHTTP(w, r)
+	if !reflect.DeepEqual(w.pushed, []string{"/app.css", "/app.js"}) {
+		t.Errorf("Expected both resources to be pushed, got %v", w.pushed)
+	}
+	for _, err := range errs {
+		if err != nil {
+			t.Errorf("Expected pushes to succeed, got %v", err)
+		}
+	}
+
+	errs = nil
+	m.ServeHTTP(httptest.NewRecorder(), r)
+	for _, err := range errs {
+		if err != http.ErrNotSupported {
+			t.Errorf("Expected %v without a Pusher, got %v",
+				http.ErrNotSupported, err)
+		}
+	}
+}
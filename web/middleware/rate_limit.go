@@ -0,0 +1,188 @@
+package middleware
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/zenazn/goji/web"
+)
+
+// RateLimitOptions configures RateLimit.
+type RateLimitOptions struct {
+	// Rate is the number of requests per second each key is allowed on
+	// average, i.e., the rate at which its bucket is refilled.
+	Rate float64
+	// Burst is the size of each key's bucket: the number of requests a key
+	// which has been idle for long enough may make at once. It must be at
+	// least 1.
+	Burst int
+	// Key returns the key a request is limited by. Requests for which it
+	// returns the empty string are not limited. If it is nil, requests are
+	// limited by client IP address (see TrustedProxies). To limit
+	// authenticated clients individually, Key might return their principal
+	// (see GetPrincipal) instead.
+	Key func(c web.C, r *http.Request) string
+	// TrustedProxies is the set of reverse proxies whose forwarding headers
+	// are believed when determining a request's client IP address for the
+	// default Key (see web.C.ClientIP). If it is empty, the headers are
+	// ignored.
+	TrustedProxies []net.IPNet
+	// Store holds each key's bucket. If it is nil, a new MemoryStore is
+	// used.
+	Store RateLimitStore
+	// Clock tells the time at which requests are made. If it is nil,
+	// web.SystemClock is used.
+	Clock web.Clock
+}
+
+// A RateLimitStore holds the token buckets RateLimit takes tokens from. Its
+// methods may be called concurrently, and must be atomic with respect to each
+// key. Stores shared between processes (backed by Redis, for instance) allow
+// clients to be limited across them.
+type RateLimitStore interface {
+	// Take takes a token from key's bucket as of now, given that it holds
+	// at most burst tokens and is refilled at rate tokens per second.
+	// Buckets which have never been used are full. If there was a token to
+	// take, Take returns true, the number of tokens which remain, and how
+	// long it will take for the bucket to be refilled. Otherwise, it
+	// returns false and how long it will take for a token to become
+	// available.
+	Take(key string, now time.Time, rate float64, burst int) (ok bool, remaining int, wait time.Duration)
+}
+
+/*
+RateLimit returns a middleware which limits the rate of requests for each key
+(by default, each client IP address) using a token bucket: each key may make
+opts.Burst requests at once, and opts.Rate requests per second thereafter.
+
+Every response carries the headers X-RateLimit-Limit (the size of the bucket),
+X-RateLimit-Remaining (the number of requests which may still be made) and
+X-RateLimit-Reset (the number of seconds until the bucket is full again).
+Requests beyond the limit are answered with 429 Too Many Requests and a
+Retry-After header, and are aborted (see web.C.Abort).
+
+RateLimit panics if opts.Burst is less than 1 or opts.Rate is not positive.
+*/
+func RateLimit(opts RateLimitOptions) func(*web.C, http.Handler) http.Handler {
+	if opts.Burst < 1 || !(opts.Rate > 0) {
+		panic("middleware: RateLimit requires a positive Rate and Burst")
+	}
+	key := opts.Key
+	if key == nil {
+		key = func(c web.C, r *http.Request) string {
+			return c.ClientIP(r, opts.TrustedProxies)
+		}
+	}
+	store := opts.Store
+	if store == nil {
+		store = NewMemoryStore()
+	}
+	clock := opts.Clock
+	if clock == nil {
+		clock = web.SystemClock
+	}
+	limit := strconv.Itoa(opts.Burst)
+
+	return func(c *web.C, h http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			k := key(*c, r)
+			if k == "" {
+				h.ServeHTTP(w, r)
+				return
+			}
+			ok, remaining, wait := store.Take(k, clock.Now(), opts.Rate, opts.Burst)
+			header := w.Header()
+			header.Set("X-RateLimit-Limit", limit)
+			header.Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
+			if ok {
+				header.Set("X-RateLimit-Reset", seconds(wait))
+				h.ServeHTTP(w, r)
+				return
+			}
+			// The bucket is empty: it will be full once the token we
+			// are waiting for and all the others have been refilled.
+			refill := wait + time.Duration(float64(opts.Burst-1)/opts.Rate*float64(time.Second))
+			header.Set("X-RateLimit-Reset", seconds(refill))
+			header.Set("Retry-After", seconds(wait))
+			http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+			c.Abort()
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+// seconds formats d as a whole number of seconds, rounding up.
+func seconds(d time.Duration) string {
+	return strconv.FormatInt(int64(math.Ceil(d.Seconds())), 10)
+}
+
+// MemoryStore is a RateLimitStore which keeps buckets in memory. Buckets which
+// have been idle for long enough to be refilled are indistinguishable from
+// buckets which have never been used, and are periodically forgotten, so that
+// the store's size is bounded by the number of recently active keys.
+type MemoryStore struct {
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+	// nextSweep is the time at which idle buckets will next be forgotten.
+	nextSweep time.Time
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+	// full is the time at which the bucket will be full again.
+	full time.Time
+}
+
+// NewMemoryStore returns a new, empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: make(map[string]*tokenBucket)}
+}
+
+// Take implements RateLimitStore.
+func (s *MemoryStore) Take(key string, now time.Time, rate float64, burst int) (bool, int, time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Sweeping no more often than it takes to refill a bucket keeps the
+	// cost of sweeping proportional to the number of requests.
+	fill := time.Duration(float64(burst) / rate * float64(time.Second))
+	if !now.Before(s.nextSweep) {
+		for k, b := range s.buckets {
+			if !now.Before(b.full) {
+				delete(s.buckets, k)
+			}
+		}
+		s.nextSweep = now.Add(fill)
+	}
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: float64(burst), last: now}
+		s.buckets[key] = b
+	}
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(float64(burst), b.tokens+elapsed*rate)
+		b.last = now
+	}
+
+	if b.tokens < 1 {
+		wait := time.Duration((1 - b.tokens) / rate * float64(time.Second))
+		return false, 0, wait
+	}
+	b.tokens--
+	refill := time.Duration((float64(burst) - b.tokens) / rate * float64(time.Second))
+	b.full = now.Add(refill)
+	return true, int(b.tokens), refill
+}
+
+// Len returns the number of buckets the store holds.
+func (s *MemoryStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.buckets)
+}
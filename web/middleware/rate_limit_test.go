@@ -0,0 +1,114 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/zenazn/goji/web"
+)
+
+type fakeClock struct {
+	t time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.t
+}
+
+func TestRateLimit(t *testing.T) {
+	t.Parallel()
+	clock := &fakeClock{time.Unix(1400000000, 0)}
+	m := web.New()
+	m.Use(RateLimit(RateLimitOptions{Rate: 1, Burst: 2, Clock: clock}))
+	m.Get("/", func(w http.ResponseWriter, r *http.Request) {})
+
+	tests := []struct {
+		addr                      string
+		advance                   time.Duration
+		code                      int
+		remaining, reset, retryIn string
+	}{
+		{"1.2.3.4:1000", 0, 200, "1", "1", ""},
+		{"1.2.3.4:1001", 0, 200, "0", "2", ""},
+		{"1.2.3.4:1002", 0, 429, "0", "2", "1"},
+		{"5.6.7.8:1000", 0, 200, "1", "1", ""},
+		{"1.2.3.4:1003", 500 * time.Millisecond, 429, "0", "2", "1"},
+		{"1.2.3.4:1004", 500 * time.Millisecond, 200, "0", "2", ""},
+		{"1.2.3.4:1005", 10 * time.Second, 200, "1", "1", ""},
+	}
+	for i, test := range tests {
+		clock.t = clock.t.Add(test.advance)
+		r, _ := http.NewRequest("GET", "/", nil)
+		r.RemoteAddr = test.addr
+		w := httptest.NewRecorder()
+		m.ServeHTTP(w, r)
+		h := w.Header()
+		if w.Code != test.code || h.Get("X-RateLimit-Limit") != "2" ||
+			h.Get("X-RateLimit-Remaining") != test.remaining ||
+			h.Get("X-RateLimit-Reset") != test.reset ||
+			h.Get("Retry-After") != test.retryIn {
+			t.Errorf("%d: expected %d (%s remaining, reset %s, retry %q), "+
+				"got %d with %v", i, test.code, test.remaining,
+				test.reset, test.retryIn, w.Code, h)
+		}
+	}
+}
+
+func TestRateLimitKey(t *testing.T) {
+	t.Parallel()
+	m := web.New()
+	m.Use(func(c *web.C, h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if user := r.Header.Get("X-User"); user != "" {
+				setPrincipal(c, user)
+			}
+			h.ServeHTTP(w, r)
+		})
+	})
+	m.Use(RateLimit(RateLimitOptions{
+		Rate:  1,
+		Burst: 1,
+		Key: func(c web.C, r *http.Request) string {
+			user, _ := GetPrincipal(c).(string)
+			return user
+		},
+	}))
+	m.Get("/", func(w http.ResponseWriter, r *http.Request) {})
+
+	for _, test := range []struct {
+		user string
+		code int
+	}{{"carl", 200}, {"carl", 429}, {"bob", 200}, {"", 200}, {"", 200}} {
+		r, _ := http.NewRequest("GET", "/", nil)
+		r.Header.Set("X-User", test.user)
+		w := httptest.NewRecorder()
+		m.ServeHTTP(w, r)
+		if w.Code != test.code {
+			t.Errorf("For %q, expected %d, got %d", test.user, test.code,
+				w.Code)
+		}
+	}
+}
+
+func TestMemoryStoreEviction(t *testing.T) {
+	t.Parallel()
+	s := NewMemoryStore()
+	now := time.Unix(1400000000, 0)
+	for _, key := range []string{"a", "b", "c"} {
+		s.Take(key, now, 1, 2)
+	}
+	if s.Len() != 3 {
+		t.Fatalf("Expected 3 buckets, got %d", s.Len())
+	}
+	now = now.Add(time.Second)
+	s.Take("a", now, 1, 2)
+	s.Take("a", now, 1, 2)
+	now = now.Add(1500 * time.Millisecond)
+	s.Take("d", now, 1, 2)
+	// "b" and "c" are full again, and so forgotten, but "a" is not.
+	if s.Len() != 2 {
+		t.Errorf("Expected idle buckets to be evicted, got %d", s.Len())
+	}
+}
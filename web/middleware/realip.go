@@ -1,8 +1,11 @@
 package middleware
 
 import (
+	"net"
 	"net/http"
 	"strings"
+
+	"github.com/zenazn/goji/web"
 )
 
 var xForwardedFor = http.CanonicalHeaderKey("X-Forwarded-For")
@@ -23,6 +26,7 @@ var xRealIP = http.CanonicalHeaderKey("X-Real-IP")
 // values from the client, or if you use this middleware without a reverse
 // proxy, malicious clients will be able to make you very sad (or, depending on
 // how you're using RemoteAddr, vulnerable to an attack of some sort).
+// TrustedRealIP only believes the headers when they were set by known proxies.
 func RealIP(h http.Handler) http.Handler {
 	fn := func(w http.ResponseWriter, r *http.Request) {
 		if rip := realIP(r); rip != "" {
@@ -49,3 +53,22 @@ func realIP(r *http.Request) string {
 
 	return ip
 }
+
+// TrustedRealIP returns a middleware that sets a http.Request's RemoteAddr to
+// its client's IP address as determined by web.C.ClientIP, which only believes
+// the X-Forwarded-For and X-Real-IP headers when the request's peer is one of
+// trustedProxies (see web.ParseTrustedProxies). Unlike RealIP, it is therefore
+// safe to use when clients can reach Goji without going through a proxy.
+//
+// As with RealIP, this middleware should be inserted early in the middleware
+// stack. Since the rewritten RemoteAddr is not that of a trusted proxy, later
+// calls to web.C.ClientIP return it as it is.
+func TrustedRealIP(trustedProxies []net.IPNet) func(*web.C, http.Handler) http.Handler {
+	return func(c *web.C, h http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			r.RemoteAddr = c.ClientIP(r, trustedProxies)
+			h.ServeHTTP(w, r)
+		}
+		return http.HandlerFunc(fn)
+	}
+}
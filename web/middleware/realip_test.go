@@ -0,0 +1,34 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/zenazn/goji/web"
+)
+
+func TestTrustedRealIP(t *testing.T) {
+	t.Parallel()
+	trusted, _ := web.ParseTrustedProxies("10.0.0.0/8")
+	m := web.New()
+	m.Use(TrustedRealIP(trusted))
+	m.Get("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.RemoteAddr))
+	})
+
+	for _, test := range []struct{ remote, ip string }{
+		{"10.0.0.1:80", "5.6.7.8"},
+		{"1.2.3.4:80", "1.2.3.4"},
+	} {
+		r, _ := http.NewRequest("GET", "/", nil)
+		r.RemoteAddr = test.remote
+		r.Header.Set("X-Forwarded-For", "5.6.7.8")
+		w := httptest.NewRecorder()
+		m.ServeHTTP(w, r)
+		if w.Body.String() != test.ip {
+			t.Errorf("Via %q, expected %q, got %q", test.remote, test.ip,
+				w.Body.String())
+		}
+	}
+}
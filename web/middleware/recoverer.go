@@ -7,13 +7,15 @@ import (
 	"runtime/debug"
 
 	"github.com/zenazn/goji/web"
+	"github.com/zenazn/goji/web/mutil"
 )
 
 // Recoverer is a middleware that recovers from panics, logs the panic (and a
 // backtrace), and returns a HTTP 500 (Internal Server Error) status if
 // possible.
 //
-// Recoverer prints a request ID if one is provided.
+// Recoverer prints a request ID if one is provided, and aborts the request (see
+// web.C.Abort) once it has recovered.
 func Recoverer(c *web.C, h http.Handler) http.Handler {
 	fn := func(w http.ResponseWriter, r *http.Request) {
 		reqID := GetReqID(*c)
@@ -23,6 +25,7 @@ func Recoverer(c *web.C, h http.Handler) http.Handler {
 				printPanic(reqID, err)
 				debug.PrintStack()
 				http.Error(w, http.StatusText(500), 500)
+				c.Abort()
 			}
 		}()
 
@@ -32,6 +35,51 @@ func Recoverer(c *web.C, h http.Handler) http.Handler {
 	return http.HandlerFunc(fn)
 }
 
+/*
+RecoverWith returns a middleware which, like Recoverer, recovers from panics,
+but which responds to them by calling the given function, which is passed the
+request's context and the value passed to panic. This allows the response to be
+tailored to the application, for instance by setting a custom status or by
+emitting a JSON error body. If fn is nil, the panic is logged (along with a
+backtrace) and a 500 Internal Server Error is returned, just as with Recoverer.
+
+If the response has already been started by the time the handler panics (that is,
+if its header has already been written), it is too late to send a different one:
+in that case the panic is only logged, and fn is not called. Either way, the
+request is aborted (see web.C.Abort).
+
+Since it can only recover from panics in the middleware inside it, the returned
+middleware should typically be the outermost one.
+*/
+func RecoverWith(fn web.PanicHandler) func(*web.C, http.Handler) http.Handler {
+	return func(c *web.C, h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			lw := mutil.WrapWriter(w)
+			defer func() {
+				err := recover()
+				if err == nil {
+					return
+				}
+				c.Abort()
+				if fn == nil || lw.Status() != 0 {
+					printPanic(GetReqID(*c), err)
+					debug.PrintStack()
+				}
+				if lw.Status() != 0 {
+					return
+				}
+				if fn == nil {
+					http.Error(lw, http.StatusText(500), 500)
+					return
+				}
+				fn(*c, lw, r, err)
+			}()
+
+			h.ServeHTTP(lw, r)
+		})
+	}
+}
+
 func printPanic(reqID string, err interface{}) {
 	var buf bytes.Buffer
 
@@ -0,0 +1,115 @@
+package middleware
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/zenazn/goji/web"
+)
+
+func recoverMux(fn web.PanicHandler) *web.Mux {
+	m := web.New()
+	m.Use(RecoverWith(fn))
+	m.Get("/early", func(w http.ResponseWriter, r *http.Request) {
+		panic("early")
+	})
+	m.Get("/late", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("partial"))
+		panic("late")
+	})
+	return m
+}
+
+func TestRecoverWith(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	var called []interface{}
+	m := recoverMux(func(c web.C, w http.ResponseWriter, r *http.Request, err interface{}) {
+		called = append(called, err)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprintf(w, `{"error":%q}`, err)
+	})
+
+	r, _ := http.NewRequest("GET", "/early", nil)
+	w := httptest.NewRecorder()
+	m.ServeHTTP(w, r)
+	if w.Code != http.StatusServiceUnavailable ||
+		w.Body.String() != `{"error":"early"}` {
+		t.Errorf("Expected the custom response, got %d %q", w.Code,
+			w.Body.String())
+	}
+	if len(called) != 1 || called[0] != "early" {
+		t.Errorf("Expected the handler to be called with the panic, got %v",
+			called)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("Expected the handler to take care of logging, got %q",
+			buf.String())
+	}
+
+	called = nil
+	r, _ = http.NewRequest("GET", "/late", nil)
+	w = httptest.NewRecorder()
+	m.ServeHTTP(w, r)
+	if w.Code != http.StatusOK || w.Body.String() != "partial" {
+		t.Errorf("Expected the partial response alone, got %d %q", w.Code,
+			w.Body.String())
+	}
+	if len(called) != 0 {
+		t.Errorf("Expected the handler not to be called, got %v", called)
+	}
+	if !strings.Contains(buf.String(), "panic: late") {
+		t.Errorf("Expected the panic to be logged, got %q", buf.String())
+	}
+}
+
+func TestRecoverWithDefault(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	m := recoverMux(nil)
+	r, _ := http.NewRequest("GET", "/early", nil)
+	w := httptest.NewRecorder()
+	m.ServeHTTP(w, r)
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("Expected a 500, got %d", w.Code)
+	}
+	if !strings.Contains(buf.String(), "panic: early") {
+		t.Errorf("Expected the panic to be logged, got %q", buf.String())
+	}
+}
+
+func failingMiddleware(c *web.C, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("no connection")
+	})
+}
+
+func TestRecoverWithPanicLayer(t *testing.T) {
+	var layer *web.PanicLayer
+	m := web.New()
+	m.Use(RecoverWith(func(c web.C, w http.ResponseWriter, r *http.Request, err interface{}) {
+		layer = web.PanicLayerFromC(c)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	m.Use(failingMiddleware)
+	m.Get("/", http.NotFound)
+
+	r, _ := http.NewRequest("GET", "/", nil)
+	m.ServeHTTP(httptest.NewRecorder(), r)
+	name := "github.com/zenazn/goji/web/middleware.failingMiddleware"
+	if layer == nil || layer.Index != 1 || layer.Name != name {
+		t.Errorf("Expected the panic to be attributed to layer 1 (%s), got %+v",
+			name, layer)
+	}
+}
@@ -13,7 +13,7 @@ import (
 )
 
 // Key to use when setting the request ID.
-const RequestIDKey = "reqID"
+const RequestIDKey = web.RequestIDKey
 
 var prefix string
 var reqid uint64
@@ -56,14 +56,14 @@ func init() {
 // request. A request ID is a string of the form "host.example.com/random-0001",
 // where "random" is a base62 random string that uniquely identifies this go
 // process, and where the last number is an atomically incremented request
-// counter.
+// counter. The ID can be retrieved with web.RequestIDFromC. To propagate IDs
+// between services, use RequestIDHeader instead.
 func RequestID(c *web.C, h http.Handler) http.Handler {
 	fn := func(w http.ResponseWriter, r *http.Request) {
 		if c.Env == nil {
 			c.Env = make(map[interface{}]interface{})
 		}
-		myid := atomic.AddUint64(&reqid, 1)
-		c.Env[RequestIDKey] = fmt.Sprintf("%s-%06d", prefix, myid)
+		c.Env[RequestIDKey] = newRequestID()
 
 		h.ServeHTTP(w, r)
 	}
@@ -71,18 +71,66 @@ func RequestID(c *web.C, h http.Handler) http.Handler {
 	return http.HandlerFunc(fn)
 }
 
-// GetReqID returns a request ID from the given context if one is present.
-// Returns the empty string if a request ID cannot be found.
-func GetReqID(c web.C) string {
-	if c.Env == nil {
-		return ""
+func newRequestID() string {
+	myid := atomic.AddUint64(&reqid, 1)
+	return fmt.Sprintf("%s-%06d", prefix, myid)
+}
+
+// The longest request ID RequestIDHeader will accept from a client.
+const maxRequestIDLength = 128
+
+/*
+RequestIDHeader returns a middleware which, like RequestID, assigns each request
+an ID (which can be retrieved with web.RequestIDFromC), but which propagates
+IDs across services by way of the named header (typically "X-Request-Id"). If
+the incoming request carries a valid ID in the header, the request is given
+that ID rather than a new one, and in either case the ID is sent back in the
+same header of the response, so that clients (and services further upstream)
+can correlate their logs with this one's.
+
+An ID is valid if it is no longer than 128 bytes, and consists only of ASCII
+letters and digits and the punctuation "-._:/+=@". IDs which are not are
+discarded and replaced, so that a client cannot smuggle arbitrary text into the
+logs.
+*/
+func RequestIDHeader(header string) func(*web.C, http.Handler) http.Handler {
+	return func(c *web.C, h http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			if c.Env == nil {
+				c.Env = make(map[interface{}]interface{})
+			}
+			id := r.Header.Get(header)
+			if !validRequestID(id) {
+				id = newRequestID()
+			}
+			c.Env[RequestIDKey] = id
+			w.Header().Set(header, id)
+
+			h.ServeHTTP(w, r)
+		}
+		return http.HandlerFunc(fn)
 	}
-	v, ok := c.Env[RequestIDKey]
-	if !ok {
-		return ""
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
 	}
-	if reqID, ok := v.(string); ok {
-		return reqID
+	for i := 0; i < len(id); i++ {
+		c := id[i]
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		case strings.IndexByte("-._:/+=@", c) >= 0:
+		default:
+			return false
+		}
 	}
-	return ""
+	return true
+}
+
+// GetReqID returns a request ID from the given context if one is present.
+// Returns the empty string if a request ID cannot be found. It is equivalent to
+// web.RequestIDFromC.
+func GetReqID(c web.C) string {
+	return web.RequestIDFromC(c)
 }
@@ -0,0 +1,59 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/zenazn/goji/web"
+)
+
+func TestRequestIDHeader(t *testing.T) {
+	t.Parallel()
+	var seen string
+	m := web.New()
+	m.Use(RequestIDHeader("X-Request-Id"))
+	m.Get("/", func(c web.C, w http.ResponseWriter, r *http.Request) {
+		seen = web.RequestIDFromC(c)
+	})
+
+	tests := []struct {
+		in    string
+		valid bool
+	}{
+		{"", false},
+		{"abc-123", true},
+		{"trace:7f/run=2@edge", true},
+		{"has spaces", false},
+		{"new\nline", false},
+		{strings.Repeat("a", 129), false},
+	}
+	for _, test := range tests {
+		r, _ := http.NewRequest("GET", "/", nil)
+		if test.in != "" {
+			r.Header.Set("X-Request-Id", test.in)
+		}
+		w := httptest.NewRecorder()
+		m.ServeHTTP(w, r)
+		out := w.HeaderMap.Get("X-Request-Id")
+		if out != seen || out == "" {
+			t.Errorf("For %q, expected the response to carry the ID %q, "+
+				"got %q", test.in, seen, out)
+		}
+		if (out == test.in) != test.valid {
+			t.Errorf("For %q, expected propagation %v, got ID %q", test.in,
+				test.valid, out)
+		}
+	}
+
+	ids := make(map[string]bool)
+	for i := 0; i < 10; i++ {
+		r, _ := http.NewRequest("GET", "/", nil)
+		m.ServeHTTP(httptest.NewRecorder(), r)
+		if ids[seen] {
+			t.Errorf("Got the ID %q twice", seen)
+		}
+		ids[seen] = true
+	}
+}
@@ -0,0 +1,137 @@
+package middleware
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/zenazn/goji/web"
+)
+
+// KeyFunc extracts a key from a request. Middleware that group requests by key
+// (for instance, SerializeByKey) treat requests for which the key is the empty
+// string as ungrouped.
+type KeyFunc func(c web.C, r *http.Request) string
+
+// FingerprintKey returns a KeyFunc which keys requests by a fingerprint of the
+// given fields. See web.C.Fingerprint for the list of available fields.
+func FingerprintKey(fields ...string) KeyFunc {
+	return func(c web.C, r *http.Request) string {
+		return c.Fingerprint(r, fields...)
+	}
+}
+
+// keyedMutex is a set of mutexes indexed by string. Entries are reference
+// counted and removed as soon as nobody holds or is waiting on them, so that
+// the set doesn't grow without bound as keys come and go.
+type keyedMutex struct {
+	mu    sync.Mutex
+	locks map[string]*keyLock
+}
+
+type keyLock struct {
+	// A token in the channel means the lock is free.
+	ch   chan struct{}
+	refs int
+}
+
+func newKeyedMutex() *keyedMutex {
+	return &keyedMutex{locks: make(map[string]*keyLock)}
+}
+
+// lock locks the mutex for the given key. If wait is false, lock fails
+// immediately if the mutex is already held; otherwise it waits until either the
+// mutex is acquired or done is closed. Returns true if the mutex was acquired,
+// in which case the caller must call unlock.
+func (k *keyedMutex) lock(key string, wait bool, done <-chan struct{}) bool {
+	k.mu.Lock()
+	l, ok := k.locks[key]
+	if !ok {
+		l = &keyLock{ch: make(chan struct{}, 1)}
+		l.ch <- struct{}{}
+		k.locks[key] = l
+	}
+	l.refs++
+	k.mu.Unlock()
+
+	if wait {
+		select {
+		case <-l.ch:
+			return true
+		case <-done:
+		}
+	} else {
+		select {
+		case <-l.ch:
+			return true
+		default:
+		}
+	}
+	k.deref(key, l)
+	return false
+}
+
+func (k *keyedMutex) unlock(key string) {
+	k.mu.Lock()
+	l := k.locks[key]
+	k.mu.Unlock()
+	l.ch <- struct{}{}
+	k.deref(key, l)
+}
+
+func (k *keyedMutex) deref(key string, l *keyLock) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	l.refs--
+	if l.refs == 0 {
+		delete(k.locks, key)
+	}
+}
+
+func (k *keyedMutex) len() int {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return len(k.locks)
+}
+
+// SerializeByKey returns a middleware that serializes requests which share a
+// key: at most one request per key is processed at any time, while the others
+// wait their turn. This can be used to prevent concurrent writes to the same
+// resource (for instance, two PUTs to the same URL) from clobbering each other.
+// Requests with different keys, and requests for which keyFunc returns the
+// empty string, proceed concurrently as usual.
+//
+// Requests that are waiting for their turn give up if the client goes away, in
+// which case the rest of the stack is never run.
+func SerializeByKey(keyFunc KeyFunc) func(*web.C, http.Handler) http.Handler {
+	return serializeByKey(keyFunc, true)
+}
+
+// RejectConcurrentByKey is like SerializeByKey, but rather than waiting for
+// their turn, requests which arrive while another request with the same key is
+// being processed are immediately rejected with a 409 Conflict.
+func RejectConcurrentByKey(keyFunc KeyFunc) func(*web.C, http.Handler) http.Handler {
+	return serializeByKey(keyFunc, false)
+}
+
+func serializeByKey(keyFunc KeyFunc, wait bool) func(*web.C, http.Handler) http.Handler {
+	locks := newKeyedMutex()
+	return func(c *web.C, h http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			key := keyFunc(*c, r)
+			if key == "" {
+				h.ServeHTTP(w, r)
+				return
+			}
+			if !locks.lock(key, wait, r.Context().Done()) {
+				if !wait {
+					http.Error(w, http.StatusText(http.StatusConflict),
+						http.StatusConflict)
+				}
+				return
+			}
+			defer locks.unlock(key)
+			h.ServeHTTP(w, r)
+		}
+		return http.HandlerFunc(fn)
+	}
+}
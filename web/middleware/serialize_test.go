@@ -0,0 +1,139 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/zenazn/goji/web"
+)
+
+func pathKey(c web.C, r *http.Request) string {
+	return r.URL.Path
+}
+
+func serializeRequest(m *web.Mux, path string) *httptest.ResponseRecorder {
+	r, _ := http.NewRequest("PUT", path, nil)
+	w := httptest.NewRecorder()
+	m.ServeHTTP(w, r)
+	return w
+}
+
+func TestSerializeByKey(t *testing.T) {
+	t.Parallel()
+	var active, maxActive int32
+	m := web.New()
+	m.Use(SerializeByKey(pathKey))
+	m.Put("/:key", func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&active, 1)
+		for {
+			old := atomic.LoadInt32(&maxActive)
+			if n <= old || atomic.CompareAndSwapInt32(&maxActive, old, n) {
+				break
+			}
+		}
+		time.Sleep(time.Millisecond)
+		atomic.AddInt32(&active, -1)
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			serializeRequest(m, "/same")
+		}()
+	}
+	wg.Wait()
+	if maxActive != 1 {
+		t.Errorf("Expected requests to be serialized, but %d ran at once",
+			maxActive)
+	}
+}
+
+func TestSerializeByKeyDistinct(t *testing.T) {
+	t.Parallel()
+	m := web.New()
+	m.Use(SerializeByKey(pathKey))
+	var barrier sync.WaitGroup
+	barrier.Add(2)
+	m.Put("/:key", func(w http.ResponseWriter, r *http.Request) {
+		// This deadlocks unless both keys proceed concurrently.
+		barrier.Done()
+		barrier.Wait()
+	})
+
+	done := make(chan struct{})
+	go func() {
+		serializeRequest(m, "/a")
+		done <- struct{}{}
+	}()
+	go func() {
+		serializeRequest(m, "/b")
+		done <- struct{}{}
+	}()
+	for i := 0; i < 2; i++ {
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("requests for distinct keys were serialized")
+		}
+	}
+}
+
+func TestRejectConcurrentByKey(t *testing.T) {
+	t.Parallel()
+	m := web.New()
+	m.Use(RejectConcurrentByKey(pathKey))
+	entered := make(chan struct{}, 2)
+	release := make(chan struct{})
+	m.Put("/:key", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			entered <- struct{}{}
+			<-release
+		}
+	})
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() {
+		done <- serializeRequest(m, "/slow")
+	}()
+	<-entered
+
+	if w := serializeRequest(m, "/slow"); w.Code != http.StatusConflict {
+		t.Errorf("Expected 409, got %d", w.Code)
+	}
+	if w := serializeRequest(m, "/fast"); w.Code != http.StatusOK {
+		t.Errorf("Expected 200, got %d", w.Code)
+	}
+	close(release)
+	if w := <-done; w.Code != http.StatusOK {
+		t.Errorf("Expected 200, got %d", w.Code)
+	}
+	if w := serializeRequest(m, "/slow"); w.Code != http.StatusOK {
+		t.Errorf("Expected 200 once the key is free, got %d", w.Code)
+	}
+}
+
+func TestKeyedMutexCleanup(t *testing.T) {
+	t.Parallel()
+	k := newKeyedMutex()
+	if !k.lock("a", true, nil) {
+		t.Fatal("expected to acquire lock")
+	}
+	if k.lock("a", false, nil) {
+		t.Fatal("expected lock to be held")
+	}
+	done := make(chan struct{})
+	close(done)
+	if k.lock("a", true, done) {
+		t.Fatal("expected abandoned wait to fail")
+	}
+	k.unlock("a")
+	if n := k.len(); n != 0 {
+		t.Errorf("Expected all locks to be cleaned up, %d remain", n)
+	}
+}
@@ -0,0 +1,92 @@
+package middleware
+
+import (
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/zenazn/goji/web/mutil"
+)
+
+/*
+SlowlorisGuard returns a middleware that protects against "slowloris"-style
+attacks, in which a client ties up a connection by sending its request body
+very slowly. Once the rest of the stack starts reading the request body, the
+client is allowed to keep the reader waiting for a grace period, after which it
+must keep up a rate of at least minRate bytes per second. Clients that fall
+behind have their connection's read deadline expire, which causes the read to
+fail; if the handler has not yet responded, a 408 Request Timeout is sent and
+the connection is closed.
+
+Only time spent waiting for the client counts against it: time the handler
+spends between reads is not charged to the client.
+
+Request headers are read by package net/http before any middleware runs, so
+they cannot be protected here; configure the http.Server's ReadHeaderTimeout to
+bound the time a client may take to send them. SlowlorisGuard uses
+http.ResponseController to reach the underlying connection, so any middleware
+placed above it which wraps the http.ResponseWriter must provide an Unwrap
+method (as the writers in package mutil do). If the connection's read deadline
+cannot be set, requests pass through unguarded.
+*/
+func SlowlorisGuard(minRate int64, grace time.Duration) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			if r.Body == nil || r.Body == http.NoBody || minRate <= 0 {
+				h.ServeHTTP(w, r)
+				return
+			}
+			lw := mutil.WrapWriter(w)
+			body := &slowlorisBody{
+				ReadCloser: r.Body,
+				rc:         http.NewResponseController(w),
+				rate:       float64(minRate),
+				grace:      grace,
+			}
+			r.Body = body
+
+			h.ServeHTTP(lw, r)
+
+			if body.timedOut && lw.Status() == 0 {
+				lw.Header().Set("Connection", "close")
+				http.Error(lw, http.StatusText(http.StatusRequestTimeout),
+					http.StatusRequestTimeout)
+			}
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+type slowlorisBody struct {
+	io.ReadCloser
+	rc    *http.ResponseController
+	rate  float64
+	grace time.Duration
+	// The number of bytes read, and the total time spent waiting for them
+	n        int64
+	waited   time.Duration
+	timedOut bool
+}
+
+func (b *slowlorisBody) Read(buf []byte) (int, error) {
+	// By the time the next byte arrives, we'll have waited for a total of
+	// at most this long.
+	allowed := b.grace + time.Duration(float64(b.n+1)/b.rate*float64(time.Second))
+	start := time.Now()
+	if allowed <= b.waited {
+		allowed = b.waited + time.Millisecond
+	}
+	b.rc.SetReadDeadline(start.Add(allowed - b.waited))
+
+	n, err := b.ReadCloser.Read(buf)
+	b.n += int64(n)
+	b.waited += time.Since(start)
+
+	var ne net.Error
+	if errors.As(err, &ne) && ne.Timeout() {
+		b.timedOut = true
+	}
+	return n, err
+}
@@ -0,0 +1,81 @@
+package middleware
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func slowlorisServer() *httptest.Server {
+	h := func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			return
+		}
+		w.Write(body)
+	}
+	return httptest.NewServer(SlowlorisGuard(100, 50*time.Millisecond)(http.HandlerFunc(h)))
+}
+
+func sendSlowly(t *testing.T, addr string, body string, delay time.Duration) (*http.Response, time.Duration) {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	start := time.Now()
+	fmt.Fprintf(conn, "POST / HTTP/1.1\r\nHost: test\r\nContent-Length: %d\r\n\r\n",
+		len(body))
+	go func() {
+		for i := 0; i < len(body); i++ {
+			if _, err := conn.Write([]byte{body[i]}); err != nil {
+				return
+			}
+			time.Sleep(delay)
+		}
+	}()
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	if err != nil {
+		t.Fatalf("Error reading response: %v", err)
+	}
+	return resp, time.Since(start)
+}
+
+func TestSlowlorisGuard(t *testing.T) {
+	t.Parallel()
+	s := slowlorisServer()
+	defer s.Close()
+	addr := strings.TrimPrefix(s.URL, "http://")
+
+	// At 10 bytes per second we can't keep up the required 100.
+	body := strings.Repeat("x", 100)
+	resp, dt := sendSlowly(t, addr, body, 100*time.Millisecond)
+	if resp.StatusCode != http.StatusRequestTimeout {
+		t.Errorf("Expected 408, got %d", resp.StatusCode)
+	}
+	if !resp.Close {
+		t.Error("Expected the connection to be closed")
+	}
+	if dt > time.Second {
+		t.Errorf("Slow client took %v to be dropped", dt)
+	}
+
+	// But a quick client doesn't have any trouble.
+	resp, _ = sendSlowly(t, addr, body[:10], 0)
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected 200, got %d", resp.StatusCode)
+	}
+	got, _ := ioutil.ReadAll(resp.Body)
+	if string(got) != body[:10] {
+		t.Errorf("Expected body %q, got %q", body[:10], got)
+	}
+}
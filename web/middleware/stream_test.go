@@ -0,0 +1,71 @@
+package middleware
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/zenazn/goji/web"
+)
+
+func TestFlushThroughMiddleware(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	const events = 3
+	next := make(chan struct{})
+	m := web.New()
+	m.Use(RequestID)
+	m.Use(Logger)
+	m.Use(Recoverer)
+	m.Get("/events", func(w http.ResponseWriter, r *http.Request) {
+		f, ok := w.(http.Flusher)
+		if !ok {
+			t.Errorf("Expected the handler's writer to be a Flusher, got %T", w)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		for i := 0; i < events; i++ {
+			fmt.Fprintf(w, "data: %d\n\n", i)
+			f.Flush()
+			// Hold the response open until the client has seen the
+			// event, which it only can if the flush reached it.
+			select {
+			case <-next:
+			case <-time.After(5 * time.Second):
+				t.Errorf("Event %d was not received before the next was sent", i)
+				return
+			}
+		}
+	}).Use(web.Timeout(time.Minute))
+	ts := httptest.NewServer(m)
+	defer ts.Close()
+
+	res, err := http.Get(ts.URL + "/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	br := bufio.NewReader(res.Body)
+	for i := 0; i < events; i++ {
+		line, err := br.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expected := fmt.Sprintf("data: %d\n", i); line != expected {
+			t.Errorf("Expected %q, got %q", expected, line)
+		}
+		br.ReadString('\n')
+		select {
+		case next <- struct{}{}:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Handler gave up waiting after event %d", i)
+		}
+	}
+}
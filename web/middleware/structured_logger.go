@@ -0,0 +1,156 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/zenazn/goji/web"
+	"github.com/zenazn/goji/web/mutil"
+)
+
+// LogFormat is the format of the lines written by StructuredLogger.
+type LogFormat int
+
+const (
+	// LogText formats each line as space-separated key=value pairs, with
+	// values quoted where necessary.
+	LogText LogFormat = iota
+	// LogJSON formats each line as a JSON object.
+	LogJSON
+)
+
+// StructuredLoggerOptions configures StructuredLogger.
+type StructuredLoggerOptions struct {
+	// Format is the format of each line. Defaults to LogText.
+	Format LogFormat
+	// SkipPaths is a list of request paths (such as health checks) which
+	// are not logged.
+	SkipPaths []string
+}
+
+// logEntry is the set of fields StructuredLogger records for each request, in
+// the order they are written.
+type logEntry struct {
+	RequestID string  `json:"request_id,omitempty"`
+	Method    string  `json:"method"`
+	Path      string  `json:"path"`
+	Pattern   string  `json:"pattern,omitempty"`
+	Status    int     `json:"status"`
+	Bytes     int     `json:"bytes"`
+	Duration  float64 `json:"duration_ms"`
+}
+
+/*
+StructuredLogger returns a middleware which writes one line to w for each
+request, once it has been handled, recording its request ID (see RequestID), if
+it has one, its method and path, the pattern of the route it matched (if any),
+and the status, size in bytes and duration in milliseconds of its response, as
+measured from when the request entered this middleware until the handler
+returned. In the default text format, a line looks like:
+
+	request_id=host/abc-000001 method=GET path=/users/123 pattern=/users/:id status=200 bytes=512 duration_ms=1.25
+
+Unlike Logger, whose output is meant for humans, StructuredLogger's is meant
+to be parsed. Requests for any of opts.SkipPaths, and requests routed to
+LogSilent routes (see web.Route.LogLevel), are not logged. Lines are written
+with a single call to w's Write method, and concurrent requests do not write to
+w concurrently.
+*/
+func StructuredLogger(w io.Writer, opts StructuredLoggerOptions) func(*web.C, http.Handler) http.Handler {
+	skip := make(map[string]bool, len(opts.SkipPaths))
+	for _, path := range opts.SkipPaths {
+		skip[path] = true
+	}
+	var mu sync.Mutex
+
+	return func(c *web.C, h http.Handler) http.Handler {
+		fn := func(rw http.ResponseWriter, r *http.Request) {
+			if skip[r.URL.Path] {
+				h.ServeHTTP(rw, r)
+				return
+			}
+
+			t1 := time.Now()
+			lw := mutil.WrapWriter(rw)
+			h.ServeHTTP(lw, r)
+			dt := time.Since(t1)
+
+			if c.LogLevel() == web.LogSilent {
+				return
+			}
+			entry := logEntry{
+				RequestID: GetReqID(*c),
+				Method:    r.Method,
+				Path:      r.URL.Path,
+				Status:    lw.Status(),
+				Bytes:     lw.BytesWritten(),
+				Duration:  float64(dt) / float64(time.Millisecond),
+			}
+			if entry.Status == 0 {
+				entry.Status = http.StatusOK
+			}
+			if route := c.Route(); route != nil {
+				entry.Pattern = route.Pattern
+			}
+
+			var line []byte
+			if opts.Format == LogJSON {
+				line, _ = json.Marshal(entry)
+			} else {
+				line = entry.text()
+			}
+			mu.Lock()
+			w.Write(append(line, '\n'))
+			mu.Unlock()
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+func (e logEntry) text() []byte {
+	var buf bytes.Buffer
+	if e.RequestID != "" {
+		writeLogField(&buf, "request_id", e.RequestID)
+	}
+	writeLogField(&buf, "method", e.Method)
+	writeLogField(&buf, "path", e.Path)
+	if e.Pattern != "" {
+		writeLogField(&buf, "pattern", e.Pattern)
+	}
+	writeLogField(&buf, "status", strconv.Itoa(e.Status))
+	writeLogField(&buf, "bytes", strconv.Itoa(e.Bytes))
+	writeLogField(&buf, "duration_ms", strconv.FormatFloat(e.Duration, 'f', -1, 64))
+	return buf.Bytes()
+}
+
+// writeLogField writes a key=value pair, quoting the value if it is empty or
+// contains spaces, quotes, equals signs or non-printable characters.
+func writeLogField(buf *bytes.Buffer, key, value string) {
+	if buf.Len() > 0 {
+		buf.WriteByte(' ')
+	}
+	buf.WriteString(key)
+	buf.WriteByte('=')
+	if needsQuoting(value) {
+		buf.WriteString(strconv.Quote(value))
+	} else {
+		buf.WriteString(value)
+	}
+}
+
+func needsQuoting(s string) bool {
+	if s == "" {
+		return true
+	}
+	for _, r := range s {
+		if r <= ' ' || r == '"' || r == '=' || r == '\\' || r >= 0x7f {
+			return true
+		}
+	}
+	return false
+}
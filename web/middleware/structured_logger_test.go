@@ -0,0 +1,87 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/zenazn/goji/web"
+)
+
+func TestStructuredLogger(t *testing.T) {
+	t.Parallel()
+	var buf bytes.Buffer
+	m := web.New()
+	m.Use(RequestIDHeader("X-Request-Id"))
+	m.Use(StructuredLogger(&buf, StructuredLoggerOptions{
+		SkipPaths: []string{"/healthz"},
+	}))
+	m.Get("/users/:id", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello"))
+	})
+	m.Get("/healthz", func(w http.ResponseWriter, r *http.Request) {})
+	m.Get("/quiet", func(w http.ResponseWriter, r *http.Request) {}).
+		LogLevel(web.LogSilent)
+
+	for _, path := range []string{"/users/1", "/healthz", "/quiet", "/a b"} {
+		r, _ := http.NewRequest("GET", "/", nil)
+		r.URL.Path = path
+		r.Header.Set("X-Request-Id", "req-1")
+		m.ServeHTTP(httptest.NewRecorder(), r)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	expected := []*regexp.Regexp{
+		regexp.MustCompile(`^request_id=req-1 method=GET path=/users/1 ` +
+			`pattern=/users/:id status=200 bytes=5 duration_ms=[0-9.]+$`),
+		regexp.MustCompile(`^request_id=req-1 method=GET path="/a b" ` +
+			`status=404 bytes=19 duration_ms=[0-9.]+$`),
+	}
+	if len(lines) != len(expected) {
+		t.Fatalf("Expected %d lines, got %q", len(expected), lines)
+	}
+	for i, re := range expected {
+		if !re.MatchString(lines[i]) {
+			t.Errorf("Expected line %d to match %s, got %q", i, re, lines[i])
+		}
+	}
+}
+
+func TestStructuredLoggerJSON(t *testing.T) {
+	t.Parallel()
+	var buf bytes.Buffer
+	m := web.New()
+	m.Use(StructuredLogger(&buf, StructuredLoggerOptions{Format: LogJSON}))
+	m.Post("/widgets", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	})
+
+	r, _ := http.NewRequest("POST", "/widgets", nil)
+	m.ServeHTTP(httptest.NewRecorder(), r)
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("Expected a JSON line, got %q: %v", buf.String(), err)
+	}
+	for k, v := range map[string]interface{}{
+		"method":  "POST",
+		"path":    "/widgets",
+		"pattern": "/widgets",
+		"status":  float64(201),
+		"bytes":   float64(0),
+	} {
+		if entry[k] != v {
+			t.Errorf("Expected %s to be %v, got %v", k, v, entry[k])
+		}
+	}
+	if _, ok := entry["request_id"]; ok {
+		t.Errorf("Expected no request_id, got %v", entry["request_id"])
+	}
+	if _, ok := entry["duration_ms"].(float64); !ok {
+		t.Errorf("Expected a duration, got %v", entry["duration_ms"])
+	}
+}
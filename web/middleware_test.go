@@ -3,6 +3,7 @@ package web
 import (
 	"net/http"
 	"net/http/httptest"
+	"sync"
 	"testing"
 	"time"
 )
@@ -17,11 +18,7 @@ func makeStack(ch chan string) *mStack {
 	router := func(c *C, w http.ResponseWriter, r *http.Request) {
 		ch <- "router"
 	}
-	return &mStack{
-		stack:  make([]mLayer, 0),
-		pool:   makeCPool(),
-		router: iRouter(router),
-	}
+	return &mStack{router: iRouter(router)}
 }
 
 func chanWare(ch chan string, s string) func(http.Handler) http.Handler {
@@ -130,6 +127,30 @@ func TestInsert(t *testing.T) {
 	assertOrder(t, ch, "start", "one", "middle", "two", "router", "end")
 }
 
+func TestInsertAfter(t *testing.T) {
+	t.Parallel()
+
+	ch := make(chan string)
+	st := makeStack(ch)
+	one := chanWare(ch, "one")
+	two := chanWare(ch, "two")
+	st.Use(one)
+	st.Use(two)
+
+	err := st.InsertAfter(chanWare(ch, "sloth"), chanWare(ch, "squirrel"))
+	if err == nil {
+		t.Error("Expected error when referencing unknown middleware")
+	}
+
+	st.InsertAfter(chanWare(ch, "middle"), one)
+	err = st.InsertAfter(chanWare(ch, "last"), two)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go simpleRequest(ch, st)
+	assertOrder(t, ch, "one", "middle", "two", "last", "router", "end")
+}
+
 func TestAbandon(t *testing.T) {
 	t.Parallel()
 
@@ -169,11 +190,7 @@ func TestContext(t *testing.T) {
 			t.Error("Request id was not 2 :(")
 		}
 	}
-	st := mStack{
-		stack:  make([]mLayer, 0),
-		pool:   makeCPool(),
-		router: iRouter(router),
-	}
+	st := mStack{router: iRouter(router)}
 	st.Use(func(c *C, h http.Handler) http.Handler {
 		fn := func(w http.ResponseWriter, r *http.Request) {
 			if c.Env != nil || c.URLParams != nil {
@@ -202,3 +219,103 @@ func TestContext(t *testing.T) {
 	go simpleRequest(ch, &st)
 	assertOrder(t, ch, "end")
 }
+
+func maintenance(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "maintenance", http.StatusServiceUnavailable)
+	})
+}
+
+func TestReconfigureInFlight(t *testing.T) {
+	t.Parallel()
+	started, proceed := make(chan struct{}), make(chan struct{})
+	m := New()
+	m.Use(func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/slow" {
+				started <- struct{}{}
+				<-proceed
+			}
+			h.ServeHTTP(w, r)
+		})
+	})
+	m.Get("/*", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+
+	slow := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		r, _ := http.NewRequest("GET", "/slow", nil)
+		m.ServeHTTP(slow, r)
+		close(done)
+	}()
+	<-started
+	m.Use(maintenance)
+
+	r, _ := http.NewRequest("GET", "/fast", nil)
+	w := httptest.NewRecorder()
+	m.ServeHTTP(w, r)
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected a new request to see the new stack, got %d", w.Code)
+	}
+
+	close(proceed)
+	<-done
+	if slow.Body.String() != "ok" {
+		t.Errorf("Expected the request in flight to keep the old stack, "+
+			"got %q", slow.Body.String())
+	}
+}
+
+func TestReconfigureConcurrently(t *testing.T) {
+	t.Parallel()
+	tag := func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Tag", "yes")
+			h.ServeHTTP(w, r)
+		})
+	}
+	m := New()
+	m.Use(tag)
+	m.Get("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				r, _ := http.NewRequest("GET", "/", nil)
+				w := httptest.NewRecorder()
+				m.ServeHTTP(w, r)
+				if w.Code != http.StatusOK && w.Code != http.StatusServiceUnavailable {
+					t.Errorf("Unexpected response %d", w.Code)
+				}
+			}
+		}()
+	}
+
+	for i := 0; i < 200; i++ {
+		if err := m.Insert(maintenance, tag); err != nil {
+			t.Fatal(err)
+		}
+		if err := m.Abandon(maintenance); err != nil {
+			t.Fatal(err)
+		}
+		m.Use(maintenance)
+		if err := m.Abandon(maintenance); err != nil {
+			t.Fatal(err)
+		}
+	}
+	close(stop)
+	wg.Wait()
+}
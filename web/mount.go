@@ -0,0 +1,90 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+/*
+Mount routes every request whose path begins with the given prefix to sub, with
+the prefix trimmed from the path the sub-mux sees, so that it can be written
+with no knowledge of where it is mounted:
+
+	api := web.New()
+	api.Get("/users/:id", showUser)
+	m.Mount("/:tenant/api", api)
+	// GET /acme/api/users/123 is routed to showUser, with both "tenant"
+	// and "id" in c.URLParams
+
+The prefix is an ordinary string pattern, and may contain named parameters of
+its own. These are merged with the parameters bound by the sub-mux's routes.
+A request for the prefix itself (without a trailing slash) is routed to the
+sub-mux as "/". The parent's middleware runs before the sub-mux's, and both see
+the original, untrimmed request: the sub-mux is given a copy of the request with
+a trimmed URL.
+
+The returned Route is the parent's route to sub, and can be configured like any
+other.
+*/
+func (m *Mux) Mount(prefix string, sub *Mux) *Route {
+	prefix = strings.TrimSuffix(prefix, "/")
+	p := mountPattern{
+		exact: parseStringPattern(prefix),
+		tree:  parseStringPattern(prefix + "/*"),
+	}
+	return m.rt.handle(p, mALL, mountHandler{sub: sub, prefix: p.exact.raw})
+}
+
+// mountPattern matches both everything below a prefix and the prefix itself.
+type mountPattern struct {
+	exact, tree stringPattern
+}
+
+// Prefix returns the prefix of the exact pattern, since requests for the mount
+// point itself do not have the tree's trailing slash.
+func (p mountPattern) Prefix() string {
+	return p.exact.Prefix()
+}
+
+func (p mountPattern) Match(r *http.Request, c *C) bool {
+	return p.tree.Match(r, c) || p.exact.Match(r, c)
+}
+
+func (p mountPattern) Run(r *http.Request, c *C) {
+	if p.tree.Match(r, c) {
+		p.tree.Run(r, c)
+		return
+	}
+	p.exact.Run(r, c)
+	c.urlParams(1)["*"] = "/"
+}
+
+func (p mountPattern) String() string {
+	return fmt.Sprintf("mountPattern(%q)", p.tree.raw)
+}
+
+type mountHandler struct {
+	sub    *Mux
+	prefix string
+}
+
+func (h mountHandler) ServeHTTPC(c C, w http.ResponseWriter, r *http.Request) {
+	// The sub-mux gets its own copy of the parameters, less the unmatched
+	// tail, which it may well bind to a value of its own.
+	params := make(map[string]string, len(c.URLParams))
+	for k, v := range c.URLParams {
+		params[k] = v
+	}
+	path := params["*"]
+	delete(params, "*")
+	c.URLParams = params
+	c.mountPrefix += h.prefix
+
+	u := *r.URL
+	u.Path = path
+	u.RawPath = ""
+	r2 := r.WithContext(r.Context())
+	r2.URL = &u
+	h.sub.ServeHTTPC(c, w, r2)
+}
@@ -0,0 +1,91 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestMount(t *testing.T) {
+	t.Parallel()
+	var trace []string
+	tracer := func(name string) func(http.Handler) http.Handler {
+		return func(h http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				trace = append(trace, name+":"+r.URL.Path)
+				h.ServeHTTP(w, r)
+				trace = append(trace, name+":"+r.URL.Path)
+			})
+		}
+	}
+	show := func(c C, w http.ResponseWriter, r *http.Request) {
+		params := make([]string, 0, len(c.URLParams))
+		for k, v := range c.URLParams {
+			params = append(params, k+"="+v)
+		}
+		sort.Strings(params)
+		fmt.Fprintf(w, "%s %s", r.URL.Path, strings.Join(params, ","))
+	}
+
+	api := New()
+	api.Use(tracer("api"))
+	api.Get("/", show)
+	api.Get("/users/:id", show)
+	api.Get("/files/*", show)
+
+	m := New()
+	m.Use(tracer("root"))
+	m.Mount("/:tenant/api/", api)
+	m.Mount("/admin", api)
+	m.Get("/about", show)
+	m.Get("/administrators", show)
+
+	tests := []struct {
+		path, body string
+	}{
+		{"/acme/api/users/123", "/users/123 id=123,tenant=acme"},
+		{"/acme/api/files/a/b", "/files/a/b *=/a/b,tenant=acme"},
+		{"/acme/api/", "/ tenant=acme"},
+		{"/acme/api", "/ tenant=acme"},
+		{"/about", "/about "},
+		{"/admin", "/ "},
+		{"/admin/users/7", "/users/7 id=7"},
+		{"/administrators", "/administrators "},
+	}
+	for _, test := range tests {
+		r, _ := http.NewRequest("GET", test.path, nil)
+		w := httptest.NewRecorder()
+		m.ServeHTTP(w, r)
+		if w.Body.String() != test.body {
+			t.Errorf("For %q, expected %q, got %q", test.path,
+				test.body, w.Body.String())
+		}
+		if r.URL.Path != test.path {
+			t.Errorf("For %q, the path was changed to %q", test.path,
+				r.URL.Path)
+		}
+	}
+
+	trace = nil
+	r, _ := http.NewRequest("GET", "/acme/api/users/1", nil)
+	m.ServeHTTP(httptest.NewRecorder(), r)
+	expected := []string{
+		"root:/acme/api/users/1",
+		"api:/users/1",
+		"api:/users/1",
+		"root:/acme/api/users/1",
+	}
+	if strings.Join(trace, " ") != strings.Join(expected, " ") {
+		t.Errorf("Expected middleware to run as %v, got %v", expected, trace)
+	}
+
+	r, _ = http.NewRequest("GET", "/acme/api/nowhere", nil)
+	w := httptest.NewRecorder()
+	m.ServeHTTP(w, r)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected the sub-mux's 404, got %d", w.Code)
+	}
+}
@@ -14,6 +14,9 @@ type WriterProxy interface {
 	// Status returns the HTTP status of the request, or 0 if one has not
 	// yet been sent.
 	Status() int
+	// Written reports whether the response header has been sent, either
+	// explicitly with WriteHeader or implicitly by the first Write.
+	Written() bool
 	// BytesWritten returns the total number of bytes sent to the client.
 	BytesWritten() int
 	// Tee causes the response body to be written to the given io.Writer in
@@ -27,19 +30,46 @@ type WriterProxy interface {
 	Unwrap() http.ResponseWriter
 }
 
-// WrapWriter wraps an http.ResponseWriter, returning a proxy that allows you to
-// hook into various parts of the response process.
+// ErrorReporter is implemented by the proxies returned by WrapWriter. It is kept
+// apart from WriterProxy so that other implementations of WriterProxy need not
+// provide it; callers should check for it with a type assertion.
+type ErrorReporter interface {
+	// Err returns the first error returned by the proxied writer while
+	// writing the response body, or nil if there has not been one.
+	Err() error
+}
+
+/*
+WrapWriter wraps an http.ResponseWriter, returning a proxy that allows you to
+hook into various parts of the response process.
+
+The proxy forwards each of the optional interfaces http.CloseNotifier,
+http.Flusher, http.Hijacker, and http.Pusher if (and only if) w satisfies it,
+in any combination, so that wrapping a writer never hides a capability of the
+connection beneath it: a WebSocket upgrade, for instance, can hijack the
+connection through any number of proxies. The proxy always satisfies
+io.ReaderFrom, which uses w's ReadFrom if it has one.
+
+The proxy never buffers: each Write is passed straight to w, and Flush flushes w
+itself, so streaming responses like Server-Sent Events reach the client as they
+are written even through middleware (like middleware.Logger) which wraps the
+writer.
+*/
 func WrapWriter(w http.ResponseWriter) WriterProxy {
-	_, cn := w.(http.CloseNotifier)
-	_, fl := w.(http.Flusher)
-	_, hj := w.(http.Hijacker)
-	_, rf := w.(io.ReaderFrom)
-
-	bw := basicWriter{ResponseWriter: w}
-	if cn && fl && hj && rf {
-		return &fancyWriter{bw}
+	var caps int
+	if _, ok := w.(http.CloseNotifier); ok {
+		caps |= capCloseNotifier
+	}
+	if _, ok := w.(http.Flusher); ok {
+		caps |= capFlusher
+	}
+	if _, ok := w.(http.Hijacker); ok {
+		caps |= capHijacker
+	}
+	if _, ok := w.(http.Pusher); ok {
+		caps |= capPusher
 	}
-	return &bw
+	return wrapCaps(&basicWriter{ResponseWriter: w}, caps)
 }
 
 // basicWriter wraps a http.ResponseWriter that implements the minimal
@@ -50,6 +80,7 @@ type basicWriter struct {
 	code        int
 	bytes       int
 	tee         io.Writer
+	err         error
 }
 
 func (b *basicWriter) WriteHeader(code int) {
@@ -62,6 +93,9 @@ func (b *basicWriter) WriteHeader(code int) {
 func (b *basicWriter) Write(buf []byte) (int, error) {
 	b.WriteHeader(http.StatusOK)
 	n, err := b.ResponseWriter.Write(buf)
+	if err != nil && b.err == nil {
+		b.err = err
+	}
 	if b.tee != nil {
 		_, err2 := b.tee.Write(buf[:n])
 		// Prefer errors generated by the proxied writer.
@@ -80,6 +114,9 @@ func (b *basicWriter) maybeWriteHeader() {
 func (b *basicWriter) Status() int {
 	return b.code
 }
+func (b *basicWriter) Written() bool {
+	return b.wroteHeader
+}
 func (b *basicWriter) BytesWritten() int {
 	return b.bytes
 }
@@ -89,37 +126,162 @@ func (b *basicWriter) Tee(w io.Writer) {
 func (b *basicWriter) Unwrap() http.ResponseWriter {
 	return b.ResponseWriter
 }
+func (b *basicWriter) Err() error {
+	return b.err
+}
+
+// writerOnly hides every method of a writer but Write, so that io.Copy does
+// not call back into ReadFrom.
+type writerOnly struct {
+	io.Writer
+}
+
+func (b *basicWriter) ReadFrom(r io.Reader) (int64, error) {
+	rf, ok := b.ResponseWriter.(io.ReaderFrom)
+	if !ok || b.tee != nil {
+		return io.Copy(writerOnly{b}, r)
+	}
+	b.maybeWriteHeader()
+	n, err := rf.ReadFrom(r)
+	b.bytes += int(n)
+	if err != nil && b.err == nil {
+		b.err = err
+	}
+	return n, err
+}
+
+// Each of the following types supplies a single optional interface's method to
+// the proxies which embed it, forwarding the call to the proxied writer.
 
-// fancyWriter is a writer that additionally satisfies http.CloseNotifier,
-// http.Flusher, http.Hijacker, and io.ReaderFrom. It exists for the common case
-// of wrapping the http.ResponseWriter that package http gives you, in order to
-// make the proxied object support the full method set of the proxied object.
-type fancyWriter struct {
-	basicWriter
+type closeNotifier struct{ b *basicWriter }
+
+func (c closeNotifier) CloseNotify() <-chan bool {
+	return c.b.ResponseWriter.(http.CloseNotifier).CloseNotify()
 }
 
-func (f *fancyWriter) CloseNotify() <-chan bool {
-	cn := f.basicWriter.ResponseWriter.(http.CloseNotifier)
-	return cn.CloseNotify()
+type flusher struct{ b *basicWriter }
+
+func (f flusher) Flush() {
+	f.b.maybeWriteHeader()
+	f.b.ResponseWriter.(http.Flusher).Flush()
 }
-func (f *fancyWriter) Flush() {
-	fl := f.basicWriter.ResponseWriter.(http.Flusher)
-	fl.Flush()
+
+type hijacker struct{ b *basicWriter }
+
+func (h hijacker) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return h.b.ResponseWriter.(http.Hijacker).Hijack()
 }
-func (f *fancyWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
-	hj := f.basicWriter.ResponseWriter.(http.Hijacker)
-	return hj.Hijack()
+
+type pusher struct{ b *basicWriter }
+
+func (p pusher) Push(target string, opts *http.PushOptions) error {
+	return p.b.ResponseWriter.(http.Pusher).Push(target, opts)
 }
-func (f *fancyWriter) ReadFrom(r io.Reader) (int64, error) {
-	if f.basicWriter.tee != nil {
-		return io.Copy(&f.basicWriter, r)
+
+const (
+	capCloseNotifier = 1 << iota
+	capFlusher
+	capHijacker
+	capPusher
+)
+
+// wrapCaps returns a proxy around b which satisfies exactly the optional
+// interfaces in caps.
+func wrapCaps(b *basicWriter, caps int) WriterProxy {
+	cn, fl, hj, ps := closeNotifier{b}, flusher{b}, hijacker{b}, pusher{b}
+	switch caps {
+	case capCloseNotifier:
+		return struct {
+			*basicWriter
+			closeNotifier
+		}{b, cn}
+	case capFlusher:
+		return struct {
+			*basicWriter
+			flusher
+		}{b, fl}
+	case capCloseNotifier | capFlusher:
+		return struct {
+			*basicWriter
+			closeNotifier
+			flusher
+		}{b, cn, fl}
+	case capHijacker:
+		return struct {
+			*basicWriter
+			hijacker
+		}{b, hj}
+	case capCloseNotifier | capHijacker:
+		return struct {
+			*basicWriter
+			closeNotifier
+			hijacker
+		}{b, cn, hj}
+	case capFlusher | capHijacker:
+		return struct {
+			*basicWriter
+			flusher
+			hijacker
+		}{b, fl, hj}
+	case capCloseNotifier | capFlusher | capHijacker:
+		return struct {
+			*basicWriter
+			closeNotifier
+			flusher
+			hijacker
+		}{b, cn, fl, hj}
+	case capPusher:
+		return struct {
+			*basicWriter
+			pusher
+		}{b, ps}
+	case capCloseNotifier | capPusher:
+		return struct {
+			*basicWriter
+			closeNotifier
+			pusher
+		}{b, cn, ps}
+	case capFlusher | capPusher:
+		return struct {
+			*basicWriter
+			flusher
+			pusher
+		}{b, fl, ps}
+	case capCloseNotifier | capFlusher | capPusher:
+		return struct {
+			*basicWriter
+			closeNotifier
+			flusher
+			pusher
+		}{b, cn, fl, ps}
+	case capHijacker | capPusher:
+		return struct {
+			*basicWriter
+			hijacker
+			pusher
+		}{b, hj, ps}
+	case capCloseNotifier | capHijacker | capPusher:
+		return struct {
+			*basicWriter
+			closeNotifier
+			hijacker
+			pusher
+		}{b, cn, hj, ps}
+	case capFlusher | capHijacker | capPusher:
+		return struct {
+			*basicWriter
+			flusher
+			hijacker
+			pusher
+		}{b, fl, hj, ps}
+	case capCloseNotifier | capFlusher | capHijacker | capPusher:
+		return struct {
+			*basicWriter
+			closeNotifier
+			flusher
+			hijacker
+			pusher
+		}{b, cn, fl, hj, ps}
 	}
-	rf := f.basicWriter.ResponseWriter.(io.ReaderFrom)
-	f.basicWriter.maybeWriteHeader()
-	return rf.ReadFrom(r)
+	return b
 }
-
-var _ http.CloseNotifier = &fancyWriter{}
-var _ http.Flusher = &fancyWriter{}
-var _ http.Hijacker = &fancyWriter{}
-var _ io.ReaderFrom = &fancyWriter{}
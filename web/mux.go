@@ -1,7 +1,12 @@
 package web
 
 import (
+	"context"
+	"fmt"
 	"net/http"
+	"sync/atomic"
+
+	"github.com/zenazn/goji/web/mutil"
 )
 
 /*
@@ -15,51 +20,134 @@ respectively.
 
 Routes may be added using any of the HTTP verb functions (Get, Post, etc.), or
 through the generic Handle function. Goji's routing algorithm is very simple:
-routes are processed in the order they are added, and the first matching route
-will be executed. Routes match if their HTTP method and Pattern both match.
+routes are processed most specific first, and the first matching route will be
+executed. Routes match if their HTTP method and Pattern both match. Patterns
+are compared path segment by path segment, from left to right, with literal
+segments beating named parameters, which beat wildcards, so "/users/new" is
+tried before "/users/:id", which is tried before "/users/*", whichever order
+they were added in. Routes which are equally specific are processed in the
+order they were added.
+
+Each of the route-adding functions returns a Route, which can be used to
+further configure the route. In particular, a route's Priority can be raised
+(or lowered) to have it processed before (or after) routes which would otherwise
+take precedence over it (or which it would take precedence over).
 */
 type Mux struct {
-	ms mStack
-	rt router
+	ms              mStack
+	rt              router
+	responseHooks   []ResponseHook
+	writeErrorHooks []WriteErrorHook
+	trackResponses  bool
+	health          healthProbes
+	// See Mux.Shutdown.
+	active       atomic.Int64
+	shuttingDown atomic.Bool
 }
 
 // New creates a new Mux without any routes or middleware.
 func New() *Mux {
 	mux := Mux{
-		ms: mStack{
-			stack: make([]mLayer, 0),
-			pool:  makeCPool(),
-		},
 		rt: router{
-			routes:   make([]route, 0),
+			routes:   make([]*route, 0),
 			notFound: parseHandler(http.NotFound),
+			clock:    SystemClock,
+
+			notAcceptable:        statusHandler(http.StatusNotAcceptable),
+			unsupportedMediaType: statusHandler(http.StatusUnsupportedMediaType),
+			autoOptions:          true,
+			methodNotAllowed:     statusHandler(http.StatusMethodNotAllowed),
 		},
 	}
+	mux.rt.base, mux.rt.closeBase = context.WithCancel(context.Background())
+	mux.rt.mux = &mux
 	mux.ms.router = &mux.rt
 	return &mux
 }
 
 // ServeHTTP processes HTTP requests. Satisfies net/http.Handler.
 func (m *Mux) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	stack := m.ms.alloc()
-	stack.ServeHTTP(w, r)
-	m.ms.release(stack)
+	m.ServeHTTPC(C{}, w, r)
 }
 
 // ServeHTTPC creates a context dependent request with the given Mux. Satisfies
 // the Handler interface.
 func (m *Mux) ServeHTTPC(c C, w http.ResponseWriter, r *http.Request) {
+	if !m.admit(w) {
+		return
+	}
+	defer m.active.Add(-1)
+	rs := &requestState{}
+	rs.ctx = linkedCtx{Context: r.Context(), other: m.rt.base}
+	defer rs.ctx.release()
+	rs.req = *r.WithContext(&rs.ctx)
+	r = &rs.req
+	if c.rs == nil {
+		c.rs = rs
+	}
+	if c.ctx == nil {
+		c.ctx = &rs.ctx
+	} else {
+		// Keep whatever an enclosing Mux's middleware stored in c's
+		// context, but let the request's deadline and cancellation
+		// (and Close, which cancels rs.ctx) apply to it.
+		rs.cctx = linkedCtx{Context: c.ctx, other: &rs.ctx}
+		defer rs.cctx.release()
+		c.ctx = &rs.cctx
+	}
 	stack := m.ms.alloc()
+
+	var lw mutil.WriterProxy
+	if m.trackResponses || len(m.responseHooks) != 0 ||
+		len(m.writeErrorHooks) != 0 {
+		lw = mutil.WrapWriter(w)
+		w = lw
+		defer func() {
+			// Only reached with lw != nil if we're panicking
+			if lw != nil {
+				m.responded(stack.C, lw, r, false)
+			}
+		}()
+	}
+
 	stack.ServeHTTPC(c, w, r)
+	if lw != nil {
+		m.responded(stack.C, lw, r, true)
+		lw = nil
+	}
 	m.ms.release(stack)
 }
 
+/*
+Close cancels the context of every request this mux is serving (as well as the
+context of every request it serves in the future), so that long-running
+handlers which observe their request's context (r.Context()) can abort cleanly.
+Handlers that do not observe their context are unaffected. Close does not stop
+the mux from serving requests, and always returns nil.
+
+Close is complementary to the graceful shutdown provided by package graceful:
+graceful stops accepting new connections and waits for in-flight requests to
+drain, while Close tells the in-flight requests that they should hurry up. A
+typical shutdown sequence therefore begins a graceful shutdown, waits for some
+grace period, and then calls Close to abort whichever requests remain.
+*/
+func (m *Mux) Close() error {
+	m.rt.closeBase()
+	return nil
+}
+
 // Middleware Stack functions
 
 // Append the given middleware to the middleware stack.
 //
-// No attempt is made to enforce the uniqueness of middlewares. It is illegal to
-// call this function concurrently with active requests.
+// No attempt is made to enforce the uniqueness of middlewares.
+//
+// This function (like the other functions which modify the middleware stack)
+// may be called concurrently with active requests, for instance to switch a
+// maintenance mode middleware on and off. Changes are eventually consistent:
+// the stack is replaced rather than modified, so requests which are already
+// being served carry on with the middleware they started with, and only those
+// which begin after the change see it.
 func (m *Mux) Use(middleware MiddlewareType) {
 	m.ms.Use(middleware)
 }
@@ -68,18 +156,27 @@ func (m *Mux) Use(middleware MiddlewareType) {
 // the stack. Returns an error if "before" cannot be found in the current stack.
 //
 // No attempt is made to enforce the uniqueness of middlewares. If the insertion
-// point is ambiguous, the first (outermost) one is chosen. It is illegal to
-// call this function concurrently with active requests.
+// point is ambiguous, the first (outermost) one is chosen. See Use for how this
+// interacts with active requests.
 func (m *Mux) Insert(middleware, before MiddlewareType) error {
 	return m.ms.Insert(middleware, before)
 }
 
+// Insert the given middleware immediately after a given existing middleware in
+// the stack. Returns an error if "after" cannot be found in the current stack.
+//
+// No attempt is made to enforce the uniqueness of middlewares. If the insertion
+// point is ambiguous, the first (outermost) one is chosen. See Use for how this
+// interacts with active requests.
+func (m *Mux) InsertAfter(middleware, after MiddlewareType) error {
+	return m.ms.InsertAfter(middleware, after)
+}
+
 // Remove the given middleware from the middleware stack. Returns an error if
 // no such middleware can be found.
 //
 // If the name of the middleware to delete is ambiguous, the first (outermost)
-// one is chosen. It is illegal to call this function concurrently with active
-// requests.
+// one is chosen. See Use for how this interacts with active requests.
 func (m *Mux) Abandon(middleware MiddlewareType) error {
 	return m.ms.Abandon(middleware)
 }
@@ -97,83 +194,237 @@ doesn't strip this prefix from the path before forwarding it on (e.g., the
 handler will see the full path, including the "/admin/" part), but this
 functionality can easily be performed by an extra middleware layer.
 */
-func (m *Mux) Handle(pattern PatternType, handler HandlerType) {
-	m.rt.handleUntyped(pattern, mALL, handler)
+func (m *Mux) Handle(pattern PatternType, handler HandlerType) *Route {
+	return m.rt.handleUntyped(pattern, mALL, handler)
 }
 
 // Dispatch to the given handler when the pattern matches and the HTTP method is
 // CONNECT.
-func (m *Mux) Connect(pattern PatternType, handler HandlerType) {
-	m.rt.handleUntyped(pattern, mCONNECT, handler)
+func (m *Mux) Connect(pattern PatternType, handler HandlerType) *Route {
+	return m.rt.handleUntyped(pattern, mCONNECT, handler)
 }
 
 // Dispatch to the given handler when the pattern matches and the HTTP method is
 // DELETE.
-func (m *Mux) Delete(pattern PatternType, handler HandlerType) {
-	m.rt.handleUntyped(pattern, mDELETE, handler)
+func (m *Mux) Delete(pattern PatternType, handler HandlerType) *Route {
+	return m.rt.handleUntyped(pattern, mDELETE, handler)
 }
 
 // Dispatch to the given handler when the pattern matches and the HTTP method is
 // GET.
 //
 // All GET handlers also transparently serve HEAD requests, since net/http will
-// take care of all the fiddly bits for you. If you wish to provide an alternate
-// implementation of HEAD, you should add a handler explicitly and place it
-// above your GET handler.
-func (m *Mux) Get(pattern PatternType, handler HandlerType) {
-	m.rt.handleUntyped(pattern, mGET|mHEAD, handler)
+// take care of all the fiddly bits (like discarding the body) for you. If you
+// wish to provide an alternate implementation of HEAD, add it with Head: routes
+// added with Head take precedence over the GET routes of the same priority and
+// specificity, whichever order they were added in.
+func (m *Mux) Get(pattern PatternType, handler HandlerType) *Route {
+	return m.rt.handleUntyped(pattern, mGET|mHEAD, handler)
 }
 
 // Dispatch to the given handler when the pattern matches and the HTTP method is
 // HEAD.
-func (m *Mux) Head(pattern PatternType, handler HandlerType) {
-	m.rt.handleUntyped(pattern, mHEAD, handler)
+func (m *Mux) Head(pattern PatternType, handler HandlerType) *Route {
+	return m.rt.handleUntyped(pattern, mHEAD, handler)
+}
+
+/*
+Dispatch to the given handler when the pattern matches and the HTTP method is
+the given one. For the methods which have a function of their own, Method is
+equivalent to that function (so Method("GET", ...) also serves HEAD requests,
+just as Get does), but any other method may be routed too, such as WebDAV's
+REPORT or PROPFIND:
+
+	m.Method("REPORT", "/calendars/:id", calendarReport)
+
+Method names are case-sensitive, and routes for other methods are reported by
+the Allow header and the ValidMethodsKey environment variable alongside the
+standard methods. Method panics if method is not a valid HTTP method name.
+*/
+func (m *Mux) Method(method string, pattern PatternType, handler HandlerType) *Route {
+	if method == "GET" {
+		return m.Get(pattern, handler)
+	}
+	if meth, ok := validMethodsMap[method]; ok {
+		return m.rt.handleUntyped(pattern, meth, handler)
+	}
+	if !validVerb(method) {
+		panic(fmt.Sprintf("web: invalid HTTP method %q", method))
+	}
+	r := m.rt.handleUntyped(pattern, mIDK, handler)
+	m.rt.lock.Lock()
+	r.route.verb = method
+	m.rt.lock.Unlock()
+	return r
 }
 
 // Dispatch to the given handler when the pattern matches and the HTTP method is
 // OPTIONS.
-func (m *Mux) Options(pattern PatternType, handler HandlerType) {
-	m.rt.handleUntyped(pattern, mOPTIONS, handler)
+func (m *Mux) Options(pattern PatternType, handler HandlerType) *Route {
+	return m.rt.handleUntyped(pattern, mOPTIONS, handler)
 }
 
 // Dispatch to the given handler when the pattern matches and the HTTP method is
 // PATCH.
-func (m *Mux) Patch(pattern PatternType, handler HandlerType) {
-	m.rt.handleUntyped(pattern, mPATCH, handler)
+func (m *Mux) Patch(pattern PatternType, handler HandlerType) *Route {
+	return m.rt.handleUntyped(pattern, mPATCH, handler)
 }
 
 // Dispatch to the given handler when the pattern matches and the HTTP method is
 // POST.
-func (m *Mux) Post(pattern PatternType, handler HandlerType) {
-	m.rt.handleUntyped(pattern, mPOST, handler)
+func (m *Mux) Post(pattern PatternType, handler HandlerType) *Route {
+	return m.rt.handleUntyped(pattern, mPOST, handler)
 }
 
 // Dispatch to the given handler when the pattern matches and the HTTP method is
 // PUT.
-func (m *Mux) Put(pattern PatternType, handler HandlerType) {
-	m.rt.handleUntyped(pattern, mPUT, handler)
+func (m *Mux) Put(pattern PatternType, handler HandlerType) *Route {
+	return m.rt.handleUntyped(pattern, mPUT, handler)
 }
 
 // Dispatch to the given handler when the pattern matches and the HTTP method is
 // TRACE.
-func (m *Mux) Trace(pattern PatternType, handler HandlerType) {
-	m.rt.handleUntyped(pattern, mTRACE, handler)
+func (m *Mux) Trace(pattern PatternType, handler HandlerType) *Route {
+	return m.rt.handleUntyped(pattern, mTRACE, handler)
 }
 
 // Set the fallback (i.e., 404) handler for this mux.
 //
-// As a convenience, the context environment variable "goji.web.validMethods"
-// (also available as the constant ValidMethodsKey) will be set to the list of
-// HTTP methods that could have been routed had they been provided on an
-// otherwise identical request.
+// The handler is called by the router, at the bottom of the middleware stack, so
+// like any other handler it is passed a C populated by the mux's middleware, and
+// the middleware (a logger, say) runs for unmatched requests too.
+//
+// If AutoOptions is disabled, requests whose path was matched by some route but
+// whose method was not are also passed to this handler. As a convenience, the
+// context environment variable "goji.web.validMethods" (also available as the
+// constant ValidMethodsKey) will then be set to the list of HTTP methods that
+// could have been routed had they been provided on an otherwise identical
+// request.
 func (m *Mux) NotFound(handler HandlerType) {
 	m.rt.notFound = parseHandler(handler)
 }
 
-// Compile the list of routes into bytecode. This only needs to be done once
-// after all the routes have been added, and will be called automatically for
-// you (at some performance cost on the first request) if you do not call it
-// explicitly.
-func (m *Mux) Compile() {
+/*
+AutoOptions controls how the mux responds to requests whose path is matched by
+some route, but whose method is not. By default (or if enabled is true), the
+response carries an Allow header listing the methods which would have been
+routed, and is either an empty 200 OK for OPTIONS requests, or passed to the
+MethodNotAllowed handler for every other method. If enabled is false, such
+requests are passed to the NotFound handler instead, with the context
+environment variable ValidMethodsKey set to the list of methods which would have
+been routed (this is how the mux behaved before it supported AutoOptions, and is
+what middleware.AutomaticOptions expects).
+
+It is illegal to call this function concurrently with active requests.
+*/
+func (m *Mux) AutoOptions(enabled bool) {
+	m.rt.autoOptions = enabled
+}
+
+/*
+RedirectTrailingSlash controls what happens to requests which match no route,
+but would have been routed had their path had a trailing slash added or removed.
+If enabled, such requests are redirected to the alternate path, with their query
+string intact: with a 301 Moved Permanently for GET and HEAD requests, and a 308
+Permanent Redirect (which preserves the method and body) for every other method.
+Requests are only redirected if the alternate path would actually be routed, so
+enabling this never turns a 404 into a redirect to another 404. It is disabled
+by default.
+
+It is illegal to call this function concurrently with active requests.
+*/
+func (m *Mux) RedirectTrailingSlash(enabled bool) {
+	m.rt.redirectSlash = enabled
+}
+
+/*
+ReuseURLParams controls whether the URLParams maps of requests routed by this
+Mux are recycled. If enabled, each map is cleared once its request is done, and
+reused for a later request, which saves routes with parameters an allocation on
+every request. It is disabled by default.
+
+Enabling this changes the contract of C.URLParams: the map (and any C holding
+it) must not be retained past the end of the request, so goroutines which
+outlive the request, or which might, must be given a copy made by C.Copy. Code
+which hands c or c.URLParams to such goroutines without copying them will
+instead read the parameters of unrelated requests, so this must only be enabled
+once every handler and middleware of the Mux has been checked.
+
+It is illegal to call this function concurrently with active requests.
+*/
+func (m *Mux) ReuseURLParams(enabled bool) {
+	m.ms.reuseParams = enabled
+	m.ms.invalidate()
+}
+
+// Set the handler used when a request's path was matched by some route, but its
+// method was not (see AutoOptions). The Allow header and the context
+// environment variable ValidMethodsKey have already been set when the handler
+// is called. By default, a plain 405 Method Not Allowed is returned.
+func (m *Mux) MethodNotAllowed(handler HandlerType) {
+	m.rt.methodNotAllowed = parseHandler(handler)
+}
+
+// Set the handler used when a request would have been routed, were it not for
+// the fact that none of the media types produced by the matching routes (see
+// Route.Produces) are acceptable to the client. By default, a plain 406 Not
+// Acceptable is returned.
+func (m *Mux) NotAcceptable(handler HandlerType) {
+	m.rt.notAcceptable = parseHandler(handler)
+}
+
+// Set the handler used when a request would have been routed, were it not for
+// the fact that the request body's media type is not consumed by any of the
+// matching routes (see Route.Consumes). By default, a plain 415 Unsupported
+// Media Type is returned.
+func (m *Mux) UnsupportedMediaType(handler HandlerType) {
+	m.rt.unsupportedMediaType = parseHandler(handler)
+}
+
+// SetClock sets the Clock used by this mux's time-dependent features, such as
+// Route.Active. By default, the mux uses SystemClock. It is illegal to call this
+// function concurrently with active requests.
+func (m *Mux) SetClock(clock Clock) {
+	m.rt.clock = clock
+}
+
+// Routes returns a description of each of the routes that have been added to
+// this mux, in the order in which they will be considered for matching. The
+// routes of sub-muxes added with Mount are listed in place of the route they
+// are mounted by, with their patterns prefixed by the mount point's.
+func (m *Mux) Routes() []RouteInfo {
+	return m.rt.routeInfo()
+}
+
+/*
+Compile the list of routes into bytecode. This only needs to be done once after
+all the routes have been added, and will be called automatically for you (at
+some performance cost on the first request) if you do not call it explicitly.
+
+Compile also returns a warning for each route which can never be matched,
+because a route which is tried before it (in the order given by Routes) matches
+every request it would. Calling it at startup, or from a test, catches routes
+which were shadowed by mistake:
+
+	for _, w := range m.Compile() {
+		log.Print(w)
+	}
+
+Since routes are tried most specific first, adding "/users/:id" before
+"/users/me" shadows nothing. Routes are shadowed by routes with the same
+pattern, by routes whose patterns are as specific but broader (as "/:user" is
+than "/:org", or "/files/**" than "/files/*"), and by broader routes whose
+priority has been raised (see Route.Priority).
+
+The check is conservative: a route is only reported if it is certain to be
+unreachable. Only string patterns (see PatternType) are compared, and routes
+whose patterns have inline constraints, host or query constraints, or file
+extensions, as well as routes which consume or produce particular media types,
+are never reported as shadowing others, since they may decline requests their
+patterns would otherwise match. The routes of mounted sub-muxes are not
+compared either.
+*/
+func (m *Mux) Compile() []RouteWarning {
 	m.rt.compile()
+	return m.rt.shadowed()
 }
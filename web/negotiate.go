@@ -0,0 +1,292 @@
+package web
+
+import (
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type negotiation uint8
+
+const (
+	negUnsupportedMediaType negotiation = 1 << iota
+	negNotAcceptable
+)
+
+// statusHandler returns a Handler which responds with a plain error of the
+// given status.
+func statusHandler(code int) Handler {
+	return HandlerFunc(func(c C, w http.ResponseWriter, r *http.Request) {
+		http.Error(w, http.StatusText(code), code)
+	})
+}
+
+/*
+Consumes restricts this route to requests whose body has one of the given media
+types (e.g., "application/json"), as reported by the Content-Type header. A media
+type of the form "type/*" accepts any subtype. Requests with some other (or no)
+Content-Type fall through to the following routes, and if no other route
+matches, are passed to the mux's UnsupportedMediaType handler.
+*/
+func (r *Route) Consumes(mediaTypes ...string) *Route {
+	r.rt.lock.Lock()
+	defer r.rt.lock.Unlock()
+	r.route.consumes = mediaTypes
+	r.rt.setMachine(nil)
+	return r
+}
+
+/*
+Produces declares the media types this route is able to respond with, and
+restricts the route to requests which find at least one of them acceptable, as
+reported by the Accept header. Requests without an Accept header accept every
+media type. Requests which reject all of the given media types fall through to
+the following routes, and if no other route matches, are passed to the mux's
+NotAcceptable handler.
+*/
+func (r *Route) Produces(mediaTypes ...string) *Route {
+	r.rt.lock.Lock()
+	defer r.rt.lock.Unlock()
+	r.route.produces = mediaTypes
+	r.rt.setMachine(nil)
+	return r
+}
+
+/*
+NegotiateContentType returns whichever of the available media types the request
+finds most acceptable, according to the quality values of the Accept header
+(RFC 7231, section 5.3.2), in which the most specific media range matching each
+type determines its quality: given "text/*;q=0.5, text/html", "text/html" is
+preferred to "text/plain". Ties are broken in favor of the type listed first,
+so the first available type is returned for requests without an Accept header.
+If none of the available types is acceptable, the empty string is returned.
+*/
+func (c C) NegotiateContentType(r *http.Request, available ...string) string {
+	accept := parseAccept(r.Header.Get("Accept"))
+	best, bestQ := "", 0.0
+	for _, mt := range available {
+		if q := accept.quality(mt); q > bestQ {
+			best, bestQ = mt, q
+		}
+	}
+	return best
+}
+
+// ErrNotAcceptable is returned by C.Negotiate when the request accepts none of
+// the offered media types.
+var ErrNotAcceptable = errors.New("web: no acceptable media type")
+
+/*
+Negotiate responds to the request with whichever of the offered representations
+it finds most acceptable (see NegotiateContentType), setting the response's
+Content-Type to the chosen media type and calling the corresponding function to
+write the body:
+
+	err := c.Negotiate(w, r, map[string]func(io.Writer) error{
+		"application/json": func(w io.Writer) error {
+			return json.NewEncoder(w).Encode(widget)
+		},
+		"application/xml": func(w io.Writer) error {
+			return xml.NewEncoder(w).Encode(widget)
+		},
+	})
+
+Since maps are unordered, ties between equally acceptable types (including the
+case of a request without an Accept header) are broken in favor of the lexically
+first media type; use NegotiateContentType to choose a default of your own. If
+none of the offered types is acceptable, a plain 406 Not Acceptable is sent,
+and ErrNotAcceptable is returned. Otherwise, the error returned by the chosen
+function is returned.
+*/
+func (c C) Negotiate(w http.ResponseWriter, r *http.Request, offers map[string]func(io.Writer) error) error {
+	available := make([]string, 0, len(offers))
+	for mt := range offers {
+		available = append(available, mt)
+	}
+	sort.Strings(available)
+
+	mt := c.NegotiateContentType(r, available...)
+	if mt == "" {
+		http.Error(w, http.StatusText(http.StatusNotAcceptable),
+			http.StatusNotAcceptable)
+		return ErrNotAcceptable
+	}
+	w.Header().Set("Content-Type", mt)
+	return offers[mt](w)
+}
+
+// Accepts returns a pattern which matches the requests the given pattern
+// matches, but only if their Accept header shows that the client prefers the
+// given media type: it must be named, either exactly or by a "type/*" range,
+// with the highest quality value in the header. This allows requests for the
+// same path and method to be routed to different handlers by the representation
+// the client asks for:
+//
+//	m.Get(web.Accepts("application/json", "/data"), dataJSON)
+//	m.Get("/data", dataHTML)
+//
+// Requests which prefer some other media type (such as those of browsers, which
+// name HTML explicitly and accept everything else at a lower quality) fall
+// through to the following routes, as do requests without an Accept header and
+// those which accept "*/*" and nothing else, since they express no preference.
+// Routes with an Accepts pattern are tried before routes with the same pattern
+// and no Accept constraint, whichever order they were added in.
+//
+// Unlike Route.Produces, which excludes clients which find none of the route's
+// media types acceptable, Accepts only matches clients which ask for its media
+// type in particular, so it is suited to choosing between representations.
+func Accepts(mediaType string, pattern PatternType) Pattern {
+	return acceptsPattern{
+		Pattern:   ParsePattern(pattern),
+		mediaType: strings.ToLower(mediaType),
+	}
+}
+
+// acceptsPattern is a pattern which only matches requests which prefer its
+// media type. See Accepts.
+type acceptsPattern struct {
+	Pattern
+	mediaType string
+}
+
+func (p acceptsPattern) Match(r *http.Request, c *C) bool {
+	accept := parseAccept(r.Header.Get("Accept"))
+	return accept.prefers(p.mediaType) && p.Pattern.Match(r, c)
+}
+
+func (p acceptsPattern) String() string {
+	return patternString(p.Pattern) + " (Accept: " + p.mediaType + ")"
+}
+
+// negotiate returns the way in which the request fails to meet the route's
+// content negotiation requirements, or zero if it meets all of them. The request
+// body is checked first, so a request which fails both checks is reported as
+// an unsupported media type.
+func (rt route) negotiate(r *http.Request) negotiation {
+	if len(rt.consumes) != 0 {
+		mt, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if err != nil || !mediaTypeIn(mt, rt.consumes) {
+			return negUnsupportedMediaType
+		}
+	}
+	if len(rt.produces) != 0 {
+		accept := parseAccept(r.Header.Get("Accept"))
+		for _, mt := range rt.produces {
+			if accept.quality(mt) > 0 {
+				return 0
+			}
+		}
+		return negNotAcceptable
+	}
+	return 0
+}
+
+func mediaTypeIn(mt string, mediaTypes []string) bool {
+	for _, t := range mediaTypes {
+		if mediaRangeMatches(t, mt) {
+			return true
+		}
+	}
+	return false
+}
+
+// mediaRangeMatches returns true if the media type mt falls within the media
+// range mr, which may be a media type, "type/*", or "*/*".
+func mediaRangeMatches(mr, mt string) bool {
+	if mr == "*/*" || strings.EqualFold(mr, mt) {
+		return true
+	}
+	if strings.HasSuffix(mr, "/*") {
+		return len(mt) > len(mr)-1 &&
+			strings.EqualFold(mr[:len(mr)-1], mt[:len(mr)-1])
+	}
+	return false
+}
+
+type acceptRange struct {
+	mediaRange string
+	q          float64
+}
+
+// acceptHeader is a parsed Accept header, sorted from most to least specific
+// media range. A nil acceptHeader accepts everything.
+type acceptHeader []acceptRange
+
+// parseAccept parses an Accept header as described by RFC 7231, section 5.3.2.
+// Media ranges which cannot be parsed are ignored, and accept parameters other
+// than the quality value are discarded.
+func parseAccept(header string) acceptHeader {
+	if strings.TrimSpace(header) == "" {
+		return nil
+	}
+	ranges := make(acceptHeader, 0)
+	for _, part := range strings.Split(header, ",") {
+		mr, params, err := mime.ParseMediaType(part)
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if qs, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(qs, 64); err != nil {
+				continue
+			}
+		}
+		ranges = append(ranges, acceptRange{mr, q})
+	}
+	sort.Stable(bySpecificity(ranges))
+	return ranges
+}
+
+// quality returns the quality value the client assigned to the given media
+// type, or 0 if it is not acceptable.
+func (a acceptHeader) quality(mt string) float64 {
+	if a == nil {
+		return 1
+	}
+	mt = strings.ToLower(mt)
+	for _, r := range a {
+		if mediaRangeMatches(r.mediaRange, mt) {
+			return r.q
+		}
+	}
+	return 0
+}
+
+// prefers reports whether the client prefers the given (lowercase) media type:
+// whether the most specific media range matching it is not "*/*", and has the
+// highest quality value in the header.
+func (a acceptHeader) prefers(mt string) bool {
+	best := 0.0
+	for _, r := range a {
+		if r.q > best {
+			best = r.q
+		}
+	}
+	for _, r := range a {
+		if mediaRangeMatches(r.mediaRange, mt) {
+			return r.mediaRange != "*/*" && r.q > 0 && r.q == best
+		}
+	}
+	return false
+}
+
+func specificity(mr string) int {
+	if mr == "*/*" {
+		return 0
+	} else if strings.HasSuffix(mr, "/*") {
+		return 1
+	}
+	return 2
+}
+
+type bySpecificity acceptHeader
+
+func (a bySpecificity) Len() int      { return len(a) }
+func (a bySpecificity) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a bySpecificity) Less(i, j int) bool {
+	return specificity(a[i].mediaRange) > specificity(a[j].mediaRange)
+}
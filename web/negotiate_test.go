@@ -0,0 +1,224 @@
+package web
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseAccept(t *testing.T) {
+	t.Parallel()
+	accept := parseAccept("text/*;q=0.3, text/html;q=0.7, text/html;level=1, */*;q=0.5, bogus")
+	table := map[string]float64{
+		"text/html":        0.7,
+		"TEXT/HTML":        0.7,
+		"text/plain":       0.3,
+		"image/jpeg":       0.5,
+		"application/json": 0.5,
+	}
+	for mt, q := range table {
+		if actual := accept.quality(mt); actual != q {
+			t.Errorf("Expected %q to have quality %v, got %v", mt, q,
+				actual)
+		}
+	}
+
+	if q := parseAccept("").quality("image/png"); q != 1 {
+		t.Errorf("Empty Accept should accept everything, got %v", q)
+	}
+	if q := parseAccept("text/html, image/*;q=0").quality("image/png"); q != 0 {
+		t.Errorf("Explicitly refused type has quality %v", q)
+	}
+}
+
+func negotiationMux() *Mux {
+	m := New()
+	m.Post("/widgets", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("json"))
+	}).Consumes("application/json").Produces("application/json")
+	m.Post("/widgets", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("form"))
+	}).Consumes("application/x-www-form-urlencoded", "multipart/*")
+	return m
+}
+
+func negotiate(m *Mux, ctype, accept string) *httptest.ResponseRecorder {
+	r, _ := http.NewRequest("POST", "/widgets", nil)
+	if ctype != "" {
+		r.Header.Set("Content-Type", ctype)
+	}
+	if accept != "" {
+		r.Header.Set("Accept", accept)
+	}
+	w := httptest.NewRecorder()
+	m.ServeHTTP(w, r)
+	return w
+}
+
+func TestNegotiation(t *testing.T) {
+	t.Parallel()
+	m := negotiationMux()
+
+	table := []struct {
+		ctype, accept string
+		code          int
+		body          string
+	}{
+		{"application/json; charset=utf-8", "", 200, "json"},
+		{"application/json", "application/*", 200, "json"},
+		{"application/x-www-form-urlencoded", "text/html", 200, "form"},
+		{"multipart/form-data; boundary=x", "", 200, "form"},
+		{"text/plain", "", 415, ""},
+		{"", "", 415, ""},
+		{"application/json", "text/html", 406, ""},
+	}
+	for _, test := range table {
+		w := negotiate(m, test.ctype, test.accept)
+		if w.Code != test.code {
+			t.Errorf("For %q/%q, expected %d, got %d", test.ctype,
+				test.accept, test.code, w.Code)
+		}
+		if test.code == 200 && w.Body.String() != test.body {
+			t.Errorf("For %q/%q, expected %q, got %q", test.ctype,
+				test.accept, test.body, w.Body.String())
+		}
+	}
+}
+
+func TestNegotiationHandlers(t *testing.T) {
+	t.Parallel()
+	m := negotiationMux()
+	m.Use(func(c *C, h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			c.Env = map[interface{}]interface{}{"brand": "acme"}
+			h.ServeHTTP(w, r)
+		})
+	})
+	m.NotAcceptable(func(c C, w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotAcceptable)
+		w.Write([]byte(c.Env["brand"].(string) + " 406"))
+	})
+	m.UnsupportedMediaType(func(c C, w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnsupportedMediaType)
+		w.Write([]byte(c.Env["brand"].(string) + " 415"))
+	})
+
+	if w := negotiate(m, "application/json", "text/html"); w.Body.String() != "acme 406" {
+		t.Errorf("Expected custom 406, got %d %q", w.Code, w.Body.String())
+	}
+	if w := negotiate(m, "text/plain", ""); w.Body.String() != "acme 415" {
+		t.Errorf("Expected custom 415, got %d %q", w.Code, w.Body.String())
+	}
+}
+
+func TestNegotiateContentType(t *testing.T) {
+	t.Parallel()
+	available := []string{"application/json", "application/xml", "text/html"}
+	tests := []struct {
+		accept, expected string
+	}{
+		{"", "application/json"},
+		{"*/*", "application/json"},
+		{"text/html", "text/html"},
+		{"application/xml;q=0.9, application/json;q=0.8", "application/xml"},
+		{"application/*;q=0.5, text/html;q=0.6", "text/html"},
+		{"application/*, application/json;q=0", "application/xml"},
+		{"text/*;q=0.5, */*;q=0.1", "text/html"},
+		{"image/png", ""},
+		{"*/*;q=0", ""},
+	}
+	for _, test := range tests {
+		r, _ := http.NewRequest("GET", "/", nil)
+		if test.accept != "" {
+			r.Header.Set("Accept", test.accept)
+		}
+		if mt := (C{}).NegotiateContentType(r, available...); mt != test.expected {
+			t.Errorf("For %q, expected %q, got %q", test.accept,
+				test.expected, mt)
+		}
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	t.Parallel()
+	offers := map[string]func(io.Writer) error{
+		"application/json": func(w io.Writer) error {
+			_, err := io.WriteString(w, `{"ok":true}`)
+			return err
+		},
+		"application/xml": func(w io.Writer) error {
+			_, err := io.WriteString(w, `<ok/>`)
+			return err
+		},
+	}
+	tests := []struct {
+		accept string
+		code   int
+		ctype  string
+		body   string
+	}{
+		{"", 200, "application/json", `{"ok":true}`},
+		{"application/xml", 200, "application/xml", `<ok/>`},
+		{"text/html", 406, "text/plain; charset=utf-8", "Not Acceptable\n"},
+	}
+	for _, test := range tests {
+		r, _ := http.NewRequest("GET", "/", nil)
+		if test.accept != "" {
+			r.Header.Set("Accept", test.accept)
+		}
+		w := httptest.NewRecorder()
+		err := (C{}).Negotiate(w, r, offers)
+		if (err == ErrNotAcceptable) != (test.code == 406) {
+			t.Errorf("For %q, unexpected error %v", test.accept, err)
+		}
+		if w.Code != test.code || w.HeaderMap.Get("Content-Type") != test.ctype ||
+			w.Body.String() != test.body {
+			t.Errorf("For %q, expected %d %q %q, got %d %q %q", test.accept,
+				test.code, test.ctype, test.body, w.Code,
+				w.HeaderMap.Get("Content-Type"), w.Body.String())
+		}
+	}
+}
+
+func TestAccepts(t *testing.T) {
+	t.Parallel()
+	m := New()
+	m.Get("/data", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("html"))
+	})
+	m.Get(Accepts("application/json", "/data"), func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("json"))
+	})
+	m.Get(Accepts("text/csv", "/other"), func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("csv"))
+	})
+
+	tests := []struct {
+		path, accept, body string
+	}{
+		{"/data", "application/json", "json"},
+		{"/data", "Application/JSON; charset=utf-8", "json"},
+		{"/data", "application/*", "json"},
+		{"/data", "application/json;q=0.5, text/html;q=0.4", "json"},
+		{"/data", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", "html"},
+		{"/data", "text/html, application/json;q=0.9", "html"},
+		{"/data", "*/*", "html"},
+		{"/data", "", "html"},
+		{"/data", "application/json;q=0", "html"},
+		{"/other", "text/csv", "csv"},
+		{"/other", "application/json", "404 page not found\n"},
+	}
+	for _, test := range tests {
+		r, _ := http.NewRequest("GET", test.path, nil)
+		if test.accept != "" {
+			r.Header.Set("Accept", test.accept)
+		}
+		w := httptest.NewRecorder()
+		m.ServeHTTP(w, r)
+		if w.Body.String() != test.body {
+			t.Errorf("For %q with %q, expected %q, got %q", test.path,
+				test.accept, test.body, w.Body.String())
+		}
+	}
+}
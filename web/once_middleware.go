@@ -0,0 +1,58 @@
+package web
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+)
+
+/*
+OnceMiddleware returns a middleware that lazily constructs the middleware
+returned by init, which is called exactly once, the first time a request passes
+through this layer of the stack. This is useful for middleware that hold
+expensive resources (database pools, compiled templates, etc.) that should be
+created once, but not until the application actually starts serving traffic.
+
+If init returns an error (or a value which is not a valid MiddlewareType), the
+error is logged once and every request passing through this layer is answered
+with a 500 Internal Server Error. Init is not retried.
+*/
+func OnceMiddleware(init func() (MiddlewareType, error)) MiddlewareType {
+	var once sync.Once
+	var mw func(*C, http.Handler) http.Handler
+	var err error
+
+	setup := func() {
+		var raw MiddlewareType
+		raw, err = init()
+		if err == nil {
+			var ok bool
+			if mw, ok = parseMiddleware(raw); !ok {
+				err = fmt.Errorf(unknownMiddleware, raw)
+			}
+		}
+		if err != nil {
+			log.Printf("web: unable to initialize middleware: %v", err)
+		}
+	}
+
+	return func(c *C, h http.Handler) http.Handler {
+		// Each middleware stack instance is only ever used by a single
+		// request at a time, so we can lazily build (and cache) the
+		// inner layer for this instance without any further locking.
+		var inner http.Handler
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			once.Do(setup)
+			if err != nil {
+				http.Error(w, http.StatusText(500), 500)
+				return
+			}
+			if inner == nil {
+				inner = mw(c, h)
+			}
+			inner.ServeHTTP(w, r)
+		}
+		return http.HandlerFunc(fn)
+	}
+}
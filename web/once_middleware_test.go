@@ -0,0 +1,70 @@
+package web
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestOnceMiddleware(t *testing.T) {
+	t.Parallel()
+	var calls int32
+	m := New()
+	m.Use(OnceMiddleware(func() (MiddlewareType, error) {
+		atomic.AddInt32(&calls, 1)
+		return func(h http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("X-Once", "yes")
+				h.ServeHTTP(w, r)
+			})
+		}, nil
+	}))
+	m.Get("/", func(w http.ResponseWriter, r *http.Request) {})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r, _ := http.NewRequest("GET", "/", nil)
+			w := httptest.NewRecorder()
+			m.ServeHTTP(w, r)
+			if w.HeaderMap.Get("X-Once") != "yes" {
+				t.Error("middleware did not run")
+			}
+		}()
+	}
+	wg.Wait()
+
+	if calls != 1 {
+		t.Errorf("init was called %d times, expected once", calls)
+	}
+}
+
+func TestOnceMiddlewareError(t *testing.T) {
+	t.Parallel()
+	var calls int32
+	m := New()
+	m.Use(OnceMiddleware(func() (MiddlewareType, error) {
+		atomic.AddInt32(&calls, 1)
+		return nil, errors.New("no database for you")
+	}))
+	m.Get("/", func(w http.ResponseWriter, r *http.Request) {
+		t.Error("handler should not run")
+	})
+
+	for i := 0; i < 2; i++ {
+		r, _ := http.NewRequest("GET", "/", nil)
+		w := httptest.NewRecorder()
+		m.ServeHTTP(w, r)
+		if w.Code != http.StatusInternalServerError {
+			t.Errorf("Expected 500, got %d", w.Code)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("init was called %d times, expected once", calls)
+	}
+}
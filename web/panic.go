@@ -0,0 +1,72 @@
+package web
+
+import (
+	"net/http"
+)
+
+// PanicHandler handles a panic raised by a route's handler. It is passed the
+// same arguments as the handler that panicked, as well as the value passed to
+// panic. See Route.OnPanic.
+type PanicHandler func(c C, w http.ResponseWriter, r *http.Request, err interface{})
+
+/*
+OnPanic sets a function to recover from panics in this route's handler, in
+place of whatever recovery middleware (like middleware.Recoverer) has been
+installed on the Mux. This allows an experimental or unusual endpoint to handle
+its own failures specially, for instance by finishing a partial response, while
+every other route continues to use the global policy.
+
+Since the router sits at the bottom of the middleware stack, a route's panic
+handler always runs before any recovery middleware gets a chance to, and the
+panic never reaches the middleware stack at all. The global recovery middleware
+remains the fallback for routes without a panic handler, as well as for panics
+raised by the panic handler itself.
+*/
+func (r *Route) OnPanic(fn PanicHandler) *Route {
+	r.rt.lock.Lock()
+	defer r.rt.lock.Unlock()
+	r.route.onPanic = fn
+	r.rt.setMachine(nil)
+	return r
+}
+
+/*
+OnPanic sets a function to recover from panics raised while the mux is routing a
+request: those from the handlers of routes without a panic handler of their own,
+as well as those from the mux's NotFound and other fallback handlers. It is the
+mux's counterpart to Route.OnPanic, and exists so that the 500 Internal Server
+Error page can be rendered with the same C as the handler that failed:
+
+	m.Use(middleware.RequestID)
+	m.OnPanic(func(c web.C, w http.ResponseWriter, r *http.Request, err interface{}) {
+		c.JSON(w, http.StatusInternalServerError, map[string]string{
+			"error":      "internal error",
+			"request_id": web.RequestIDFromC(c),
+		})
+	})
+
+Like a route's panic handler, it runs inside the middleware stack, so it sees the
+values middleware has placed in C.Env, and panics it recovers from never reach
+recovery middleware like middleware.Recoverer. Pass nil to leave panics to the
+middleware stack, which is the default.
+*/
+func (m *Mux) OnPanic(fn PanicHandler) {
+	m.rt.onPanic = fn
+}
+
+// serve calls the route's handler (by way of its CORS policy and middleware, if
+// it has them), recovering from panics if the route has a panic handler.
+func (rt route) serve(c C, w http.ResponseWriter, r *http.Request) {
+	if rt.onPanic != nil {
+		defer func() {
+			if err := recover(); err != nil {
+				rt.onPanic(c, w, r, err)
+			}
+		}()
+	}
+	if rt.cors != nil {
+		rt.serveCORS(c, w, r)
+		return
+	}
+	rt.serveHandler(c, w, r)
+}
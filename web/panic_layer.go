@@ -0,0 +1,85 @@
+package web
+
+import "net/http"
+
+// The key used to record the layer of the middleware stack a panic was raised
+// in. See PanicLayerFromC.
+const PanicLayerKey = "goji.web.panicLayer"
+
+// PanicLayer identifies the layer of a Mux's middleware stack which raised a
+// panic. See PanicLayerFromC.
+type PanicLayer struct {
+	// Index is the position of the middleware in the stack, counting from
+	// zero for the outermost. Panics raised beneath the middleware stack,
+	// by the router (and so, usually, by a route's handler), are given the
+	// index one past that of the innermost middleware.
+	Index int
+	// Name is the name of the middleware: the fully qualified name of the
+	// function it was given as, or its type. For panics raised by the
+	// router, it is the name of the handler of the route the request was
+	// matched to, as reported by C.Route, or "router" if the request was
+	// not matched to a route.
+	Name string
+}
+
+/*
+PanicLayerFromC returns the layer of the middleware stack which raised the panic
+the request is unwinding from (or was, before it was recovered), or nil if no
+panic has been raised. It allows recovery middleware to report where a panic
+came from, as well as what it was:
+
+	m.Use(middleware.RecoverWith(func(c web.C, w http.ResponseWriter, r *http.Request, err interface{}) {
+		if l := web.PanicLayerFromC(c); l != nil {
+			log.Printf("panic in %s (layer %d): %v", l.Name, l.Index, err)
+		}
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	}))
+
+The Mux records the layer as the panic leaves it, without recovering from the
+panic, so the panic value and its stack trace are left untouched, and the
+deferred functions of the layers the panic passes through (for instance, those
+releasing resources placed in C.Env) run as usual. Only the innermost layer is
+recorded: panics which are recovered and raised again further out are still
+attributed to the layer they were first raised in, and panics raised by a mux
+mounted inside another (see Mux.Mount) are attributed to the layers of the
+mounted mux.
+*/
+func PanicLayerFromC(c C) *PanicLayer {
+	l, _ := c.env()[PanicLayerKey].(*PanicLayer)
+	return l
+}
+
+// layerGuard wraps a single layer of a middleware stack (or the router beneath
+// it), and records it in the request's context if it raises a panic.
+type layerGuard struct {
+	c     *C
+	h     http.Handler
+	index int
+	// The middleware as it was given to us, or nil for the router.
+	orig interface{}
+}
+
+func (g layerGuard) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	panicking := true
+	defer func() {
+		if panicking {
+			g.record()
+		}
+	}()
+	g.h.ServeHTTP(w, r)
+	panicking = false
+}
+
+func (g layerGuard) record() {
+	g.c.initEnv()
+	if _, ok := g.c.Env[PanicLayerKey]; ok {
+		return
+	}
+	l := &PanicLayer{Index: g.index, Name: "router"}
+	if g.orig != nil {
+		l.Name = valueName(g.orig)
+	} else if info := g.c.Route(); info != nil {
+		l.Name = info.Handler
+	}
+	g.c.Env[PanicLayerKey] = l
+}
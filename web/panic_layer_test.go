@@ -0,0 +1,79 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func panicky(c *C, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("panic") == "middleware" {
+			panic("middleware")
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+func TestPanicLayer(t *testing.T) {
+	t.Parallel()
+	var layer *PanicLayer
+	var cleanedUp bool
+	m := New()
+	m.Use(func(c *C, h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			defer func() {
+				if err := recover(); err != nil {
+					layer = PanicLayerFromC(*c)
+					w.WriteHeader(http.StatusInternalServerError)
+				}
+			}()
+			h.ServeHTTP(w, r)
+		})
+	})
+	m.Use(func(c *C, h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Deferred functions in the layers a panic passes
+			// through still run.
+			defer func() { cleanedUp = true }()
+			h.ServeHTTP(w, r)
+		})
+	})
+	m.Use(panicky)
+	m.Get("/boom", func(w http.ResponseWriter, r *http.Request) {
+		panic("handler")
+	})
+	m.Get("/ok", func(w http.ResponseWriter, r *http.Request) {})
+
+	tests := []struct {
+		path  string
+		index int
+		name  string
+	}{
+		{"/ok?panic=middleware", 2, "github.com/zenazn/goji/web.panicky"},
+		{"/boom", 3, "github.com/zenazn/goji/web.TestPanicLayer.func"},
+		{"/ok", -1, ""},
+	}
+	for _, test := range tests {
+		layer, cleanedUp = nil, false
+		r, _ := http.NewRequest("GET", test.path, nil)
+		w := httptest.NewRecorder()
+		m.ServeHTTP(w, r)
+		if test.index < 0 {
+			if layer != nil || w.Code != http.StatusOK {
+				t.Errorf("For %q, expected no panic, got %d %+v",
+					test.path, w.Code, layer)
+			}
+			continue
+		}
+		if !cleanedUp {
+			t.Errorf("For %q, expected deferred functions to run", test.path)
+		}
+		if layer == nil || layer.Index != test.index ||
+			!strings.HasPrefix(layer.Name, test.name) {
+			t.Errorf("For %q, expected layer %d (%s), got %+v",
+				test.path, test.index, test.name, layer)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestOnPanic(t *testing.T) {
+	t.Parallel()
+	m := New()
+	m.Use(func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			defer func() {
+				if err := recover(); err != nil {
+					w.WriteHeader(http.StatusInternalServerError)
+					w.Write([]byte("global"))
+				}
+			}()
+			h.ServeHTTP(w, r)
+		})
+	})
+
+	var recovered interface{}
+	m.Get("/risky/:id", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("partial"))
+		panic("oops")
+	}).OnPanic(func(c C, w http.ResponseWriter, r *http.Request, err interface{}) {
+		recovered = err
+		w.Write([]byte(" response for " + c.URLParams["id"]))
+	})
+	m.Get("/safe", func(w http.ResponseWriter, r *http.Request) {
+		panic("oops")
+	})
+	m.Get("/doubly-risky", func(w http.ResponseWriter, r *http.Request) {
+		panic("oops")
+	}).OnPanic(func(c C, w http.ResponseWriter, r *http.Request, err interface{}) {
+		panic(err)
+	})
+
+	tests := []struct {
+		path string
+		code int
+		body string
+	}{
+		{"/risky/123", http.StatusOK, "partial response for 123"},
+		{"/safe", http.StatusInternalServerError, "global"},
+		{"/doubly-risky", http.StatusInternalServerError, "global"},
+	}
+	for _, test := range tests {
+		r, _ := http.NewRequest("GET", test.path, nil)
+		w := httptest.NewRecorder()
+		m.ServeHTTP(w, r)
+		if w.Code != test.code || w.Body.String() != test.body {
+			t.Errorf("For %q, expected %d %q, got %d %q", test.path,
+				test.code, test.body, w.Code, w.Body.String())
+		}
+	}
+	if recovered != "oops" {
+		t.Errorf("Panic handler was passed %v", recovered)
+	}
+}
+
+func TestMuxOnPanic(t *testing.T) {
+	t.Parallel()
+	m := New()
+	m.Use(func(c *C, h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if c.Env == nil {
+				c.Env = make(map[interface{}]interface{})
+			}
+			c.Env["user"] = "carl"
+			h.ServeHTTP(w, r)
+		})
+	})
+	m.OnPanic(func(c C, w http.ResponseWriter, r *http.Request, err interface{}) {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(c.Env["user"].(string) + ": " + err.(string)))
+	})
+
+	m.Get("/boom", func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	})
+	m.Get("/own", func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}).OnPanic(func(c C, w http.ResponseWriter, r *http.Request, err interface{}) {
+		w.Write([]byte("own handler"))
+	})
+	m.NotFound(func(c C, w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing/boom" {
+			panic("not found")
+		}
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("no page for " + c.Env["user"].(string)))
+	})
+
+	tests := []struct {
+		path string
+		code int
+		body string
+	}{
+		{"/boom", http.StatusInternalServerError, "carl: boom"},
+		{"/own", http.StatusOK, "own handler"},
+		{"/missing", http.StatusNotFound, "no page for carl"},
+		{"/missing/boom", http.StatusInternalServerError, "carl: not found"},
+	}
+	for _, test := range tests {
+		r, _ := http.NewRequest("GET", test.path, nil)
+		w := httptest.NewRecorder()
+		m.ServeHTTP(w, r)
+		if w.Code != test.code || w.Body.String() != test.body {
+			t.Errorf("For %q, expected %d %q, got %d %q", test.path,
+				test.code, test.body, w.Code, w.Body.String())
+		}
+	}
+}
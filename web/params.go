@@ -0,0 +1,221 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Errors describing why a URL parameter could not be parsed. They are wrapped
+// in a ParamError.
+var (
+	// ErrParamMissing is returned when the route did not capture a URL
+	// parameter of the given name at all.
+	ErrParamMissing = errors.New("missing")
+	// ErrParamEmpty is returned when the URL parameter was captured, but
+	// is empty (as can happen with wildcards and regular expressions).
+	ErrParamEmpty = errors.New("empty")
+	// ErrParamSyntax is returned when the URL parameter is not of the
+	// expected form.
+	ErrParamSyntax = errors.New("invalid syntax")
+	// ErrParamRange is returned when the URL parameter is a number too
+	// large to be represented by the requested type.
+	ErrParamRange = errors.New("out of range")
+)
+
+// ParamError is the error returned by the typed URL parameter accessors (like
+// C.ParamInt). Its message is suitable for reporting to clients, for instance as
+// part of a 400 Bad Request response.
+type ParamError struct {
+	// Name is the name of the URL parameter.
+	Name string
+	// Value is the raw value of the URL parameter.
+	Value string
+	// Type is a description of the type the value was to be parsed as.
+	Type string
+	// Err is one of ErrParamMissing, ErrParamEmpty, ErrParamSyntax, or
+	// ErrParamRange.
+	Err error
+}
+
+func (e *ParamError) Error() string {
+	switch e.Err {
+	case ErrParamMissing, ErrParamEmpty:
+		return fmt.Sprintf("web: URL parameter %q is %v", e.Name, e.Err)
+	}
+	return fmt.Sprintf("web: URL parameter %q: %q is not a valid %s (%v)",
+		e.Name, e.Value, e.Type, e.Err)
+}
+
+// Unwrap returns e.Err.
+func (e *ParamError) Unwrap() error {
+	return e.Err
+}
+
+// param returns the raw value of the named URL parameter, or an error if it is
+// missing or empty.
+func (c C) param(name, typ string) (string, error) {
+	v, ok := c.URLParams[name]
+	if !ok {
+		return "", &ParamError{Name: name, Type: typ, Err: ErrParamMissing}
+	} else if v == "" {
+		return "", &ParamError{Name: name, Type: typ, Err: ErrParamEmpty}
+	}
+	return v, nil
+}
+
+// ParamInt64 parses the named URL parameter as a base 10 integer. If it cannot,
+// the returned error is a *ParamError describing why.
+func (c C) ParamInt64(name string) (int64, error) {
+	v, err := c.param(name, "integer")
+	if err != nil {
+		return 0, err
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return 0, numError(name, v, "integer", err)
+	}
+	return n, nil
+}
+
+// numError converts an error returned by package strconv into a *ParamError.
+func numError(name, value, typ string, err error) *ParamError {
+	perr := &ParamError{Name: name, Value: value, Type: typ,
+		Err: ErrParamSyntax}
+	if err.(*strconv.NumError).Err == strconv.ErrRange {
+		perr.Err = ErrParamRange
+	}
+	return perr
+}
+
+// ParamInt is like ParamInt64, but returns an int.
+func (c C) ParamInt(name string) (int, error) {
+	n, err := c.ParamInt64(name)
+	if err != nil {
+		return 0, err
+	}
+	if int64(int(n)) != n {
+		return 0, &ParamError{Name: name, Value: c.URLParams[name],
+			Type: "integer", Err: ErrParamRange}
+	}
+	return int(n), nil
+}
+
+// ParamIntDefault is like ParamInt, but returns def if the named URL parameter
+// is missing, empty, or cannot be parsed. It is intended for optional
+// parameters, like those bound by regular expressions with optional groups.
+func (c C) ParamIntDefault(name string, def int) int {
+	n, err := c.ParamInt(name)
+	if err != nil {
+		return def
+	}
+	return n
+}
+
+// ParamUint64 parses the named URL parameter as a base 10 unsigned integer. If
+// it cannot, the returned error is a *ParamError describing why.
+func (c C) ParamUint64(name string) (uint64, error) {
+	v, err := c.param(name, "unsigned integer")
+	if err != nil {
+		return 0, err
+	}
+	n, err := strconv.ParseUint(v, 10, 64)
+	if err != nil {
+		return 0, numError(name, v, "unsigned integer", err)
+	}
+	return n, nil
+}
+
+// ParamUint is like ParamUint64, but returns a uint.
+func (c C) ParamUint(name string) (uint, error) {
+	n, err := c.ParamUint64(name)
+	if err != nil {
+		return 0, err
+	}
+	if uint64(uint(n)) != n {
+		return 0, &ParamError{Name: name, Value: c.URLParams[name],
+			Type: "unsigned integer", Err: ErrParamRange}
+	}
+	return uint(n), nil
+}
+
+// ParamBool parses the named URL parameter as a boolean, accepting the same
+// values as strconv.ParseBool ("1", "t", "true", "0", "f", "false", and so on).
+// If it cannot, the returned error is a *ParamError describing why.
+func (c C) ParamBool(name string) (bool, error) {
+	v, err := c.param(name, "boolean")
+	if err != nil {
+		return false, err
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, &ParamError{Name: name, Value: v, Type: "boolean",
+			Err: ErrParamSyntax}
+	}
+	return b, nil
+}
+
+// ParamUUID checks that the named URL parameter is a UUID in its canonical,
+// hyphenated form (in either case), returning it in lower case. If it is not,
+// the returned error is a *ParamError describing why.
+func (c C) ParamUUID(name string) (string, error) {
+	v, err := c.param(name, "UUID")
+	if err != nil {
+		return "", err
+	}
+	if !isUUID(v) {
+		return "", &ParamError{Name: name, Value: v, Type: "UUID",
+			Err: ErrParamSyntax}
+	}
+	return strings.ToLower(v), nil
+}
+
+func isUUID(s string) bool {
+	if len(s) != 36 {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		switch i {
+		case 8, 13, 18, 23:
+			if s[i] != '-' {
+				return false
+			}
+			continue
+		}
+		if !strings.ContainsRune("0123456789abcdefABCDEF", rune(s[i])) {
+			return false
+		}
+	}
+	return true
+}
+
+// MustParamInt64 is like ParamInt64, but panics (with a *ParamError) if the
+// parameter cannot be parsed.
+func (c C) MustParamInt64(name string) int64 {
+	n, err := c.ParamInt64(name)
+	if err != nil {
+		panic(err)
+	}
+	return n
+}
+
+// MustParamInt is like ParamInt, but panics (with a *ParamError) if the
+// parameter cannot be parsed.
+func (c C) MustParamInt(name string) int {
+	n, err := c.ParamInt(name)
+	if err != nil {
+		panic(err)
+	}
+	return n
+}
+
+// MustParamUUID is like ParamUUID, but panics (with a *ParamError) if the
+// parameter is not a UUID.
+func (c C) MustParamUUID(name string) string {
+	id, err := c.ParamUUID(name)
+	if err != nil {
+		panic(err)
+	}
+	return id
+}
@@ -0,0 +1,152 @@
+package web
+
+import (
+	"testing"
+)
+
+var paramParsingTests = []struct {
+	value string
+	n     int64
+	err   error
+}{
+	{"123", 123, nil},
+	{"-7", -7, nil},
+	{"abc", 0, ErrParamSyntax},
+	{"12abc", 0, ErrParamSyntax},
+	{"99999999999999999999", 0, ErrParamRange},
+	{"", 0, ErrParamEmpty},
+}
+
+func TestParamInt(t *testing.T) {
+	t.Parallel()
+	for _, test := range paramParsingTests {
+		c := C{URLParams: map[string]string{"id": test.value}}
+		n, err := c.ParamInt64("id")
+		if test.err == nil {
+			if err != nil || n != test.n {
+				t.Errorf("For %q, expected %d, got %d, %v", test.value,
+					test.n, n, err)
+			}
+			if m := c.MustParamInt("id"); int64(m) != test.n {
+				t.Errorf("For %q, MustParamInt returned %d", test.value, m)
+			}
+			continue
+		}
+		perr, ok := err.(*ParamError)
+		if !ok || perr.Err != test.err || perr.Name != "id" {
+			t.Errorf("For %q, expected %v, got %v", test.value, test.err, err)
+		}
+		if _, err := c.ParamInt("id"); err == nil {
+			t.Errorf("For %q, expected ParamInt to fail too", test.value)
+		}
+	}
+
+	_, err := C{}.ParamInt("id")
+	if perr, ok := err.(*ParamError); !ok || perr.Err != ErrParamMissing {
+		t.Errorf("Expected missing parameter error, got %v", err)
+	}
+}
+
+func TestParamUUID(t *testing.T) {
+	t.Parallel()
+	c := C{URLParams: map[string]string{
+		"good": "6BA7B810-9DAD-11D1-80B4-00C04FD430C8",
+		"bad":  "6ba7b810-9dad-11d1-80b4_00c04fd430c8",
+	}}
+	id, err := c.ParamUUID("good")
+	if err != nil || id != "6ba7b810-9dad-11d1-80b4-00c04fd430c8" {
+		t.Errorf("Expected lowercase UUID, got %q, %v", id, err)
+	}
+	_, err = c.ParamUUID("bad")
+	if perr, ok := err.(*ParamError); !ok || perr.Err != ErrParamSyntax {
+		t.Errorf("Expected syntax error, got %v", err)
+	}
+}
+
+func TestMustParamPanics(t *testing.T) {
+	t.Parallel()
+	defer func() {
+		if _, ok := recover().(*ParamError); !ok {
+			t.Error("Expected MustParamInt to panic with a *ParamError")
+		}
+	}()
+	C{}.MustParamInt("id")
+}
+
+var paramUintTests = []struct {
+	value string
+	n     uint64
+	err   error
+}{
+	{"123", 123, nil},
+	{"-7", 0, ErrParamSyntax},
+	{"+7", 0, ErrParamSyntax},
+	{"18446744073709551615", 18446744073709551615, nil},
+	{"18446744073709551616", 0, ErrParamRange},
+	{"", 0, ErrParamEmpty},
+}
+
+func TestParamUint(t *testing.T) {
+	t.Parallel()
+	for _, test := range paramUintTests {
+		c := C{URLParams: map[string]string{"id": test.value}}
+		n, err := c.ParamUint64("id")
+		if test.err == nil {
+			if err != nil || n != test.n {
+				t.Errorf("For %q, expected %d, got %d, %v", test.value,
+					test.n, n, err)
+			}
+			continue
+		}
+		perr, ok := err.(*ParamError)
+		if !ok || perr.Err != test.err || perr.Name != "id" {
+			t.Errorf("For %q, expected %v, got %v", test.value, test.err, err)
+		}
+		if _, err := c.ParamUint("id"); err == nil {
+			t.Errorf("For %q, expected ParamUint to fail too", test.value)
+		}
+	}
+
+	c := C{URLParams: map[string]string{"id": "42"}}
+	if n, err := c.ParamUint("id"); err != nil || n != 42 {
+		t.Errorf("Expected 42, got %d, %v", n, err)
+	}
+}
+
+func TestParamBool(t *testing.T) {
+	t.Parallel()
+	c := C{URLParams: map[string]string{
+		"yes":   "true",
+		"one":   "1",
+		"no":    "F",
+		"maybe": "maybe",
+	}}
+	for name, expected := range map[string]bool{"yes": true, "one": true, "no": false} {
+		if b, err := c.ParamBool(name); err != nil || b != expected {
+			t.Errorf("For %q, expected %v, got %v, %v", name, expected, b, err)
+		}
+	}
+	_, err := c.ParamBool("maybe")
+	if perr, ok := err.(*ParamError); !ok || perr.Err != ErrParamSyntax {
+		t.Errorf("Expected a syntax error, got %v", err)
+	}
+	_, err = c.ParamBool("missing")
+	if perr, ok := err.(*ParamError); !ok || perr.Err != ErrParamMissing {
+		t.Errorf("Expected missing parameter error, got %v", err)
+	}
+}
+
+func TestParamIntDefault(t *testing.T) {
+	t.Parallel()
+	c := C{URLParams: map[string]string{
+		"page":  "3",
+		"empty": "",
+		"bad":   "three",
+	}}
+	tests := map[string]int{"page": 3, "empty": 1, "bad": 1, "missing": 1}
+	for name, expected := range tests {
+		if n := c.ParamIntDefault(name, 1); n != expected {
+			t.Errorf("For %q, expected %d, got %d", name, expected, n)
+		}
+	}
+}
@@ -4,21 +4,45 @@ import (
 	"log"
 	"net/http"
 	"regexp"
+	"sync"
 )
 
-// A Pattern determines whether or not a given request matches some criteria.
-// They are often used in routes, which are essentially (pattern, methodSet,
-// handler) tuples. If the method and pattern match, the given handler is used.
-//
-// Built-in implementations of this interface are used to implement regular
-// expression and string matching.
+/*
+A Pattern determines whether or not a given request matches some criteria.
+They are often used in routes, which are essentially (pattern, methodSet,
+handler) tuples. If the method and pattern match, the given handler is used.
+
+Built-in implementations of this interface are used to implement regular
+expression and string matching, but any type can be used as a route's pattern
+by implementing it, and types which do not can be accepted by the router by
+registering a parser for them with RegisterPatternParser.
+
+When routing a request, the router first discards every route whose Prefix is
+not a prefix of the request's path, and then calls Match on the remaining
+routes, in order, until one returns true and its method is acceptable. Match
+may therefore be called many times for a single request, including for
+requests which are ultimately routed elsewhere, and should not have any side
+effects. Once a route has been chosen, its pattern's Run is called exactly
+once, with the same request and context, to bind the URL parameters (or other
+parsed state) the handler will see. By convention, Run adds each parameter to
+c.URLParams (allocating it if it is nil, and leaving existing entries for other
+names alone), and a pattern which matches a trailing portion of the path it
+does not otherwise interpret binds it to the key "*", including the leading
+"/", so that it composes with Mux.Mount and with the built-in patterns.
+*/
 type Pattern interface {
 	// In practice, most real-world routes have a string prefix that can be
 	// used to quickly determine if a pattern is an eligible match. The
 	// router uses the result of this function to optimize away calls to the
-	// full Match function, which is likely much more expensive to compute.
-	// If your Pattern does not support prefixes, this function should
-	// return the empty string.
+	// full Match function, which is likely much more expensive to compute:
+	// Match is never called for requests whose path does not begin with
+	// the prefix (compared without regard to case on case-insensitive
+	// Muxes, see Mux.CaseInsensitive). The prefix must therefore be a
+	// prefix of every path the pattern matches, or the requests it fails
+	// to be a prefix of will be routed elsewhere. If your Pattern does not
+	// support prefixes, this function should return the empty string, in
+	// which case Match is called for every request that reaches the route.
+	// It is called once, when the route is added.
 	Prefix() string
 	// Returns true if the request satisfies the pattern. This function is
 	// free to examine both the request and the context to make this
@@ -27,10 +51,49 @@ type Pattern interface {
 	// request, it should be reasonably efficient.
 	Match(r *http.Request, c *C) bool
 	// Run the pattern on the request and context, modifying the context as
-	// necessary to bind URL parameters or other parsed state.
+	// necessary to bind URL parameters or other parsed state. Run is only
+	// called on requests for which Match returned true.
 	Run(r *http.Request, c *C)
 }
 
+// PatternParser converts a value into a Pattern, reporting whether it knew how
+// to. See RegisterPatternParser.
+type PatternParser func(raw interface{}) (Pattern, bool)
+
+var patternParsers struct {
+	sync.RWMutex
+	parsers []PatternParser
+}
+
+/*
+RegisterPatternParser extends the set of types accepted as patterns (see
+PatternType) by the router and by ParsePattern. Values which are not of one of
+the built-in pattern types are passed to each registered parser in turn, in the
+order they were registered, and the first Pattern one of them returns is used.
+Parsers should return false for values they do not recognize, so that other
+parsers get a chance at them.
+
+Parsers are typically registered from an init function, before any routes are
+added. Registering a parser does not affect routes which have already been
+added.
+*/
+func RegisterPatternParser(parser PatternParser) {
+	patternParsers.Lock()
+	defer patternParsers.Unlock()
+	patternParsers.parsers = append(patternParsers.parsers, parser)
+}
+
+func parseRegisteredPattern(raw interface{}) (Pattern, bool) {
+	patternParsers.RLock()
+	defer patternParsers.RUnlock()
+	for _, parser := range patternParsers.parsers {
+		if p, ok := parser(raw); ok {
+			return p, true
+		}
+	}
+	return nil, false
+}
+
 const unknownPattern = `Unknown pattern type %T. See http://godoc.org/github.com/zenazn/goji/web#PatternType for a list of acceptable types.`
 
 /*
@@ -39,9 +102,10 @@ publicly to make it easier to write thin wrappers around the built-in Pattern
 implementations.
 
 ParsePattern fatally exits (using log.Fatalf) if it is passed a value of an
-unexpected type (see the documentation for PatternType for a list of which types
-are accepted). It is the caller's responsibility to ensure that ParsePattern is
-called in a type-safe manner.
+unexpected type which no parser registered with RegisterPatternParser accepts
+(see the documentation for PatternType for a list of which types are accepted).
+It is the caller's responsibility to ensure that ParsePattern is called in a
+type-safe manner.
 */
 func ParsePattern(raw PatternType) Pattern {
 	switch v := raw.(type) {
@@ -52,6 +116,9 @@ func ParsePattern(raw PatternType) Pattern {
 	case string:
 		return parseStringPattern(v)
 	default:
+		if p, ok := parseRegisteredPattern(v); ok {
+			return p
+		}
 		log.Fatalf(unknownPattern, v)
 		panic("log.Fatalf does not return")
 	}
@@ -2,6 +2,7 @@ package web
 
 import (
 	"net/http"
+	"net/http/httptest"
 	"reflect"
 	"regexp"
 	"testing"
@@ -127,6 +128,30 @@ var patternTests = []struct {
 			pt("/a/cat/dog.gif", false, nil),
 		}},
 
+	{parseStringPattern(`/report\.:format`),
+		"/report.", []patternTest{
+			pt("/report.json", true, map[string]string{"format": "json"}),
+			pt("/report.tar.gz", false, nil),
+			pt("/report.", false, nil),
+			pt("/report", false, nil),
+			pt("/report.json/x", false, nil),
+		}},
+	{parseStringPattern("/:user/avatar.:format"),
+		"/", []patternTest{
+			pt("/carl/avatar.png", true, map[string]string{
+				"user":   "carl",
+				"format": "png",
+			}),
+			pt("/carl/avatar.tar.gz", false, nil),
+		}},
+	{parseStringPattern("/files/:name.:ext/raw"),
+		"/files/", []patternTest{
+			pt("/files/cat.tar.gz/raw", true, map[string]string{
+				"name": "cat",
+				"ext":  "tar.gz",
+			}),
+		}},
+
 	// String prefix tests
 	{parseStringPattern("/user/:user/*"),
 		"/user/", []patternTest{
@@ -154,6 +179,163 @@ var patternTests = []struct {
 			}),
 			pt("/user/bob/enemies", false, nil),
 		}},
+
+	{parseStringPattern("/proxy/:service/*path"),
+		"/proxy/", []patternTest{
+			pt("/proxy/users/v1/list", true, map[string]string{
+				"service": "users",
+				"path":    "/v1/list",
+			}),
+			pt("/proxy/users/", true, map[string]string{
+				"service": "users",
+				"path":    "/",
+			}),
+			pt("/proxy/users", false, nil),
+		}},
+	{parseStringPattern("/docs/**"),
+		"/docs", []patternTest{
+			pt("/docs", true, map[string]string{"*": ""}),
+			pt("/docs/", true, map[string]string{"*": "/"}),
+			pt("/docs/api/mux", true, map[string]string{"*": "/api/mux"}),
+			pt("/docsx", false, nil),
+			pt("/doc", false, nil),
+		}},
+	{parseStringPattern("/u/:name/**rest"),
+		"/u/", []patternTest{
+			pt("/u/carl", true, map[string]string{
+				"name": "carl",
+				"rest": "",
+			}),
+			pt("/u/carl/projects", true, map[string]string{
+				"name": "carl",
+				"rest": "/projects",
+			}),
+			pt("/u/", false, nil),
+		}},
+	{parseStringPattern("/files/*a*b"),
+		"/files/*a*b", []patternTest{
+			pt("/files/*a*b", true, nil),
+			pt("/files/x", false, nil),
+		}},
+
+	// Inline constraint tests
+	{parseStringPattern(`/users/:id(\d+)`),
+		"/users/", []patternTest{
+			pt("/users/123", true, map[string]string{"id": "123"}),
+			pt("/users/carl", false, nil),
+			pt("/users/12a", false, nil),
+			pt("/users/", false, nil),
+		}},
+	{parseStringPattern(`/files/:name([a-z0-9-]+).:ext(json|xml)`),
+		"/files/", []patternTest{
+			pt("/files/my-file.json", true, map[string]string{
+				"name": "my-file",
+				"ext":  "json",
+			}),
+			pt("/files/my-file.txt", false, nil),
+			pt("/files/My_File.json", false, nil),
+		}},
+	// Optional group tests
+	{parseStringPattern("/posts/:year(/:month)?"),
+		"/posts/", []patternTest{
+			pt("/posts/2014", true, map[string]string{"year": "2014"}),
+			pt("/posts/2014/06", true, map[string]string{
+				"year":  "2014",
+				"month": "06",
+			}),
+			pt("/posts/2014/", false, nil),
+			pt("/posts/2014/06/01", false, nil),
+		}},
+	{parseStringPattern("/archive(/:year(/:month)?)?"),
+		"/archive", []patternTest{
+			pt("/archive", true, nil),
+			pt("/archive/2014", true, map[string]string{"year": "2014"}),
+			pt("/archive/2014/06", true, map[string]string{
+				"year":  "2014",
+				"month": "06",
+			}),
+			pt("/archives", false, nil),
+		}},
+	{parseStringPattern(`/items/:id(\d+)(/:slug)?`),
+		"/items/", []patternTest{
+			pt("/items/1", true, map[string]string{"id": "1"}),
+			pt("/items/1/hat", true, map[string]string{
+				"id":   "1",
+				"slug": "hat",
+			}),
+			pt("/items/x/hat", false, nil),
+		}},
+	{parseStringPattern("/what?"),
+		"/what?", []patternTest{
+			pt("/what%3F", true, nil),
+			pt("/wha", false, nil),
+		}},
+
+	// Query constraint tests
+	{parseStringPattern("/search?type=image&q=:query&debug"),
+		"/search", []patternTest{
+			pt("/search?type=image&q=cats&debug", true, map[string]string{
+				"query": "cats",
+			}),
+			pt("/search?debug=&q=cats&type=video&type=image", true,
+				map[string]string{"query": "cats"}),
+			pt("/search?type=image&q=&debug", false, nil),
+			pt("/search?type=video&q=cats&debug", false, nil),
+			pt("/search?type=image&q=cats", false, nil),
+			pt("/searches?type=image&q=cats&debug", false, nil),
+		}},
+	{parseStringPattern("/posts/:year(/:month)??draft"),
+		"/posts/", []patternTest{
+			pt("/posts/2014/06?draft", true, map[string]string{
+				"year":  "2014",
+				"month": "06",
+			}),
+			pt("/posts/2014?draft", true, map[string]string{"year": "2014"}),
+			pt("/posts/2014", false, nil),
+		}},
+
+	{parseStringPattern(`/v/:ver(\d+(\.\d+)?)/:rest([)(])/x/*`),
+		"/v/", []patternTest{
+			pt("/v/2/)/x/y", true, map[string]string{
+				"ver":  "2",
+				"rest": ")",
+				"*":    "/y",
+			}),
+		}},
+
+	// Host-qualified string pattern tests
+	{parseStringPattern("api.example.com/status"),
+		"/status", []patternTest{
+			pt("http://api.example.com/status", true, nil),
+			pt("http://API.Example.com:8080/status", true, nil),
+			pt("http://api.example.com./status", true, nil),
+			pt("http://www.example.com/status", false, nil),
+			pt("http://api.example.com/other", false, nil),
+			pt("/status", false, nil),
+		}},
+	{parseStringPattern("{tenant}.example.com/:user/settings"),
+		"/", []patternTest{
+			pt("http://acme.example.com/carl/settings", true,
+				map[string]string{
+					"tenant": "acme",
+					"user":   "carl",
+				}),
+			pt("http://example.com/carl/settings", false, nil),
+			pt("http://a.b.example.com/carl/settings", false, nil),
+			pt("http://acme.example.org/carl/settings", false, nil),
+		}},
+	{parseStringPattern("*.example.com/*"),
+		"/", []patternTest{
+			pt("http://www.example.com/a/b", true, map[string]string{
+				"*": "/a/b",
+			}),
+			pt("http://example.com/a/b", false, nil),
+		}},
+	{parseStringPattern("[::1]/local"),
+		"/local", []patternTest{
+			pt("http://[::1]:8000/local", true, nil),
+			pt("http://127.0.0.1/local", false, nil),
+		}},
 }
 
 func TestPatterns(t *testing.T) {
@@ -172,6 +354,22 @@ func TestPatterns(t *testing.T) {
 	}
 }
 
+func TestHostPatternAllocs(t *testing.T) {
+	p := parseStringPattern("{tenant}.example.com/status")
+	for _, host := range []string{"acme.example.com", "acme.example.com:8080"} {
+		r, _ := http.NewRequest("GET", "http://"+host+"/status", nil)
+		var c C
+		n := testing.AllocsPerRun(100, func() {
+			if !p.match(r, &c, true) {
+				t.Fatalf("Expected %q to match", host)
+			}
+		})
+		if n != 0 {
+			t.Errorf("For %q, expected no allocations, got %v", host, n)
+		}
+	}
+}
+
 func runTest(t *testing.T, p Pattern, test patternTest) {
 	result := p.Match(test.r, test.c)
 	if result != test.match {
@@ -186,3 +384,127 @@ func runTest(t *testing.T, p Pattern, test patternTest) {
 			test.c)
 	}
 }
+
+func TestConstraintFallthrough(t *testing.T) {
+	t.Parallel()
+	m := New()
+	m.Get(`/users/:id(\d+)`, func(c C, w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("id " + c.URLParams["id"]))
+	})
+	m.Get("/users/:name", func(c C, w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("name " + c.URLParams["name"]))
+	})
+	for path, body := range map[string]string{
+		"/users/123":  "id 123",
+		"/users/carl": "name carl",
+	} {
+		r, _ := http.NewRequest("GET", path, nil)
+		w := httptest.NewRecorder()
+		m.ServeHTTP(w, r)
+		if w.Body.String() != body {
+			t.Errorf("For %q, expected %q, got %q", path, body,
+				w.Body.String())
+		}
+	}
+}
+
+func TestQueryFallthrough(t *testing.T) {
+	t.Parallel()
+	m := New()
+	m.Get("/search", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("any"))
+	})
+	m.Get("/search?type=image", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("image"))
+	})
+	m.Get("/search?type=image&size=:size", func(c C, w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("image " + c.URLParams["size"]))
+	})
+	m.Get("/search?type=video", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("video"))
+	})
+	for path, body := range map[string]string{
+		"/search":                       "any",
+		"/search?type=audio":            "any",
+		"/search?type=image":            "image",
+		"/search?type=image&size=large": "image large",
+		"/search?type=video&size=large": "video",
+	} {
+		r, _ := http.NewRequest("GET", path, nil)
+		w := httptest.NewRecorder()
+		m.ServeHTTP(w, r)
+		if w.Body.String() != body {
+			t.Errorf("For %q, expected %q, got %q", path, body,
+				w.Body.String())
+		}
+	}
+}
+
+func TestInvalidConstraint(t *testing.T) {
+	t.Parallel()
+	for _, pat := range []string{`/users/:id(\d+`, `/users/:id([)`} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Expected %q to panic", pat)
+				}
+			}()
+			parseStringPattern(pat)
+		}()
+	}
+}
+
+// acceptPattern is the kind of thing one might register a PatternParser for:
+// it routes requests by their Accept header rather than their path.
+type acceptPattern struct {
+	accept string
+}
+
+type acceptPatternImpl struct {
+	accept string
+}
+
+func (p acceptPatternImpl) Prefix() string { return "" }
+func (p acceptPatternImpl) Match(r *http.Request, c *C) bool {
+	return r.Header.Get("Accept") == p.accept
+}
+func (p acceptPatternImpl) Run(r *http.Request, c *C) {
+	if c.URLParams == nil {
+		c.URLParams = make(map[string]string, 1)
+	}
+	c.URLParams["accept"] = p.accept
+}
+
+func init() {
+	RegisterPatternParser(func(raw interface{}) (Pattern, bool) {
+		p, ok := raw.(acceptPattern)
+		return acceptPatternImpl{p.accept}, ok
+	})
+}
+
+func TestRegisterPatternParser(t *testing.T) {
+	t.Parallel()
+	if _, ok := ParsePattern(acceptPattern{"text/csv"}).(acceptPatternImpl); !ok {
+		t.Errorf("Expected the registered parser to be used")
+	}
+
+	m := New()
+	m.Get(acceptPattern{"text/csv"}, func(c C, w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(c.URLParams["accept"]))
+	})
+	r, _ := http.NewRequest("GET", "/anything", nil)
+	r.Header.Set("Accept", "text/csv")
+	w := httptest.NewRecorder()
+	m.ServeHTTP(w, r)
+	if w.Body.String() != "text/csv" {
+		t.Errorf("Expected the custom pattern to route, got %d %q", w.Code,
+			w.Body.String())
+	}
+
+	r.Header.Set("Accept", "text/html")
+	w = httptest.NewRecorder()
+	m.ServeHTTP(w, r)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404, got %d", w.Code)
+	}
+}
@@ -0,0 +1,107 @@
+package web
+
+import (
+	"strings"
+)
+
+// The kinds of path segment a pattern may have, from least to most specific.
+const (
+	segWildcard = iota
+	segParam
+	segMixed
+	segStatic
+)
+
+/*
+precedence describes how specific a route's pattern is, so that routes can be
+matched most specific first, whatever the order they were added in. It holds
+the kind of each of the path segments of the pattern: a segment which is entirely
+literal (as in "/users/new") beats one which mixes literal text and named
+parameters (as in "/report.:format"), which beats one which is a single named
+parameter (as in "/users/:id"), which beats a wildcard (as in "/users/*").
+Segments are compared from left to right, so the first segment in which two
+patterns differ decides between them, and a pattern with more segments beats
+one which is otherwise the same, but shorter. Among patterns with the same
+segments, the one with more query constraints goes first, where an Accept
+constraint (see Accepts) counts as a query constraint.
+
+Patterns other than string patterns are opaque, so they are ranked as though
+they were a wildcard following the last "/" of their prefix: "^/hello/(\w+)$"
+ranks as "/hello/*" would.
+*/
+type precedence struct {
+	segs  []byte
+	query int
+}
+
+func precedenceOf(p Pattern) precedence {
+	switch v := p.(type) {
+	case stringPattern:
+		return v.precedence()
+	case mountPattern:
+		return v.tree.precedence()
+	case schedulePattern:
+		return precedenceOf(v.Pattern)
+	case acceptsPattern:
+		prec := precedenceOf(v.Pattern)
+		prec.query++
+		return prec
+	}
+	prefix := p.Prefix()
+	var segs []byte
+	if i := strings.LastIndexByte(prefix, '/'); i > 0 {
+		for range strings.Split(prefix[1:i], "/") {
+			segs = append(segs, segStatic)
+		}
+	}
+	return precedence{segs: append(segs, segWildcard)}
+}
+
+// compare returns a positive number if p is more specific than q, a negative
+// one if it is less specific, and zero if neither is.
+func (p precedence) compare(q precedence) int {
+	for i := 0; i < len(p.segs) && i < len(q.segs); i++ {
+		if p.segs[i] != q.segs[i] {
+			return int(p.segs[i]) - int(q.segs[i])
+		}
+	}
+	if len(p.segs) != len(q.segs) {
+		return len(p.segs) - len(q.segs)
+	}
+	return p.query - q.query
+}
+
+// precedence returns the precedence of a string pattern. Patterns with optional
+// groups are ranked by their longest form.
+func (s stringPattern) precedence() precedence {
+	// Names are replaced with a byte which cannot appear in a literal.
+	var b strings.Builder
+	for i, lit := range s.literals {
+		if i > 0 {
+			b.WriteByte(0)
+		}
+		b.WriteString(lit)
+	}
+	path := strings.TrimPrefix(b.String(), "/")
+	if s.wildcard {
+		path = strings.TrimSuffix(path, "/")
+	}
+
+	var segs []byte
+	if path != "" || !s.wildcard {
+		for _, seg := range strings.Split(path, "/") {
+			switch {
+			case seg == "\x00":
+				segs = append(segs, segParam)
+			case strings.IndexByte(seg, 0) >= 0:
+				segs = append(segs, segMixed)
+			default:
+				segs = append(segs, segStatic)
+			}
+		}
+	}
+	if s.wildcard {
+		segs = append(segs, segWildcard)
+	}
+	return precedence{segs: segs, query: len(s.query)}
+}
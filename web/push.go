@@ -0,0 +1,40 @@
+package web
+
+import "net/http"
+
+/*
+Push initiates an HTTP/2 server push of target (see http.Pusher), so that a page
+can have the resources it is about to reference sent to the client along with
+it:
+
+	func page(c web.C, w http.ResponseWriter, r *http.Request) {
+		c.Push(w, "/static/app.css", nil)
+		c.Push(w, "/static/app.js", nil)
+		renderPage(w)
+	}
+
+w is searched for an http.Pusher, following the Unwrap methods of any writers
+middleware has wrapped it in which do not forward Push themselves (the writers
+in package mutil, and those of the middleware in package middleware, all do).
+If the connection does not support server push, as is the case for HTTP/1.x
+connections and for HTTP/2 clients which have disabled it, http.ErrNotSupported
+is returned, and the handler should carry on without pushing.
+*/
+func (c C) Push(w http.ResponseWriter, target string, opts *http.PushOptions) error {
+	return push(w, target, opts)
+}
+
+func push(w http.ResponseWriter, target string, opts *http.PushOptions) error {
+	for {
+		if p, ok := w.(http.Pusher); ok {
+			return p.Push(target, opts)
+		}
+		u, ok := w.(interface {
+			Unwrap() http.ResponseWriter
+		})
+		if !ok {
+			return http.ErrNotSupported
+		}
+		w = u.Unwrap()
+	}
+}
@@ -0,0 +1,54 @@
+package web
+
+import (
+	"net/http"
+)
+
+/*
+QueryDefault declares a default value for the query parameter with the given
+name. When a request is matched to this route, the parameter's value is placed
+in the context's URLParams under the same name: the value provided in the
+request's query string if there is one, or the default otherwise. This allows
+handlers to read a consistent value without implementing default logic of their
+own:
+
+	m.Get("/widgets", listWidgets).QueryDefault("page", "1")
+
+	func listWidgets(c web.C, w http.ResponseWriter, r *http.Request) {
+		page := c.URLParams["page"] // "1", unless the client said otherwise
+	}
+
+A query parameter which appears in the query string with an empty value (as in
+"/widgets?page=") counts as provided. Query defaults have no effect on routing,
+and if the route's pattern captures a URL parameter of the same name, the value
+captured from the path is left alone.
+*/
+func (r *Route) QueryDefault(name, value string) *Route {
+	r.rt.lock.Lock()
+	defer r.rt.lock.Unlock()
+	if r.route.queryDefaults == nil {
+		r.route.queryDefaults = make(map[string]string)
+	}
+	r.route.queryDefaults[name] = value
+	r.rt.setMachine(nil)
+	return r
+}
+
+// applyQueryDefaults populates the context's URLParams with the route's query
+// parameters, falling back to their defaults when they are absent.
+func (rt *route) applyQueryDefaults(c *C, r *http.Request) {
+	if len(rt.queryDefaults) == 0 {
+		return
+	}
+	query := r.URL.Query()
+	params := c.urlParams(len(rt.queryDefaults))
+	for name, value := range rt.queryDefaults {
+		if _, ok := params[name]; ok {
+			continue
+		}
+		if vs, ok := query[name]; ok {
+			value = vs[0]
+		}
+		params[name] = value
+	}
+}
@@ -0,0 +1,41 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+var queryDefaultTests = []struct {
+	path   string
+	params map[string]string
+}{
+	{"/widgets", map[string]string{"page": "1", "sort": "name"}},
+	{"/widgets?page=3", map[string]string{"page": "3", "sort": "name"}},
+	{"/widgets?page=&sort=id&sort=color",
+		map[string]string{"page": "", "sort": "id"}},
+	{"/widgets/7", map[string]string{"id": "7", "page": "1"}},
+	{"/widgets/7?id=8", map[string]string{"id": "7", "page": "1"}},
+}
+
+func TestQueryDefault(t *testing.T) {
+	t.Parallel()
+	m := New()
+	var params map[string]string
+	h := func(c C, w http.ResponseWriter, r *http.Request) {
+		params = c.Copy().URLParams
+	}
+	m.Get("/widgets", h).QueryDefault("page", "1").QueryDefault("sort", "name")
+	m.Get("/widgets/:id", h).QueryDefault("page", "1").QueryDefault("id", "0")
+
+	for _, test := range queryDefaultTests {
+		params = nil
+		r, _ := http.NewRequest("GET", test.path, nil)
+		m.ServeHTTP(httptest.NewRecorder(), r)
+		if !reflect.DeepEqual(params, test.params) {
+			t.Errorf("For %q, expected params %v, got %v", test.path,
+				test.params, params)
+		}
+	}
+}
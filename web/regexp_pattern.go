@@ -35,11 +35,9 @@ func (p regexpPattern) match(r *http.Request, c *C, dryrun bool) bool {
 		return true
 	}
 
-	if c.URLParams == nil {
-		c.URLParams = make(map[string]string, len(matches)-1)
-	}
+	params := c.urlParams(len(matches) - 1)
 	for i := 1; i < len(matches); i++ {
-		c.URLParams[p.names[i]] = matches[i]
+		params[p.names[i]] = matches[i]
 	}
 	return true
 }
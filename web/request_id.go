@@ -0,0 +1,15 @@
+package web
+
+// The key under which the request's ID is stored in Env, by
+// middleware.RequestID and friends. See RequestIDFromC. (This key predates
+// Goji's convention of prefixing Env keys with "goji.web.", and is kept for
+// compatibility.)
+const RequestIDKey = "reqID"
+
+// RequestIDFromC returns the ID assigned to the request by middleware such as
+// middleware.RequestID, or the empty string if the request has not been
+// assigned one.
+func RequestIDFromC(c C) string {
+	id, _ := c.env()[RequestIDKey].(string)
+	return id
+}
@@ -0,0 +1,122 @@
+package web
+
+/*
+Resource is a set of routes which share a single pattern, differing only in the
+HTTP methods they respond to, as is typical of the routes for a REST resource.
+It saves repeating the pattern for each method:
+
+	w := m.Route("/widgets/:id")
+	w.Use(LoadWidget)
+	w.Get(showWidget)
+	w.Put(updateWidget)
+	w.Delete(destroyWidget)
+
+The pattern is parsed once, and the resulting Pattern shared by each of the
+resource's routes. These are ordinary routes, added to the Mux (or group) the
+resource was created from just as if they had been added one by one, so they
+are matched exactly as such routes would be, and each may be further configured
+through the Route it is returned as.
+*/
+type Resource struct {
+	mux        *Mux
+	group      *Group
+	pattern    Pattern
+	middleware []MiddlewareType
+	routes     []*Route
+}
+
+// Route returns a new resource for the given pattern. See Resource.
+func (m *Mux) Route(pattern PatternType) *Resource {
+	return &Resource{mux: m, pattern: ParsePattern(pattern)}
+}
+
+// Route returns a new resource for the given pattern, prefixed with the group's
+// prefix. Its routes are given the group's middleware before the resource's
+// own. See Resource.
+func (g *Group) Route(pattern PatternType) *Resource {
+	return &Resource{
+		mux:     g.mux,
+		group:   g,
+		pattern: ParsePattern(g.pattern(pattern)),
+	}
+}
+
+// Use appends the given middleware to the middleware of each of the resource's
+// routes (see Route.Use), both those which have already been added and those
+// which are added later.
+func (res *Resource) Use(middleware MiddlewareType) {
+	res.middleware = append(res.middleware, middleware)
+	for _, r := range res.routes {
+		r.Use(middleware)
+	}
+}
+
+// Handle adds a route for every HTTP method to the resource. See Mux.Handle.
+func (res *Resource) Handle(handler HandlerType) *Route {
+	return res.add(res.mux.Handle(res.pattern, handler))
+}
+
+// Connect adds a CONNECT route to the resource. See Mux.Connect.
+func (res *Resource) Connect(handler HandlerType) *Route {
+	return res.add(res.mux.Connect(res.pattern, handler))
+}
+
+// Delete adds a DELETE route to the resource. See Mux.Delete.
+func (res *Resource) Delete(handler HandlerType) *Route {
+	return res.add(res.mux.Delete(res.pattern, handler))
+}
+
+// Get adds a GET route to the resource, which also serves HEAD requests. See
+// Mux.Get.
+func (res *Resource) Get(handler HandlerType) *Route {
+	return res.add(res.mux.Get(res.pattern, handler))
+}
+
+// Head adds a HEAD route to the resource. See Mux.Head.
+func (res *Resource) Head(handler HandlerType) *Route {
+	return res.add(res.mux.Head(res.pattern, handler))
+}
+
+// Method adds a route for the given HTTP method to the resource. See
+// Mux.Method.
+func (res *Resource) Method(method string, handler HandlerType) *Route {
+	return res.add(res.mux.Method(method, res.pattern, handler))
+}
+
+// Options adds an OPTIONS route to the resource. See Mux.Options.
+func (res *Resource) Options(handler HandlerType) *Route {
+	return res.add(res.mux.Options(res.pattern, handler))
+}
+
+// Patch adds a PATCH route to the resource. See Mux.Patch.
+func (res *Resource) Patch(handler HandlerType) *Route {
+	return res.add(res.mux.Patch(res.pattern, handler))
+}
+
+// Post adds a POST route to the resource. See Mux.Post.
+func (res *Resource) Post(handler HandlerType) *Route {
+	return res.add(res.mux.Post(res.pattern, handler))
+}
+
+// Put adds a PUT route to the resource. See Mux.Put.
+func (res *Resource) Put(handler HandlerType) *Route {
+	return res.add(res.mux.Put(res.pattern, handler))
+}
+
+// Trace adds a TRACE route to the resource. See Mux.Trace.
+func (res *Resource) Trace(handler HandlerType) *Route {
+	return res.add(res.mux.Trace(res.pattern, handler))
+}
+
+// add installs the middleware of the resource's group, if it has one, and then
+// the resource's own on a newly added route.
+func (res *Resource) add(r *Route) *Route {
+	if res.group != nil {
+		res.group.add(r)
+	}
+	for _, middleware := range res.middleware {
+		r.Use(middleware)
+	}
+	res.routes = append(res.routes, r)
+	return r
+}
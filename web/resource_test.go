@@ -0,0 +1,73 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestResource(t *testing.T) {
+	t.Parallel()
+	var trace []string
+	tracer := func(name string) func(*C, http.Handler) http.Handler {
+		return func(c *C, h http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				trace = append(trace, name)
+				h.ServeHTTP(w, r)
+			})
+		}
+	}
+	handler := func(name string) func(C, http.ResponseWriter, *http.Request) {
+		return func(c C, w http.ResponseWriter, r *http.Request) {
+			trace = append(trace, name+":"+c.URLParams["id"])
+		}
+	}
+
+	m := New()
+	widgets := m.Route("/widgets/:id")
+	widgets.Get(handler("show"))
+	widgets.Use(tracer("load"))
+	widgets.Put(handler("update"))
+	widgets.Delete(handler("destroy")).Use(tracer("admin"))
+
+	admin := m.Group("/admin")
+	admin.Use(tracer("group"))
+	users := admin.Route("/users/:id")
+	users.Use(tracer("user"))
+	users.Post(handler("create"))
+
+	tests := []struct {
+		method, path string
+		code         int
+		trace        string
+	}{
+		{"GET", "/widgets/1", 200, "load show:1"},
+		{"HEAD", "/widgets/1", 200, "load show:1"},
+		{"PUT", "/widgets/2", 200, "load update:2"},
+		{"DELETE", "/widgets/3", 200, "load admin destroy:3"},
+		{"POST", "/widgets/4", 405, ""},
+		{"POST", "/admin/users/5", 200, "group user create:5"},
+	}
+	for _, test := range tests {
+		trace = nil
+		r, _ := http.NewRequest(test.method, test.path, nil)
+		w := httptest.NewRecorder()
+		m.ServeHTTP(w, r)
+		if w.Code != test.code || strings.Join(trace, " ") != test.trace {
+			t.Errorf("For %s %q, expected %d %q, got %d %q", test.method,
+				test.path, test.code, test.trace, w.Code,
+				strings.Join(trace, " "))
+		}
+	}
+
+	routes := m.Routes()
+	if len(routes) != 4 {
+		t.Fatalf("Expected 4 routes, got %+v", routes)
+	}
+	for _, r := range routes {
+		if r.Pattern != "/widgets/:id" && r.Pattern != "/admin/users/:id" {
+			t.Errorf("Unexpected pattern %q", r.Pattern)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Headers which describe a response body, and which therefore have no business
+// appearing on a response that must not have one.
+var bodyHeaders = []string{
+	"Content-Type",
+	"Content-Length",
+	"Transfer-Encoding",
+}
+
+// NoContent responds to the request with a bare 204 No Content. Any headers
+// describing a response body (Content-Type, Content-Length, and
+// Transfer-Encoding) that were set earlier in the request are removed, since a
+// 204 response must not include a body. Handlers should not write to w after
+// calling NoContent.
+func (c C) NoContent(w http.ResponseWriter) {
+	h := w.Header()
+	for _, k := range bodyHeaders {
+		h.Del(k)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+/*
+PaginationLinks sets an RFC 5988 Link header describing the pages of a paginated
+list. Page numbers start at 1. Each link is built from base (which may itself
+carry query parameters, which are preserved) by setting the "page" and
+"per_page" query parameters. The "first" and "last" relations are always
+present, while "prev" and "next" are omitted on the first and last pages
+respectively. For instance,
+
+	c.PaginationLinks(w, "/widgets?color=red", 2, 10, 35)
+
+sets a Link header equivalent to
+
+	</widgets?color=red&page=1&per_page=10>; rel="first",
+	</widgets?color=red&page=1&per_page=10>; rel="prev",
+	</widgets?color=red&page=3&per_page=10>; rel="next",
+	</widgets?color=red&page=4&per_page=10>; rel="last"
+
+An error is returned if base cannot be parsed or perPage is not positive.
+*/
+func (c C) PaginationLinks(w http.ResponseWriter, base string, page, perPage, total int) error {
+	u, err := url.Parse(base)
+	if err != nil {
+		return err
+	}
+	if perPage <= 0 {
+		return errors.New("web: perPage must be positive")
+	}
+	last := (total + perPage - 1) / perPage
+	if last < 1 {
+		last = 1
+	}
+
+	query := u.Query()
+	link := func(page int, rel string) string {
+		query.Set("page", strconv.Itoa(page))
+		query.Set("per_page", strconv.Itoa(perPage))
+		u.RawQuery = query.Encode()
+		return fmt.Sprintf("<%s>; rel=%q", u.String(), rel)
+	}
+
+	links := []string{link(1, "first")}
+	if page > 1 {
+		prev := page - 1
+		if prev > last {
+			prev = last
+		}
+		links = append(links, link(prev, "prev"))
+	}
+	if page < last {
+		links = append(links, link(page+1, "next"))
+	}
+	links = append(links, link(last, "last"))
+
+	w.Header().Set("Link", strings.Join(links, ", "))
+	return nil
+}
+
+/*
+DeclareTrailers announces, via the Trailer header, that the response will end
+with trailers of the given names. It must be called before the response's
+header has been written (i.e., before the first call to Write or WriteHeader).
+Declaring trailers is optional, since WriteTrailer works without it, but some
+clients (and proxies) only look for trailers which have been announced.
+*/
+func (c C) DeclareTrailers(w http.ResponseWriter, names ...string) {
+	for _, name := range names {
+		w.Header().Add("Trailer", http.CanonicalHeaderKey(name))
+	}
+}
+
+/*
+WriteTrailer sets a trailer of the given name, which is sent after the response
+body. This is useful for values that cannot be known until the body has been
+written, like checksums or the status of a stream. Unlike setting the trailer's
+value in the header map directly, WriteTrailer may be called both before and
+after the body has been written, and regardless of whether the trailer was
+declared with DeclareTrailers: in every case, the value is sent as a trailer
+and never as an ordinary header.
+
+WriteTrailer works through any ResponseWriter that passes calls to Header
+through to the original, which includes every wrapper in this package and in
+the middleware package.
+*/
+func (c C) WriteTrailer(w http.ResponseWriter, name, value string) {
+	name = http.CanonicalHeaderKey(name)
+	w.Header().Set(http.TrailerPrefix+name, value)
+}
@@ -0,0 +1,71 @@
+package web
+
+import (
+	"net/http"
+
+	"github.com/zenazn/goji/web/mutil"
+)
+
+// ResponseHook is a function called once a Mux has finished responding to a
+// request. See Mux.OnResponse.
+type ResponseHook func(c C, status int, bytes int)
+
+/*
+OnResponse registers a function to be called after every response the mux
+serves, whether it was served by a route, by the NotFound handler, or by a
+recovery middleware after a panic. The function is passed the request's context
+(as left by the middleware stack), the final status code, and the number of
+bytes in the response body. This provides a single place to do final
+accounting without wrapping ResponseWriters yourself.
+
+Response hooks run outside the middleware stack, after every middleware has
+returned. If a panic is not recovered by any middleware, the hooks still run
+(with the status the handler had written by the time it panicked, or 0 if it
+had not written one) before the panic continues on its way.
+
+Like the rest of the Mux's configuration, it is illegal to call OnResponse
+concurrently with active requests.
+*/
+func (m *Mux) OnResponse(hook ResponseHook) {
+	m.responseHooks = append(m.responseHooks, hook)
+}
+
+/*
+TrackResponses controls whether the mux wraps every ResponseWriter in a
+mutil.WriterProxy before passing it to its middleware stack, so that middleware
+can find out the status and size of the response produced by the layers inside
+it, after they have returned:
+
+	h.ServeHTTP(w, r)
+	if wp, ok := w.(mutil.WriterProxy); ok && wp.Status() >= 500 {
+		...
+	}
+
+The proxy preserves the optional interfaces of the ResponseWriter it wraps (see
+mutil.WrapWriter), so streaming responses, HTTP/2 server push, and connection
+hijacking (as used by WebSocket upgrades) continue to work. Writers are also
+wrapped whenever response or write error hooks are registered, in which case
+TrackResponses(false) has no effect.
+
+It is illegal to call TrackResponses concurrently with active requests.
+*/
+func (m *Mux) TrackResponses(enabled bool) {
+	m.trackResponses = enabled
+}
+
+func (m *Mux) responded(c C, w mutil.WriterProxy, r *http.Request, completed bool) {
+	if er, ok := w.(mutil.ErrorReporter); ok {
+		if err := er.Err(); err != nil {
+			m.writeFailed(c, r, err)
+		}
+	}
+
+	status := w.Status()
+	if status == 0 && completed {
+		// This is what net/http will send
+		status = http.StatusOK
+	}
+	for _, hook := range m.responseHooks {
+		hook(c, status, w.BytesWritten())
+	}
+}
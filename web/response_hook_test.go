@@ -0,0 +1,158 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/zenazn/goji/web/mutil"
+)
+
+func TestOnResponse(t *testing.T) {
+	t.Parallel()
+	m := New()
+	m.Use(func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			defer func() {
+				if err := recover(); err != nil {
+					w.WriteHeader(http.StatusInternalServerError)
+				}
+			}()
+			h.ServeHTTP(w, r)
+		})
+	})
+	m.Get("/ok", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello"))
+	})
+	m.Get("/empty", func(w http.ResponseWriter, r *http.Request) {})
+	m.Get("/panic", func(w http.ResponseWriter, r *http.Request) {
+		panic("oops")
+	})
+
+	var status, bytes, calls int
+	m.OnResponse(func(c C, s, b int) {
+		status, bytes = s, b
+		calls++
+	})
+
+	tests := []struct {
+		path          string
+		status, bytes int
+	}{
+		{"/ok", http.StatusOK, 5},
+		{"/empty", http.StatusOK, 0},
+		{"/missing", http.StatusNotFound, len("404 page not found\n")},
+		{"/panic", http.StatusInternalServerError, 0},
+	}
+	for i, test := range tests {
+		r, _ := http.NewRequest("GET", test.path, nil)
+		m.ServeHTTP(httptest.NewRecorder(), r)
+		if calls != i+1 {
+			t.Errorf("For %q, hook called %d times", test.path, calls)
+		}
+		if status != test.status || bytes != test.bytes {
+			t.Errorf("For %q, expected %d (%d bytes), got %d (%d bytes)",
+				test.path, test.status, test.bytes, status, bytes)
+		}
+	}
+}
+
+func TestOnResponseUnrecovered(t *testing.T) {
+	t.Parallel()
+	m := New()
+	m.Get("/panic", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+		panic("oops")
+	})
+	status := -1
+	m.OnResponse(func(c C, s, b int) {
+		status = s
+	})
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("Expected panic to propagate")
+			}
+		}()
+		r, _ := http.NewRequest("GET", "/panic", nil)
+		m.ServeHTTP(httptest.NewRecorder(), r)
+	}()
+	if status != http.StatusAccepted {
+		t.Errorf("Expected hook to see status 202, got %d", status)
+	}
+}
+
+func TestTrackResponses(t *testing.T) {
+	t.Parallel()
+	type result struct {
+		status, bytes                 int
+		written                       bool
+		flusher, hijacker, pusher, ok bool
+	}
+	results := make(chan result, 1)
+	m := New()
+	m.TrackResponses(true)
+	m.Use(func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			h.ServeHTTP(w, r)
+			var res result
+			var wp mutil.WriterProxy
+			if wp, res.ok = w.(mutil.WriterProxy); res.ok {
+				res.status = wp.Status()
+				res.bytes = wp.BytesWritten()
+				res.written = wp.Written()
+			}
+			_, res.flusher = w.(http.Flusher)
+			_, res.hijacker = w.(http.Hijacker)
+			_, res.pusher = w.(http.Pusher)
+			results <- res
+		})
+	})
+	m.Get("/", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte("hello"))
+	})
+
+	// A ResponseRecorder only supports flushing
+	r, _ := http.NewRequest("GET", "/", nil)
+	m.ServeHTTP(httptest.NewRecorder(), r)
+	res := <-results
+	expected := result{status: 202, bytes: 5, written: true, flusher: true,
+		ok: true}
+	if res != expected {
+		t.Errorf("For a recorder, expected %+v, got %+v", expected, res)
+	}
+
+	s := httptest.NewServer(m)
+	defer s.Close()
+	resp, err := http.Get(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	res = <-results
+	expected.hijacker = true
+	if res != expected {
+		t.Errorf("For HTTP/1.1, expected %+v, got %+v", expected, res)
+	}
+
+	s2 := httptest.NewUnstartedServer(m)
+	s2.EnableHTTP2 = true
+	s2.StartTLS()
+	defer s2.Close()
+	resp, err = s2.Client().Get(s2.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.ProtoMajor != 2 {
+		t.Fatalf("Expected an HTTP/2 response, got %s", resp.Proto)
+	}
+	res = <-results
+	expected.hijacker = false
+	expected.pusher = true
+	if res != expected {
+		t.Errorf("For HTTP/2, expected %+v, got %+v", expected, res)
+	}
+}
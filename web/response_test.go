@@ -0,0 +1,123 @@
+package web
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/zenazn/goji/web/mutil"
+)
+
+func TestNoContent(t *testing.T) {
+	t.Parallel()
+	m := New()
+	m.Delete("/widgets/:id", func(c C, w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Length", "12")
+		w.Header().Set("X-Widget", c.URLParams["id"])
+		c.NoContent(w)
+	})
+
+	r, _ := http.NewRequest("DELETE", "/widgets/123", nil)
+	w := httptest.NewRecorder()
+	m.ServeHTTP(w, r)
+
+	if w.Code != http.StatusNoContent {
+		t.Errorf("status is %d, not 204", w.Code)
+	}
+	if w.Body.Len() != 0 {
+		t.Errorf("body was %q, should be empty", w.Body.String())
+	}
+	for _, k := range bodyHeaders {
+		if v, ok := w.HeaderMap[k]; ok {
+			t.Errorf("header %s was set to %q, should be absent", k, v)
+		}
+	}
+	if v := w.HeaderMap.Get("X-Widget"); v != "123" {
+		t.Errorf("unrelated header X-Widget was %q, expected %q", v, "123")
+	}
+}
+
+var paginationTests = []struct {
+	base                 string
+	page, perPage, total int
+	link                 string
+}{
+	{"/widgets", 1, 10, 35, `</widgets?page=1&per_page=10>; rel="first", ` +
+		`</widgets?page=2&per_page=10>; rel="next", ` +
+		`</widgets?page=4&per_page=10>; rel="last"`},
+	{"/widgets?color=red", 2, 10, 35,
+		`</widgets?color=red&page=1&per_page=10>; rel="first", ` +
+			`</widgets?color=red&page=1&per_page=10>; rel="prev", ` +
+			`</widgets?color=red&page=3&per_page=10>; rel="next", ` +
+			`</widgets?color=red&page=4&per_page=10>; rel="last"`},
+	{"http://example.com/w?q=a+b%26c", 4, 10, 35,
+		`<http://example.com/w?page=1&per_page=10&q=a+b%26c>; rel="first", ` +
+			`<http://example.com/w?page=3&per_page=10&q=a+b%26c>; rel="prev", ` +
+			`<http://example.com/w?page=4&per_page=10&q=a+b%26c>; rel="last"`},
+	{"/empty", 1, 10, 0, `</empty?page=1&per_page=10>; rel="first", ` +
+		`</empty?page=1&per_page=10>; rel="last"`},
+}
+
+func TestPaginationLinks(t *testing.T) {
+	t.Parallel()
+	for _, test := range paginationTests {
+		w := httptest.NewRecorder()
+		err := C{}.PaginationLinks(w, test.base, test.page, test.perPage,
+			test.total)
+		if err != nil {
+			t.Errorf("Unexpected error for %q: %v", test.base, err)
+		}
+		if link := w.HeaderMap.Get("Link"); link != test.link {
+			t.Errorf("For %q page %d, expected\n\t%s\ngot\n\t%s",
+				test.base, test.page, test.link, link)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	if err := (C{}).PaginationLinks(w, "/", 1, 0, 10); err == nil {
+		t.Error("Expected an error for a zero page size")
+	}
+}
+
+func TestWriteTrailer(t *testing.T) {
+	t.Parallel()
+	m := New()
+	m.Use(func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			h.ServeHTTP(mutil.WrapWriter(w), r)
+		})
+	})
+	m.Get("/stream", func(c C, w http.ResponseWriter, r *http.Request) {
+		c.DeclareTrailers(w, "x-checksum")
+		w.Write([]byte("hello"))
+		w.(http.Flusher).Flush()
+		c.WriteTrailer(w, "x-checksum", "abc123")
+		c.WriteTrailer(w, "Grpc-Status", "0")
+	})
+	s := httptest.NewServer(m)
+	defer s.Close()
+
+	resp, err := http.Get(s.URL + "/stream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if _, ok := resp.Trailer["X-Checksum"]; !ok {
+		t.Errorf("Expected X-Checksum to be announced, got %v", resp.Trailer)
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	if string(body) != "hello" {
+		t.Errorf("Expected body %q, got %q", "hello", body)
+	}
+	if v := resp.Trailer.Get("X-Checksum"); v != "abc123" {
+		t.Errorf("Expected X-Checksum trailer %q, got %q", "abc123", v)
+	}
+	if v := resp.Trailer.Get("Grpc-Status"); v != "0" {
+		t.Errorf("Expected Grpc-Status trailer %q, got %q", "0", v)
+	}
+	if v := resp.Header.Get("Grpc-Status"); v != "" {
+		t.Errorf("Trailer leaked into the header as %q", v)
+	}
+}
@@ -0,0 +1,296 @@
+package web
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"regexp/syntax"
+	"strings"
+)
+
+// Name gives this route a name, by which URLs for it can be generated (see
+// Mux.URL and Mux.URLOpts). Names must be unique within a Mux: Name panics if a different
+// route already has the given name.
+func (r *Route) Name(name string) *Route {
+	r.rt.lock.Lock()
+	defer r.rt.lock.Unlock()
+	if r.rt.names == nil {
+		r.rt.names = make(map[string]*route)
+	}
+	if other, ok := r.rt.names[name]; ok && other != r.route {
+		panic(fmt.Sprintf("web: duplicate route name %q", name))
+	}
+	r.rt.names[name] = r.route
+	return r
+}
+
+// TrailingSlash controls whether URLs generated by Mux.URLOpts end in a slash.
+type TrailingSlash int
+
+const (
+	// SlashAsRegistered generates URLs which end in a slash if and only
+	// if the route's pattern does. This is the default.
+	SlashAsRegistered TrailingSlash = iota
+	// SlashAlways generates URLs which always end in a slash.
+	SlashAlways
+	// SlashNever generates URLs which never end in a slash (except for
+	// the URL "/" itself).
+	SlashNever
+)
+
+// URLOptions controls how Mux.URLOpts generates URLs.
+type URLOptions struct {
+	// TrailingSlash controls whether the URL ends in a slash.
+	TrailingSlash TrailingSlash
+	// Raw is a list of URL parameters whose values are already escaped,
+	// and which should therefore be inserted into the URL verbatim. The
+	// values of all other parameters are escaped.
+	Raw []string
+}
+
+func (o URLOptions) raw(name string) bool {
+	for _, r := range o.Raw {
+		if r == name {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+URLOpts generates the path which the route with the given name would match,
+given the values of its URL parameters, which are passed as alternating names and
+values:
+
+	m.Get("/users/:id/posts/*", h).Name("user.posts")
+	m.URLOpts("user.posts", web.URLOptions{}, "id", "123", "*", "/2014/11")
+	// "/users/123/posts/2014/11"
+
+Parameter values are escaped (so that a value of "a/b" produces "a%2Fb"),
+unless they are listed in opts.Raw. The wildcard parameter of string patterns
+("*", or the wildcard's name, as in "/files/*path") is special: since it may
+legitimately contain slashes, only its individual path segments are escaped
+(and listing "*" in opts.Raw leaves it unescaped, whatever its name). If it is
+not provided, it defaults to "/", except for optional wildcards (as in
+"/docs/**"), for which it defaults to the empty string, giving the path without
+the wildcard ("/docs").
+
+An error is returned if there is no route of the given name, if a parameter
+required by its pattern is not provided or does not match the regular expression
+it is captured by, or if the route's pattern is of a kind that cannot be
+reversed. The query constraints of string patterns (see PatternType) are
+appended to the path as a query string.
+*/
+func (m *Mux) URLOpts(name string, opts URLOptions, params ...string) (string, error) {
+	if len(params)%2 != 0 {
+		return "", errors.New("web: URL parameters must be name, value pairs")
+	}
+	values := make(map[string]string, len(params)/2)
+	for i := 0; i < len(params); i += 2 {
+		values[params[i]] = params[i+1]
+	}
+	return m.rt.url(name, opts, values)
+}
+
+/*
+URL generates the path which the route with the given name would match, given
+the values of its URL parameters:
+
+	m.Get("/users/:id", h).Name("user.show")
+	m.URL("user.show", map[string]string{"id": "123"})
+	// "/users/123"
+
+Routes with regular expression patterns can be reversed too, provided that
+everything outside of their capturing groups is literal text: each group is
+replaced by the value of the corresponding parameter (named, or "$1", "$2",
+etc.), which must match the group's expression.
+
+Parameter values are escaped, except for the wildcard parameter of string
+patterns ("*", or the wildcard's name), which is appended verbatim. See URLOpts for more control over how
+URLs are generated, and for the conditions under which an error is returned.
+*/
+func (m *Mux) URL(name string, params map[string]string) (string, error) {
+	return m.rt.url(name, URLOptions{Raw: []string{"*"}}, params)
+}
+
+func (rt *router) url(name string, opts URLOptions, params map[string]string) (string, error) {
+	rt.lock.Lock()
+	route, ok := rt.names[name]
+	rt.lock.Unlock()
+	if !ok {
+		return "", fmt.Errorf("web: no route named %q", name)
+	}
+
+	var path string
+	var err error
+	switch p := unwrapPattern(route.pattern).(type) {
+	case stringPattern:
+		path, err = p.reverse(params, opts)
+	case regexpPattern:
+		path, err = p.reverse(params, opts)
+	default:
+		return "", fmt.Errorf("web: cannot generate URLs for route %q "+
+			"with pattern %s", name, patternString(route.pattern))
+	}
+	if err != nil {
+		return "", fmt.Errorf("web: route %q: %v", name, err)
+	}
+
+	// Query constraints follow the path, and the slash goes before them
+	var query string
+	if i := strings.IndexByte(path, '?'); i >= 0 {
+		path, query = path[:i], path[i:]
+	}
+	switch opts.TrailingSlash {
+	case SlashAlways:
+		if !strings.HasSuffix(path, "/") {
+			path += "/"
+		}
+	case SlashNever:
+		if path != "/" {
+			path = strings.TrimRight(path, "/")
+		}
+	}
+	return path + query, nil
+}
+
+// unwrapPattern returns the pattern wrapped by Route.Active and Accepts, which
+// only add conditions to it that do not bear on the URL it matches.
+func unwrapPattern(p Pattern) Pattern {
+	for {
+		switch v := p.(type) {
+		case schedulePattern:
+			p = v.Pattern
+		case acceptsPattern:
+			p = v.Pattern
+		default:
+			return p
+		}
+	}
+}
+
+func (s stringPattern) reverse(params map[string]string, opts URLOptions) (string, error) {
+	if s.short != nil {
+		// Include the optional group if we have its parameters.
+		long := s
+		long.short = nil
+		path, err := long.reverse(params, opts)
+		if err == nil {
+			return path, nil
+		}
+		if path, err := s.short.reverse(params, opts); err == nil {
+			return path, nil
+		}
+		return "", err
+	}
+	var buf []string
+	for i, pat := range s.pats {
+		v, ok := params[pat]
+		if !ok || v == "" {
+			return "", fmt.Errorf("missing URL parameter %q", pat)
+		}
+		if re := s.constraint(i); re != nil && !re.MatchString(v) {
+			return "", fmt.Errorf("URL parameter %q is %q, which "+
+				"does not match its constraint", pat, v)
+		}
+		if s.ext && i == len(s.pats)-1 && strings.Contains(v, ".") {
+			return "", fmt.Errorf("URL parameter %q is %q, but file "+
+				"extensions may not contain a %q", pat, v, ".")
+		}
+		if !opts.raw(pat) {
+			v = url.PathEscape(v)
+		}
+		buf = append(buf, s.literals[i], v)
+	}
+
+	tail := s.literals[len(s.pats)]
+	if s.wildcard {
+		// The tail literal ends in the slash the wildcard's value
+		// begins with.
+		tail = tail[:len(tail)-1]
+		v := params[s.wildcardKey]
+		if v == "" && s.optionalTail {
+			// The bare path, as "/docs" is for "/docs/**".
+		} else if !strings.HasPrefix(v, "/") {
+			v = "/" + v
+		}
+		if !opts.raw(s.wildcardKey) && !opts.raw("*") {
+			segments := strings.Split(v, "/")
+			for i, seg := range segments {
+				segments[i] = url.PathEscape(seg)
+			}
+			v = strings.Join(segments, "/")
+		}
+		tail += v
+	}
+	buf = append(buf, tail)
+	for i, q := range s.query {
+		sep := "&"
+		if i == 0 {
+			sep = "?"
+		}
+		v := q.value
+		if q.key != "" {
+			if v = params[q.key]; v == "" {
+				return "", fmt.Errorf("missing URL parameter %q", q.key)
+			}
+		}
+		buf = append(buf, sep, url.QueryEscape(q.name))
+		if !q.present {
+			buf = append(buf, "=", url.QueryEscape(v))
+		}
+	}
+	return strings.Join(buf, ""), nil
+}
+
+func (p regexpPattern) reverse(params map[string]string, opts URLOptions) (string, error) {
+	re, err := syntax.Parse(p.re.String(), syntax.Perl)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := p.reverseInto(&buf, re, params, opts); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+func (p regexpPattern) reverseInto(buf *bytes.Buffer, re *syntax.Regexp, params map[string]string, opts URLOptions) error {
+	switch re.Op {
+	case syntax.OpLiteral:
+		buf.WriteString(string(re.Rune))
+	case syntax.OpConcat:
+		for _, sub := range re.Sub {
+			if err := p.reverseInto(buf, sub, params, opts); err != nil {
+				return err
+			}
+		}
+	case syntax.OpCapture:
+		name := p.names[re.Cap]
+		v, ok := params[name]
+		if !ok {
+			return fmt.Errorf("missing URL parameter %q", name)
+		}
+		valid, err := regexp.Compile(`\A(?:` + re.Sub[0].String() + `)\z`)
+		if err != nil {
+			return err
+		}
+		if !valid.MatchString(v) {
+			return fmt.Errorf("URL parameter %q is %q, which does "+
+				"not match %s", name, v, re.Sub[0])
+		}
+		if !opts.raw(name) {
+			v = url.PathEscape(v)
+		}
+		buf.WriteString(v)
+	case syntax.OpEmptyMatch, syntax.OpBeginLine, syntax.OpEndLine,
+		syntax.OpBeginText, syntax.OpEndText:
+		// Anchors match the empty string
+	default:
+		return fmt.Errorf("cannot reverse %s outside of a capturing "+
+			"group", re)
+	}
+	return nil
+}
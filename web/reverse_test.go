@@ -0,0 +1,161 @@
+package web
+
+import (
+	"net/http"
+	"regexp"
+	"testing"
+	"time"
+)
+
+var urlOptsTests = []struct {
+	name   string
+	opts   URLOptions
+	params []string
+	url    string
+}{
+	{"user", URLOptions{}, []string{"id", "123"}, "/users/123"},
+	{"user", URLOptions{}, []string{"id", "a/b c"}, "/users/a%2Fb%20c"},
+	{"user", URLOptions{Raw: []string{"id"}}, []string{"id", "a%2Fb"},
+		"/users/a%2Fb"},
+	{"user", URLOptions{TrailingSlash: SlashAlways}, []string{"id", "1"},
+		"/users/1/"},
+	{"posts", URLOptions{}, []string{"id", "1"}, "/users/1/posts/"},
+	{"posts", URLOptions{TrailingSlash: SlashNever}, []string{"id", "1"},
+		"/users/1/posts"},
+	{"file", URLOptions{}, []string{"name", "x", "ext", "tar.gz"},
+		"/files/x.tar.gz"},
+	{"static", URLOptions{}, []string{"*", "/css/a b.css"},
+		"/static/css/a%20b.css"},
+	{"static", URLOptions{}, []string{"*", "js/app.js"}, "/static/js/app.js"},
+	{"static", URLOptions{}, nil, "/static/"},
+	{"static", URLOptions{Raw: []string{"*"}}, []string{"*", "/a%20b"},
+		"/static/a%20b"},
+	{"root", URLOptions{TrailingSlash: SlashNever}, nil, "/"},
+	{"search", URLOptions{TrailingSlash: SlashAlways},
+		[]string{"query", "a&b"}, "/search/?type=image&q=a%26b&debug"},
+}
+
+func TestURLOpts(t *testing.T) {
+	t.Parallel()
+	m := New()
+	m.Get("/users/:id", http.NotFound).Name("user")
+	m.Get("/users/:id/posts/", http.NotFound).Name("posts")
+	m.Get("/files/:name.:ext", http.NotFound).Name("file")
+	m.Get("/report.:format", http.NotFound).Name("report")
+	m.Get("/static/*", http.NotFound).Name("static")
+	m.Get("/", http.NotFound).Name("root")
+	m.Get("/search?type=image&q=:query&debug", http.NotFound).Name("search")
+	m.Get(regexp.MustCompile(`^/re/(?P<id>\d+)$`), http.NotFound).Name("re")
+
+	for _, test := range urlOptsTests {
+		url, err := m.URLOpts(test.name, test.opts, test.params...)
+		if err != nil {
+			t.Errorf("For %q %v, unexpected error: %v", test.name,
+				test.params, err)
+		} else if url != test.url {
+			t.Errorf("For %q %v, expected %q, got %q", test.name,
+				test.params, test.url, url)
+		}
+	}
+
+	errTests := []struct {
+		name   string
+		params []string
+	}{
+		{"nonexistent", nil},
+		{"user", nil},
+		{"user", []string{"id"}},
+		{"re", []string{"id", "x"}},
+		{"re", nil},
+		{"search", nil},
+		{"report", []string{"format", "tar.gz"}},
+	}
+	for _, test := range errTests {
+		if _, err := m.URLOpts(test.name, URLOptions{}, test.params...); err == nil {
+			t.Errorf("For %q %v, expected an error", test.name, test.params)
+		}
+	}
+}
+
+var urlTests = []struct {
+	name   string
+	params map[string]string
+	url    string
+}{
+	{"user", map[string]string{"id": "123"}, "/users/123"},
+	{"user", map[string]string{"id": "a b"}, "/users/a%20b"},
+	{"static", map[string]string{"*": "/css/a%20b.css"}, "/static/css/a%20b.css"},
+	{"re", map[string]string{"id": "42"}, "/re/42"},
+	{"re.multi", map[string]string{"year": "2014", "$2": "11"},
+		"/archive/2014/11.html"},
+	{"re.prefix", nil, "/hello"},
+	{"proxy", map[string]string{"service": "users", "path": "/v1/a b"},
+		"/proxy/users/v1/a b"},
+	{"docs", nil, "/docs"},
+	{"docs", map[string]string{"*": "/api/mux"}, "/docs/api/mux"},
+	{"posts", map[string]string{"year": "2014"}, "/posts/2014"},
+	{"posts", map[string]string{"year": "2014", "month": "06"},
+		"/posts/2014/06"},
+	{"active", map[string]string{"x": "1"}, "/a/1"},
+	{"accepts", map[string]string{"x": "1"}, "/b/1"},
+}
+
+var urlErrTests = []struct {
+	name   string
+	params map[string]string
+}{
+	{"user", nil},
+	{"re", map[string]string{"id": "abc"}},
+	{"re", map[string]string{"id": "4/2"}},
+	{"re.multi", map[string]string{"year": "2014"}},
+	{"re.multi", map[string]string{"year": "14", "$2": "11"}},
+	{"re.alt", map[string]string{"id": "1"}},
+	{"posts", map[string]string{"month": "06"}},
+}
+
+func TestURL(t *testing.T) {
+	t.Parallel()
+	m := New()
+	m.Get("/users/:id", http.NotFound).Name("user")
+	m.Get("/static/*", http.NotFound).Name("static")
+	m.Get(regexp.MustCompile(`^/re/(?P<id>\d+)$`), http.NotFound).Name("re")
+	m.Get("/posts/:year(/:month)?", http.NotFound).Name("posts")
+	m.Get("/proxy/:service/*path", http.NotFound).Name("proxy")
+	m.Get("/docs/**", http.NotFound).Name("docs")
+	m.Get(regexp.MustCompile(`^/archive/(?P<year>\d{4})/(\d\d)\.html$`),
+		http.NotFound).Name("re.multi")
+	m.Get(regexp.MustCompile(`/hello`), http.NotFound).Name("re.prefix")
+	m.Get(regexp.MustCompile(`^/(a|b)/(?P<id>\d+)$`), http.NotFound).Name("re.alt")
+	m.Get("/a/:x", http.NotFound).Name("active").Active(ScheduleFunc(
+		func(time.Time) bool { return true }))
+	m.Get(Accepts("application/json", "/b/:x"), http.NotFound).Name("accepts")
+
+	for _, test := range urlTests {
+		url, err := m.URL(test.name, test.params)
+		if err != nil {
+			t.Errorf("For %q %v, unexpected error: %v", test.name,
+				test.params, err)
+		} else if url != test.url {
+			t.Errorf("For %q %v, expected %q, got %q", test.name,
+				test.params, test.url, url)
+		}
+	}
+	for _, test := range urlErrTests {
+		if url, err := m.URL(test.name, test.params); err == nil {
+			t.Errorf("For %q %v, expected an error, got %q", test.name,
+				test.params, url)
+		}
+	}
+}
+
+func TestDuplicateRouteName(t *testing.T) {
+	t.Parallel()
+	m := New()
+	m.Get("/a", http.NotFound).Name("a")
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected a duplicate route name to panic")
+		}
+	}()
+	m.Get("/b", http.NotFound).Name("a")
+}
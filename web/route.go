@@ -0,0 +1,286 @@
+package web
+
+import (
+	"fmt"
+	"reflect"
+	"runtime"
+	"strings"
+)
+
+/*
+Route is a handle on a single route that has been added to a Mux. It is
+returned by each of the Mux's route-adding functions (Get, Post, Handle, etc.)
+so that the route may be further configured, typically by chaining calls:
+
+	m.Get("/maintenance/*", maintenance).Priority(10)
+
+Like the rest of the routing API, it is illegal to modify a Route concurrently
+with active requests.
+*/
+type Route struct {
+	rt    *router
+	route *route
+}
+
+/*
+Priority sets the priority of this route. Routes with a higher priority are
+matched before routes with a lower priority, regardless of how specific their
+patterns are or the order in which they were added; routes with equal priorities
+are matched most specific first, as usual (see Mux). The default priority is
+zero, so raising the priority of a broad route (like "/admin/*") above zero
+allows it to temporarily shadow more specific routes, and priorities can break
+ties between routes which are equally specific, like "/:user" and "/:org".
+*/
+func (r *Route) Priority(n int) *Route {
+	r.rt.lock.Lock()
+	defer r.rt.lock.Unlock()
+	r.route.priority = n
+	r.rt.setMachine(nil)
+	return r
+}
+
+// Doc attaches a human-readable description of this route, such as "Returns
+// the widget with the given ID". The description has no effect on routing, but
+// is reported by Mux.Routes for the benefit of documentation and debugging
+// tools.
+func (r *Route) Doc(doc string) *Route {
+	r.rt.lock.Lock()
+	defer r.rt.lock.Unlock()
+	r.route.doc = doc
+	r.rt.setMachine(nil)
+	return r
+}
+
+/*
+Meta attaches a piece of metadata to this route, such as whether it requires
+authentication, under the given key. Like a route's Doc, metadata has no effect
+on routing, but it is reported by Mux.Routes and, once a request has been
+matched to the route, by RouteMeta. This allows routes to be annotated
+declaratively, and the annotations enforced by a single middleware rather than
+by middleware added to each route:
+
+	m.Get("/admin/stats", stats).Meta("scope", "admin")
+
+	func requireScope(c *web.C, h http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			web.OnRouted(c, func(rc web.C) {
+				scope, _ := web.RouteMeta(rc)["scope"].(string)
+				if scope != "" && !hasScope(r, scope) {
+					http.Error(w, "Forbidden", http.StatusForbidden)
+					c.Abort()
+				}
+			})
+			h.ServeHTTP(w, r)
+		}
+		return http.HandlerFunc(fn)
+	}
+
+Setting a key which has already been set replaces its value.
+*/
+func (r *Route) Meta(key string, value interface{}) *Route {
+	r.rt.lock.Lock()
+	defer r.rt.lock.Unlock()
+	if r.route.meta == nil {
+		r.route.meta = make(map[string]interface{})
+	}
+	r.route.meta[key] = value
+	r.rt.setMachine(nil)
+	return r
+}
+
+// RouteInfo describes a single route added to a Mux. See Mux.Routes.
+type RouteInfo struct {
+	// Methods is the sorted list of HTTP methods this route responds to,
+	// or nil if the route responds to every method (i.e., it was added
+	// with Handle).
+	Methods []string
+	// Pattern is a string representation of the route's pattern. For
+	// string patterns this is the pattern as it was originally given, and
+	// for regular expressions it is the (left-anchored) expression.
+	Pattern string
+	// Doc is the route's description, as set by Route.Doc.
+	Doc string
+	// Example is the route's example request and response, as set by
+	// Route.Example, or nil if it has none.
+	Example *Example
+	// Handler is the name of the route's handler: the fully qualified name
+	// of the function, for handlers which are functions, and the handler's
+	// type otherwise.
+	Handler string
+	// Middleware is the number of middleware in the route's own stack (see
+	// Route.Use), which run in addition to the Mux's middleware.
+	Middleware int
+	// Meta is the route's metadata, as set by Route.Meta, or nil if it has
+	// none.
+	Meta map[string]interface{}
+}
+
+func (rt *router) routeInfo() []RouteInfo {
+	rt.lock.Lock()
+	routes := byMatchOrder(rt.routes)
+	infos := make([]RouteInfo, 0, len(routes))
+	var mounts []int
+	for _, r := range routes {
+		if _, ok := r.handler.(mountHandler); ok {
+			mounts = append(mounts, len(infos))
+		}
+		infos = append(infos, r.describe())
+	}
+	rt.lock.Unlock()
+
+	// Mounted sub-muxes are listed in place of the routes they are mounted
+	// by. This is done without holding the lock, since the sub-mux has a
+	// lock of its own.
+	for i := len(mounts) - 1; i >= 0; i-- {
+		at := mounts[i]
+		r := routes[at]
+		prefix := r.pattern.(mountPattern).exact.raw
+		sub := r.handler.(mountHandler).sub.rt.routeInfo()
+		for j := range sub {
+			sub[j].Pattern = mountedPattern(prefix, sub[j].Pattern)
+		}
+		infos = append(infos[:at], append(sub, infos[at+1:]...)...)
+	}
+	return infos
+}
+
+// mountedPattern returns the pattern of a route of a sub-mux mounted at the
+// given prefix, as seen from the parent.
+func mountedPattern(prefix, pattern string) string {
+	if strings.HasPrefix(pattern, "^/") {
+		return "^" + prefix + pattern[1:]
+	}
+	return prefix + pattern
+}
+
+type mountedKey struct {
+	route  *route
+	prefix string
+}
+
+// mountedInfo returns the description of the given route as seen from the Muxes
+// this one is mounted in, which add the given prefix to its pattern. Like the
+// route's own description, it is the same pointer for every request until the
+// router is next compiled.
+func (rt *router) mountedInfo(r *route, prefix string) *RouteInfo {
+	key := mountedKey{r, prefix}
+	rt.mountedLock.Lock()
+	defer rt.mountedLock.Unlock()
+	if info, ok := rt.mountedInfos[key]; ok {
+		return info
+	}
+	info := *r.info
+	info.Pattern = mountedPattern(prefix, info.Pattern)
+	if rt.mountedInfos == nil {
+		rt.mountedInfos = make(map[mountedKey]*RouteInfo)
+	}
+	rt.mountedInfos[key] = &info
+	return &info
+}
+
+func (r *route) describe() RouteInfo {
+	info := RouteInfo{
+		Pattern: patternString(r.pattern),
+		Doc:     r.doc,
+		Example: r.example,
+		Handler: handlerName(r.handler),
+	}
+	if r.middleware != nil {
+		info.Middleware = len(r.middleware.layers())
+	}
+	if r.meta != nil {
+		info.Meta = make(map[string]interface{}, len(r.meta))
+		for k, v := range r.meta {
+			info.Meta[k] = v
+		}
+	}
+	if r.verb != "" {
+		info.Methods = []string{r.verb}
+	} else if r.method != mALL {
+		info.Methods = methodNames(r.method)
+	}
+	return info
+}
+
+// The key under which the route matched by the request is recorded in its Env,
+// if it has one (a Mux does not allocate an Env just for this). Middleware
+// should prefer C.Route, which works either way.
+const RouteKey = "goji.web.route"
+
+/*
+Route returns a description of the route the request was matched to, or nil if
+it has not (or not yet) been matched to one. Since routing happens after the
+middleware stack has run, middleware will typically call Route either after the
+handler has returned, or from a function registered with OnRouted. For a request
+routed by a sub-mux (see Mux.Mount), Route describes the sub-mux's route as
+Mux.Routes lists it, with the mount prefix included in its Pattern.
+
+The returned RouteInfo is shared by every request matched to the route, and
+the same pointer is returned for each of them until the Mux's routes are next
+changed, which makes it suitable as a map key (for instance, to cache
+per-route data). It must not be modified.
+*/
+func (c C) Route() *RouteInfo {
+	if c.rs != nil && c.rs.route != nil {
+		return c.rs.route
+	}
+	info, _ := c.env()[RouteKey].(*RouteInfo)
+	return info
+}
+
+// PatternFromC returns the pattern of the route the request was matched to, as
+// reported by C.Route (for instance, "/users/:id"), or the empty string if it has
+// not been matched to one. Unlike the request's path, the pattern takes only as
+// many values as there are routes, which makes it suitable as a label for
+// metrics and traces.
+func PatternFromC(c C) string {
+	if info := c.Route(); info != nil {
+		return info.Pattern
+	}
+	return ""
+}
+
+// RouteMeta returns the metadata (see Route.Meta) of the route the request was
+// matched to, as reported by C.Route, or nil if it has not been matched to one
+// or the route has no metadata. The returned map is shared by every request
+// matched to the route, and must not be modified.
+func RouteMeta(c C) map[string]interface{} {
+	if info := c.Route(); info != nil {
+		return info.Meta
+	}
+	return nil
+}
+
+// handlerName returns a human-readable name for the given handler.
+func handlerName(h Handler) string {
+	var v interface{} = h
+	if w, ok := h.(netHTTPWrap); ok {
+		v = w.Handler
+	}
+	return valueName(v)
+}
+
+// valueName returns the fully qualified name of the given value, if it is a
+// function, or the name of its type otherwise.
+func valueName(v interface{}) string {
+	if isFunc(v) {
+		if fn := runtime.FuncForPC(reflect.ValueOf(v).Pointer()); fn != nil {
+			return fn.Name()
+		}
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// patternString returns a human-readable representation of the given pattern.
+func patternString(p Pattern) string {
+	switch v := p.(type) {
+	case stringPattern:
+		return v.raw
+	case regexpPattern:
+		return v.re.String()
+	case fmt.Stringer:
+		return v.String()
+	default:
+		return fmt.Sprintf("%T", p)
+	}
+}
@@ -0,0 +1,55 @@
+package web
+
+import (
+	"net/http"
+)
+
+/*
+Use appends the given middleware to this route's own middleware stack. Route
+middleware wraps only this route's handler: it runs after the Mux's middleware
+stack and after routing, so it is only run for requests which are routed to this
+route (and never for requests which end up at the NotFound handler), and the
+*C it is given already has the route's URLParams. This makes it a good fit for
+concerns like authentication or rate limiting which only apply to a handful of
+routes:
+
+	m.Get("/admin", admin).Use(RequireAdmin).Use(Audit)
+
+Middleware is run in the order it was added, outermost first. It runs inside
+the route's CORS policy (see CORS), so preflight requests are answered before
+it is run, and inside its panic handler (see OnPanic).
+*/
+func (r *Route) Use(middleware MiddlewareType) *Route {
+	r.rt.lock.Lock()
+	defer r.rt.lock.Unlock()
+	if r.route.middleware == nil {
+		r.route.middleware = &mStack{router: routeEndpoint{r.route}}
+	}
+	r.route.middleware.Use(middleware)
+	r.rt.setMachine(nil)
+	return r
+}
+
+// routeEndpoint sits at the bottom of a route's middleware stack, in place of a
+// router. It refers to the route by pointer, rather than closing over its
+// handler, so that it sees the route's handler as it is when the request is
+// served.
+type routeEndpoint struct {
+	rt *route
+}
+
+func (e routeEndpoint) route(c *C, w http.ResponseWriter, r *http.Request) {
+	e.rt.handler.ServeHTTPC(*c, w, r)
+}
+
+// serveHandler calls the route's handler by way of its middleware stack, if it
+// has one.
+func (rt route) serveHandler(c C, w http.ResponseWriter, r *http.Request) {
+	if rt.middleware == nil {
+		rt.handler.ServeHTTPC(c, w, r)
+		return
+	}
+	stack := rt.middleware.alloc()
+	stack.ServeHTTPC(c, w, r)
+	rt.middleware.release(stack)
+}
@@ -0,0 +1,72 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRouteUse(t *testing.T) {
+	t.Parallel()
+	var trace []string
+	tracer := func(name string) func(*C, http.Handler) http.Handler {
+		return func(c *C, h http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				trace = append(trace, name+":"+c.URLParams["id"])
+				h.ServeHTTP(w, r)
+			})
+		}
+	}
+	auth := func(c *C, h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") == "" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			if c.Env == nil {
+				c.Env = make(map[interface{}]interface{})
+			}
+			c.Env["user"] = "carl"
+			h.ServeHTTP(w, r)
+		})
+	}
+
+	m := New()
+	m.Use(tracer("global"))
+	m.Get("/admin/:id", func(c C, w http.ResponseWriter, r *http.Request) {
+		trace = append(trace, "handler:"+c.Env["user"].(string))
+	}).Use(tracer("route")).Use(auth)
+	m.Get("/public/:id", func(c C, w http.ResponseWriter, r *http.Request) {
+		trace = append(trace, "public")
+	})
+
+	tests := []struct {
+		path  string
+		auth  bool
+		code  int
+		trace string
+	}{
+		{"/admin/1", true, 200, "global: route:1 handler:carl"},
+		{"/admin/2", false, 401, "global: route:2"},
+		{"/public/3", false, 200, "global: public"},
+		{"/nowhere", true, 404, "global:"},
+	}
+	for _, test := range tests {
+		trace = nil
+		r, _ := http.NewRequest("GET", test.path, nil)
+		if test.auth {
+			r.Header.Set("Authorization", "yes")
+		}
+		w := httptest.NewRecorder()
+		m.ServeHTTP(w, r)
+		if w.Code != test.code {
+			t.Errorf("For %q, expected status %d, got %d", test.path,
+				test.code, w.Code)
+		}
+		if s := strings.Join(trace, " "); s != test.trace {
+			t.Errorf("For %q, expected trace %q, got %q", test.path,
+				test.trace, s)
+		}
+	}
+}
@@ -0,0 +1,209 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+func TestRoutes(t *testing.T) {
+	t.Parallel()
+	m := New()
+
+	m.Get("/widgets/:id", http.NotFound).Doc("Returns the widget by id")
+	m.Post(regexp.MustCompile(`^/widgets$`), http.NotFound)
+	m.Handle("/admin/*", http.NotFound).Priority(1).Doc("Admin panel").
+		Meta("scope", "admin")
+
+	expected := []RouteInfo{
+		{Methods: nil, Pattern: "/admin/*", Doc: "Admin panel",
+			Handler: "net/http.NotFound",
+			Meta:    map[string]interface{}{"scope": "admin"}},
+		{Methods: []string{"GET", "HEAD"}, Pattern: "/widgets/:id",
+			Doc: "Returns the widget by id", Handler: "net/http.NotFound"},
+		{Methods: []string{"POST"}, Pattern: `^/widgets$`,
+			Handler: "net/http.NotFound"},
+	}
+	if routes := m.Routes(); !reflect.DeepEqual(routes, expected) {
+		t.Errorf("Expected routes %+v, got %+v", expected, routes)
+	}
+}
+
+func showWidget(c C, w http.ResponseWriter, r *http.Request) {}
+
+func TestRoutesMounted(t *testing.T) {
+	t.Parallel()
+	api := New()
+	api.Get("/widgets/:id", showWidget).Use(func(h http.Handler) http.Handler {
+		return h
+	})
+	api.Post(regexp.MustCompile(`^/widgets$`), http.RedirectHandler("/", 303))
+
+	m := New()
+	m.Get("/", http.NotFound)
+	m.Mount("/:tenant/api", api)
+	m.Get("/about", http.NotFound)
+
+	expected := []RouteInfo{
+		{Methods: []string{"GET", "HEAD"}, Pattern: "/",
+			Handler: "net/http.NotFound"},
+		{Methods: []string{"GET", "HEAD"}, Pattern: "/about",
+			Handler: "net/http.NotFound"},
+		{Methods: []string{"GET", "HEAD"}, Pattern: "/:tenant/api/widgets/:id",
+			Handler: "github.com/zenazn/goji/web.showWidget", Middleware: 1},
+		{Methods: []string{"POST"}, Pattern: `^/:tenant/api/widgets$`,
+			Handler: "*http.redirectHandler"},
+	}
+	if routes := m.Routes(); !reflect.DeepEqual(routes, expected) {
+		t.Errorf("Expected routes %+v, got %+v", expected, routes)
+	}
+}
+
+func TestCRoute(t *testing.T) {
+	t.Parallel()
+	m := New()
+	var infos []*RouteInfo
+	m.Use(func(c *C, h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			h.ServeHTTP(w, r)
+			infos = append(infos, c.Route())
+		})
+	})
+	m.Get("/widgets/:id", http.NotFound)
+
+	for _, path := range []string{"/widgets/1", "/widgets/2", "/nope"} {
+		r, _ := http.NewRequest("GET", path, nil)
+		m.ServeHTTP(httptest.NewRecorder(), r)
+	}
+	if infos[0] == nil || infos[0].Pattern != "/widgets/:id" {
+		t.Errorf("Expected matched route, got %+v", infos[0])
+	}
+	if infos[1] != infos[0] {
+		t.Error("Expected requests to the same route to share a RouteInfo")
+	}
+	if infos[2] != nil {
+		t.Errorf("Expected no route for unmatched request, got %+v", infos[2])
+	}
+}
+
+func TestPatternFromC(t *testing.T) {
+	t.Parallel()
+	m := New()
+	patterns := make(chan string, 1)
+	m.Use(func(c *C, h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			h.ServeHTTP(w, r)
+			patterns <- PatternFromC(*c)
+		})
+	})
+	m.Get("/users/:id", func(c C, w http.ResponseWriter, r *http.Request) {
+		if p := PatternFromC(c); p != "/users/:id" {
+			t.Errorf("Expected the handler to see the pattern, got %q", p)
+		}
+	})
+	m.Get(regexp.MustCompile(`^/posts/(?P<id>\d+)$`), http.NotFound)
+
+	tests := map[string]string{
+		"/users/4827": "/users/:id",
+		"/posts/12":   `^/posts/(?P<id>\d+)$`,
+		"/nowhere":    "",
+	}
+	for path, expected := range tests {
+		r, _ := http.NewRequest("GET", path, nil)
+		m.ServeHTTP(httptest.NewRecorder(), r)
+		if p := <-patterns; p != expected {
+			t.Errorf("For %q, expected pattern %q, got %q", path, expected, p)
+		}
+	}
+}
+
+func TestPatternFromCMounted(t *testing.T) {
+	t.Parallel()
+	m := New()
+	patterns := make(chan string, 2)
+	m.Use(func(c *C, h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			h.ServeHTTP(w, r)
+			patterns <- PatternFromC(*c)
+		})
+	})
+	api := New()
+	api.Get("/users/:id", func(c C, w http.ResponseWriter, r *http.Request) {
+		patterns <- PatternFromC(c)
+	})
+	v1 := New()
+	v1.Get(regexp.MustCompile(`^/posts/(?P<id>\d+)$`), func(c C, w http.ResponseWriter, r *http.Request) {
+		patterns <- PatternFromC(c)
+	})
+	api.Mount("/v1", v1)
+	m.Mount("/:tenant/api", api)
+
+	tests := map[string]string{
+		"/acme/api/users/4827":  "/:tenant/api/users/:id",
+		"/acme/api/v1/posts/12": `^/:tenant/api/v1/posts/(?P<id>\d+)$`,
+	}
+	for path, expected := range tests {
+		r, _ := http.NewRequest("GET", path, nil)
+		m.ServeHTTP(httptest.NewRecorder(), r)
+		if p := <-patterns; p != expected {
+			t.Errorf("For %q, expected the handler to see %q, got %q",
+				path, expected, p)
+		}
+		if p := <-patterns; p != expected {
+			t.Errorf("For %q, expected middleware to see %q, got %q",
+				path, expected, p)
+		}
+	}
+}
+
+func TestRouteMeta(t *testing.T) {
+	t.Parallel()
+	m := New()
+	m.Use(func(c *C, h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			OnRouted(c, func(rc C) {
+				scope, _ := RouteMeta(rc)["scope"].(string)
+				if scope != "" && r.Header.Get("X-Scope") != scope {
+					http.Error(w, "Forbidden", http.StatusForbidden)
+					c.Abort()
+				}
+			})
+			h.ServeHTTP(w, r)
+		})
+	})
+	ok := func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}
+	m.Get("/admin/stats", ok).Meta("auth", "required").Meta("scope", "user").
+		Meta("scope", "admin")
+	m.Get("/public", ok)
+
+	tests := []struct {
+		path, scope string
+		code        int
+	}{
+		{"/admin/stats", "admin", http.StatusOK},
+		{"/admin/stats", "", http.StatusForbidden},
+		{"/public", "", http.StatusOK},
+	}
+	for _, test := range tests {
+		r, _ := http.NewRequest("GET", test.path, nil)
+		r.Header.Set("X-Scope", test.scope)
+		w := httptest.NewRecorder()
+		m.ServeHTTP(w, r)
+		if w.Code != test.code {
+			t.Errorf("For %q with scope %q, expected %d, got %d",
+				test.path, test.scope, test.code, w.Code)
+		}
+	}
+
+	expected := map[string]interface{}{"auth": "required", "scope": "admin"}
+	if meta := m.Routes()[0].Meta; !reflect.DeepEqual(meta, expected) {
+		t.Errorf("Expected metadata %v, got %v", expected, meta)
+	}
+	if meta := RouteMeta(C{}); meta != nil {
+		t.Errorf("Expected no metadata for an unrouted request, got %v", meta)
+	}
+}
@@ -0,0 +1,69 @@
+package web
+
+// The key used to store the list of functions to call once a request has been
+// routed. See OnRouted.
+const routedHooksKey = "goji.web.routedHooks"
+
+// The key used to record that the request was routed, but matched no route. See
+// C.Unmatched.
+const UnmatchedKey = "goji.web.unmatched"
+
+/*
+OnRouted arranges for fn to be called once the request associated with the given
+context has been matched to a route, immediately before the route's handler is
+called. This allows middleware, which run before routing takes place, to act on
+properties of the matched route (for instance, its LogLevel). The function is
+passed the context as the handler will see it. If the request does not match a
+route (i.e., it is passed to the NotFound handler), fn is never called.
+
+Functions are registered in the context's Env, so they are lost if a later
+middleware replaces the Env map wholesale.
+*/
+func OnRouted(c *C, fn func(C)) {
+	c.initEnv()
+	hooks, _ := c.Env[routedHooksKey].([]func(C))
+	c.Env[routedHooksKey] = append(hooks, fn)
+}
+
+// matched records the route matched by the given request in its context, and
+// runs any functions registered with OnRouted.
+func (rt *router) matched(c *C, route *route) {
+	if c.mux == nil {
+		c.mux = rt.mux
+	}
+	info := route.info
+	if c.mountPrefix != "" {
+		info = rt.mountedInfo(route, c.mountPrefix)
+	}
+	if c.rs != nil {
+		c.rs.route = info
+	}
+	if rt.errorHandler != nil {
+		c.initEnv()
+		c.Env[errorHandlerKey] = rt.errorHandler
+	}
+	if route.logLevel != LogNormal {
+		c.initEnv()
+		c.Env[LogLevelKey] = route.logLevel
+	}
+	if c.Env == nil {
+		return
+	}
+	c.Env[RouteKey] = info
+	if hooks, ok := c.Env[routedHooksKey].([]func(C)); ok {
+		delete(c.Env, routedHooksKey)
+		for _, fn := range hooks {
+			fn(*c)
+		}
+	}
+}
+
+// Unmatched reports whether the request was routed by a Mux, but matched none of
+// its routes, and so was passed to one of the Mux's handlers for unroutable
+// requests (see Mux.NotFound and Mux.MethodNotAllowed) instead. Requests which
+// never reached the router, for instance because middleware answered them
+// itself, are not unmatched.
+func (c C) Unmatched() bool {
+	unmatched, _ := c.env()[UnmatchedKey].(bool)
+	return unmatched
+}
@@ -0,0 +1,76 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestOnRouted(t *testing.T) {
+	t.Parallel()
+	m := New()
+	var calls []string
+	m.Use(func(c *C, h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			OnRouted(c, func(c C) {
+				calls = append(calls, "routed "+c.URLParams["name"])
+			})
+			h.ServeHTTP(w, r)
+		})
+	})
+	m.Get("/hello/:name", func(c C, w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, "handler")
+	})
+
+	r, _ := http.NewRequest("GET", "/hello/carl", nil)
+	m.ServeHTTP(httptest.NewRecorder(), r)
+	if len(calls) != 2 || calls[0] != "routed carl" || calls[1] != "handler" {
+		t.Errorf("Unexpected calls %v", calls)
+	}
+
+	calls = nil
+	r, _ = http.NewRequest("GET", "/nope", nil)
+	m.ServeHTTP(httptest.NewRecorder(), r)
+	if len(calls) != 0 {
+		t.Errorf("Expected no calls for a 404, got %v", calls)
+	}
+}
+
+func TestUnmatched(t *testing.T) {
+	t.Parallel()
+	m := New()
+	unmatched := false
+	m.Use(func(c *C, h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("X-Reject") != "" {
+				w.WriteHeader(http.StatusForbidden)
+				c.Abort()
+			}
+			h.ServeHTTP(w, r)
+			unmatched = c.Unmatched()
+		})
+	})
+	m.Get("/hello", http.NotFound)
+
+	tests := []struct {
+		method, path string
+		reject       bool
+		unmatched    bool
+	}{
+		{"GET", "/hello", false, false},
+		{"GET", "/nope", false, true},
+		{"POST", "/hello", false, true},
+		{"GET", "/nope", true, false},
+	}
+	for _, test := range tests {
+		r, _ := http.NewRequest(test.method, test.path, nil)
+		if test.reject {
+			r.Header.Set("X-Reject", "1")
+		}
+		m.ServeHTTP(httptest.NewRecorder(), r)
+		if unmatched != test.unmatched {
+			t.Errorf("For %s %s (rejected: %v), expected unmatched %v",
+				test.method, test.path, test.reject, test.unmatched)
+		}
+	}
+}
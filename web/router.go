@@ -1,6 +1,8 @@
 package web
 
 import (
+	"context"
+	"fmt"
 	"log"
 	"net/http"
 	"sort"
@@ -45,17 +47,72 @@ var validMethodsMap = map[string]method{
 }
 
 type route struct {
-	prefix  string
-	method  method
-	pattern Pattern
-	handler Handler
+	prefix string
+	method method
+	// The name of the method this route responds to, if it is not one of
+	// the natively supported methods (in which case method is mIDK). See
+	// Mux.Method.
+	verb     string
+	pattern  Pattern
+	handler  Handler
+	priority int
+	doc      string
+	meta     map[string]interface{}
+	consumes []string
+	produces []string
+	logLevel LogLevel
+	// Defaults for query parameters, keyed by name. See
+	// Route.QueryDefault.
+	queryDefaults map[string]string
+	onPanic       PanicHandler
+	example       *Example
+	cors          CORSPolicy
+	middleware    *mStack
+	// A description of this route, regenerated each time the router is
+	// compiled. See C.Route.
+	info *RouteInfo
 }
 
 type router struct {
-	lock     sync.Mutex
-	routes   []route
+	lock sync.Mutex
+	// routes is the list of routes in the order they were added. Routes
+	// are not sorted until the router is compiled, since their priorities
+	// may be changed after they are added.
+	routes   []*route
 	notFound Handler
 	machine  *routeMachine
+	clock    Clock
+	// Routes by name. See Route.Name.
+	names map[string]*route
+	// The Mux this router belongs to. See C.Dispatch.
+	mux *Mux
+	// All request contexts are canceled when base is. See Mux.Close.
+	base      context.Context
+	closeBase context.CancelFunc
+
+	notAcceptable        Handler
+	unsupportedMediaType Handler
+	// See Mux.AutoOptions and Mux.MethodNotAllowed.
+	autoOptions      bool
+	methodNotAllowed Handler
+	// See Mux.RedirectTrailingSlash.
+	redirectSlash bool
+	// See Mux.CaseInsensitive.
+	caseInsensitive bool
+	// See Mux.CleanPath.
+	cleanPath bool
+	// See Mux.OnPanic.
+	onPanic PanicHandler
+	// See Mux.Tracer.
+	tracer Tracer
+	// See Mux.ErrorHandler.
+	errorHandler ErrorHandler
+
+	// Descriptions of routes as seen from the Muxes this one is mounted
+	// in, which are cleared each time the router is compiled. See
+	// router.mountedInfo.
+	mountedLock  sync.Mutex
+	mountedInfos map[mountedKey]*RouteInfo
 }
 
 type netHTTPWrap struct {
@@ -69,19 +126,43 @@ func (h netHTTPWrap) ServeHTTPC(c C, w http.ResponseWriter, r *http.Request) {
 const unknownHandler = `Unknown handler type %T. See http://godoc.org/github.com/zenazn/goji/web#HandlerType for a list of acceptable types.`
 
 func parseHandler(h interface{}) Handler {
+	handler, ok := handlerOf(h)
+	if !ok {
+		log.Fatalf(unknownHandler, h)
+	}
+	return handler
+}
+
+/*
+HandlerOf converts a value of any of the types described by HandlerType to a
+Handler, in the same way the Mux does when it is given one. This is useful to
+code which accepts handlers the way the Mux does, but which calls them itself
+(for instance, test helpers). HandlerOf panics if h is of an unknown type.
+*/
+func HandlerOf(h HandlerType) Handler {
+	handler, ok := handlerOf(h)
+	if !ok {
+		panic(fmt.Sprintf(unknownHandler, h))
+	}
+	return handler
+}
+
+func handlerOf(h interface{}) (Handler, bool) {
 	switch f := h.(type) {
 	case Handler:
-		return f
+		return f, true
 	case http.Handler:
-		return netHTTPWrap{f}
+		return netHTTPWrap{f}, true
 	case func(c C, w http.ResponseWriter, r *http.Request):
-		return HandlerFunc(f)
+		return HandlerFunc(f), true
 	case func(w http.ResponseWriter, r *http.Request):
-		return netHTTPWrap{http.HandlerFunc(f)}
-	default:
-		log.Fatalf(unknownHandler, h)
-		panic("log.Fatalf does not return")
+		return netHTTPWrap{http.HandlerFunc(f)}, true
+	case func(ctx context.Context, w http.ResponseWriter, r *http.Request):
+		return ContextHandlerFunc(f), true
+	case func(c C, w http.ResponseWriter, r *http.Request) error:
+		return HandlerFuncWithError(f), true
 	}
+	return nil, false
 }
 
 func httpMethod(mname string) method {
@@ -91,12 +172,74 @@ func httpMethod(mname string) method {
 	return mIDK
 }
 
+// methodNames returns the sorted list of names of the natively supported HTTP
+// methods in the given method set.
+func methodNames(methods method) []string {
+	var names = make([]string, 0)
+	for mname, meth := range validMethodsMap {
+		if methods&meth != 0 {
+			names = append(names, mname)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// addVerbs adds the given extension methods to a sorted list of method names,
+// keeping it sorted and free of duplicates.
+func addVerbs(names, verbs []string) []string {
+	for _, verb := range verbs {
+		i := sort.SearchStrings(names, verb)
+		if i < len(names) && names[i] == verb {
+			continue
+		}
+		names = append(names, "")
+		copy(names[i+1:], names[i:])
+		names[i] = verb
+	}
+	return names
+}
+
+// validVerb reports whether name is a syntactically valid HTTP method (that
+// is, an RFC 7230 token).
+func validVerb(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		case strings.IndexByte("!#$%&'*+-.^_`|~", c) >= 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
 func (rt *router) compile() *routeMachine {
 	rt.lock.Lock()
 	defer rt.lock.Unlock()
+	for _, r := range rt.routes {
+		info := r.describe()
+		r.info = &info
+	}
+	rt.mountedLock.Lock()
+	rt.mountedInfos = nil
+	rt.mountedLock.Unlock()
+	routes := rt.routes
+	if rt.caseInsensitive {
+		routes = make([]*route, len(rt.routes))
+		for i, r := range rt.routes {
+			routes[i] = foldRoute(r)
+		}
+	}
+	sorted := sortRoutes(routes)
 	sm := routeMachine{
-		sm:     compile(rt.routes),
-		routes: rt.routes,
+		sm:     compile(sorted),
+		routes: sorted,
+		fold:   rt.caseInsensitive,
 	}
 	rt.setMachine(&sm)
 	return &sm
@@ -107,66 +250,190 @@ func (rt *router) route(c *C, w http.ResponseWriter, r *http.Request) {
 	if rm == nil {
 		rm = rt.compile()
 	}
+	defer rt.recordTermination(c, r)
+	if rt.onPanic != nil {
+		defer func() {
+			if err := recover(); err != nil {
+				rt.onPanic(*c, w, r, err)
+			}
+		}()
+	}
 
-	methods, route := rm.route(c, w, r)
+	if rt.cleanPath && rt.redirectCleaned(w, r) {
+		return
+	}
+
+	ms, route := rm.route(c, w, r)
 	if route != nil {
-		route.handler.ServeHTTPC(*c, w, r)
+		route.applyQueryDefaults(c, r)
+		rt.matched(c, route)
+		if rt.tracer != nil {
+			rt.tracer.RouteMatched(c, route.pattern)
+		}
+		// OnRouted hooks may abort the request
+		if c.Aborted() {
+			return
+		}
+		if rt.tracer != nil {
+			rt.serveTraced(route, *c, w, r)
+		} else {
+			route.serve(*c, w, r)
+		}
+		return
+	}
+	c.initEnv()
+	c.Env[UnmatchedKey] = true
+	if rt.tracer != nil {
+		rt.tracer.RouteNotFound(r)
+	}
+
+	// If some route was willing to accept the request body, the problem
+	// is with the response, so we prefer reporting that.
+	if ms.negotiation&negNotAcceptable != 0 {
+		rt.notAcceptable.ServeHTTPC(*c, w, r)
+		return
+	} else if ms.negotiation&negUnsupportedMediaType != 0 {
+		rt.unsupportedMediaType.ServeHTTPC(*c, w, r)
 		return
 	}
 
+	methods := ms.methods
+	if methods == 0 && rt.redirectSlash && rt.redirectSlashed(rm, w, r) {
+		return
+	}
 	if methods == 0 {
 		rt.notFound.ServeHTTPC(*c, w, r)
 		return
 	}
 
-	var methodsList = make([]string, 0)
-	for mname, meth := range validMethodsMap {
-		if methods&meth != 0 {
-			methodsList = append(methodsList, mname)
-		}
+	methodsList := addVerbs(methodNames(methods), ms.verbs)
+	c.initEnv()
+	c.Env[ValidMethodsKey] = methodsList
+	if !rt.autoOptions {
+		rt.notFound.ServeHTTPC(*c, w, r)
+		return
 	}
-	sort.Strings(methodsList)
 
-	if c.Env == nil {
-		c.Env = map[interface{}]interface{}{
-			ValidMethodsKey: methodsList,
-		}
+	allow := methodsList
+	if methods&mOPTIONS == 0 {
+		allow = append(allow[:len(allow):len(allow)], "OPTIONS")
+	}
+	w.Header().Set("Allow", strings.Join(allow, ", "))
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	rt.methodNotAllowed.ServeHTTPC(*c, w, r)
+}
+
+// redirectSlashed redirects the request to the same path with its trailing
+// slash added or removed, provided the request would be routed there, and
+// reports whether it did so.
+func (rt *router) redirectSlashed(rm *routeMachine, w http.ResponseWriter, r *http.Request) bool {
+	path := r.URL.Path
+	if strings.HasSuffix(path, "/") {
+		path = path[:len(path)-1]
 	} else {
-		c.Env[ValidMethodsKey] = methodsList
+		path += "/"
+	}
+	if path == "" {
+		return false
+	}
+
+	u := *r.URL
+	u.Path = path
+	u.RawPath = ""
+	r2 := r.WithContext(r.Context())
+	r2.URL = &u
+	if _, route := rm.route(&C{}, w, r2); route == nil {
+		return false
 	}
-	rt.notFound.ServeHTTPC(*c, w, r)
+
+	permanentRedirect(w, r, u.EscapedPath())
+	return true
+}
+
+// permanentRedirect redirects the request to the given (escaped) path, keeping
+// its query string: with a 301 Moved Permanently for GET and HEAD requests, and
+// a 308 Permanent Redirect, which preserves the method and body, otherwise.
+func permanentRedirect(w http.ResponseWriter, r *http.Request, path string) {
+	code := http.StatusPermanentRedirect
+	if r.Method == "GET" || r.Method == "HEAD" {
+		code = http.StatusMovedPermanently
+	}
+	location := path
+	if r.URL.RawQuery != "" {
+		location += "?" + r.URL.RawQuery
+	}
+	w.Header().Set("Location", location)
+	w.WriteHeader(code)
 }
 
-func (rt *router) handleUntyped(p interface{}, m method, h interface{}) {
-	rt.handle(ParsePattern(p), m, parseHandler(h))
+func (rt *router) handleUntyped(p interface{}, m method, h interface{}) *Route {
+	return rt.handle(ParsePattern(p), m, parseHandler(h))
 }
 
-func (rt *router) handle(p Pattern, m method, h Handler) {
+func (rt *router) handle(p Pattern, m method, h Handler) *Route {
 	rt.lock.Lock()
 	defer rt.lock.Unlock()
 
-	// Calculate the sorted insertion point, because there's no reason to do
-	// swapping hijinks if we're already making a copy. We need to use
-	// bubble sort because we can only compare adjacent elements.
-	pp := p.Prefix()
-	var i int
-	for i = len(rt.routes); i > 0; i-- {
-		rip := rt.routes[i-1].prefix
-		if rip <= pp || strings.HasPrefix(rip, pp) {
-			break
-		}
-	}
-
-	newRoutes := make([]route, len(rt.routes)+1)
-	copy(newRoutes, rt.routes[:i])
-	newRoutes[i] = route{
-		prefix:  pp,
+	r := &route{
+		prefix:  p.Prefix(),
 		method:  m,
 		pattern: p,
 		handler: h,
 	}
-	copy(newRoutes[i+1:], rt.routes[i:])
-
+	rt.routes = append(rt.routes, r)
 	rt.setMachine(nil)
-	rt.routes = newRoutes
+	return &Route{rt: rt, route: r}
+}
+
+// sortRoutes returns a copy of the given routes in the order in which they
+// should be compiled. Routes are first put in the order a naive router would
+// try them in (see byMatchOrder). We then sort routes by prefix, with
+// the caveat that a route may not be moved past a route that might also match
+// the same string. We need to use insertion sort here because we can only
+// compare adjacent elements.
+func sortRoutes(routes []*route) []route {
+	sorted := make([]route, 0, len(routes))
+	for _, r := range byMatchOrder(routes) {
+		var i int
+		for i = len(sorted); i > 0; i-- {
+			rip := sorted[i-1].prefix
+			if rip <= r.prefix || strings.HasPrefix(rip, r.prefix) {
+				break
+			}
+		}
+		sorted = append(sorted, route{})
+		copy(sorted[i+1:], sorted[i:])
+		sorted[i] = *r
+	}
+	return sorted
+}
+
+// byMatchOrder returns a copy of the given routes in the order a naive router
+// would try them in: by priority, and then by precedence (see precedence), so
+// that more specific routes are tried first. Routes with the same precedence
+// are tried in the order they were added, except that routes which only respond
+// to HEAD requests go first, so that they take precedence over the GET routes
+// which also serve HEAD requests (see Mux.Get). Since such routes match no
+// other requests, this changes nothing else.
+func byMatchOrder(routes []*route) []*route {
+	sorted := make([]*route, len(routes))
+	copy(sorted, routes)
+	ranks := make(map[*route]precedence, len(routes))
+	for _, r := range routes {
+		ranks[r] = precedenceOf(r.pattern)
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		ri, rj := sorted[i], sorted[j]
+		if ri.priority != rj.priority {
+			return ri.priority > rj.priority
+		}
+		if c := ranks[ri].compare(ranks[rj]); c != 0 {
+			return c > 0
+		}
+		return ri.method == mHEAD && rj.method != mHEAD
+	})
+	return sorted
 }
@@ -1,10 +1,12 @@
 package web
 
 import (
+	"context"
 	"net/http"
 	"net/http/httptest"
 	"reflect"
 	"regexp"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -51,6 +53,92 @@ func TestMethods(t *testing.T) {
 	}
 }
 
+func TestMethod(t *testing.T) {
+	t.Parallel()
+	m := New()
+	ch := make(chan string, 1)
+
+	m.Method("REPORT", "/cal", chHandler(ch, "REPORT"))
+	m.Method("PROPFIND", "/cal", chHandler(ch, "PROPFIND"))
+	m.Method("GET", "/cal", chHandler(ch, "GET"))
+	m.Handle("/cal", chHandler(ch, "OTHER"))
+
+	tests := []struct {
+		method, expected string
+	}{
+		{"REPORT", "REPORT"},
+		{"PROPFIND", "PROPFIND"},
+		{"GET", "GET"},
+		{"HEAD", "GET"},
+		{"report", "OTHER"},
+		{"MKCOL", "OTHER"},
+	}
+	for _, test := range tests {
+		r, _ := http.NewRequest(test.method, "/cal", nil)
+		m.ServeHTTP(httptest.NewRecorder(), r)
+		select {
+		case val := <-ch:
+			if val != test.expected {
+				t.Errorf("For %q, got %q, expected %q", test.method,
+					val, test.expected)
+			}
+		case <-time.After(5 * time.Millisecond):
+			t.Errorf("Timeout waiting for method %q", test.method)
+		}
+	}
+
+	m = New()
+	m.Method("REPORT", "/cal", http.NotFound)
+	m.Put("/cal", http.NotFound)
+	r, _ := http.NewRequest("DELETE", "/cal", nil)
+	w := httptest.NewRecorder()
+	m.ServeHTTP(w, r)
+	if allow := w.HeaderMap.Get("Allow"); allow != "PUT, REPORT, OPTIONS" {
+		t.Errorf("Expected Allow: PUT, REPORT, OPTIONS, got %q", allow)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected an invalid method to panic")
+		}
+	}()
+	m.Method("NOT A METHOD", "/", http.NotFound)
+}
+
+func TestHeadPrecedence(t *testing.T) {
+	t.Parallel()
+	m := New()
+	ch := make(chan string, 1)
+
+	m.Get("/", chHandler(ch, "GET"))
+	m.Head("/", chHandler(ch, "HEAD"))
+	m.Get("/only-get", chHandler(ch, "GET"))
+	m.Head("/urgent", chHandler(ch, "HEAD"))
+	m.Get("/urgent", chHandler(ch, "URGENT")).Priority(1)
+
+	tests := []struct {
+		method, path, expected string
+	}{
+		{"GET", "/", "GET"},
+		{"HEAD", "/", "HEAD"},
+		{"HEAD", "/only-get", "GET"},
+		{"HEAD", "/urgent", "URGENT"},
+	}
+	for _, test := range tests {
+		r, _ := http.NewRequest(test.method, test.path, nil)
+		m.ServeHTTP(httptest.NewRecorder(), r)
+		select {
+		case val := <-ch:
+			if val != test.expected {
+				t.Errorf("For %s %q, got %q, expected %q", test.method,
+					test.path, val, test.expected)
+			}
+		case <-time.After(5 * time.Millisecond):
+			t.Errorf("Timeout waiting for %s %q", test.method, test.path)
+		}
+	}
+}
+
 type testPattern struct{}
 
 func (t testPattern) Prefix() string {
@@ -90,6 +178,7 @@ var testHandlerTable = map[string]string{
 	"/c": "web fn",
 	"/d": "web handler",
 	"/e": "httpc",
+	"/f": "context fn",
 }
 
 func TestHandlerTypes(t *testing.T) {
@@ -110,6 +199,9 @@ func TestHandlerTypes(t *testing.T) {
 		ch <- "web handler"
 	}))
 	m.Get("/e", testHandler(ch))
+	m.Get("/f", func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		ch <- "context fn"
+	})
 
 	for route, response := range testHandlerTable {
 		r, _ := http.NewRequest("GET", route, nil)
@@ -216,6 +308,59 @@ func TestRouteSelection(t *testing.T) {
 	}
 }
 
+func TestURLParamsReused(t *testing.T) {
+	t.Parallel()
+	m := New()
+	m.ReuseURLParams(true)
+	var got map[string]string
+	h := func(c C, w http.ResponseWriter, r *http.Request) {
+		got = c.Copy().URLParams
+	}
+	m.Get("/static", h)
+	m.Get("/users/:id", h)
+	m.Get("/files/*", h)
+
+	// Requests are served one at a time, so the same middleware stack (and
+	// so the same URLParams map) is used for each of them.
+	tests := []struct {
+		path   string
+		params map[string]string
+	}{
+		{"/users/carl", map[string]string{"id": "carl"}},
+		{"/static", nil},
+		{"/files/a/b", map[string]string{"*": "/a/b"}},
+		{"/users/bob", map[string]string{"id": "bob"}},
+	}
+	for _, test := range tests {
+		r, _ := http.NewRequest("GET", test.path, nil)
+		m.ServeHTTP(httptest.NewRecorder(), r)
+		if !reflect.DeepEqual(got, test.params) {
+			t.Errorf("For %q, expected params %v, got %v", test.path,
+				test.params, got)
+		}
+	}
+}
+
+func TestURLParamsRetained(t *testing.T) {
+	t.Parallel()
+	m := New()
+	var retained []map[string]string
+	m.Get("/users/:id", func(c C, w http.ResponseWriter, r *http.Request) {
+		retained = append(retained, c.URLParams)
+	})
+
+	// Unless told otherwise, each request keeps its own URLParams map.
+	for _, id := range []string{"carl", "bob"} {
+		r, _ := http.NewRequest("GET", "/users/"+id, nil)
+		m.ServeHTTP(httptest.NewRecorder(), r)
+	}
+	if len(retained) != 2 || retained[0]["id"] != "carl" ||
+		retained[1]["id"] != "bob" {
+		t.Errorf("Expected retained params to be left alone, got %v",
+			retained)
+	}
+}
+
 func TestNotFound(t *testing.T) {
 	t.Parallel()
 	m := New()
@@ -261,6 +406,83 @@ func TestPrefix(t *testing.T) {
 	}
 }
 
+func TestOptionalWildcard(t *testing.T) {
+	t.Parallel()
+	m := New()
+	ch := make(chan string, 1)
+
+	// The sibling routes share a prefix with "/docs/**", so that the bare
+	// path must survive the router's prefix matching.
+	m.Get("/docs/**", func(c C, w http.ResponseWriter, r *http.Request) {
+		ch <- "docs " + c.URLParams["*"]
+	})
+	m.Get("/docs/changelog", chHandler(ch, "changelog"))
+	m.Get("/docsite", chHandler(ch, "docsite"))
+
+	table := []struct {
+		path, expected string
+	}{
+		{"/docs", "docs "},
+		{"/docs/", "docs /"},
+		{"/docs/api/mux", "docs /api/mux"},
+		{"/docs/changelog", "changelog"},
+		{"/docsite", "docsite"},
+	}
+	for _, test := range table {
+		r, _ := http.NewRequest("GET", test.path, nil)
+		m.ServeHTTP(httptest.NewRecorder(), r)
+		select {
+		case val := <-ch:
+			if val != test.expected {
+				t.Errorf("For %q, got %q, expected %q", test.path,
+					val, test.expected)
+			}
+		case <-time.After(5 * time.Millisecond):
+			t.Errorf("Timeout waiting for path %q", test.path)
+		}
+	}
+}
+
+type countingPattern struct {
+	prefix  string
+	matches *int32
+}
+
+func (p countingPattern) Prefix() string {
+	return p.prefix
+}
+func (p countingPattern) Match(r *http.Request, c *C) bool {
+	atomic.AddInt32(p.matches, 1)
+	return false
+}
+func (p countingPattern) Run(r *http.Request, c *C) {
+}
+
+func TestPrefixRejection(t *testing.T) {
+	t.Parallel()
+	var admin, any int32
+	m := New()
+	m.Get(countingPattern{"/admin/", &admin}, http.NotFound)
+	m.Get(countingPattern{"", &any}, http.NotFound)
+
+	tests := []struct {
+		path       string
+		admin, any int32
+	}{
+		{"/public/index.html", 0, 1},
+		{"/adm", 0, 2},
+		{"/admin/users", 1, 3},
+	}
+	for _, test := range tests {
+		r, _ := http.NewRequest("GET", test.path, nil)
+		m.ServeHTTP(httptest.NewRecorder(), r)
+		if a, n := atomic.LoadInt32(&admin), atomic.LoadInt32(&any); a != test.admin || n != test.any {
+			t.Errorf("After %q, expected %d and %d calls to Match, "+
+				"got %d and %d", test.path, test.admin, test.any, a, n)
+		}
+	}
+}
+
 var validMethodsTable = map[string][]string{
 	"/hello/carl":       {"DELETE", "GET", "HEAD", "PATCH", "POST", "PUT"},
 	"/hello/bob":        {"DELETE", "GET", "HEAD", "PATCH", "PUT"},
@@ -272,6 +494,7 @@ var validMethodsTable = map[string][]string{
 func TestValidMethods(t *testing.T) {
 	t.Parallel()
 	m := New()
+	m.AutoOptions(false)
 	ch := make(chan []string, 1)
 
 	m.NotFound(func(c C, w http.ResponseWriter, r *http.Request) {
@@ -324,3 +547,224 @@ func TestValidMethods(t *testing.T) {
 		}
 	}
 }
+
+func TestAutoOptions(t *testing.T) {
+	t.Parallel()
+	m := New()
+	m.Get("/hello/carl", http.NotFound)
+	m.Post("/hello/:name", http.NotFound)
+	m.Options("/bye", http.NotFound)
+	m.Put("/bye", http.NotFound)
+
+	tests := []struct {
+		method, path string
+		code         int
+		allow        string
+	}{
+		{"DELETE", "/hello/carl", 405, "GET, HEAD, POST, OPTIONS"},
+		{"OPTIONS", "/hello/carl", 200, "GET, HEAD, POST, OPTIONS"},
+		{"GET", "/hello/bob", 405, "POST, OPTIONS"},
+		{"GET", "/bye", 405, "OPTIONS, PUT"},
+		{"GET", "/nowhere", 404, ""},
+		{"OPTIONS", "/nowhere", 404, ""},
+	}
+	for _, test := range tests {
+		r, _ := http.NewRequest(test.method, test.path, nil)
+		w := httptest.NewRecorder()
+		m.ServeHTTP(w, r)
+		allow := w.HeaderMap.Get("Allow")
+		if w.Code != test.code || allow != test.allow {
+			t.Errorf("For %s %s, expected %d with Allow %q, got %d "+
+				"with Allow %q", test.method, test.path, test.code,
+				test.allow, w.Code, allow)
+		}
+	}
+	r, _ := http.NewRequest("OPTIONS", "/hello/carl", nil)
+	w := httptest.NewRecorder()
+	m.ServeHTTP(w, r)
+	if w.Body.Len() != 0 {
+		t.Errorf("Expected an empty OPTIONS response, got %q", w.Body.String())
+	}
+
+	m.MethodNotAllowed(func(c C, w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+	r, _ = http.NewRequest("DELETE", "/hello/carl", nil)
+	w = httptest.NewRecorder()
+	m.ServeHTTP(w, r)
+	if w.Code != http.StatusTeapot {
+		t.Errorf("Expected the MethodNotAllowed handler, got %d", w.Code)
+	}
+
+	m.AutoOptions(false)
+	for _, method := range []string{"DELETE", "OPTIONS"} {
+		r, _ := http.NewRequest(method, "/hello/carl", nil)
+		w := httptest.NewRecorder()
+		m.ServeHTTP(w, r)
+		if w.Code != http.StatusNotFound {
+			t.Errorf("For %s with AutoOptions disabled, expected 404, "+
+				"got %d", method, w.Code)
+		}
+	}
+}
+
+func TestRedirectTrailingSlash(t *testing.T) {
+	t.Parallel()
+	m := New()
+	m.Get("/users/", http.NotFound)
+	m.Post("/users/:id", http.NotFound)
+	m.Get("/about", http.NotFound)
+
+	r, _ := http.NewRequest("GET", "/users", nil)
+	w := httptest.NewRecorder()
+	m.ServeHTTP(w, r)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected no redirect by default, got %d", w.Code)
+	}
+
+	m.RedirectTrailingSlash(true)
+	tests := []struct {
+		method, path string
+		code         int
+		location     string
+	}{
+		{"GET", "/users", 301, "/users/"},
+		{"HEAD", "/users?page=2", 301, "/users/?page=2"},
+		{"GET", "/about/", 301, "/about"},
+		{"POST", "/users/carl/", 308, "/users/carl"},
+		{"GET", "/users/carl/", 404, ""},
+		{"GET", "/nowhere/", 404, ""},
+	}
+	for _, test := range tests {
+		r, _ := http.NewRequest(test.method, test.path, nil)
+		w := httptest.NewRecorder()
+		m.ServeHTTP(w, r)
+		location := w.HeaderMap.Get("Location")
+		if w.Code != test.code || location != test.location {
+			t.Errorf("For %s %s, expected %d to %q, got %d to %q",
+				test.method, test.path, test.code, test.location,
+				w.Code, location)
+		}
+	}
+}
+
+func TestPriority(t *testing.T) {
+	t.Parallel()
+	m := New()
+	ch := make(chan string, 1)
+
+	m.Get("/users/new", chHandler(ch, "new"))
+	m.Get("/users/:id", chHandler(ch, "show"))
+	maintenance := m.Get("/users/*", chHandler(ch, "maintenance"))
+	m.Get("/other", chHandler(ch, "other")).Priority(-1)
+	m.Get("/:any", chHandler(ch, "any"))
+
+	table := []struct {
+		path, expected string
+	}{
+		{"/users/new", "new"},
+		{"/users/123", "show"},
+		{"/users/123/edit", "maintenance"},
+		{"/other", "any"},
+	}
+	check := func() {
+		for _, test := range table {
+			r, _ := http.NewRequest("GET", test.path, nil)
+			m.ServeHTTP(httptest.NewRecorder(), r)
+			select {
+			case val := <-ch:
+				if val != test.expected {
+					t.Errorf("For %q, got %q, expected %q",
+						test.path, val, test.expected)
+				}
+			case <-time.After(5 * time.Millisecond):
+				t.Errorf("Timeout waiting for path %q", test.path)
+			}
+		}
+	}
+	check()
+
+	// Raising the priority of the broad route shadows the specific ones,
+	// even after the router has been compiled.
+	maintenance.Priority(1)
+	table[0].expected = "maintenance"
+	table[1].expected = "maintenance"
+	check()
+
+	// Restoring the default priority restores the original order.
+	maintenance.Priority(0)
+	table[0].expected = "new"
+	table[1].expected = "show"
+	check()
+}
+
+func TestPrecedence(t *testing.T) {
+	t.Parallel()
+	ch := make(chan string, 1)
+	routes := []struct {
+		pattern, name string
+	}{
+		{"/users/new", "new"},
+		{"/users/:id", "show"},
+		{"/users/*", "catchall"},
+		{"/users/:id.:format", "format"},
+		{"/:section/new", "section"},
+		{"/users/:id/posts/*", "posts"},
+	}
+	table := []struct {
+		path, expected string
+	}{
+		{"/users/new", "new"},
+		{"/users/123", "show"},
+		{"/users/123.json", "format"},
+		{"/users/123/edit", "catchall"},
+		{"/users/123/posts/1", "posts"},
+		{"/groups/new", "section"},
+	}
+
+	// The order in which the routes are added makes no difference.
+	for _, reversed := range []bool{false, true} {
+		m := New()
+		for i := range routes {
+			route := routes[i]
+			if reversed {
+				route = routes[len(routes)-1-i]
+			}
+			m.Get(route.pattern, chHandler(ch, route.name))
+		}
+		for _, test := range table {
+			r, _ := http.NewRequest("GET", test.path, nil)
+			m.ServeHTTP(httptest.NewRecorder(), r)
+			select {
+			case val := <-ch:
+				if val != test.expected {
+					t.Errorf("For %q (reversed %v), got %q, "+
+						"expected %q", test.path, reversed,
+						val, test.expected)
+				}
+			case <-time.After(5 * time.Millisecond):
+				t.Errorf("Timeout waiting for path %q", test.path)
+			}
+		}
+	}
+}
+
+func TestHandlerOf(t *testing.T) {
+	t.Parallel()
+	h := HandlerOf(func(c C, w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(c.URLParams["id"]))
+	})
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "/", nil)
+	h.ServeHTTPC(C{URLParams: map[string]string{"id": "42"}}, w, r)
+	if w.Body.String() != "42" {
+		t.Errorf("Expected the handler to be called with c, got %q", w.Body.String())
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected HandlerOf to panic for an unknown type")
+		}
+	}()
+	HandlerOf(42)
+}
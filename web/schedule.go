@@ -0,0 +1,117 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// A Schedule determines whether something is active at a given time. See
+// Route.Active.
+type Schedule interface {
+	Active(t time.Time) bool
+}
+
+// ScheduleFunc is an adapter that allows an ordinary function to be used as a
+// Schedule.
+type ScheduleFunc func(time.Time) bool
+
+// Active implements Schedule.
+func (f ScheduleFunc) Active(t time.Time) bool {
+	return f(t)
+}
+
+// TimeRange returns a Schedule that is active from start (inclusive) until end
+// (exclusive). A zero start or end time leaves that side of the range unbounded.
+func TimeRange(start, end time.Time) Schedule {
+	return ScheduleFunc(func(t time.Time) bool {
+		return (start.IsZero() || !t.Before(start)) &&
+			(end.IsZero() || t.Before(end))
+	})
+}
+
+/*
+Daily returns a Schedule that is active every day between the wall clock times
+from (inclusive) and to (exclusive), given as "15:04" strings and interpreted in
+the given location (time.UTC if nil). If to is earlier than from, the window
+wraps around midnight: Daily("22:00", "06:00", loc) is active overnight. If any
+weekdays are given, the schedule is only active on those days, where windows
+wrapping around midnight belong to the day on which they started.
+
+Daily panics if from or to cannot be parsed.
+*/
+func Daily(from, to string, loc *time.Location, days ...time.Weekday) Schedule {
+	if loc == nil {
+		loc = time.UTC
+	}
+	start := parseClockTime(from)
+	end := parseClockTime(to)
+	var dayMask uint8
+	for _, d := range days {
+		dayMask |= 1 << uint(d)
+	}
+
+	return ScheduleFunc(func(t time.Time) bool {
+		t = t.In(loc)
+		now := time.Duration(t.Hour())*time.Hour +
+			time.Duration(t.Minute())*time.Minute +
+			time.Duration(t.Second())*time.Second
+		day := t.Weekday()
+
+		if start <= end {
+			if now < start || now >= end {
+				return false
+			}
+		} else if now < end {
+			// We're in the portion of the window after midnight, so
+			// the window started yesterday.
+			day = (day + 6) % 7
+		} else if now < start {
+			return false
+		}
+		return dayMask == 0 || dayMask&(1<<uint(day)) != 0
+	})
+}
+
+func parseClockTime(s string) time.Duration {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		panic(fmt.Sprintf("web: invalid time of day %q: %v", s, err))
+	}
+	return time.Duration(t.Hour())*time.Hour +
+		time.Duration(t.Minute())*time.Minute
+}
+
+// schedulePattern is a pattern which only matches while its schedule is active.
+type schedulePattern struct {
+	Pattern
+	schedule Schedule
+	rt       *router
+}
+
+func (s schedulePattern) Match(r *http.Request, c *C) bool {
+	return s.schedule.Active(s.rt.clock.Now()) && s.Pattern.Match(r, c)
+}
+
+func (s schedulePattern) String() string {
+	return patternString(s.Pattern)
+}
+
+/*
+Active restricts this route to the times at which the given schedule is active.
+At all other times the route behaves as if it had never been added: requests
+fall through to the routes after it, and it is not considered when computing the
+methods that would have been valid for a request (see Mux.NotFound). The current
+time is read from the mux's Clock (see Mux.SetClock).
+*/
+func (r *Route) Active(s Schedule) *Route {
+	r.rt.lock.Lock()
+	defer r.rt.lock.Unlock()
+	r.route.pattern = schedulePattern{
+		Pattern:  r.route.pattern,
+		schedule: s,
+		rt:       r.rt,
+	}
+	r.rt.setMachine(nil)
+	return r
+}
@@ -0,0 +1,105 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type fakeClock struct {
+	t time.Time
+}
+
+func (f *fakeClock) Now() time.Time {
+	return f.t
+}
+
+func TestRouteActive(t *testing.T) {
+	t.Parallel()
+	start := time.Date(2014, 11, 28, 0, 0, 0, 0, time.UTC)
+	clock := &fakeClock{}
+	m := New()
+	m.SetClock(clock)
+	m.Get("/promo", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("sale!"))
+	}).Active(TimeRange(start, start.Add(24*time.Hour)))
+
+	table := []struct {
+		t    time.Time
+		code int
+	}{
+		{start.Add(-time.Second), http.StatusNotFound},
+		{start, http.StatusOK},
+		{start.Add(23 * time.Hour), http.StatusOK},
+		{start.Add(24 * time.Hour), http.StatusNotFound},
+	}
+	for _, test := range table {
+		clock.t = test.t
+		r, _ := http.NewRequest("GET", "/promo", nil)
+		w := httptest.NewRecorder()
+		m.ServeHTTP(w, r)
+		if w.Code != test.code {
+			t.Errorf("At %v, expected %d, got %d", test.t, test.code,
+				w.Code)
+		}
+	}
+}
+
+func TestRouteActiveFallthrough(t *testing.T) {
+	t.Parallel()
+	clock := &fakeClock{}
+	m := New()
+	m.SetClock(clock)
+	ch := make(chan string, 1)
+	m.Get("/", chHandler(ch, "maintenance")).Active(ScheduleFunc(
+		func(t time.Time) bool { return t.IsZero() }))
+	m.Get("/", chHandler(ch, "normal"))
+
+	for _, expected := range []string{"maintenance", "normal"} {
+		r, _ := http.NewRequest("GET", "/", nil)
+		m.ServeHTTP(httptest.NewRecorder(), r)
+		if actual := <-ch; actual != expected {
+			t.Errorf("Expected %q, got %q", expected, actual)
+		}
+		clock.t = time.Now()
+	}
+}
+
+func TestDaily(t *testing.T) {
+	t.Parallel()
+	est := time.FixedZone("EST", -5*60*60)
+	business := Daily("09:00", "17:00", est, time.Monday, time.Tuesday)
+	overnight := Daily("22:00", "06:00", est, time.Monday)
+
+	// Monday, November 24th, 2014
+	at := func(hour, minute int) time.Time {
+		return time.Date(2014, 11, 24, hour, minute, 0, 0, est)
+	}
+	table := []struct {
+		s        Schedule
+		t        time.Time
+		expected bool
+	}{
+		{business, at(8, 59), false},
+		{business, at(9, 0), true},
+		{business, at(16, 59), true},
+		{business, at(17, 0), false},
+		// 10:00 EST is 15:00 UTC
+		{business, at(10, 0).UTC(), true},
+		// Wednesday
+		{business, at(10, 0).Add(48 * time.Hour), false},
+		{overnight, at(21, 59), false},
+		{overnight, at(23, 0), true},
+		// Early Tuesday belongs to Monday night
+		{overnight, at(3, 0).Add(24 * time.Hour), true},
+		// But early Monday belongs to Sunday night
+		{overnight, at(3, 0), false},
+	}
+	for _, test := range table {
+		if actual := test.s.Active(test.t); actual != test.expected {
+			t.Errorf("At %v, expected %v, got %v", test.t,
+				test.expected, actual)
+		}
+	}
+}
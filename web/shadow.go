@@ -0,0 +1,213 @@
+package web
+
+import (
+	"fmt"
+	"strings"
+)
+
+// RouteWarning reports a route which can never be matched, because another
+// route, which is tried before it, matches every request it would. See
+// Mux.Compile.
+type RouteWarning struct {
+	// Route is the route which can never be matched.
+	Route RouteInfo
+	// ShadowedBy is the route which matches its requests instead.
+	ShadowedBy RouteInfo
+}
+
+func (w RouteWarning) String() string {
+	return fmt.Sprintf("web: route %s is shadowed by route %s",
+		describeRoute(w.Route), describeRoute(w.ShadowedBy))
+}
+
+func describeRoute(info RouteInfo) string {
+	methods := "*"
+	if info.Methods != nil {
+		methods = strings.Join(info.Methods, ",")
+	}
+	return methods + " " + info.Pattern
+}
+
+// shadowed returns a warning for each of the router's routes which is shadowed
+// by another. See Mux.Compile.
+func (rt *router) shadowed() []RouteWarning {
+	rt.lock.Lock()
+	defer rt.lock.Unlock()
+	routes := rt.routes
+	if rt.caseInsensitive {
+		routes = make([]*route, len(rt.routes))
+		for i, r := range rt.routes {
+			routes[i] = foldRoute(r)
+		}
+	}
+	routes = byMatchOrder(routes)
+
+	var warnings []RouteWarning
+	for j, b := range routes {
+		for _, a := range routes[:j] {
+			if a.shadows(b) {
+				warnings = append(warnings, RouteWarning{
+					Route:      *b.info,
+					ShadowedBy: *a.info,
+				})
+				break
+			}
+		}
+	}
+	return warnings
+}
+
+// shadows reports whether r matches every request the other route would.
+func (r *route) shadows(other *route) bool {
+	if len(r.consumes) != 0 || len(r.produces) != 0 {
+		return false
+	}
+	switch {
+	case r.verb != "":
+		if other.verb != r.verb {
+			return false
+		}
+	case other.verb != "":
+		if r.method&mIDK == 0 {
+			return false
+		}
+	case r.method&other.method != other.method:
+		return false
+	}
+
+	a, ok := r.pattern.(stringPattern)
+	b, ok2 := other.pattern.(stringPattern)
+	if !ok || !ok2 {
+		return false
+	}
+	// Every form of the other pattern (see splitOptional) must be matched
+	// by some form of this one.
+	for _, bs := range b.forms() {
+		found := false
+		for _, as := range a.forms() {
+			if as.covers(bs) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// forms returns the pattern's alternatives, without its optional groups and
+// without the optional groups' alternatives.
+func (s stringPattern) forms() []stringPattern {
+	var forms []stringPattern
+	for alt := &s; alt != nil; alt = alt.short {
+		form := *alt
+		form.short = nil
+		forms = append(forms, form)
+	}
+	return forms
+}
+
+// The kinds of path segment compared by covers.
+const (
+	coverStatic = iota
+	coverParam
+	coverMixed
+)
+
+type coverSeg struct {
+	kind int
+	lit  string
+}
+
+// segments splits a pattern without optional groups into its path segments, up
+// to but excluding its wildcard, if it has one. A path's segments are those of
+// the pattern which matches it literally.
+func (s stringPattern) segments() []coverSeg {
+	var b strings.Builder
+	for i, lit := range s.literals {
+		if i > 0 {
+			b.WriteByte(0)
+		}
+		b.WriteString(lit)
+	}
+	path := strings.TrimPrefix(b.String(), "/")
+	if s.wildcard {
+		if path == "" {
+			return nil
+		}
+		path = strings.TrimSuffix(path, "/")
+	}
+	var segs []coverSeg
+	for _, seg := range strings.Split(path, "/") {
+		switch {
+		case seg == "\x00":
+			segs = append(segs, coverSeg{kind: coverParam})
+		case strings.IndexByte(seg, 0) >= 0:
+			segs = append(segs, coverSeg{kind: coverMixed})
+		default:
+			segs = append(segs, coverSeg{kind: coverStatic, lit: seg})
+		}
+	}
+	return segs
+}
+
+// covers reports whether s, which has no optional groups, matches every path
+// which other, which has none either, matches.
+func (s stringPattern) covers(other stringPattern) bool {
+	if s.host != nil || s.query != nil || s.constraints != nil || s.ext {
+		return false
+	}
+	if !strings.HasPrefix(s.literals[0], "/") ||
+		!strings.HasPrefix(other.literals[0], "/") {
+		return false
+	}
+	as, bs := s.segments(), other.segments()
+
+	if !s.wildcard {
+		if other.wildcard || len(as) != len(bs) {
+			return false
+		}
+	} else {
+		// The fewest segments each pattern matches paths of. A
+		// wildcard adds at least one, unless it is optional.
+		min := func(p stringPattern, segs []coverSeg) int {
+			if !p.wildcard || p.optionalTail {
+				return len(segs)
+			}
+			return len(segs) + 1
+		}
+		if min(other, bs) < min(s, as) {
+			return false
+		}
+		// Segments of other's wildcard can be anything, even empty,
+		// so they can only be covered by s's wildcard.
+		if len(as) > len(bs) {
+			return false
+		}
+	}
+	for i, a := range as {
+		if !s.coversSeg(a, bs[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func (s stringPattern) coversSeg(a, b coverSeg) bool {
+	switch a.kind {
+	case coverStatic:
+		if b.kind != coverStatic {
+			return false
+		}
+		if s.fold {
+			return equalFoldASCII(a.lit, b.lit)
+		}
+		return a.lit == b.lit
+	case coverParam:
+		// Names never match the empty string.
+		return b.kind != coverStatic || b.lit != ""
+	}
+	return false
+}
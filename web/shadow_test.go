@@ -0,0 +1,111 @@
+package web
+
+import (
+	"net/http"
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+func TestCompile(t *testing.T) {
+	t.Parallel()
+	type route struct {
+		method, pattern string
+	}
+	tests := []struct {
+		routes []route
+		// Pairs of shadowed and shadowing patterns.
+		warnings [][2]string
+	}{
+		// Routes are tried most specific first, so none of these shadow
+		// one another.
+		{[]route{
+			{"GET", "/users/:id"},
+			{"GET", "/users/me"},
+			{"GET", "/users/*"},
+			{"GET", "/users/:id/posts/*"},
+			{"GET", "/report.:format"},
+			{"POST", "/report"},
+			{"GET", "/report"},
+			{"GET", "/search?q=:query"},
+			{"GET", "/search"},
+			{"GET", "/:section/:id(\\d+)"},
+			{"GET", "/:section/:slug"},
+			{"GET", "/docs/*"},
+			{"GET", "/docs"},
+		}, nil},
+		{[]route{
+			{"GET", "/users/:id"},
+			{"GET", "/users/:name"},
+		}, [][2]string{{"/users/:name", "/users/:id"}}},
+		{[]route{
+			{"GET", "/about"},
+			{"GET", "/about"},
+		}, [][2]string{{"/about", "/about"}}},
+		{[]route{
+			{"ALL", "/files/*"},
+			{"POST", "/files/*path"},
+			{"GET", "/files/:name/*"},
+			{"GET", "/files/**"},
+		}, [][2]string{{"/files/*path", "/files/*"}}},
+		{[]route{
+			{"GET", "/docs/**"},
+			{"GET", "/docs/*"},
+			{"GET", "/posts/:year(/:month)?"},
+			{"GET", "/posts/:y"},
+		}, [][2]string{
+			{"/posts/:y", "/posts/:year(/:month)?"},
+			{"/docs/*", "/docs/**"},
+		}},
+	}
+
+	for i, test := range tests {
+		m := New()
+		for _, r := range test.routes {
+			if r.method == "ALL" {
+				m.Handle(r.pattern, http.NotFound)
+			} else {
+				m.Method(r.method, r.pattern, http.NotFound)
+			}
+		}
+		var got [][2]string
+		for _, w := range m.Compile() {
+			got = append(got, [2]string{w.Route.Pattern, w.ShadowedBy.Pattern})
+		}
+		if !reflect.DeepEqual(got, test.warnings) {
+			t.Errorf("For test %d, expected warnings %v, got %v", i,
+				test.warnings, got)
+		}
+	}
+}
+
+func TestCompilePriority(t *testing.T) {
+	t.Parallel()
+	m := New()
+	m.Get("/users/me", http.NotFound)
+	m.Get("/users/*", http.NotFound).Priority(1)
+	m.Get(regexp.MustCompile(`^/users/x$`), http.NotFound)
+
+	warnings := m.Compile()
+	if len(warnings) != 1 {
+		t.Fatalf("Expected one warning, got %v", warnings)
+	}
+	expected := "web: route GET,HEAD /users/me is shadowed by route GET,HEAD /users/*"
+	if s := warnings[0].String(); s != expected {
+		t.Errorf("Expected %q, got %q", expected, s)
+	}
+}
+
+func TestCompileCaseInsensitive(t *testing.T) {
+	t.Parallel()
+	m := New()
+	m.Get("/About", http.NotFound)
+	m.Get("/about", http.NotFound)
+	if warnings := m.Compile(); len(warnings) != 0 {
+		t.Errorf("Expected no warnings, got %v", warnings)
+	}
+	m.CaseInsensitive(true)
+	if warnings := m.Compile(); len(warnings) != 1 {
+		t.Errorf("Expected one warning, got %v", warnings)
+	}
+}
@@ -0,0 +1,69 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// How often Shutdown checks whether the mux's requests have finished.
+const shutdownPollInterval = 10 * time.Millisecond
+
+// ActiveRequests returns the number of requests the mux is currently serving.
+func (m *Mux) ActiveRequests() int {
+	return int(m.active.Load())
+}
+
+/*
+Shutdown drains the mux: it waits for the requests it is serving to finish, and
+then returns nil. Requests which arrive once Shutdown has been called are not
+served, but are instead answered with a 503 Service Unavailable and a
+"Connection: close" header, so that clients (and load balancers) take them
+elsewhere. If ctx is done before every request has finished, Shutdown gives up
+waiting, calls Close to cancel the contexts of the requests which remain, and
+returns ctx.Err().
+
+A mux cannot stop its server from accepting connections, nor close the server's
+idle keep-alive connections. Those are the province of package graceful, and a
+typical rolling deploy therefore begins a graceful shutdown (which closes the
+listeners and idle connections, but lets active connections finish), and drains
+the mux at the same time:
+
+	go graceful.Shutdown()
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	err := m.Shutdown(ctx)
+
+A mux which has been shut down cannot be restarted.
+*/
+func (m *Mux) Shutdown(ctx context.Context) error {
+	m.shuttingDown.Store(true)
+	ticker := time.NewTicker(shutdownPollInterval)
+	defer ticker.Stop()
+	for m.active.Load() != 0 {
+		select {
+		case <-ctx.Done():
+			m.Close()
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
+
+// admit registers the start of a request, returning false (having responded to
+// it) if the mux is shutting down. Requests which are admitted must be finished
+// with m.active.Add(-1).
+func (m *Mux) admit(w http.ResponseWriter) bool {
+	// Count the request before checking for a shutdown, so that Shutdown
+	// cannot miss a request which slips in as it begins.
+	m.active.Add(1)
+	if !m.shuttingDown.Load() {
+		return true
+	}
+	m.active.Add(-1)
+	w.Header().Set("Connection", "close")
+	http.Error(w, http.StatusText(http.StatusServiceUnavailable),
+		http.StatusServiceUnavailable)
+	return false
+}
@@ -0,0 +1,96 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func shutdownMux() (*Mux, chan struct{}, chan struct{}) {
+	m := New()
+	started := make(chan struct{}, 1)
+	finish := make(chan struct{})
+	m.Get("/slow", func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		select {
+		case <-finish:
+		case <-r.Context().Done():
+		}
+	})
+	m.Get("/fast", func(w http.ResponseWriter, r *http.Request) {})
+	return m, started, finish
+}
+
+func TestShutdown(t *testing.T) {
+	t.Parallel()
+	m, started, finish := shutdownMux()
+
+	r, _ := http.NewRequest("GET", "/slow", nil)
+	go m.ServeHTTP(httptest.NewRecorder(), r)
+	<-started
+	if n := m.ActiveRequests(); n != 1 {
+		t.Errorf("Expected 1 active request, got %d", n)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- m.Shutdown(context.Background())
+	}()
+	// Wait for the mux to start refusing requests
+	for {
+		r, _ := http.NewRequest("GET", "/fast", nil)
+		w := httptest.NewRecorder()
+		m.ServeHTTP(w, r)
+		if w.Code == http.StatusServiceUnavailable {
+			if c := w.HeaderMap.Get("Connection"); c != "close" {
+				t.Errorf("Expected Connection: close, got %q", c)
+			}
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	select {
+	case err := <-done:
+		t.Fatalf("Shutdown returned %v before the request finished", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(finish)
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Expected a clean shutdown, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Shutdown did not return once the request finished")
+	}
+	if n := m.ActiveRequests(); n != 0 {
+		t.Errorf("Expected no active requests, got %d", n)
+	}
+}
+
+func TestShutdownDeadline(t *testing.T) {
+	t.Parallel()
+	m, started, _ := shutdownMux()
+
+	r, _ := http.NewRequest("GET", "/slow", nil)
+	served := make(chan struct{})
+	go func() {
+		m.ServeHTTP(httptest.NewRecorder(), r)
+		close(served)
+	}()
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := m.Shutdown(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Expected %v, got %v", context.DeadlineExceeded, err)
+	}
+	select {
+	case <-served:
+	case <-time.After(time.Second):
+		t.Fatal("Expected the remaining request's context to be canceled")
+	}
+}
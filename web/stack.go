@@ -0,0 +1,119 @@
+package web
+
+import (
+	"log"
+	"net/http"
+)
+
+/*
+Stack is an ordered list of middleware, outermost first, which can be shared
+between muxes (see Mux.UseStack) or wrapped around an individual handler (see
+Then). Stacks are values: Append, Prepend and Extend return new stacks, leaving
+the stack they are called on unchanged, so a common base stack can safely be
+specialized in several different ways:
+
+	base := web.NewStack(middleware.RequestID, middleware.Logger)
+	public := base.Append(middleware.NoCache)
+	internal := base.Append(RequireAdmin)
+
+	api.UseStack(public)
+	admin.UseStack(internal)
+
+The middleware in a stack are checked when they are added to it, exactly as Use
+would check them.
+*/
+type Stack struct {
+	layers []mLayer
+}
+
+// NewStack returns a stack of the given middleware, outermost first.
+func NewStack(middleware ...MiddlewareType) Stack {
+	return Stack{}.Append(middleware...)
+}
+
+// Append returns a new stack consisting of this stack's middleware followed by
+// the given middleware.
+func (s Stack) Append(middleware ...MiddlewareType) Stack {
+	layers := make([]mLayer, 0, len(s.layers)+len(middleware))
+	layers = append(layers, s.layers...)
+	return Stack{layers: append(layers, stackLayers(middleware)...)}
+}
+
+// Prepend returns a new stack consisting of the given middleware followed by
+// this stack's middleware.
+func (s Stack) Prepend(middleware ...MiddlewareType) Stack {
+	layers := make([]mLayer, 0, len(s.layers)+len(middleware))
+	layers = append(layers, stackLayers(middleware)...)
+	return Stack{layers: append(layers, s.layers...)}
+}
+
+// Extend returns a new stack consisting of this stack's middleware followed by
+// the middleware of other.
+func (s Stack) Extend(other Stack) Stack {
+	layers := make([]mLayer, 0, len(s.layers)+len(other.layers))
+	layers = append(layers, s.layers...)
+	return Stack{layers: append(layers, other.layers...)}
+}
+
+// Len returns the number of middleware in the stack.
+func (s Stack) Len() int {
+	return len(s.layers)
+}
+
+/*
+Then returns a Handler which runs the given handler inside this stack's
+middleware. The Handler can be used as a route's handler, or, since it is also an
+http.Handler, on its own. The middleware run much as they would on a Mux: they
+share the *C the handler is given, and a middleware which aborts the request
+(see C.Abort) prevents the layers inside it from running.
+*/
+func (s Stack) Then(handler HandlerType) Handler {
+	ms := &mStack{router: handlerEndpoint{parseHandler(handler)}}
+	ms.setLayers(s.layers)
+	return stackHandler{ms}
+}
+
+// UseStack appends each of the middleware in the given stack to the Mux's
+// middleware stack, in order, all at once. See Use.
+func (m *Mux) UseStack(s Stack) {
+	m.ms.modify(func(stack []mLayer) ([]mLayer, error) {
+		return append(stack, s.layers...), nil
+	})
+}
+
+func stackLayers(middleware []MiddlewareType) []mLayer {
+	layers := make([]mLayer, len(middleware))
+	for i, mw := range middleware {
+		fn, ok := parseMiddleware(mw)
+		if !ok {
+			log.Fatalf(unknownMiddleware, mw)
+		}
+		layers[i] = mLayer{fn: fn, orig: mw}
+	}
+	return layers
+}
+
+// handlerEndpoint sits at the bottom of a Stack's middleware, in place of a
+// router.
+type handlerEndpoint struct {
+	h Handler
+}
+
+func (e handlerEndpoint) route(c *C, w http.ResponseWriter, r *http.Request) {
+	e.h.ServeHTTPC(*c, w, r)
+}
+
+// stackHandler is a handler wrapped in a Stack. See Stack.Then.
+type stackHandler struct {
+	ms *mStack
+}
+
+func (h stackHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.ServeHTTPC(C{}, w, r)
+}
+
+func (h stackHandler) ServeHTTPC(c C, w http.ResponseWriter, r *http.Request) {
+	stack := h.ms.alloc()
+	stack.ServeHTTPC(c, w, r)
+	h.ms.release(stack)
+}
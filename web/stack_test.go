@@ -0,0 +1,90 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestStack(t *testing.T) {
+	t.Parallel()
+	var trace []string
+	tracer := func(name string) func(*C, http.Handler) http.Handler {
+		return func(c *C, h http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				trace = append(trace, name)
+				h.ServeHTTP(w, r)
+			})
+		}
+	}
+	handler := func(c C, w http.ResponseWriter, r *http.Request) {
+		trace = append(trace, "handler")
+	}
+
+	base := NewStack(tracer("a"), tracer("b"))
+	public := base.Append(tracer("c"))
+	internal := base.Prepend(tracer("z")).Extend(NewStack(tracer("d")))
+	if base.Len() != 2 || public.Len() != 3 || internal.Len() != 4 {
+		t.Fatalf("Expected stacks of length 2, 3 and 4, got %d, %d and %d",
+			base.Len(), public.Len(), internal.Len())
+	}
+
+	m1 := New()
+	m1.UseStack(public)
+	m1.Get("/", handler)
+	m2 := New()
+	m2.UseStack(internal)
+	m2.Get("/", handler)
+
+	tests := []struct {
+		h     http.Handler
+		trace string
+	}{
+		{m1, "a b c handler"},
+		{m2, "z a b d handler"},
+		{base.Then(handler).(http.Handler), "a b handler"},
+	}
+	for i, test := range tests {
+		trace = nil
+		r, _ := http.NewRequest("GET", "/", nil)
+		test.h.ServeHTTP(httptest.NewRecorder(), r)
+		if s := strings.Join(trace, " "); s != test.trace {
+			t.Errorf("Test %d: expected trace %q, got %q", i, test.trace, s)
+		}
+	}
+}
+
+func TestStackThen(t *testing.T) {
+	t.Parallel()
+	auth := func(c *C, h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") == "" {
+				w.WriteHeader(http.StatusUnauthorized)
+				c.Abort()
+			}
+			h.ServeHTTP(w, r)
+		})
+	}
+	m := New()
+	m.Get("/users/:id", NewStack(auth).Then(func(c C, w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("user " + c.URLParams["id"]))
+	}))
+
+	for _, authorized := range []bool{false, true} {
+		r, _ := http.NewRequest("GET", "/users/123", nil)
+		if authorized {
+			r.Header.Set("Authorization", "yes")
+		}
+		w := httptest.NewRecorder()
+		m.ServeHTTP(w, r)
+		if authorized && w.Body.String() != "user 123" {
+			t.Errorf("Expected the handler to see its parameters, got %q",
+				w.Body.String())
+		} else if !authorized && (w.Code != http.StatusUnauthorized ||
+			w.Body.Len() != 0) {
+			t.Errorf("Expected an aborted 401, got %d %q", w.Code,
+				w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+)
+
+// StreamOptions controls how C.StreamNDJSONOpts streams a response.
+type StreamOptions struct {
+	// Context, if non-nil, stops the stream early when it is done.
+	// Typically this is the request's context, r.Context().
+	Context context.Context
+	// StopOnError stops the stream at the first item that cannot be
+	// encoded. By default, such items are replaced by an error object of
+	// the form {"error":"..."} and the stream carries on.
+	StopOnError bool
+}
+
+// StreamNDJSON is equivalent to StreamNDJSONOpts with the default options. See
+// StreamNDJSONOpts.
+func (c C) StreamNDJSON(w http.ResponseWriter, items <-chan interface{}) error {
+	return c.StreamNDJSONOpts(w, items, StreamOptions{})
+}
+
+/*
+StreamNDJSONOpts writes each item received from items to w as a line of
+newline-delimited JSON, flushing the response after each one so that clients see
+items as soon as they are produced. This is friendlier to streaming consumers
+(log tails, large exports, and the like) than one large JSON array. The
+Content-Type of the response is set to "application/x-ndjson".
+
+The stream ends when items is closed, when opts.Context is done, or when writing
+to the client fails, and the corresponding error (nil in the first case) is
+returned. Since the items channel is not drained in the latter two cases, its
+producer should watch the same context in order to know when to give up.
+
+Items which cannot be encoded as JSON are reported to the client as an object of
+the form {"error":"..."}, unless opts.StopOnError is set, in which case the
+stream is stopped and the encoding error is returned.
+*/
+func (c C) StreamNDJSONOpts(w http.ResponseWriter, items <-chan interface{}, opts StreamOptions) error {
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	flusher, _ := w.(http.Flusher)
+
+	var done <-chan struct{}
+	if opts.Context != nil {
+		done = opts.Context.Done()
+	}
+
+	for {
+		var item interface{}
+		var ok bool
+		select {
+		case <-done:
+			return opts.Context.Err()
+		case item, ok = <-items:
+		}
+		if !ok {
+			return nil
+		}
+
+		line, err := json.Marshal(item)
+		if err != nil {
+			if opts.StopOnError {
+				return err
+			}
+			line, _ = json.Marshal(struct {
+				Error string `json:"error"`
+			}{err.Error()})
+		}
+		if _, err := w.Write(append(line, '\n')); err != nil {
+			return err
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package web
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+)
+
+type flushCounter struct {
+	*httptest.ResponseRecorder
+	flushes []string
+}
+
+func (f *flushCounter) Flush() {
+	f.flushes = append(f.flushes, f.Body.String())
+}
+
+func TestStreamNDJSON(t *testing.T) {
+	t.Parallel()
+	items := make(chan interface{}, 3)
+	items <- map[string]int{"a": 1}
+	items <- "two"
+	items <- []int{3}
+	close(items)
+
+	w := &flushCounter{ResponseRecorder: httptest.NewRecorder()}
+	if err := (C{}).StreamNDJSON(w, items); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if ct := w.HeaderMap.Get("Content-Type"); ct != "application/x-ndjson" {
+		t.Errorf("Expected Content-Type application/x-ndjson, got %q", ct)
+	}
+	expected := []string{
+		"{\"a\":1}\n",
+		"{\"a\":1}\n\"two\"\n",
+		"{\"a\":1}\n\"two\"\n[3]\n",
+	}
+	if len(w.flushes) != len(expected) {
+		t.Fatalf("Expected %d flushes, got %d: %q", len(expected),
+			len(w.flushes), w.flushes)
+	}
+	for i, body := range expected {
+		if w.flushes[i] != body {
+			t.Errorf("At flush %d, expected body %q, got %q", i, body,
+				w.flushes[i])
+		}
+	}
+}
+
+func TestStreamNDJSONEncodingError(t *testing.T) {
+	t.Parallel()
+	stream := func(opts StreamOptions) (string, error) {
+		items := make(chan interface{}, 3)
+		items <- 1
+		items <- func() {}
+		items <- 2
+		close(items)
+		w := httptest.NewRecorder()
+		err := C{}.StreamNDJSONOpts(w, items, opts)
+		return w.Body.String(), err
+	}
+
+	body, err := stream(StreamOptions{})
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	expected := "1\n{\"error\":\"json: unsupported type: func()\"}\n2\n"
+	if body != expected {
+		t.Errorf("Expected body %q, got %q", expected, body)
+	}
+
+	body, err = stream(StreamOptions{StopOnError: true})
+	if err == nil {
+		t.Error("Expected an encoding error")
+	}
+	if body != "1\n" {
+		t.Errorf("Expected body %q, got %q", "1\n", body)
+	}
+}
+
+func TestStreamNDJSONCancel(t *testing.T) {
+	t.Parallel()
+	ctx, cancel := context.WithCancel(context.Background())
+	items := make(chan interface{})
+	w := &flushCounter{ResponseRecorder: httptest.NewRecorder()}
+	errs := make(chan error)
+	go func() {
+		errs <- C{}.StreamNDJSONOpts(w, items, StreamOptions{Context: ctx})
+	}()
+
+	items <- "first"
+	cancel()
+	if err := <-errs; err != context.Canceled {
+		t.Errorf("Expected %v, got %v", context.Canceled, err)
+	}
+	if body := w.Body.String(); body != "\"first\"\n" {
+		t.Errorf("Expected body %q, got %q", "\"first\"\n", body)
+	}
+}
@@ -9,14 +9,45 @@ import (
 
 // stringPattern is a struct describing
 type stringPattern struct {
-	raw      string
-	pats     []string
-	breaks   []byte
-	literals []string
-	wildcard bool
+	raw  string
+	host hostPattern
+	pats []string
+	// Inline constraints on the values of pats (nil for unconstrained
+	// names), as in "/users/:id(\\d+)".
+	constraints []*regexp.Regexp
+	breaks      []byte
+	literals    []string
+	wildcard    bool
+	// The name the wildcard's value is bound to: "*", unless the pattern
+	// named it, as in "/files/*path".
+	wildcardKey string
+	// Whether the wildcard is optional, as in "/docs/**": the pattern then
+	// also matches the path without the wildcard's leading "/", binding
+	// its key to the empty string.
+	optionalTail bool
+	// Whether the last name is a file extension following a literal stem,
+	// as in "/report.:format", in which case its value may not contain a
+	// ".".
+	ext bool
+	// Whether literals match regardless of (ASCII) case. See
+	// Mux.CaseInsensitive.
+	fold bool
+	// For patterns ending in an optional group, like "/posts/:year(/:month)?",
+	// short is the pattern without the group, which is tried if this one
+	// (in which the group is present) does not match.
+	short *stringPattern
+	// Constraints on the request's query string, as in
+	// "/search?type=image". See queryConstraint.
+	query []queryConstraint
 }
 
 func (s stringPattern) Prefix() string {
+	if s.short != nil {
+		return s.short.Prefix()
+	}
+	if s.optionalTail && len(s.pats) == 0 {
+		return strings.TrimSuffix(s.literals[0], "/")
+	}
 	return s.literals[0]
 }
 func (s stringPattern) Match(r *http.Request, c *C) bool {
@@ -26,18 +57,26 @@ func (s stringPattern) Run(r *http.Request, c *C) {
 	s.match(r, c, false)
 }
 func (s stringPattern) match(r *http.Request, c *C, dryrun bool) bool {
+	if s.short != nil {
+		long := s
+		long.short = nil
+		return long.match(r, c, dryrun) || s.short.match(r, c, dryrun)
+	}
 	path := r.URL.Path
-	var matches map[string]string
-	if !dryrun {
-		if s.wildcard {
-			matches = make(map[string]string, len(s.pats)+1)
-		} else if len(s.pats) != 0 {
-			matches = make(map[string]string, len(s.pats))
+	// Captures are collected on the stack, and only copied into
+	// c.URLParams once the whole pattern is known to match, so that
+	// matching allocates nothing of its own.
+	var buf [8]urlParam
+	matches := buf[:0]
+	if s.host != nil {
+		var ok bool
+		if matches, ok = s.host.match(requestHost(r), matches); !ok {
+			return false
 		}
 	}
 	for i, pat := range s.pats {
 		sli := s.literals[i]
-		if !strings.HasPrefix(path, sli) {
+		if !s.hasPrefix(path, sli) {
 			return false
 		}
 		path = path[len(sli):]
@@ -49,43 +88,89 @@ func (s stringPattern) match(r *http.Request, c *C, dryrun bool) bool {
 				break
 			}
 		}
+		if s.ext && i == len(s.pats)-1 && strings.IndexByte(path[:m], '.') >= 0 {
+			return false
+		}
 		if m == 0 {
 			// Empty strings are not matches, otherwise routes like
 			// "/:foo" would match the path "/"
 			return false
 		}
+		if re := s.constraint(i); re != nil && !re.MatchString(path[:m]) {
+			return false
+		}
 		if !dryrun {
-			matches[pat] = path[:m]
+			matches = append(matches, urlParam{pat, path[:m]})
 		}
 		path = path[m:]
 	}
 	// There's exactly one more literal than pat.
 	tail := s.literals[len(s.pats)]
 	if s.wildcard {
-		if !strings.HasPrefix(path, tail) {
+		bare := tail[:len(tail)-1]
+		if s.optionalTail && len(path) == len(bare) && s.hasPrefix(path, bare) {
+			if !dryrun {
+				matches = append(matches, urlParam{s.wildcardKey, ""})
+			}
+		} else if !s.hasPrefix(path, tail) {
 			return false
+		} else if !dryrun {
+			matches = append(matches, urlParam{s.wildcardKey, path[len(tail)-1:]})
 		}
-		if !dryrun {
-			matches["*"] = path[len(tail)-1:]
-		}
-	} else if path != tail {
+	} else if len(path) != len(tail) || !s.hasPrefix(path, tail) {
 		return false
 	}
+	if s.query != nil {
+		var ok bool
+		if matches, ok = s.matchQuery(r, matches, dryrun); !ok {
+			return false
+		}
+	}
 
-	if c == nil || dryrun {
+	if c == nil || dryrun || len(matches) == 0 {
 		return true
 	}
 
-	if c.URLParams == nil {
-		c.URLParams = matches
-	} else {
-		for k, v := range matches {
-			c.URLParams[k] = v
-		}
+	params := c.urlParams(len(matches))
+	for _, m := range matches {
+		params[m.name] = m.value
 	}
 	return true
 }
 
+// urlParam is a single URL parameter captured by a stringPattern.
+type urlParam struct {
+	name, value string
+}
+
+// hasPrefix reports whether path begins with the literal lit, ignoring case if
+// the pattern is case-insensitive.
+func (s stringPattern) hasPrefix(path, lit string) bool {
+	if !s.fold {
+		return strings.HasPrefix(path, lit)
+	}
+	return len(path) >= len(lit) && equalFoldASCII(path[:len(lit)], lit)
+}
+
+// withFold returns a copy of s (and of its optional-group alternatives) which
+// matches regardless of case.
+func (s stringPattern) withFold() stringPattern {
+	s.fold = true
+	if s.short != nil {
+		short := s.short.withFold()
+		s.short = &short
+	}
+	return s
+}
+
+// constraint returns the inline constraint on the i'th name, if any.
+func (s stringPattern) constraint(i int) *regexp.Regexp {
+	if s.constraints == nil {
+		return nil
+	}
+	return s.constraints[i]
+}
+
 func (s stringPattern) String() string {
 	return fmt.Sprintf("stringPattern(%q)", s.raw)
 }
@@ -98,13 +183,51 @@ const bc = "/.;,"
 
 var patternRe = regexp.MustCompile(`[` + bc + `]:([^` + bc + `]+)`)
 
+// wildcardRe matches the wildcard at the end of a pattern, the second "*" of an
+// optional wildcard, and its name, if any.
+var wildcardRe = regexp.MustCompile(`/\*(\*?)([^` + bc + `*]*)$`)
+
 func parseStringPattern(s string) stringPattern {
+	if path, query, ok := splitQuery(s); ok {
+		p := parseStringPattern(path)
+		q := parseQueryConstraints(s, query)
+		for alt := &p; alt != nil; alt = alt.short {
+			alt.raw = s
+			alt.query = q
+		}
+		return p
+	}
+	if long, short, ok := splitOptional(s); ok {
+		lp, sp := parseStringPattern(long), parseStringPattern(short)
+		// The pattern with the group may itself have alternatives (if
+		// groups are nested), all of which are longer than this one.
+		lp.raw = s
+		tail := &lp
+		for tail.short != nil {
+			tail = tail.short
+			tail.raw = s
+		}
+		sp.raw = s
+		tail.short = &sp
+		return lp
+	}
 	raw := s
-	var wildcard bool
-	if strings.HasSuffix(s, "/*") {
-		s = s[:len(s)-1]
+	var host hostPattern
+	if i := strings.IndexByte(s, '/'); i > 0 {
+		host = parseHostPattern(s[:i])
+		s = s[i:]
+	}
+	var wildcard, optionalTail bool
+	wildcardKey := "*"
+	if m := wildcardRe.FindStringSubmatchIndex(s); m != nil {
+		optionalTail = m[2] < m[3]
+		if m[4] < m[5] {
+			wildcardKey = s[m[4]:m[5]]
+		}
+		s = s[:m[0]+1]
 		wildcard = true
 	}
+	s, exprs := extractConstraints(raw, s)
 
 	matches := patternRe.FindAllStringSubmatchIndex(s, -1)
 	pats := make([]string, len(matches))
@@ -123,11 +246,281 @@ func parseStringPattern(s string) stringPattern {
 		n = b
 	}
 	literals[len(matches)] = s[n:]
+	for i, lit := range literals {
+		literals[i] = strings.Replace(lit, `\.`, ".", -1)
+	}
+	// Only a literal stem makes for an extension: in "/:name.:ext", name
+	// stops at the first ".", as names followed by a "." always have.
+	ext := len(pats) > 0 && !wildcard && literals[len(pats)] == "" &&
+		strings.HasSuffix(literals[len(pats)-1], ".") &&
+		literals[len(pats)-1] != "."
+
+	var constraints []*regexp.Regexp
+	if len(exprs) != 0 {
+		constraints = make([]*regexp.Regexp, len(pats))
+		for i, pat := range pats {
+			expr, ok := exprs[pat]
+			if !ok {
+				continue
+			}
+			re, err := regexp.Compile(`\A(?:` + expr + `)\z`)
+			if err != nil {
+				panic(fmt.Sprintf("web: invalid constraint on %q "+
+					"in pattern %q: %v", pat, raw, err))
+			}
+			constraints[i] = re
+		}
+	}
 	return stringPattern{
-		raw:      raw,
-		pats:     pats,
-		breaks:   breaks,
-		literals: literals,
-		wildcard: wildcard,
+		raw:          raw,
+		host:         host,
+		pats:         pats,
+		constraints:  constraints,
+		breaks:       breaks,
+		literals:     literals,
+		wildcard:     wildcard,
+		wildcardKey:  wildcardKey,
+		optionalTail: optionalTail,
+		ext:          ext,
+	}
+}
+
+// splitQuery splits a pattern with query constraints, such as
+// "/search?type=image", into its path and query portions. The query begins at
+// the first "?" which is neither inside an inline constraint nor the marker of
+// an optional group, and which is not the last character of the pattern, so
+// that patterns like "/what?" continue to match a literal "?".
+func splitQuery(s string) (path, query string, ok bool) {
+	for i := 0; i+1 < len(s); i++ {
+		switch s[i] {
+		case '(':
+			if end := constraintEnd(s, i); end > 0 {
+				i = end
+			}
+		case '?':
+			if i == 0 || s[i-1] != ')' {
+				return s[:i], s[i+1:], true
+			}
+		}
+	}
+	return "", "", false
+}
+
+// splitOptional splits a pattern ending in an optional group, such as
+// "/posts/:year(/:month)?", into the pattern with the group (less its
+// parentheses) and the pattern without it. Since a group must begin with a
+// slash, it cannot be confused with an inline constraint, whose values never
+// contain one.
+func splitOptional(s string) (long, short string, ok bool) {
+	if !strings.HasSuffix(s, ")?") {
+		return "", "", false
+	}
+	for i := 0; i+1 < len(s); i++ {
+		if s[i] == '(' && s[i+1] == '/' && constraintEnd(s, i) == len(s)-2 {
+			return s[:i] + s[i+1:len(s)-2], s[:i], true
+		}
+	}
+	return "", "", false
+}
+
+// extractConstraints removes the parenthesized inline constraints from the
+// names in s, returning the remaining pattern and the constraints' expressions
+// keyed by name. raw is the pattern as it was given, for error messages.
+func extractConstraints(raw, s string) (string, map[string]string) {
+	if !strings.Contains(s, ":") || !strings.Contains(s, "(") {
+		return s, nil
+	}
+	var exprs map[string]string
+	var out []byte
+	for i := 0; i < len(s); i++ {
+		out = append(out, s[i])
+		if s[i] != ':' || i == 0 || strings.IndexByte(bc, s[i-1]) < 0 {
+			continue
+		}
+		j := i + 1
+		for j < len(s) && s[j] != '(' && strings.IndexByte(bc, s[j]) < 0 {
+			j++
+		}
+		out = append(out, s[i+1:j]...)
+		if j == len(s) || s[j] != '(' {
+			i = j - 1
+			continue
+		}
+		end := constraintEnd(s, j)
+		if end < 0 {
+			panic(fmt.Sprintf("web: unterminated constraint on %q in "+
+				"pattern %q", s[i+1:j], raw))
+		}
+		if exprs == nil {
+			exprs = make(map[string]string)
+		}
+		exprs[s[i+1:j]] = s[j+1 : end]
+		i = end
+	}
+	return string(out), exprs
+}
+
+// constraintEnd returns the index of the parenthesis which closes the one at
+// s[start], or -1 if it is never closed.
+func constraintEnd(s string, start int) int {
+	depth := 0
+	class := false
+	for i := start; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '\\':
+			i++
+		case class:
+			class = c != ']'
+		case c == '[':
+			class = true
+			// A "]" right after the opening bracket is literal
+			if i+1 < len(s) && s[i+1] == '^' {
+				i++
+			}
+			if i+1 < len(s) && s[i+1] == ']' {
+				i++
+			}
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// queryConstraint is a single constraint on the query string of a request,
+// parsed from the query portion of a string pattern. "name" requires that the
+// parameter be present (with any value, even an empty one), "name=value" that
+// one of its values be value, and "name=:key" that it have a non-empty value,
+// which is bound to key as though it were a URL parameter.
+type queryConstraint struct {
+	name, value string
+	key         string
+	present     bool
+}
+
+func parseQueryConstraints(raw, query string) []queryConstraint {
+	var qs []queryConstraint
+	for _, part := range strings.Split(query, "&") {
+		name, value, hasValue := part, "", false
+		if i := strings.IndexByte(part, '='); i >= 0 {
+			name, value, hasValue = part[:i], part[i+1:], true
+		}
+		if name == "" {
+			panic(fmt.Sprintf("web: empty query parameter name in "+
+				"pattern %q", raw))
+		}
+		q := queryConstraint{name: name, value: value, present: !hasValue}
+		if strings.HasPrefix(value, ":") && len(value) > 1 {
+			q.key = value[1:]
+		}
+		qs = append(qs, q)
+	}
+	return qs
+}
+
+// matchQuery checks the request's query string against the pattern's query
+// constraints, appending the values they bind to matches.
+func (s stringPattern) matchQuery(r *http.Request, matches []urlParam, dryrun bool) ([]urlParam, bool) {
+	values := r.URL.Query()
+	for _, q := range s.query {
+		vs, ok := values[q.name]
+		switch {
+		case !ok:
+			return nil, false
+		case q.present:
+		case q.key != "":
+			if vs[0] == "" {
+				return nil, false
+			}
+			if !dryrun {
+				matches = append(matches, urlParam{q.key, vs[0]})
+			}
+		default:
+			found := false
+			for _, v := range vs {
+				if v == q.value {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return nil, false
+			}
+		}
+	}
+	return matches, true
+}
+
+// hostPattern is the host portion of a host-qualified string pattern, split into
+// its dot-separated labels. A label of the form "{name}" matches any single label
+// of the request's host, binding it to name, and a label of "*" matches any
+// single label without binding it. All other labels match case-insensitively.
+type hostPattern []string
+
+func parseHostPattern(s string) hostPattern {
+	s = strings.TrimSuffix(strings.TrimPrefix(s, "["), "]")
+	return hostPattern(strings.Split(s, "."))
+}
+
+func isHostParam(label string) bool {
+	return len(label) > 2 && label[0] == '{' && label[len(label)-1] == '}'
+}
+
+func (h hostPattern) match(host string, matches []urlParam) ([]urlParam, bool) {
+	// The host is walked label by label, rather than split, so as not to
+	// allocate.
+	more := true
+	for _, label := range h {
+		if !more {
+			return nil, false
+		}
+		part := host
+		if i := strings.IndexByte(host, '.'); i >= 0 {
+			part, host = host[:i], host[i+1:]
+		} else {
+			more = false
+		}
+		switch {
+		case isHostParam(label):
+			if part == "" {
+				return nil, false
+			}
+			matches = append(matches, urlParam{label[1 : len(label)-1], part})
+		case label == "*":
+			if part == "" {
+				return nil, false
+			}
+		case !strings.EqualFold(label, part):
+			return nil, false
+		}
+	}
+	if more {
+		return nil, false
+	}
+	return matches, true
+}
+
+// requestHost returns the host the request was addressed to, without any port,
+// IPv6 brackets, or trailing dot.
+func requestHost(r *http.Request) string {
+	host := r.Host
+	if host == "" {
+		host = r.URL.Host
+	}
+	// net.SplitHostPort would allocate an error for hosts without a port,
+	// which are most of them.
+	if strings.HasPrefix(host, "[") {
+		if i := strings.IndexByte(host, ']'); i >= 0 {
+			host = host[1:i]
+		}
+	} else if i := strings.LastIndexByte(host, ':'); i >= 0 &&
+		strings.IndexByte(host, ':') == i {
+		host = host[:i]
 	}
+	return strings.TrimSuffix(host, ".")
 }
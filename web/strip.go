@@ -0,0 +1,74 @@
+package web
+
+import (
+	"net/http"
+)
+
+// The key used to record the part of the path removed by StripPrefix. See
+// C.StrippedPrefix.
+const StrippedPrefixKey = "goji.web.strippedPrefix"
+
+/*
+StripPrefix returns a handler which serves requests with the given handler,
+after removing from their paths everything which comes before the unmatched tail
+bound by the route's pattern (the "*" URL parameter). It is the counterpart of
+http.StripPrefix for routes with wildcard patterns, which need not give their
+prefix twice, and whose prefix may contain named parameters:
+
+	m.Handle("/:tenant/legacy/*", web.StripPrefix(legacyHandler))
+	// legacyHandler sees "/acme/legacy/a/b" as "/a/b"
+
+The handler is given a copy of the request, so the request seen by the layers
+around it (such as middleware which runs after it returns) keeps its original
+path. The part of the path which was removed is available to the handler from
+C.StrippedPrefix. Requests whose route did not bind "*" (including those routed
+by a pattern with a named wildcard, like "/files/*path") are passed through
+unchanged.
+*/
+func StripPrefix(handler HandlerType) Handler {
+	return stripHandler{parseHandler(handler)}
+}
+
+type stripHandler struct {
+	h Handler
+}
+
+func (s stripHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.ServeHTTPC(C{}, w, r)
+}
+
+func (s stripHandler) ServeHTTPC(c C, w http.ResponseWriter, r *http.Request) {
+	tail, ok := c.URLParams["*"]
+	path := r.URL.Path
+	if !ok || len(tail) > len(path) || path[len(path)-len(tail):] != tail {
+		s.h.ServeHTTPC(c, w, r)
+		return
+	}
+
+	c.initEnv()
+	prev, hadPrev := c.Env[StrippedPrefixKey]
+	prefix, _ := prev.(string)
+	c.Env[StrippedPrefixKey] = prefix + path[:len(path)-len(tail)]
+	defer func() {
+		if hadPrev {
+			c.Env[StrippedPrefixKey] = prev
+		} else {
+			delete(c.Env, StrippedPrefixKey)
+		}
+	}()
+
+	u := *r.URL
+	u.Path = tail
+	u.RawPath = ""
+	r2 := r.WithContext(r.Context())
+	r2.URL = &u
+	s.h.ServeHTTPC(c, w, r2)
+}
+
+// StrippedPrefix returns the part of the request's path which was removed by
+// StripPrefix (or by several of them, if they were nested) before the request
+// reached the current handler, or "" if none was.
+func (c C) StrippedPrefix() string {
+	prefix, _ := c.env()[StrippedPrefixKey].(string)
+	return prefix
+}
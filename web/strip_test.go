@@ -0,0 +1,46 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestStripPrefix(t *testing.T) {
+	t.Parallel()
+	var after string
+	m := New()
+	m.Use(func(c *C, h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			h.ServeHTTP(w, r)
+			after = r.URL.Path + " " + c.StrippedPrefix()
+		})
+	})
+	legacy := func(c C, w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.URL.Path + " " + c.StrippedPrefix() + " " +
+			r.URL.RawQuery))
+	}
+	m.Handle("/:tenant/legacy/*", StripPrefix(legacy))
+	m.Handle("/exact", StripPrefix(legacy))
+
+	tests := []struct {
+		path, body string
+	}{
+		{"/acme/legacy/a/b?x=1", "/a/b /acme/legacy x=1"},
+		{"/acme/legacy/", "/ /acme/legacy "},
+		{"/exact", "/exact  "},
+	}
+	for _, test := range tests {
+		r, _ := http.NewRequest("GET", test.path, nil)
+		w := httptest.NewRecorder()
+		m.ServeHTTP(w, r)
+		if w.Body.String() != test.body {
+			t.Errorf("For %q, expected %q, got %q", test.path,
+				test.body, w.Body.String())
+		}
+		if expected := r.URL.Path + " "; after != expected {
+			t.Errorf("For %q, expected middleware to see %q after the "+
+				"handler, got %q", test.path, expected, after)
+		}
+	}
+}
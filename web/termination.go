@@ -0,0 +1,79 @@
+package web
+
+import (
+	"context"
+	"net/http"
+)
+
+// TerminationReason describes why a request finished.
+type TerminationReason int
+
+const (
+	// Completed requests ran to completion normally.
+	Completed TerminationReason = iota
+	// ClientGone requests were abandoned by the client, typically because
+	// it closed the connection before a response was sent.
+	ClientGone
+	// TimedOut requests ran past a server-imposed deadline.
+	TimedOut
+	// Closed requests were canceled because the mux serving them was
+	// closed. See Mux.Close.
+	Closed
+)
+
+func (t TerminationReason) String() string {
+	switch t {
+	case Completed:
+		return "completed"
+	case ClientGone:
+		return "client gone"
+	case TimedOut:
+		return "timed out"
+	case Closed:
+		return "closed"
+	default:
+		return "unknown"
+	}
+}
+
+// The key used to communicate why a request finished, if it did not finish
+// normally. See C.TerminationReason.
+const TerminationReasonKey = "goji.web.terminationReason"
+
+// TerminationReason reports why the request finished. It is set by the Mux once
+// the handler (or the NotFound handler) returns, so it is primarily useful to
+// middleware, such as request loggers, that run after the handler. Requests
+// whose context was canceled are reported as ClientGone (or Closed, if the
+// cancellation was due to Mux.Close), while requests whose context's deadline
+// passed are reported as TimedOut.
+func (c C) TerminationReason() TerminationReason {
+	if reason, ok := c.env()[TerminationReasonKey].(TerminationReason); ok {
+		return reason
+	}
+	return Completed
+}
+
+func (c *C) setTerminationReason(reason TerminationReason) {
+	c.initEnv()
+	c.Env[TerminationReasonKey] = reason
+}
+
+// recordTermination records why the given request finished, unless it ran to
+// completion or its reason has already been recorded.
+func (rt *router) recordTermination(c *C, r *http.Request) {
+	var reason TerminationReason
+	switch r.Context().Err() {
+	case context.Canceled:
+		reason = ClientGone
+		if rt.base.Err() != nil {
+			reason = Closed
+		}
+	case context.DeadlineExceeded:
+		reason = TimedOut
+	default:
+		return
+	}
+	if c.TerminationReason() == Completed {
+		c.setTerminationReason(reason)
+	}
+}
@@ -0,0 +1,63 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func terminationReason(m *Mux, r *http.Request) TerminationReason {
+	var reason TerminationReason
+	mw := func(c *C, h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			h.ServeHTTP(w, r)
+			reason = c.TerminationReason()
+		})
+	}
+	m.Use(mw)
+	defer m.Abandon(mw)
+	m.ServeHTTP(httptest.NewRecorder(), r)
+	return reason
+}
+
+func TestTerminationReason(t *testing.T) {
+	t.Parallel()
+	m := New()
+	m.Get("/", func(w http.ResponseWriter, r *http.Request) {
+		// Simulate a handler that gives up when its context does.
+		select {
+		case <-r.Context().Done():
+		case <-time.After(10 * time.Millisecond):
+		}
+	})
+
+	r, _ := http.NewRequest("GET", "/", nil)
+	if reason := terminationReason(m, r); reason != Completed {
+		t.Errorf("Expected %v, got %v", Completed, reason)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(time.Millisecond)
+		cancel()
+	}()
+	if reason := terminationReason(m, r.WithContext(ctx)); reason != ClientGone {
+		t.Errorf("Expected %v, got %v", ClientGone, reason)
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	if reason := terminationReason(m, r.WithContext(ctx)); reason != TimedOut {
+		t.Errorf("Expected %v, got %v", TimedOut, reason)
+	}
+
+	// 404s are recorded too.
+	r, _ = http.NewRequest("GET", "/nope", nil)
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	if reason := terminationReason(m, r.WithContext(ctx)); reason != ClientGone {
+		t.Errorf("Expected %v, got %v", ClientGone, reason)
+	}
+}
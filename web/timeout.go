@@ -0,0 +1,229 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Timeout is equivalent to TimeoutStatus with a status of 503 Service
+// Unavailable. See TimeoutStatus.
+func Timeout(d time.Duration) func(*C, http.Handler) http.Handler {
+	return TimeoutStatus(d, http.StatusServiceUnavailable)
+}
+
+/*
+TimeoutStatus returns a middleware which bounds the time the layers inside it
+(typically, the handler) may take to respond. It gives both the request's
+context and the C's (see C.Context) a deadline d in the future. If the handler
+has not begun its response by the deadline, the given status code is sent on its
+behalf, the request is recorded as TimedOut (see C.TerminationReason), and
+anything the handler writes afterwards is discarded, with Write returning
+http.ErrHandlerTimeout. If the handler had already begun responding, the
+response is left alone, and the handler is merely expected to notice its
+context's deadline.
+
+Which of the two happens is decided under a lock, so a handler which responds
+at the very instant the deadline passes gets either its own response or the
+timeout response, never a mixture of the two.
+
+The middleware returns only once the handler does, so handlers should watch
+their context's Done channel in order to give up promptly. It is intended to be
+installed on individual routes (see Route.Use), but works equally well on a
+whole Mux.
+*/
+func TimeoutStatus(d time.Duration, code int) func(*C, http.Handler) http.Handler {
+	return func(c *C, h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := newTimeoutCtx(r.Context(), time.Now().Add(d))
+			defer ctx.cancel(context.Canceled)
+			cctx := &linkedCtx{Context: c.Context(), other: ctx}
+			defer cctx.release()
+			*c = c.WithContext(cctx)
+
+			tw := &timeoutWriter{w: w, h: cloneHeader(w.Header())}
+			// Only expire the context once the timeout response has
+			// been sent, so that a handler which wakes up on Done
+			// finds the writer already timed out.
+			timer := time.AfterFunc(d, func() {
+				tw.timeout(code)
+				ctx.cancel(context.DeadlineExceeded)
+			})
+			defer timer.Stop()
+
+			var tw2 http.ResponseWriter = tw
+			if _, ok := w.(http.Flusher); ok {
+				tw2 = timeoutFlushWriter{tw}
+			}
+			h.ServeHTTP(tw2, r.WithContext(ctx))
+			if tw.finish() {
+				c.setTerminationReason(TimedOut)
+			}
+		})
+	}
+}
+
+// timeoutCtx is the context TimeoutStatus gives the handler. Like a context made
+// by context.WithDeadline, it has a deadline, and reports DeadlineExceeded once
+// it passes, but it is the middleware's timer rather than the context's own
+// which expires it.
+type timeoutCtx struct {
+	context.Context
+	deadline time.Time
+	done     chan struct{}
+	stop     func() bool
+
+	mu  sync.Mutex
+	err error
+}
+
+func newTimeoutCtx(parent context.Context, deadline time.Time) *timeoutCtx {
+	t := &timeoutCtx{
+		Context:  parent,
+		deadline: deadline,
+		done:     make(chan struct{}),
+	}
+	t.stop = context.AfterFunc(parent, func() { t.cancel(parent.Err()) })
+	return t
+}
+
+func (t *timeoutCtx) Deadline() (time.Time, bool) {
+	if d, ok := t.Context.Deadline(); ok && d.Before(t.deadline) {
+		return d, true
+	}
+	return t.deadline, true
+}
+
+func (t *timeoutCtx) Done() <-chan struct{} {
+	return t.done
+}
+
+func (t *timeoutCtx) Err() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.err
+}
+
+// cancel makes t done with the given error, unless it is done already.
+func (t *timeoutCtx) cancel(err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.err != nil {
+		return
+	}
+	t.err = err
+	close(t.done)
+	t.stop()
+}
+
+func cloneHeader(h http.Header) http.Header {
+	h2 := make(http.Header, len(h))
+	for k, v := range h {
+		h2[k] = append([]string(nil), v...)
+	}
+	return h2
+}
+
+// timeoutWriter guards a ResponseWriter against concurrent use by a handler
+// and by the timer which fires at its deadline. The handler is given its own
+// header map, which is only copied to the real one when it begins its
+// response, so that the timer can write its own headers without racing with
+// it.
+type timeoutWriter struct {
+	w http.ResponseWriter
+	h http.Header
+
+	mu          sync.Mutex
+	wroteHeader bool
+	timedOut    bool
+	finished    bool
+}
+
+func (tw *timeoutWriter) Header() http.Header {
+	return tw.h
+}
+
+func (tw *timeoutWriter) WriteHeader(code int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	tw.writeHeader(code)
+}
+
+// writeHeader must be called with tw.mu held.
+func (tw *timeoutWriter) writeHeader(code int) {
+	if tw.timedOut || tw.wroteHeader {
+		return
+	}
+	tw.wroteHeader = true
+	dst := tw.w.Header()
+	for k := range dst {
+		delete(dst, k)
+	}
+	for k, v := range tw.h {
+		dst[k] = v
+	}
+	tw.w.WriteHeader(code)
+}
+
+func (tw *timeoutWriter) Write(buf []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	tw.writeHeader(http.StatusOK)
+	return tw.w.Write(buf)
+}
+
+func (tw *timeoutWriter) Push(target string, opts *http.PushOptions) error {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut {
+		return http.ErrHandlerTimeout
+	}
+	return push(tw.w, target, opts)
+}
+
+// timeout sends the timeout response, unless the handler has already begun (or
+// finished) its own.
+func (tw *timeoutWriter) timeout(code int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.wroteHeader || tw.finished {
+		return
+	}
+	tw.timedOut = true
+	h := tw.w.Header()
+	h.Set("Content-Type", "text/plain; charset=utf-8")
+	h.Set("X-Content-Type-Options", "nosniff")
+	tw.w.WriteHeader(code)
+	tw.w.Write([]byte(http.StatusText(code) + "\n"))
+	if f, ok := tw.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// finish marks the handler as having returned, after which the timer has no
+// effect, and reports whether the timeout response was sent. Once it returns,
+// the timer is no longer using the ResponseWriter.
+func (tw *timeoutWriter) finish() bool {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	tw.finished = true
+	return tw.timedOut
+}
+
+type timeoutFlushWriter struct {
+	*timeoutWriter
+}
+
+func (tw timeoutFlushWriter) Flush() {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut {
+		return
+	}
+	tw.writeHeader(http.StatusOK)
+	tw.w.(http.Flusher).Flush()
+}
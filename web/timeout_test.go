@@ -0,0 +1,132 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTimeout(t *testing.T) {
+	t.Parallel()
+	m := New()
+	reasons := make(chan TerminationReason, 1)
+	m.Use(func(c *C, h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			h.ServeHTTP(w, r)
+			reasons <- c.TerminationReason()
+		})
+	})
+	writeErrs := make(chan error, 1)
+	m.Get("/slow", func(c C, w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Handler", "slow")
+		<-r.Context().Done()
+		<-c.Context().Done()
+		_, err := w.Write([]byte("too late"))
+		writeErrs <- err
+	}).Use(Timeout(10 * time.Millisecond))
+	m.Get("/started", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+		<-r.Context().Done()
+		w.Write([]byte("partial"))
+	}).Use(TimeoutStatus(10*time.Millisecond, http.StatusGatewayTimeout))
+	m.Get("/fast", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Handler", "fast")
+		w.Write([]byte("ok"))
+	}).Use(Timeout(time.Second))
+
+	r, _ := http.NewRequest("GET", "/slow", nil)
+	w := httptest.NewRecorder()
+	m.ServeHTTP(w, r)
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected a 503, got %d", w.Code)
+	}
+	if w.HeaderMap.Get("X-Handler") != "" {
+		t.Error("Expected the handler's headers to be discarded")
+	}
+	if err := <-writeErrs; err != http.ErrHandlerTimeout {
+		t.Errorf("Expected late write to fail with %v, got %v",
+			http.ErrHandlerTimeout, err)
+	}
+	if body := w.Body.String(); body != "Service Unavailable\n" {
+		t.Errorf("Unexpected body %q", body)
+	}
+	if reason := <-reasons; reason != TimedOut {
+		t.Errorf("Expected TimedOut, got %v", reason)
+	}
+
+	r, _ = http.NewRequest("GET", "/started", nil)
+	w = httptest.NewRecorder()
+	m.ServeHTTP(w, r)
+	if w.Code != http.StatusAccepted || w.Body.String() != "partial" {
+		t.Errorf("Expected the handler's response, got %d %q", w.Code,
+			w.Body.String())
+	}
+	<-reasons
+
+	r, _ = http.NewRequest("GET", "/fast", nil)
+	w = httptest.NewRecorder()
+	m.ServeHTTP(w, r)
+	if w.Code != http.StatusOK || w.Body.String() != "ok" ||
+		w.HeaderMap.Get("X-Handler") != "fast" {
+		t.Errorf("Expected the handler's response, got %d %q %v", w.Code,
+			w.Body.String(), w.HeaderMap)
+	}
+	if reason := <-reasons; reason != Completed {
+		t.Errorf("Expected Completed, got %v", reason)
+	}
+}
+
+// Handlers which respond just as the deadline passes get either their own
+// response or the timeout response, but never a mixture of the two.
+func TestTimeoutRace(t *testing.T) {
+	t.Parallel()
+	m := New()
+	m.Use(Timeout(time.Millisecond))
+	m.Get("/", func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(time.Millisecond)
+		w.Write([]byte("ok"))
+	})
+	for i := 0; i < 50; i++ {
+		r, _ := http.NewRequest("GET", "/", nil)
+		w := httptest.NewRecorder()
+		m.ServeHTTP(w, r)
+		switch {
+		case w.Code == http.StatusOK && w.Body.String() == "ok":
+		case w.Code == http.StatusServiceUnavailable &&
+			w.Body.String() == "Service Unavailable\n":
+		default:
+			t.Fatalf("Got mixed response %d %q", w.Code, w.Body.String())
+		}
+	}
+}
+
+// Handlers which give up as soon as their context is done never get their
+// response in ahead of the timeout response.
+func TestTimeoutWakeup(t *testing.T) {
+	t.Parallel()
+	m := New()
+	errs := make(chan error, 2)
+	m.Get("/", func(w http.ResponseWriter, r *http.Request) {
+		child, cancel := context.WithCancel(r.Context())
+		defer cancel()
+		<-child.Done()
+		errs <- r.Context().Err()
+		errs <- child.Err()
+		w.Write([]byte("too late"))
+	}).Use(Timeout(time.Millisecond))
+	for i := 0; i < 50; i++ {
+		r, _ := http.NewRequest("GET", "/", nil)
+		w := httptest.NewRecorder()
+		m.ServeHTTP(w, r)
+		if w.Code != http.StatusServiceUnavailable {
+			t.Fatalf("Expected a 503, got %d %q", w.Code, w.Body.String())
+		}
+		for j := 0; j < 2; j++ {
+			if err := <-errs; err != context.DeadlineExceeded {
+				t.Fatalf("Expected %v, got %v", context.DeadlineExceeded, err)
+			}
+		}
+	}
+}
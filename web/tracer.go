@@ -0,0 +1,58 @@
+package web
+
+import "net/http"
+
+/*
+Tracer receives notice of the events of a Mux's dispatch of each request, for
+the benefit of tracing and other observability tools, which can start and end
+spans at the points the events mark without wrapping the Mux or its
+ResponseWriters. See Mux.Tracer.
+
+Its methods are called from the goroutine serving the request, and so must be
+safe to call concurrently, since requests are served concurrently.
+*/
+type Tracer interface {
+	// RouteMatched is called once the request has been matched to a
+	// route with the given pattern. By then, C.Route describes the route
+	// (and so gives its pattern as a string, suitable for naming spans),
+	// and functions registered with OnRouted have run.
+	RouteMatched(c *C, pattern Pattern)
+	// RouteNotFound is called when the request matches no route, before
+	// it is passed to the NotFound handler (or to the handler for
+	// whichever of the other ways of not matching applies: see
+	// Mux.MethodNotAllowed, for instance).
+	RouteNotFound(r *http.Request)
+	// BeforeHandler is called immediately before the matched route's
+	// handler (including the route's own middleware) is called. It is not
+	// called if the request was aborted by a function registered with
+	// OnRouted.
+	BeforeHandler(c C, w http.ResponseWriter, r *http.Request)
+	// AfterHandler is called once the handler called after BeforeHandler
+	// has returned, or has panicked. The ResponseWriter is the one given to
+	// the handler, so if the Mux tracks responses (see
+	// Mux.TrackResponses), the status and size of the response can be
+	// found with mutil.WriterProxy.
+	AfterHandler(c C, w http.ResponseWriter, r *http.Request)
+}
+
+/*
+Tracer sets the Tracer notified of the mux's dispatch events, or, if t is nil,
+removes it. A mux has no tracer by default, in which case dispatch costs
+nothing more than it otherwise would.
+
+Since the events are those of the mux's own routing, a tracer set on a mux
+mounted inside another (see Mux.Mount) is notified of the requests routed by
+the mounted mux only. Like the rest of the Mux's configuration, it is illegal
+to call Tracer concurrently with active requests.
+*/
+func (m *Mux) Tracer(t Tracer) {
+	m.rt.tracer = t
+}
+
+// serveTraced serves the request with the matched route, notifying the tracer
+// before and after.
+func (rt *router) serveTraced(route *route, c C, w http.ResponseWriter, r *http.Request) {
+	rt.tracer.BeforeHandler(c, w, r)
+	defer rt.tracer.AfterHandler(c, w, r)
+	route.serve(c, w, r)
+}
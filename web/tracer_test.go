@@ -0,0 +1,95 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+type recordingTracer struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (t *recordingTracer) record(event string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.events = append(t.events, event)
+}
+
+func (t *recordingTracer) RouteMatched(c *C, pattern Pattern) {
+	t.record("matched " + c.Route().Pattern)
+}
+func (t *recordingTracer) RouteNotFound(r *http.Request) {
+	t.record("not found " + r.URL.Path)
+}
+func (t *recordingTracer) BeforeHandler(c C, w http.ResponseWriter, r *http.Request) {
+	t.record("before " + c.URLParams["id"])
+}
+func (t *recordingTracer) AfterHandler(c C, w http.ResponseWriter, r *http.Request) {
+	t.record("after " + c.URLParams["id"])
+}
+
+func (t *recordingTracer) take() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	events := t.events
+	t.events = nil
+	return events
+}
+
+func TestTracer(t *testing.T) {
+	t.Parallel()
+	tracer := &recordingTracer{}
+	m := New()
+	m.Tracer(tracer)
+	m.Get("/users/:id", func(c C, w http.ResponseWriter, r *http.Request) {
+		tracer.record("handler")
+	})
+	m.Get("/panic/:id", func(w http.ResponseWriter, r *http.Request) {
+		panic("oops")
+	})
+	m.Get("/aborted/:id", http.NotFound)
+	m.Use(func(c *C, h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			OnRouted(c, func(rc C) {
+				if rc.URLParams["id"] == "abort" {
+					c.Abort()
+				}
+			})
+			h.ServeHTTP(w, r)
+		})
+	})
+	m.OnPanic(func(c C, w http.ResponseWriter, r *http.Request, err interface{}) {
+		tracer.record("recovered")
+	})
+
+	table := []struct {
+		path   string
+		events []string
+	}{
+		{"/users/123", []string{"matched /users/:id", "before 123",
+			"handler", "after 123"}},
+		{"/panic/1", []string{"matched /panic/:id", "before 1",
+			"after 1", "recovered"}},
+		{"/aborted/abort", []string{"matched /aborted/:id"}},
+		{"/missing", []string{"not found /missing"}},
+	}
+	for _, test := range table {
+		r, _ := http.NewRequest("GET", test.path, nil)
+		m.ServeHTTP(httptest.NewRecorder(), r)
+		if events := tracer.take(); !reflect.DeepEqual(events, test.events) {
+			t.Errorf("For %q, expected events %v, got %v", test.path,
+				test.events, events)
+		}
+	}
+
+	m.Tracer(nil)
+	r, _ := http.NewRequest("GET", "/users/123", nil)
+	m.ServeHTTP(httptest.NewRecorder(), r)
+	if events := tracer.take(); !reflect.DeepEqual(events, []string{"handler"}) {
+		t.Errorf("Expected only the handler once the tracer is removed, got %v", events)
+	}
+}
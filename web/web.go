@@ -11,6 +11,7 @@ handlers.
 package web
 
 import (
+	"context"
 	"net/http"
 )
 
@@ -23,13 +24,35 @@ type C struct {
 	// from the path portion) during routing. See the documentation for the
 	// URL Pattern you are using (or the documentation for PatternType for
 	// the case of standard pattern types) for more information about how
-	// variables are extracted and named.
+	// variables are extracted and named. Routes with no variables are
+	// given a nil map.
+	//
+	// If the Mux has been told to reuse URLParams maps (see
+	// Mux.ReuseURLParams), the map is cleared and reused for a later
+	// request once this one is done, and must therefore not be retained:
+	// goroutines which outlive the request (or which might) must be given
+	// a copy, as made by C.Copy, rather than c.URLParams or c itself.
 	URLParams map[string]string
 	// Env is a free-form environment for storing request-local data. Keys
 	// may be arbitrary types that support equality, however package-private
 	// types with type-safe accessors provide a convenient way for packages
 	// to mediate access to their request-local data.
 	Env map[interface{}]interface{}
+
+	ctx context.Context
+	// Storage for URLParams, lent by the middleware stack serving the
+	// request if the Mux reuses URLParams maps. See C.urlParams.
+	params map[string]string
+	// The outermost Mux to have routed the request, and the number of
+	// nested dispatches the request is the result of. See C.Dispatch.
+	mux           *Mux
+	dispatchDepth int
+	// The state kept by the outermost Mux serving the request, which the
+	// Muxes mounted in it share.
+	rs *requestState
+	// The prefixes of the Muxes the request has been passed to sub-muxes
+	// by, outermost first. See Mux.Mount.
+	mountPrefix string
 }
 
 // Handler is similar to net/http's http.Handler, but also accepts a Goji
@@ -65,7 +88,42 @@ accepted:
 	  particular, the following syntax is recognized:
 		- a path segment starting with with a colon will match any
 		  string placed at that position. e.g., "/:name" will match
-		  "/carl", binding "name" to "carl".
+		  "/carl", binding "name" to "carl". The name may be followed
+		  by a regular expression in parentheses, which the value
+		  must match in its entirety: "/users/:id(\\d+)" will match
+		  "/users/123", but not "/users/carl", in which case routing
+		  continues with the next route. Malformed expressions cause
+		  a panic when the route is added.
+		- a name which ends a pattern and follows a literal stem
+		  ending in "." (which may be escaped, as in
+		  "/report\\.:format") captures a file extension: the part
+		  of the path after the stem's final ".", which may not
+		  contain a "." itself. So "/report.:format" will match
+		  "/report.json", binding "format" to "json", but not
+		  "/report.tar.gz". This only applies to a literal stem: a
+		  name followed by a "." stops at the first "." in the path
+		  segment wherever it appears, so "/files/:name.:ext" will
+		  match "/files/my.report.pdf", binding "name" to "my" and
+		  "ext" to "report.pdf".
+		- a pattern may end with an optional group: a part of the
+		  pattern beginning with "/", in parentheses and followed by
+		  "?". The pattern matches with or without the group, and
+		  prefers to match with it, so "/posts/:year(/:month)?" will
+		  match both "/posts/2014", binding only "year", and
+		  "/posts/2014/06", binding both "year" and "month". Groups
+		  may be nested, as in "/posts/:year(/:month(/:day)?)?".
+		- a pattern may end with query constraints, following a
+		  "?" and separated by "&", which the request's query string
+		  must satisfy: "name=value" requires that one of the
+		  parameter's values be value, "name" only that it be
+		  present, and "name=:key" that it have a non-empty value,
+		  which is bound to key. So "/search?type=image&q=:query"
+		  will match "/search?q=cats&type=image", binding "query"
+		  to "cats", but not "/search?q=cats". Requests which fail
+		  the constraints fall through to the next route, and a
+		  route with query constraints is tried before the routes
+		  with the same path and fewer constraints. A "?" at the
+		  very end of a pattern is matched literally.
 		- a pattern ending with "/*" will match any route with that
 		  prefix. For instance, the pattern "/u/:name/*" will match
 		  "/u/carl/" and "/u/carl/projects/123", but not "/u/carl"
@@ -74,10 +132,33 @@ accepted:
 		  unmatched tail of the match, but including the leading "/". So
 		  for the two matching examples above, "*" would be bound to "/"
 		  and "/projects/123" respectively.
-	  Unlike http.ServeMux's patterns, string patterns support neither the
-	  "rooted subtree" behavior nor Host-specific routes. Users who require
-	  either of these features are encouraged to compose package http's mux
-	  with the mux provided by this package.
+		  The wildcard may be given a name of its own, under which the
+		  tail is bound instead of "*": "/proxy/:service/*path" will
+		  match "/proxy/users/v1/list", binding "service" to "users"
+		  and "path" to "/v1/list". As with "*", the tail includes its
+		  leading "/", so that it is always a path in its own right.
+		  A pattern ending with "/**" (or "/**name") is the same, except
+		  that the wildcard is optional: the pattern also matches the
+		  path without the trailing slash, binding "*" (or name) to
+		  the empty string. So "/docs/**" will match "/docs", binding
+		  "*" to "", as well as "/docs/" and "/docs/api/mux", binding
+		  "*" to "/" and "/api/mux", which allows a single route to
+		  serve a whole subtree, its root included.
+		- a pattern which does not begin with "/" is host-qualified:
+		  the part before the first "/" must match the request's
+		  host (ignoring any port, and case-insensitively), and the
+		  rest is an ordinary pattern matched against the path. In
+		  the host, a label of the form "{name}" will match any
+		  single label, binding it to name, and a label of "*" will
+		  match any single label without binding it. For instance,
+		  "{tenant}.example.com/:user/settings" will match a request
+		  for "/carl/settings" on host "acme.example.com", binding
+		  "tenant" to "acme" and "user" to "carl". Requests for other
+		  hosts fall through to the next route.
+	  Unlike http.ServeMux's patterns, string patterns do not support the
+	  "rooted subtree" behavior. Users who require this feature are
+	  encouraged to compose package http's mux with the mux provided by
+	  this package.
 	- regexp.Regexp, which is assumed to be a Perl-style regular expression
 	  that is anchored on the left (i.e., the beginning of the string). If
 	  your regular expression is not anchored on the left, a
@@ -87,6 +168,8 @@ accepted:
 	  Capturing groups will be converted into bound URL parameters in
 	  URLParams. If the capturing group is named, that name will be used;
 	  otherwise the special identifiers "$1", "$2", etc. will be used.
+	- any other type accepted by a parser registered with
+	  RegisterPatternParser
 */
 type PatternType interface{}
 
@@ -99,6 +182,12 @@ following concrete types are accepted:
 	- types that implement Handler
 	- func(http.ResponseWriter, *http.Request)
 	- func(web.C, http.ResponseWriter, *http.Request)
+	- func(context.Context, http.ResponseWriter, *http.Request) (see
+	  ContextHandlerFunc)
+	- func(web.C, http.ResponseWriter, *http.Request) error (see
+	  HandlerFuncWithError)
+
+HandlerOf performs the same conversion.
 */
 type HandlerType interface{}
 
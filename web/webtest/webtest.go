@@ -0,0 +1,110 @@
+/*
+Package webtest contains helpers for testing Goji handlers, which, unlike plain
+net/http handlers, expect a context (web.C) populated by the Mux that routed the
+request to them.
+
+A handler can be tested in isolation, with whatever context it expects:
+
+	w := webtest.NewRequest("GET", "/users/5").
+		WithParam("id", "5").
+		WithEnv("user", admin).
+		Serve(showUser)
+
+or by way of a full Mux, which routes the request and runs it through the Mux's
+middleware, as a real request would be:
+
+	w, route := webtest.ServeMux(m, webtest.NewRequest("GET", "/users/5").Request())
+	// route.Pattern == "/users/:id"
+*/
+package webtest
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/zenazn/goji/web"
+)
+
+// Serve serves r with h, using the given context, and returns the recorded
+// response.
+func Serve(h web.Handler, c web.C, r *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	h.ServeHTTPC(c, w, r)
+	return w
+}
+
+// ServeMux serves r with m, and returns the recorded response along with a
+// description of the route the request was routed to, or nil if it matched no
+// route.
+func ServeMux(m *web.Mux, r *http.Request) (*httptest.ResponseRecorder, *web.RouteInfo) {
+	// The Mux records the route in the Env map it is given, so we keep a
+	// reference to it.
+	env := make(map[interface{}]interface{})
+	w := httptest.NewRecorder()
+	m.ServeHTTPC(web.C{Env: env}, w, r)
+	route, _ := env[web.RouteKey].(*web.RouteInfo)
+	return w, route
+}
+
+// Request builds a request, and the context a handler is to be given along
+// with it. See NewRequest.
+type Request struct {
+	r *http.Request
+	c web.C
+}
+
+// NewRequest returns a new request for the given method and path (which may
+// include a query string), with an empty context. It panics if the path cannot
+// be parsed.
+func NewRequest(method, path string) *Request {
+	return &Request{r: httptest.NewRequest(method, path, nil)}
+}
+
+// WithParam sets the URL parameter name to value in the request's context.
+func (req *Request) WithParam(name, value string) *Request {
+	if req.c.URLParams == nil {
+		req.c.URLParams = make(map[string]string)
+	}
+	req.c.URLParams[name] = value
+	return req
+}
+
+// WithEnv sets the given key of the Env of the request's context to value.
+func (req *Request) WithEnv(key, value interface{}) *Request {
+	if req.c.Env == nil {
+		req.c.Env = make(map[interface{}]interface{})
+	}
+	req.c.Env[key] = value
+	return req
+}
+
+// WithHeader sets the named request header to value.
+func (req *Request) WithHeader(name, value string) *Request {
+	req.r.Header.Set(name, value)
+	return req
+}
+
+// WithBody sets the request's body.
+func (req *Request) WithBody(body io.Reader) *Request {
+	r := httptest.NewRequest(req.r.Method, req.r.URL.RequestURI(), body)
+	r.Header = req.r.Header
+	req.r = r
+	return req
+}
+
+// Request returns the request.
+func (req *Request) Request() *http.Request {
+	return req.r
+}
+
+// C returns the context the request's handler is given.
+func (req *Request) C() web.C {
+	return req.c
+}
+
+// Serve serves the request with the given handler, giving it the request's
+// context, and returns the recorded response.
+func (req *Request) Serve(h web.HandlerType) *httptest.ResponseRecorder {
+	return Serve(web.HandlerOf(h), req.c, req.r)
+}
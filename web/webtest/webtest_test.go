@@ -0,0 +1,66 @@
+package webtest
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/zenazn/goji/web"
+)
+
+func showUser(c web.C, w http.ResponseWriter, r *http.Request) {
+	body, _ := ioutil.ReadAll(r.Body)
+	w.Write([]byte(c.URLParams["id"] + " " + c.Env["role"].(string) + " " +
+		r.Header.Get("Accept") + " " + r.URL.Query().Get("v") + " " +
+		string(body)))
+}
+
+func TestRequest(t *testing.T) {
+	t.Parallel()
+	w := NewRequest("POST", "/users/5?v=2").
+		WithParam("id", "5").
+		WithEnv("role", "admin").
+		WithHeader("Accept", "text/plain").
+		WithBody(strings.NewReader("hi")).
+		Serve(showUser)
+	if expected := "5 admin text/plain 2 hi"; w.Body.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, w.Body.String())
+	}
+
+	req := NewRequest("GET", "/").WithParam("id", "7").WithEnv("role", "guest")
+	w = Serve(web.HandlerFunc(showUser), req.C(), req.Request())
+	if expected := "7 guest   "; w.Body.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, w.Body.String())
+	}
+
+	w = NewRequest("GET", "/").Serve(http.NotFound)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected a plain handler to be served, got %d", w.Code)
+	}
+}
+
+func TestServeMux(t *testing.T) {
+	t.Parallel()
+	m := web.New()
+	m.Use(func(c *web.C, h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			c.Env["role"] = "admin"
+			h.ServeHTTP(w, r)
+		})
+	})
+	m.Get("/users/:id", showUser)
+
+	w, route := ServeMux(m, NewRequest("GET", "/users/5").Request())
+	if w.Body.String() != "5 admin   " {
+		t.Errorf("Expected the Mux's response, got %q", w.Body.String())
+	}
+	if route == nil || route.Pattern != "/users/:id" {
+		t.Errorf("Expected the route for /users/:id, got %+v", route)
+	}
+
+	w, route = ServeMux(m, NewRequest("GET", "/nowhere").Request())
+	if w.Code != http.StatusNotFound || route != nil {
+		t.Errorf("Expected an unrouted 404, got %d and %+v", w.Code, route)
+	}
+}
@@ -0,0 +1,59 @@
+package web
+
+import (
+	"errors"
+	"net/http"
+	"syscall"
+)
+
+// WriteErrorHook is a function called when writing a response fails. See
+// Mux.OnWriteError.
+type WriteErrorHook func(c C, err error)
+
+// WriteError describes the failure of a write to a response. It is the type of
+// the errors passed to the functions registered with Mux.OnWriteError.
+type WriteError struct {
+	// Err is the error returned by the ResponseWriter.
+	Err error
+	// ClientGone is true if the write appears to have failed because the
+	// client went away (its connection was closed or reset, or the
+	// request's context was canceled), which is routine for any server
+	// on the Internet, rather than because of a genuine failure.
+	ClientGone bool
+}
+
+func (e *WriteError) Error() string {
+	return "web: writing response: " + e.Err.Error()
+}
+
+// Unwrap returns e.Err.
+func (e *WriteError) Unwrap() error {
+	return e.Err
+}
+
+/*
+OnWriteError registers a function to be called when writing a response body
+fails. net/http discards such errors (and most handlers ignore them, since there
+is rarely anything to be done), which makes it difficult to monitor how often
+connections are broken mid-response. The function is passed the request's
+context and a *WriteError describing the first write which failed; it is called
+at most once per request, after the middleware stack has returned.
+
+Like the rest of the Mux's configuration, it is illegal to call OnWriteError
+concurrently with active requests.
+*/
+func (m *Mux) OnWriteError(hook WriteErrorHook) {
+	m.writeErrorHooks = append(m.writeErrorHooks, hook)
+}
+
+func (m *Mux) writeFailed(c C, r *http.Request, err error) {
+	werr := &WriteError{
+		Err: err,
+		ClientGone: r.Context().Err() != nil ||
+			errors.Is(err, syscall.EPIPE) ||
+			errors.Is(err, syscall.ECONNRESET),
+	}
+	for _, hook := range m.writeErrorHooks {
+		hook(c, werr)
+	}
+}
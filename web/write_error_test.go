@@ -0,0 +1,62 @@
+package web
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"syscall"
+	"testing"
+)
+
+// failingWriter is a ResponseWriter whose writes always fail.
+type failingWriter struct {
+	*httptest.ResponseRecorder
+	err error
+}
+
+func (f failingWriter) Write([]byte) (int, error) {
+	return 0, f.err
+}
+
+func TestOnWriteError(t *testing.T) {
+	t.Parallel()
+	m := New()
+	m.Get("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("one"))
+		w.Write([]byte("two"))
+	})
+	var errs []error
+	m.OnWriteError(func(c C, err error) {
+		errs = append(errs, err)
+	})
+
+	diskFull := errors.New("disk full")
+	tests := []struct {
+		err        error
+		clientGone bool
+	}{
+		{diskFull, false},
+		{syscall.EPIPE, true},
+		{syscall.ECONNRESET, true},
+	}
+	for _, test := range tests {
+		errs = nil
+		r, _ := http.NewRequest("GET", "/", nil)
+		m.ServeHTTP(failingWriter{httptest.NewRecorder(), test.err}, r)
+		if len(errs) != 1 {
+			t.Errorf("For %v, expected one callback, got %v", test.err, errs)
+			continue
+		}
+		werr, ok := errs[0].(*WriteError)
+		if !ok || !errors.Is(werr, test.err) || werr.ClientGone != test.clientGone {
+			t.Errorf("For %v, got %#v", test.err, errs[0])
+		}
+	}
+
+	errs = nil
+	r, _ := http.NewRequest("GET", "/", nil)
+	m.ServeHTTP(httptest.NewRecorder(), r)
+	if len(errs) != 0 {
+		t.Errorf("Expected no callbacks for a successful response, got %v", errs)
+	}
+}